@@ -0,0 +1,308 @@
+// Package acl keeps track of the users allowed to connect to the server and
+// of the commands, keys and channels each of them may access, configured
+// with Redis's ACL rules.
+package acl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUser is the user clients are authenticated as when they connect.
+// It can't be deleted.
+const DefaultUser = "default"
+
+// Categories are the command categories rules can refer to as "@category".
+var Categories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string",
+	"bitmap", "hyperloglog", "geo", "stream", "pubsub", "admin", "blocking",
+	"dangerous", "connection", "transaction",
+}
+
+var (
+	// ErrDeleteDefault is returned when deleting the default user.
+	ErrDeleteDefault = errors.New("The 'default' user cannot be removed")
+	// ErrInvalidUsername is returned for user names containing spaces,
+	// which couldn't be written to an ACL file.
+	ErrInvalidUsername = errors.New("Usernames can't contain spaces or null characters")
+)
+
+func IsCategory(name string) bool {
+	if name == AllCategories {
+		return true
+	}
+
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxLogEntries is the number of entries kept by the ACL log, the default
+// acllog-max-len of Redis.
+const maxLogEntries = 128
+
+// ACL holds the users and logs the commands they were denied. It is safe for
+// concurrent use.
+type ACL struct {
+	mu    sync.RWMutex
+	users map[string]*User
+
+	logMu   sync.Mutex
+	log     []*LogEntry
+	nextLog int64
+}
+
+// New returns an ACL holding only the default user, which may run every
+// command against every key and channel without a password.
+func New() *ACL {
+	a := &ACL{users: make(map[string]*User)}
+	a.users[DefaultUser] = defaultUser()
+
+	return a
+}
+
+func defaultUser() *User {
+	u := newUser(DefaultUser)
+	for _, rule := range []string{"on", "nopass", "allkeys", "allchannels", "allcommands"} {
+		u.apply(rule)
+	}
+
+	return u
+}
+
+// User returns the user called name, or nil if there is none.
+func (a *ACL) User(name string) *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.users[name]
+}
+
+// Users returns every user, sorted by name.
+func (a *ACL) Users() []*User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	users := make([]*User, 0, len(a.users))
+	for _, u := range a.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	return users
+}
+
+// SetUser applies rules to the user called name, creating it if needed. The
+// user is left unchanged if any rule is invalid.
+func (a *ACL) SetUser(name string, rules ...string) error {
+	if strings.ContainsAny(name, " \x00") {
+		return ErrInvalidUsername
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var u *User
+	if existing, ok := a.users[name]; ok {
+		u = existing.clone()
+	} else {
+		u = newUser(name)
+	}
+
+	for _, rule := range rules {
+		if err := u.apply(rule); err != nil {
+			return fmt.Errorf("Error in ACL SETUSER modifier '%s': %v", rule, err)
+		}
+	}
+
+	a.users[name] = u
+
+	return nil
+}
+
+// DelUser deletes the user called name, reporting whether it existed.
+func (a *ACL) DelUser(name string) (bool, error) {
+	if name == DefaultUser {
+		return false, ErrDeleteDefault
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.users[name]; !ok {
+		return false, nil
+	}
+	delete(a.users, name)
+
+	return true, nil
+}
+
+// Authenticate returns the user called name if it is enabled and password
+// is one of its passwords, or nil otherwise.
+func (a *ACL) Authenticate(name, password string) *User {
+	u := a.User(name)
+	if u == nil || !u.Enabled() || !u.CheckPassword(password) {
+		return nil
+	}
+
+	return u
+}
+
+// Load replaces the users with the ones described by r, one per line in
+// the format of ACL LIST. Lines that are empty or start with '#' are
+// ignored. The users are left unchanged if r holds any error.
+func (a *ACL) Load(r io.Reader) error {
+	users := make(map[string]*User)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] != "user" || len(fields) < 2 {
+			return fmt.Errorf("line %d: should start with user keyword", line)
+		}
+
+		name := fields[1]
+		if _, ok := users[name]; ok {
+			return fmt.Errorf("line %d: duplicate user '%s'", line, name)
+		}
+
+		u := newUser(name)
+		for _, rule := range fields[2:] {
+			if err := u.apply(rule); err != nil {
+				return fmt.Errorf("line %d: error in user declaration '%s': %v", line, rule, err)
+			}
+		}
+		users[name] = u
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// like Redis, a file that doesn't mention the default user leaves it
+	// as it was created
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = defaultUser()
+	}
+
+	a.mu.Lock()
+	a.users = users
+	a.mu.Unlock()
+
+	return nil
+}
+
+// LoadFile loads the users from the ACL file at path.
+func (a *ACL) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := a.Load(f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Reasons for which a command was denied, as reported by ACL LOG.
+const (
+	ReasonAuth    = "auth"
+	ReasonCommand = "command"
+	ReasonKey     = "key"
+	ReasonChannel = "channel"
+)
+
+// LogEntry records commands denied for the same reason, to the same user,
+// on the same object.
+type LogEntry struct {
+	ID       int64
+	Count    int64
+	Reason   string
+	Context  string
+	Object   string
+	Username string
+	// Client describes the client that was last denied.
+	Client  string
+	Created time.Time
+	Updated time.Time
+}
+
+// Log records that a command was denied. A denial matching an entry for the
+// same reason, context, object and user updated within the last minute is
+// counted against that entry instead of creating a new one.
+func (a *ACL) Log(reason, context, object, username, client string) {
+	a.logMu.Lock()
+	defer a.logMu.Unlock()
+
+	now := time.Now()
+	for _, e := range a.log {
+		if e.Reason == reason && e.Context == context && e.Object == object &&
+			e.Username == username && now.Sub(e.Updated) < time.Minute {
+			e.Count++
+			e.Client = client
+			e.Updated = now
+			return
+		}
+	}
+
+	entry := &LogEntry{
+		ID:       a.nextLog,
+		Count:    1,
+		Reason:   reason,
+		Context:  context,
+		Object:   object,
+		Username: username,
+		Client:   client,
+		Created:  now,
+		Updated:  now,
+	}
+	a.nextLog++
+
+	a.log = append([]*LogEntry{entry}, a.log...)
+	if len(a.log) > maxLogEntries {
+		a.log = a.log[:maxLogEntries]
+	}
+}
+
+// LogEntries returns copies of up to count of the most recent log entries,
+// newest first. A negative count returns every entry.
+func (a *ACL) LogEntries(count int) []LogEntry {
+	a.logMu.Lock()
+	defer a.logMu.Unlock()
+
+	if count < 0 || count > len(a.log) {
+		count = len(a.log)
+	}
+
+	entries := make([]LogEntry, count)
+	for i := range entries {
+		entries[i] = *a.log[i]
+	}
+
+	return entries
+}
+
+// ResetLog deletes every log entry.
+func (a *ACL) ResetLog() {
+	a.logMu.Lock()
+	defer a.logMu.Unlock()
+
+	a.log = nil
+}
@@ -0,0 +1,183 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultUser(t *testing.T) {
+	a := New()
+
+	u := a.Authenticate(DefaultUser, "anything")
+	if assert.NotNil(t, u) {
+		assert.Equal(t, "user default on nopass ~* &* +@all", u.String())
+		assert.True(t, u.CanRun("flushall", "", []string{"keyspace", "write"}))
+		assert.True(t, u.CanAccessKey("k"))
+		assert.True(t, u.CanSubscribePattern("news.*"))
+	}
+
+	_, err := a.DelUser(DefaultUser)
+	assert.Equal(t, ErrDeleteDefault, err)
+}
+
+func TestPasswords(t *testing.T) {
+	a := New()
+
+	assert.NoError(t, a.SetUser("alice", "on", ">secret", ">other"))
+	assert.NotNil(t, a.Authenticate("alice", "secret"))
+	assert.NotNil(t, a.Authenticate("alice", "other"))
+	assert.Nil(t, a.Authenticate("alice", "wrong"))
+	assert.Nil(t, a.Authenticate("bob", "secret"))
+
+	assert.NoError(t, a.SetUser("alice", "<other"))
+	assert.Nil(t, a.Authenticate("alice", "other"))
+	assert.Error(t, a.SetUser("alice", "<other"))
+
+	assert.NoError(t, a.SetUser("alice", "off"))
+	assert.Nil(t, a.Authenticate("alice", "secret"))
+
+	hash := HashPassword("hashed")
+	assert.NoError(t, a.SetUser("bob", "on", "#"+hash))
+	assert.NotNil(t, a.Authenticate("bob", "hashed"))
+	assert.Equal(t, []string{hash}, a.User("bob").Passwords())
+	assert.Error(t, a.SetUser("bob", "#nothex"))
+
+	assert.NoError(t, a.SetUser("bob", "resetpass"))
+	assert.Nil(t, a.Authenticate("bob", "hashed"))
+}
+
+func TestCommandRules(t *testing.T) {
+	a := New()
+	read := []string{"read", "string"}
+	write := []string{"write", "string"}
+
+	assert.NoError(t, a.SetUser("alice", "+@read", "+set", "-get"))
+	u := a.User("alice")
+	assert.False(t, u.CanRun("get", "", read))
+	assert.True(t, u.CanRun("strlen", "", read))
+	assert.True(t, u.CanRun("SET", "", write))
+	assert.False(t, u.CanRun("incr", "", write))
+	assert.Equal(t, "-@all +@read +set -get", u.Commands())
+
+	// rules are applied in order, so a later rule wins
+	assert.NoError(t, a.SetUser("alice", "+get"))
+	assert.True(t, a.User("alice").CanRun("get", "", read))
+	assert.Equal(t, "-@all +@read +set +get", a.User("alice").Commands())
+
+	assert.NoError(t, a.SetUser("bob", "+acl|whoami"))
+	assert.True(t, a.User("bob").CanRun("acl", "whoami", nil))
+	assert.False(t, a.User("bob").CanRun("acl", "setuser", nil))
+
+	assert.NoError(t, a.SetUser("carol", "allcommands", "-@write"))
+	assert.True(t, a.User("carol").CanRun("get", "", read))
+	assert.False(t, a.User("carol").CanRun("set", "", write))
+	assert.Equal(t, "+@all -@write", a.User("carol").Commands())
+
+	assert.Error(t, a.SetUser("carol", "+@nosuchcategory"))
+	assert.Error(t, a.SetUser("carol", "bogus"))
+}
+
+func TestSetUserIsAtomic(t *testing.T) {
+	a := New()
+
+	assert.NoError(t, a.SetUser("alice", "on"))
+	before := a.User("alice")
+	assert.Error(t, a.SetUser("alice", "off", "+@bogus"))
+	assert.True(t, before == a.User("alice"))
+	assert.True(t, a.User("alice").Enabled())
+
+	assert.Equal(t, ErrInvalidUsername, a.SetUser("a b"))
+}
+
+func TestKeyAndChannelPatterns(t *testing.T) {
+	a := New()
+
+	assert.NoError(t, a.SetUser("alice", "~cache:*", "~session", "&news.*"))
+	u := a.User("alice")
+	assert.True(t, u.CanAccessKey("cache:1"))
+	assert.True(t, u.CanAccessKey("session"))
+	assert.False(t, u.CanAccessKey("other"))
+	assert.True(t, u.CanAccessChannel("news.sports"))
+	assert.False(t, u.CanAccessChannel("weather"))
+	assert.True(t, u.CanSubscribePattern("news.*"))
+	assert.False(t, u.CanSubscribePattern("*"))
+	assert.Equal(t, "~cache:* ~session", u.Keys())
+
+	assert.NoError(t, a.SetUser("alice", "allkeys", "~more"))
+	assert.Equal(t, "~*", a.User("alice").Keys())
+	assert.NoError(t, a.SetUser("alice", "resetkeys", "resetchannels"))
+	assert.False(t, a.User("alice").CanAccessKey("cache:1"))
+	assert.False(t, a.User("alice").CanAccessChannel("news.sports"))
+}
+
+func TestDelUser(t *testing.T) {
+	a := New()
+	a.SetUser("alice")
+
+	deleted, err := a.DelUser("alice")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = a.DelUser("alice")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Nil(t, a.User("alice"))
+}
+
+func TestLoad(t *testing.T) {
+	a := New()
+
+	file := `
+# users
+user alice on >secret ~* &* +@all -flushall
+user default on nopass resetkeys -@all +ping
+`
+	assert.NoError(t, a.Load(strings.NewReader(file)))
+
+	users := a.Users()
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, "alice", users[0].Name)
+		assert.Equal(t, "user default on nopass resetkeys resetchannels -@all +ping", users[1].String())
+	}
+	assert.NotNil(t, a.Authenticate("alice", "secret"))
+	assert.False(t, a.User("alice").CanRun("flushall", "", nil))
+
+	// the users written by ACL LIST can be loaded back
+	var lines []string
+	for _, u := range a.Users() {
+		lines = append(lines, u.String())
+	}
+	b := New()
+	assert.NoError(t, b.Load(strings.NewReader(strings.Join(lines, "\n"))))
+	assert.Equal(t, a.User("alice").String(), b.User("alice").String())
+
+	assert.Error(t, a.Load(strings.NewReader("alice on")))
+	assert.Error(t, a.Load(strings.NewReader("user alice +@bogus")))
+	assert.Error(t, a.Load(strings.NewReader("user alice\nuser alice")))
+	assert.NotNil(t, a.User("alice"), "a failed load leaves the users unchanged")
+
+	assert.NoError(t, a.Load(strings.NewReader("user alice on")))
+	assert.NotNil(t, a.Authenticate(DefaultUser, ""), "the default user is kept")
+}
+
+func TestLog(t *testing.T) {
+	a := New()
+
+	a.Log(ReasonCommand, "toplevel", "get", "alice", "id=1")
+	a.Log(ReasonCommand, "toplevel", "get", "alice", "id=2")
+	a.Log(ReasonKey, "multi", "k", "alice", "id=2")
+
+	entries := a.LogEntries(-1)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, ReasonKey, entries[0].Reason)
+		assert.Equal(t, int64(1), entries[0].ID)
+		assert.Equal(t, int64(2), entries[1].Count)
+		assert.Equal(t, "id=2", entries[1].Client)
+	}
+	assert.Len(t, a.LogEntries(1), 1)
+
+	a.ResetLog()
+	assert.Empty(t, a.LogEntries(-1))
+}
@@ -0,0 +1,363 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scnewma/godb/glob"
+)
+
+// AllCategories is the category every command belongs to.
+const AllCategories = "all"
+
+// commandRule allows or denies a command, a subcommand written as
+// "command|subcommand", or a category written as "@category".
+type commandRule struct {
+	allow bool
+	name  string
+}
+
+func (r commandRule) String() string {
+	if r.allow {
+		return "+" + r.name
+	}
+	return "-" + r.name
+}
+
+// User is an ACL user. Users are immutable once added to an ACL: changing a
+// user replaces it, so that the users held by clients can be read without
+// locking.
+type User struct {
+	Name string
+
+	enabled bool
+	noPass  bool
+	// passwords holds the hex encoded SHA-256 hashes of the user's
+	// passwords.
+	passwords map[string]struct{}
+
+	// commands are applied in order, the last rule matching a command
+	// deciding whether the user may run it. Users start out allowed
+	// nothing.
+	commands []commandRule
+
+	keys     []string
+	channels []string
+}
+
+func newUser(name string) *User {
+	return &User{Name: name, passwords: make(map[string]struct{})}
+}
+
+func (u *User) clone() *User {
+	clone := *u
+	clone.passwords = make(map[string]struct{}, len(u.passwords))
+	for hash := range u.passwords {
+		clone.passwords[hash] = struct{}{}
+	}
+	clone.commands = append([]commandRule(nil), u.commands...)
+	clone.keys = append([]string(nil), u.keys...)
+	clone.channels = append([]string(nil), u.channels...)
+
+	return &clone
+}
+
+// HashPassword returns the hash under which password is stored, as shown by
+// ACL GETUSER and accepted by the "#<hash>" rule.
+func HashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// isHash reports whether s is a hex encoded SHA-256 hash.
+func isHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// apply applies a single rule, as given to ACL SETUSER, to u.
+func (u *User) apply(rule string) error {
+	lower := strings.ToLower(rule)
+	switch {
+	case lower == "on":
+		u.enabled = true
+	case lower == "off":
+		u.enabled = false
+	case lower == "nopass":
+		u.noPass = true
+		u.passwords = make(map[string]struct{})
+	case lower == "resetpass":
+		u.noPass = false
+		u.passwords = make(map[string]struct{})
+	case lower == "allkeys":
+		u.keys = []string{"*"}
+	case lower == "resetkeys":
+		u.keys = nil
+	case lower == "allchannels":
+		u.channels = []string{"*"}
+	case lower == "resetchannels":
+		u.channels = nil
+	case lower == "allcommands":
+		u.commands = []commandRule{{allow: true, name: "@" + AllCategories}}
+	case lower == "nocommands":
+		u.commands = nil
+	case lower == "reset":
+		*u = *newUser(u.Name)
+	case strings.HasPrefix(rule, ">"):
+		u.passwords[HashPassword(rule[1:])] = struct{}{}
+		u.noPass = false
+	case strings.HasPrefix(rule, "<"):
+		hash := HashPassword(rule[1:])
+		if _, ok := u.passwords[hash]; !ok {
+			return fmt.Errorf("no such password")
+		}
+		delete(u.passwords, hash)
+	case strings.HasPrefix(rule, "#"):
+		if !isHash(rule[1:]) {
+			return fmt.Errorf("the password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+		}
+		u.passwords[rule[1:]] = struct{}{}
+		u.noPass = false
+	case strings.HasPrefix(rule, "!"):
+		if _, ok := u.passwords[rule[1:]]; !ok {
+			return fmt.Errorf("no such password")
+		}
+		delete(u.passwords, rule[1:])
+	case strings.HasPrefix(rule, "~"):
+		u.keys = appendPattern(u.keys, rule[1:])
+	case strings.HasPrefix(rule, "&"):
+		u.channels = appendPattern(u.channels, rule[1:])
+	case (strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-")) && len(rule) > 1:
+		name := strings.ToLower(rule[1:])
+		if strings.HasPrefix(name, "@") && !IsCategory(name[1:]) {
+			return fmt.Errorf("unknown command category '%s'", name[1:])
+		}
+		u.addCommandRule(commandRule{allow: rule[0] == '+', name: name})
+	default:
+		return fmt.Errorf("syntax error")
+	}
+
+	return nil
+}
+
+// appendPattern adds pattern to patterns, unless every key or channel is
+// already matched.
+func appendPattern(patterns []string, pattern string) []string {
+	if len(patterns) == 1 && patterns[0] == "*" {
+		return patterns
+	}
+	if pattern == "*" {
+		return []string{"*"}
+	}
+
+	for _, p := range patterns {
+		if p == pattern {
+			return patterns
+		}
+	}
+
+	return append(patterns, pattern)
+}
+
+// addCommandRule adds rule after dropping the earlier rules it overrides,
+// so that the rules don't keep growing as users are edited.
+func (u *User) addCommandRule(rule commandRule) {
+	if rule.name == "@"+AllCategories {
+		u.commands = nil
+		if rule.allow {
+			u.commands = []commandRule{rule}
+		}
+		return
+	}
+
+	rules := u.commands[:0]
+	for _, r := range u.commands {
+		if r.name != rule.name {
+			rules = append(rules, r)
+		}
+	}
+	u.commands = append(rules, rule)
+}
+
+// Enabled reports whether the user can authenticate.
+func (u *User) Enabled() bool {
+	return u.enabled
+}
+
+// NoPass reports whether the user accepts any password.
+func (u *User) NoPass() bool {
+	return u.noPass
+}
+
+// CheckPassword reports whether password is one of the user's passwords.
+// Any password is accepted for nopass users.
+func (u *User) CheckPassword(password string) bool {
+	if u.noPass {
+		return true
+	}
+
+	hash := HashPassword(password)
+	for h := range u.passwords {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanRun reports whether the user may run command, which belongs to the
+// given categories. subcommand is empty for commands without subcommands.
+func (u *User) CanRun(command, subcommand string, categories []string) bool {
+	command = strings.ToLower(command)
+	full := command
+	if subcommand != "" {
+		full += "|" + strings.ToLower(subcommand)
+	}
+
+	allowed := false
+	for _, rule := range u.commands {
+		if rule.name == command || rule.name == full || inCategory(rule.name, categories) {
+			allowed = rule.allow
+		}
+	}
+
+	return allowed
+}
+
+func inCategory(name string, categories []string) bool {
+	if !strings.HasPrefix(name, "@") {
+		return false
+	}
+	name = name[1:]
+	if name == AllCategories {
+		return true
+	}
+
+	for _, category := range categories {
+		if category == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanAccessKey reports whether key matches one of the user's key patterns.
+func (u *User) CanAccessKey(key string) bool {
+	return matchesAny(u.keys, key)
+}
+
+// CanAccessChannel reports whether channel matches one of the user's
+// channel patterns.
+func (u *User) CanAccessChannel(channel string) bool {
+	return matchesAny(u.channels, channel)
+}
+
+// CanSubscribePattern reports whether the user may subscribe to pattern.
+// Patterns aren't matched against the user's channel patterns but must be
+// one of them, since a pattern could match channels the user can't access.
+func (u *User) CanSubscribePattern(pattern string) bool {
+	for _, p := range u.channels {
+		if p == "*" || p == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if p == "*" || glob.MatchString(p, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Flags returns the user's flags, as reported by ACL GETUSER.
+func (u *User) Flags() []string {
+	flags := []string{"off"}
+	if u.enabled {
+		flags[0] = "on"
+	}
+	if u.noPass {
+		flags = append(flags, "nopass")
+	}
+
+	return flags
+}
+
+// Passwords returns the hashes of the user's passwords, sorted.
+func (u *User) Passwords() []string {
+	hashes := make([]string, 0, len(u.passwords))
+	for hash := range u.passwords {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	return hashes
+}
+
+// Commands describes the commands the user may run as ACL rules.
+func (u *User) Commands() string {
+	rules := make([]string, 0, len(u.commands)+1)
+	if len(u.commands) == 0 || u.commands[0].name != "@"+AllCategories {
+		rules = append(rules, "-@"+AllCategories)
+	}
+	for _, rule := range u.commands {
+		rules = append(rules, rule.String())
+	}
+
+	return strings.Join(rules, " ")
+}
+
+// Keys describes the keys the user may access as ACL rules.
+func (u *User) Keys() string {
+	return describePatterns("~", u.keys)
+}
+
+// Channels describes the channels the user may access as ACL rules.
+func (u *User) Channels() string {
+	return describePatterns("&", u.channels)
+}
+
+func describePatterns(prefix string, patterns []string) string {
+	rules := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		rules = append(rules, prefix+p)
+	}
+
+	return strings.Join(rules, " ")
+}
+
+// String describes the user as the rules recreating it, in the format of
+// ACL LIST and ACL files.
+func (u *User) String() string {
+	parts := append([]string{"user", u.Name}, u.Flags()...)
+	for _, hash := range u.Passwords() {
+		parts = append(parts, "#"+hash)
+	}
+	if keys := u.Keys(); keys != "" {
+		parts = append(parts, keys)
+	} else {
+		parts = append(parts, "resetkeys")
+	}
+	if channels := u.Channels(); channels != "" {
+		parts = append(parts, channels)
+	} else {
+		parts = append(parts, "resetchannels")
+	}
+	parts = append(parts, u.Commands())
+
+	return strings.Join(parts, " ")
+}
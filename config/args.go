@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes in configuration line")
+
+// splitArgs splits a line of a config file into arguments separated by
+// spaces, like Redis. Arguments may be quoted: double quoted arguments
+// support the escapes \n, \r, \t, \b, \a, \\, \" and \xhh, single quoted
+// arguments only \'.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		switch quote := line[i]; quote {
+		case '"', '\'':
+			i++
+			for {
+				if i == len(line) {
+					return nil, errUnbalancedQuotes
+				}
+
+				c := line[i]
+				switch {
+				case c == quote:
+					i++
+					if i < len(line) && !isSpace(line[i]) {
+						return nil, errUnbalancedQuotes
+					}
+				case c == '\\' && i+1 < len(line) && quote == '\'':
+					if line[i+1] == '\'' {
+						c = '\''
+						i++
+					}
+					arg.WriteByte(c)
+					i++
+					continue
+				case c == '\\' && i+1 < len(line):
+					n := 2
+					c = line[i+1]
+					switch c {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					case 'x':
+						if b, err := strconv.ParseUint(line[i+2:min(i+4, len(line))], 16, 8); err == nil && i+4 <= len(line) {
+							c, n = byte(b), 4
+						}
+					}
+					arg.WriteByte(c)
+					i += n
+					continue
+				default:
+					arg.WriteByte(c)
+					i++
+					continue
+				}
+				break
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				arg.WriteByte(line[i])
+				i++
+			}
+		}
+
+		args = append(args, arg.String())
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// quoteArg quotes arg if splitArgs wouldn't read it back as a single
+// argument.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n\"'\\") && !strings.HasPrefix(arg, "#") {
+		plain := true
+		for i := 0; i < len(arg); i++ {
+			if arg[i] < ' ' || arg[i] > '~' {
+				plain = false
+				break
+			}
+		}
+		if plain {
+			return arg
+		}
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; {
+		case c == '\\' || c == '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
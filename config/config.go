@@ -0,0 +1,335 @@
+// Package config holds the parameters of the server, read on startup from a
+// godb.conf file of Redis-style directives and changed at runtime by CONFIG
+// SET.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/scnewma/godb/glob"
+)
+
+// ErrNoFile is returned when rewriting the config file of a server started
+// without one.
+var ErrNoFile = errors.New("The server is running without a config file")
+
+// rewriteMarker precedes the directives CONFIG REWRITE adds to a file.
+const rewriteMarker = "# Generated by CONFIG REWRITE"
+
+// setting is the current value of a parameter.
+type setting struct {
+	param *Param
+	value string
+	n     int64
+}
+
+// Config holds the values of a set of parameters. It is safe for concurrent
+// use.
+type Config struct {
+	// setMu serializes changes, so that the functions watching parameters
+	// see them in the order they were made.
+	setMu sync.Mutex
+
+	mu       sync.RWMutex
+	settings map[string]*setting
+	watchers map[string][]func()
+	// file is the path of the config file, if any.
+	file string
+}
+
+// New returns a config holding params at their default values.
+func New(params ...Param) *Config {
+	c := &Config{
+		settings: make(map[string]*setting, len(params)),
+		watchers: make(map[string][]func()),
+	}
+
+	for i := range params {
+		p := &params[i]
+		value, n, err := p.parse(p.Default)
+		if err != nil {
+			panic(fmt.Sprintf("config: invalid default of %s: %v", p.Name, err))
+		}
+		c.settings[p.Name] = &setting{param: p, value: value, n: n}
+	}
+
+	return c
+}
+
+// Value returns the value of the parameter called name, or "" if there is
+// none.
+func (c *Config) Value(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if s, ok := c.settings[name]; ok {
+		return s.value
+	}
+	return ""
+}
+
+// Int returns the value of the Int or Memory parameter called name.
+func (c *Config) Int(name string) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if s, ok := c.settings[name]; ok {
+		return s.n
+	}
+	return 0
+}
+
+// Get returns the values of the parameters whose names match pattern.
+func (c *Config) Get(pattern string) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	values := make(map[string]string)
+	for name, s := range c.settings {
+		if glob.MatchFold([]byte(pattern), []byte(name)) {
+			values[name] = s.value
+		}
+	}
+
+	return values
+}
+
+// Watch calls fn straight away and whenever the parameter called name
+// changes, so that changes apply while the server runs.
+func (c *Config) Watch(name string, fn func()) {
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+
+	c.mu.Lock()
+	c.watchers[name] = append(c.watchers[name], fn)
+	c.mu.Unlock()
+
+	fn()
+}
+
+// Set sets parameters given as name and value pairs, like CONFIG SET. No
+// parameter is changed if any pair is invalid or immutable.
+func (c *Config) Set(pairs ...string) error {
+	return c.set(pairs, false)
+}
+
+// Override sets the parameter called name as if it were in the config file,
+// e.g. from a command line flag.
+func (c *Config) Override(name, value string) error {
+	return c.set([]string{name, value}, true)
+}
+
+func (c *Config) set(pairs []string, startup bool) error {
+	if len(pairs)%2 != 0 {
+		return errors.New("wrong number of arguments")
+	}
+
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+
+	changes := make(map[string]setting, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		name := strings.ToLower(pairs[i])
+
+		c.mu.RLock()
+		s, ok := c.settings[name]
+		c.mu.RUnlock()
+
+		switch _, seen := changes[name]; {
+		case !ok:
+			return &ParamError{Name: name, Err: ErrUnknownParam}
+		case seen:
+			return &ParamError{Name: name, Err: ErrDuplicate}
+		case s.param.Immutable && !startup:
+			return &ParamError{Name: name, Err: ErrImmutable}
+		}
+
+		value, n, err := s.param.parse(pairs[i+1])
+		if err != nil {
+			return &ParamError{Name: name, Err: err}
+		}
+		changes[name] = setting{param: s.param, value: value, n: n}
+	}
+
+	var watchers []func()
+	c.mu.Lock()
+	for name, change := range changes {
+		change := change
+		c.settings[name] = &change
+		watchers = append(watchers, c.watchers[name]...)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn()
+	}
+
+	return nil
+}
+
+// Load sets the parameters given by the directives read from r, one per
+// line. Lines starting with # are comments.
+func (c *Config) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		args, err := splitArgs(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			continue
+		}
+
+		if len(args) != 2 {
+			return fmt.Errorf("line %d: wrong number of arguments for '%s'", line, args[0])
+		}
+		if err := c.Override(args[0], args[1]); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// LoadFile loads the config file at path, which CONFIG REWRITE rewrites.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := c.Load(f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	c.mu.Lock()
+	c.file = path
+	c.mu.Unlock()
+
+	return nil
+}
+
+// File returns the path of the config file, or "" if there is none.
+func (c *Config) File() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.file
+}
+
+// Rewrite writes the current values of the parameters to the config file.
+// Comments and unknown lines are kept, directives are changed in place,
+// and parameters missing from the file are added unless they have their
+// default value.
+func (c *Config) Rewrite() error {
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+
+	path := c.File()
+	if path == "" {
+		return ErrNoFile
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var lines []string
+	written := make(map[string]bool)
+	marked := false
+	if len(existing) > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(existing), "\n"), "\n") {
+			if line == rewriteMarker {
+				marked = true
+			}
+
+			args, err := splitArgs(line)
+			if err != nil || len(args) == 0 {
+				lines = append(lines, line)
+				continue
+			}
+
+			name := strings.ToLower(args[0])
+			s, ok := c.settings[name]
+			switch {
+			case !ok:
+				lines = append(lines, line)
+			case !written[name]:
+				lines = append(lines, s.directive())
+				written[name] = true
+			}
+		}
+	}
+
+	var missing []string
+	for name, s := range c.settings {
+		if !written[name] && s.value != s.param.Default {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(missing) > 0 && !marked {
+		lines = append(lines, rewriteMarker)
+	}
+	for _, name := range missing {
+		lines = append(lines, c.settings[name].directive())
+	}
+
+	return writeFile(path, strings.Join(lines, "\n")+"\n")
+}
+
+// directive returns the line setting the parameter in a config file.
+func (s *setting) directive() string {
+	value := s.value
+	if s.param.Kind == Memory {
+		value = formatMemory(s.n)
+	}
+
+	return s.param.Name + " " + quoteArg(value)
+}
+
+// writeFile replaces the file at path with content, so that readers never see
+// it partially written.
+func writeFile(path, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	c := New(Params...)
+
+	var maxClients []int64
+	c.Watch(MaxClients, func() { maxClients = append(maxClients, c.Int(MaxClients)) })
+
+	assert.NoError(t, c.Set("MaxClients", "50", "loglevel", "WARNING"))
+	assert.Equal(t, "50", c.Value(MaxClients))
+	assert.Equal(t, "warning", c.Value(LogLevel))
+	assert.Equal(t, []int64{10000, 50}, maxClients)
+
+	// nothing changes if any parameter is invalid
+	err := c.Set(MaxClients, "60", MaxMemory, "lots")
+	assert.Equal(t, &ParamError{Name: MaxMemory, Err: errors.New("argument must be a memory value")}, err)
+	assert.Equal(t, int64(50), c.Int(MaxClients))
+	assert.Equal(t, []int64{10000, 50}, maxClients)
+
+	assert.True(t, errors.Is(c.Set("nope", "1"), ErrUnknownParam))
+	assert.True(t, errors.Is(c.Set(Port, "1"), ErrImmutable))
+	assert.True(t, errors.Is(c.Set(Timeout, "1", "TIMEOUT", "2"), ErrDuplicate))
+	assert.EqualError(t, c.Set(Timeout, "-1"), "'timeout': argument must be between 0 and 2147483648 inclusive")
+	assert.EqualError(t, c.Set(Timeout, "x"), "'timeout': argument couldn't be parsed into an integer")
+	assert.EqualError(t, c.Set(LogLevel, "loud"),
+		"'loglevel': argument(s) must be one of the following: debug, verbose, notice, warning")
+
+	assert.NoError(t, c.Override(Port, "6379"))
+	assert.Equal(t, int64(6379), c.Int(Port))
+}
+
+func TestMemory(t *testing.T) {
+	for value, n := range map[string]int64{
+		"0": 0, "100": 100, "1k": 1000, "1kb": 1024, "2MB": 2 << 20, "1g": 1e9, "3gb": 3 << 30, "5b": 5,
+	} {
+		parsed, ok := parseMemory(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, n, parsed, value)
+	}
+
+	for _, value := range []string{"", "mb", "-1", "1tb", "1.5mb", "99999999999gb"} {
+		_, ok := parseMemory(value)
+		assert.False(t, ok, value)
+	}
+
+	assert.Equal(t, "0", formatMemory(0))
+	assert.Equal(t, "3gb", formatMemory(3<<30))
+	assert.Equal(t, "1536kb", formatMemory(1536<<10))
+	assert.Equal(t, "1000", formatMemory(1000))
+}
+
+func TestGet(t *testing.T) {
+	c := New(Params...)
+
+	assert.Equal(t, map[string]string{MaxClients: "10000", MaxMemory: "0"}, c.Get("MAX*"))
+	assert.Equal(t, map[string]string{Port: "1123"}, c.Get("port"))
+	assert.Empty(t, c.Get("nope"))
+	assert.Len(t, c.Get("*"), len(Params))
+}
+
+func TestLoad(t *testing.T) {
+	c := New(Params...)
+
+	err := c.Load(strings.NewReader(`
+# the port
+port 6380
+bind "127.0.0.1 ::1"
+maxmemory 100mb
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6380), c.Int(Port))
+	assert.Equal(t, "127.0.0.1 ::1", c.Value(Bind))
+	assert.Equal(t, int64(100<<20), c.Int(MaxMemory))
+
+	assert.EqualError(t, c.Load(strings.NewReader("port")), "line 1: wrong number of arguments for 'port'")
+	assert.EqualError(t, c.Load(strings.NewReader("\nnope 1")), "line 2: 'nope': unknown parameter")
+	assert.EqualError(t, c.Load(strings.NewReader(`bind "x`)), "line 1: unbalanced quotes in configuration line")
+}
+
+func TestSplitArgs(t *testing.T) {
+	for line, want := range map[string][]string{
+		"":                     nil,
+		"  set  k\tv ":         {"set", "k", "v"},
+		`a "b c" 'd e'`:        {"a", "b c", "d e"},
+		`"\x41\n\"\\" 'it\'s'`: {"A\n\"\\", "it's"},
+		`"" ''`:                {"", ""},
+		`"\xzz"`:               {"xzz"},
+	} {
+		args, err := splitArgs(line)
+		assert.NoError(t, err, line)
+		assert.Equal(t, want, args, line)
+	}
+
+	for _, line := range []string{`"a`, `"a"b`, `'a`} {
+		_, err := splitArgs(line)
+		assert.Equal(t, errUnbalancedQuotes, err, line)
+	}
+
+	for _, arg := range []string{"plain", "", "a b", `q"uote`, "#comment", "\x00\xff\n"} {
+		args, err := splitArgs(quoteArg(arg))
+		assert.NoError(t, err, arg)
+		assert.Equal(t, []string{arg}, args, arg)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	c := New(Params...)
+	assert.Equal(t, ErrNoFile, c.Rewrite())
+
+	path := filepath.Join(t.TempDir(), "godb.conf")
+	err := os.WriteFile(path, []byte("# comment\nunknown directive\nmaxclients 10\nMAXCLIENTS 20\n"), 0600)
+	assert.NoError(t, err)
+
+	assert.Error(t, c.LoadFile(path))
+	assert.NoError(t, os.WriteFile(path, []byte("# comment\nmaxclients 10\nMAXCLIENTS 20\n"), 0600))
+	assert.NoError(t, c.LoadFile(path))
+	assert.Equal(t, path, c.File())
+
+	assert.NoError(t, c.Set(MaxClients, "30", MaxMemory, "2gb"))
+	assert.NoError(t, c.Rewrite())
+	assertFile(t, path, "# comment\nmaxclients 30\n"+rewriteMarker+"\nmaxmemory 2gb\n")
+
+	assert.NoError(t, c.Set(Timeout, "5"))
+	assert.NoError(t, c.Rewrite())
+	assertFile(t, path, "# comment\nmaxclients 30\n"+rewriteMarker+"\nmaxmemory 2gb\ntimeout 5\n")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode())
+
+	loaded := New(Params...)
+	assert.NoError(t, loaded.LoadFile(path))
+	assert.Equal(t, c.Get("*"), loaded.Get("*"))
+}
+
+func assertFile(t *testing.T, path, want string) {
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(content))
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names of the parameters of the server.
+const (
+	Bind       = "bind"
+	Port       = "port"
+	Databases  = "databases"
+	ACLFile    = "aclfile"
+	Timeout    = "timeout"
+	MaxClients = "maxclients"
+	MaxMemory  = "maxmemory"
+	LogLevel   = "loglevel"
+
+	SlowLogLogSlowerThan = "slowlog-log-slower-than"
+	SlowLogMaxLen        = "slowlog-max-len"
+)
+
+// Params are the parameters of the server.
+var Params = []Param{
+	{Name: Bind, Kind: String, Immutable: true},
+	{Name: Port, Kind: Int, Default: "1123", Min: 0, Max: 65535, Immutable: true},
+	{Name: Databases, Kind: Int, Default: "16", Min: 1, Max: 1 << 20, Immutable: true},
+	{Name: ACLFile, Kind: String, Immutable: true},
+	// timeout is the number of seconds idle connections are kept open,
+	// or zero to keep them open forever
+	{Name: Timeout, Kind: Int, Default: "60", Min: 0, Max: 1 << 31},
+	{Name: MaxClients, Kind: Int, Default: "10000", Min: 1, Max: 1 << 31},
+	{Name: MaxMemory, Kind: Memory, Default: "0", Min: 0, Max: 1 << 62},
+	{Name: LogLevel, Kind: Enum, Default: "notice", Values: []string{"debug", "verbose", "notice", "warning"}},
+	// slowlog-log-slower-than is the number of microseconds above which
+	// commands are logged by SLOWLOG, or -1 to log none
+	{Name: SlowLogLogSlowerThan, Kind: Int, Default: "10000", Min: -1, Max: 1 << 62},
+	{Name: SlowLogMaxLen, Kind: Int, Default: "128", Min: 0, Max: 1 << 31},
+}
+
+// Kind is the type of the values of a parameter.
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	// Memory parameters are numbers of bytes, which may be given with a
+	// unit: k, m and g for powers of 1000, kb, mb and gb for powers of 1024.
+	Memory
+	// Enum parameters take one of a list of values.
+	Enum
+)
+
+// Param describes a parameter and the values it may take.
+type Param struct {
+	Name    string
+	Kind    Kind
+	Default string
+
+	// Min and Max bound the values of Int and Memory parameters.
+	Min, Max int64
+	// Values are the values Enum parameters may take.
+	Values []string
+
+	// Immutable parameters are only set on startup, by the config file or
+	// the command line, rather than by CONFIG SET.
+	Immutable bool
+}
+
+var (
+	// ErrUnknownParam is returned for parameters that don't exist.
+	ErrUnknownParam = errors.New("unknown parameter")
+	// ErrImmutable is returned when changing an immutable parameter at
+	// runtime.
+	ErrImmutable = errors.New("can't set immutable config")
+	// ErrDuplicate is returned when setting a parameter twice at once.
+	ErrDuplicate = errors.New("duplicate parameter")
+)
+
+// ParamError is the error of setting the parameter called Name.
+type ParamError struct {
+	Name string
+	Err  error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("'%s': %v", e.Name, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// parse validates value and returns it as shown by CONFIG GET, along with
+// its number for Int and Memory parameters.
+func (p *Param) parse(value string) (string, int64, error) {
+	switch p.Kind {
+	case Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", 0, errors.New("argument couldn't be parsed into an integer")
+		}
+		if err := p.checkRange(n); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(n, 10), n, nil
+	case Memory:
+		n, ok := parseMemory(value)
+		if !ok {
+			return "", 0, errors.New("argument must be a memory value")
+		}
+		if err := p.checkRange(n); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(n, 10), n, nil
+	case Enum:
+		lower := strings.ToLower(value)
+		for _, v := range p.Values {
+			if v == lower {
+				return v, 0, nil
+			}
+		}
+		return "", 0, fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(p.Values, ", "))
+	default:
+		return value, 0, nil
+	}
+}
+
+func (p *Param) checkRange(n int64) error {
+	if n < p.Min || n > p.Max {
+		return fmt.Errorf("argument must be between %d and %d inclusive", p.Min, p.Max)
+	}
+	return nil
+}
+
+// memoryUnits are the units of memory values, longest suffixes first.
+var memoryUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"g", 1000 * 1000 * 1000}, {"m", 1000 * 1000}, {"k", 1000}, {"b", 1},
+}
+
+// parseMemory parses a number of bytes followed by an optional unit, such as
+// 100mb.
+func parseMemory(value string) (int64, bool) {
+	lower := strings.ToLower(value)
+	unit := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower, unit = strings.TrimSuffix(lower, u.suffix), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/unit {
+		return 0, false
+	}
+
+	return n * unit, true
+}
+
+// formatMemory formats n bytes in the largest binary unit dividing it, as
+// written by CONFIG REWRITE.
+func formatMemory(n int64) string {
+	if n == 0 {
+		return "0"
+	}
+
+	for _, u := range memoryUnits[:3] {
+		if n%u.bytes == 0 {
+			return strconv.FormatInt(n/u.bytes, 10) + u.suffix
+		}
+	}
+
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,381 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scnewma/godb/acl"
+	"github.com/scnewma/godb/resp"
+)
+
+const (
+	AUTH = "AUTH"
+	ACL  = "ACL"
+)
+
+var (
+	errNoAuth        = &resp.Error{Value: "NOAUTH Authentication required."}
+	errWrongPass     = &resp.Error{Value: "WRONGPASS invalid username-password pair or user is disabled."}
+	errNoPassword    = &resp.Error{Value: "ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?"}
+	errNoPermKey     = &resp.Error{Value: "NOPERM No permissions to access a key"}
+	errNoPermChannel = &resp.Error{Value: "NOPERM No permissions to access a channel"}
+)
+
+// noAuthCommands can be run by clients that haven't authenticated, and by
+// every user regardless of its permissions.
+var noAuthCommands = map[string]bool{
+	AUTH:  true,
+	HELLO: true,
+	QUIT:  true,
+	RESET: true,
+}
+
+// subcommandCommands are the commands whose first argument is a subcommand,
+// which ACL rules can allow on their own as "command|subcommand".
+var subcommandCommands = map[string]bool{
+	ACL:     true,
+	CONFIG:  true,
+	SLOWLOG: true,
+	OBJECT:  true,
+	PUBSUB:  true,
+	XINFO:   true,
+	XGROUP:  true,
+}
+
+func noPermission(user, command string) *resp.Error {
+	return &resp.Error{Value: fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", user, strings.ToLower(command))}
+}
+
+// ACL returns the users allowed to run commands. Clients are authenticated
+// as the default user when they connect, as long as it needs no password.
+func (ce *compositeExecutor) ACL() *acl.ACL {
+	return ce.acl
+}
+
+// user returns the user c is authenticated as, or nil if it must
+// authenticate first. Changes to the user apply to the client's next
+// command; if the user is deleted or disabled the client must authenticate
+// again.
+func (ce *compositeExecutor) user(c *Client) *acl.User {
+	if !c.authenticated {
+		u := ce.acl.User(acl.DefaultUser)
+		if !u.Enabled() || !u.NoPass() {
+			return nil
+		}
+		c.authenticated = true
+		c.user = acl.DefaultUser
+		return u
+	}
+
+	u := ce.acl.User(c.user)
+	if u == nil || !u.Enabled() {
+		return nil
+	}
+
+	return u
+}
+
+// authorize checks that the client may run a command against the keys and
+// channels among its arguments. Denied commands are recorded in the ACL
+// log. Commands executed without a client are always allowed.
+func (ce *compositeExecutor) authorize(c *Client, name string, args [][]byte) *resp.Error {
+	if c == nil {
+		return nil
+	}
+
+	u := ce.user(c)
+	if noAuthCommands[name] {
+		return nil
+	}
+	if u == nil {
+		return errNoAuth
+	}
+
+	spec, ok := commandTable[name]
+	if !ok || !spec.checkArity(args) {
+		// unknown commands and wrong arities are reported when the
+		// command is run or queued
+		return nil
+	}
+
+	context := "toplevel"
+	if c.multi {
+		context = "multi"
+	}
+
+	command, subcommand := name, ""
+	if subcommandCommands[name] && len(args) > 0 {
+		subcommand = string(args[0])
+	}
+	if !u.CanRun(command, subcommand, categories[name]) {
+		object := strings.ToLower(command)
+		if subcommand != "" {
+			object += "|" + strings.ToLower(subcommand)
+		}
+		ce.acl.Log(acl.ReasonCommand, context, object, u.Name, c.info())
+		return noPermission(u.Name, object)
+	}
+
+	for _, key := range spec.keys(args) {
+		if !u.CanAccessKey(key) {
+			ce.acl.Log(acl.ReasonKey, context, key, u.Name, c.info())
+			return errNoPermKey
+		}
+	}
+
+	channels, patterns := commandChannels(name, args)
+	for _, channel := range channels {
+		allowed := u.CanAccessChannel(channel)
+		if patterns {
+			allowed = u.CanSubscribePattern(channel)
+		}
+		if !allowed {
+			ce.acl.Log(acl.ReasonChannel, context, channel, u.Name, c.info())
+			return errNoPermChannel
+		}
+	}
+
+	return nil
+}
+
+// commandChannels returns the channels a command publishes or subscribes
+// to, and whether they are patterns.
+func commandChannels(name string, args [][]byte) ([]string, bool) {
+	var channels []string
+	switch name {
+	case PUBLISH, SPUBLISH:
+		channels = []string{string(args[0])}
+	case SUBSCRIBE, SSUBSCRIBE, PSUBSCRIBE:
+		for _, arg := range args {
+			channels = append(channels, string(arg))
+		}
+	}
+
+	return channels, name == PSUBSCRIBE
+}
+
+// executeAuth authenticates the client as a user, or as the default user if
+// only a password is given.
+func (ce *compositeExecutor) executeAuth(c *Client, args [][]byte) resp.Message {
+	if c == nil {
+		return errNoClient
+	}
+
+	switch len(args) {
+	case 1:
+		if ce.acl.User(acl.DefaultUser).NoPass() {
+			return errNoPassword
+		}
+		if errMsg := ce.authenticate(c, acl.DefaultUser, string(args[0])); errMsg != nil {
+			return errMsg
+		}
+	case 2:
+		if errMsg := ce.authenticate(c, string(args[0]), string(args[1])); errMsg != nil {
+			return errMsg
+		}
+	default:
+		return errSyntax
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// authenticate authenticates c as the given user, leaving it authenticated
+// as before if the password is wrong.
+func (ce *compositeExecutor) authenticate(c *Client, name, password string) *resp.Error {
+	if ce.acl.Authenticate(name, password) == nil {
+		ce.acl.Log(acl.ReasonAuth, "toplevel", "AUTH", name, c.info())
+		return errWrongPass
+	}
+
+	c.authenticated = true
+	c.user = name
+
+	return nil
+}
+
+// executeACL inspects and modifies the ACL users.
+func (ce *compositeExecutor) executeACL(c *Client, args [][]byte) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(ACL)
+	}
+
+	sub := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	switch sub {
+	case "SETUSER":
+		if len(args) == 0 {
+			return wrongNumberOfArgs(ACL + "|" + sub)
+		}
+		rules := make([]string, 0, len(args)-1)
+		for _, rule := range args[1:] {
+			rules = append(rules, string(rule))
+		}
+		if err := ce.acl.SetUser(string(args[0]), rules...); err != nil {
+			return &resp.Error{Value: "ERR " + err.Error()}
+		}
+		return &resp.SimpleString{Value: "OK"}
+	case "GETUSER":
+		if len(args) != 1 {
+			return wrongNumberOfArgs(ACL + "|" + sub)
+		}
+		return getUserReply(ce.acl.User(string(args[0])))
+	case "DELUSER":
+		if len(args) == 0 {
+			return wrongNumberOfArgs(ACL + "|" + sub)
+		}
+		var deleted int64
+		for _, name := range args {
+			ok, err := ce.acl.DelUser(string(name))
+			if err != nil {
+				return &resp.Error{Value: "ERR " + err.Error()}
+			}
+			if ok {
+				deleted++
+			}
+		}
+		return &resp.Int{Value: deleted}
+	case "LIST", "USERS":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(ACL + "|" + sub)
+		}
+		users := ce.acl.Users()
+		reply := make([]resp.Message, 0, len(users))
+		for _, u := range users {
+			s := u.Name
+			if sub == "LIST" {
+				s = u.String()
+			}
+			reply = append(reply, &resp.BulkString{Value: []byte(s)})
+		}
+		return &resp.Array{Value: reply}
+	case "WHOAMI":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(ACL + "|" + sub)
+		}
+		if c == nil {
+			return errNoClient
+		}
+		return &resp.BulkString{Value: []byte(c.user)}
+	case "CAT":
+		return executeACLCat(args)
+	case "LOG":
+		return ce.executeACLLog(args)
+	default:
+		return unknownSubcommand(ACL, []byte(sub))
+	}
+}
+
+// getUserReply describes a user the way ACL GETUSER does.
+func getUserReply(u *acl.User) resp.Message {
+	if u == nil {
+		return &resp.BulkString{}
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("flags")},
+		bulkStrings(u.Flags()),
+		&resp.BulkString{Value: []byte("passwords")},
+		bulkStrings(u.Passwords()),
+		&resp.BulkString{Value: []byte("commands")},
+		&resp.BulkString{Value: []byte(u.Commands())},
+		&resp.BulkString{Value: []byte("keys")},
+		&resp.BulkString{Value: []byte(u.Keys())},
+		&resp.BulkString{Value: []byte("channels")},
+		&resp.BulkString{Value: []byte(u.Channels())},
+		&resp.BulkString{Value: []byte("selectors")},
+		&resp.Array{Value: []resp.Message{}},
+	}}
+}
+
+// executeACLCat lists the command categories, or the commands in one of
+// them.
+func executeACLCat(args [][]byte) resp.Message {
+	switch len(args) {
+	case 0:
+		return bulkStrings(acl.Categories)
+	case 1:
+	default:
+		return wrongNumberOfArgs(ACL + "|CAT")
+	}
+
+	category := strings.ToLower(string(args[0]))
+	if !acl.IsCategory(category) {
+		return &resp.Error{Value: fmt.Sprintf("ERR Unknown category '%s'", args[0])}
+	}
+
+	var names []string
+	for name, cats := range categories {
+		if category == acl.AllCategories || hasCategory(cats, category) {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	sort.Strings(names)
+
+	return bulkStrings(names)
+}
+
+// executeACLLog lists the most recent denied commands, 10 unless a count
+// is given, or clears the log.
+func (ce *compositeExecutor) executeACLLog(args [][]byte) resp.Message {
+	count := 10
+	switch len(args) {
+	case 0:
+	case 1:
+		if strings.ToUpper(string(args[0])) == "RESET" {
+			ce.acl.ResetLog()
+			return &resp.SimpleString{Value: "OK"}
+		}
+		n, err := strconv.Atoi(string(args[0]))
+		if err != nil || n < 0 {
+			return &resp.Error{Value: "ERR value is out of range, must be positive"}
+		}
+		count = n
+	default:
+		return wrongNumberOfArgs(ACL + "|LOG")
+	}
+
+	now := time.Now()
+	entries := ce.acl.LogEntries(count)
+	reply := make([]resp.Message, 0, len(entries))
+	for _, e := range entries {
+		age := now.Sub(e.Created).Seconds()
+		reply = append(reply, &resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("count")},
+			&resp.Int{Value: e.Count},
+			&resp.BulkString{Value: []byte("reason")},
+			&resp.BulkString{Value: []byte(e.Reason)},
+			&resp.BulkString{Value: []byte("context")},
+			&resp.BulkString{Value: []byte(e.Context)},
+			&resp.BulkString{Value: []byte("object")},
+			&resp.BulkString{Value: []byte(e.Object)},
+			&resp.BulkString{Value: []byte("username")},
+			&resp.BulkString{Value: []byte(e.Username)},
+			&resp.BulkString{Value: []byte("age-seconds")},
+			&resp.BulkString{Value: []byte(strconv.FormatFloat(age, 'f', 3, 64))},
+			&resp.BulkString{Value: []byte("client-info")},
+			&resp.BulkString{Value: []byte(e.Client)},
+			&resp.BulkString{Value: []byte("entry-id")},
+			&resp.Int{Value: e.ID},
+			&resp.BulkString{Value: []byte("timestamp-created")},
+			&resp.Int{Value: e.Created.UnixNano() / int64(time.Millisecond)},
+			&resp.BulkString{Value: []byte("timestamp-last-updated")},
+			&resp.Int{Value: e.Updated.UnixNano() / int64(time.Millisecond)},
+		}})
+	}
+
+	return &resp.Array{Value: reply}
+}
+
+// bulkStrings returns an array of bulk strings holding strs.
+func bulkStrings(strs []string) *resp.Array {
+	msgs := make([]resp.Message, 0, len(strs))
+	for _, s := range strs {
+		msgs = append(msgs, &resp.BulkString{Value: []byte(s)})
+	}
+
+	return &resp.Array{Value: msgs}
+}
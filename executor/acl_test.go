@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/scnewma/godb/acl"
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	// the default user needs no password until it is given one
+	assert.Equal(t, errNoPassword, executeAs(e, c, "AUTH secret"))
+	assert.Equal(t, ok, executeAs(e, c, "ACL SETUSER default >secret"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("default")}, executeAs(e, c, "ACL WHOAMI"))
+
+	other, _ := newTestClient(2)
+	assert.Equal(t, errNoAuth, executeAs(e, other, "GET k"))
+	assert.Equal(t, errWrongPass, executeAs(e, other, "AUTH wrong"))
+	assert.Equal(t, ok, executeAs(e, other, "AUTH secret"))
+	assert.Equal(t, &resp.BulkString{}, executeAs(e, other, "GET k"))
+
+	assert.Equal(t, ok, executeAs(e, c, "ACL SETUSER alice on >pw ~* +@all"))
+	assert.Equal(t, errWrongPass, executeAs(e, other, "AUTH alice wrong"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("default")}, executeAs(e, other, "ACL WHOAMI"))
+	assert.Equal(t, ok, executeAs(e, other, "AUTH alice pw"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("alice")}, executeAs(e, other, "ACL WHOAMI"))
+
+	// disabling or deleting the user makes its clients authenticate again
+	assert.Equal(t, ok, executeAs(e, c, "ACL SETUSER alice off"))
+	assert.Equal(t, errNoAuth, executeAs(e, other, "GET k"))
+	assert.Equal(t, &resp.Int{Value: 1}, executeAs(e, c, "ACL DELUSER alice nobody"))
+	assert.Equal(t, errNoAuth, executeAs(e, other, "GET k"))
+
+	// commands executed without a client are trusted
+	assert.Equal(t, ok, execute(e, "SET k v"))
+	assert.Equal(t, errNoClient, execute(e, "AUTH secret"))
+}
+
+func TestCommandPermissions(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "ACL SETUSER alice on >pw ~cache:* &news.* +@read +@pubsub -keys +acl|whoami")
+	executeAs(e, c, "AUTH alice pw")
+
+	assert.Equal(t, &resp.BulkString{}, executeAs(e, c, "GET cache:1"))
+	assert.Equal(t, errNoPermKey, executeAs(e, c, "GET other"))
+	assert.Equal(t, errNoPermKey, executeAs(e, c, "MGET cache:1 other"))
+	assert.Equal(t, errNoPermKey, executeAs(e, c, "OBJECT ENCODING other"))
+	assert.Equal(t, noPermission("alice", "set"), executeAs(e, c, "SET cache:1 v"))
+	assert.Equal(t, noPermission("alice", "keys"), executeAs(e, c, "KEYS *"))
+	assert.Equal(t, noPermission("alice", "acl|list"), executeAs(e, c, "ACL LIST"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("alice")}, executeAs(e, c, "ACL WHOAMI"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, executeAs(e, c, "PUBLISH news.sports goal"))
+	assert.Equal(t, errNoPermChannel, executeAs(e, c, "PUBLISH weather sunny"))
+	assert.Equal(t, errNoPermChannel, executeAs(e, c, "PSUBSCRIBE *"))
+	assert.Equal(t, errNoPermChannel, executeAs(e, c, "SUBSCRIBE news.a weather"))
+
+	// wrong arities are reported before permissions
+	assert.Equal(t, wrongNumberOfArgs(KEYS), executeAs(e, c, "KEYS"))
+}
+
+func TestPermissionsInTransaction(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "ACL SETUSER alice on nopass ~* +@all -flushall")
+	executeAs(e, c, "AUTH alice x")
+
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, queued, executeAs(e, c, "SET k v"))
+	assert.Equal(t, noPermission("alice", "flushall"), executeAs(e, c, "FLUSHALL"))
+	assert.Equal(t, errExecAbort, executeAs(e, c, "EXEC"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS k"))
+
+	entries := e.ACL().LogEntries(-1)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "multi", entries[0].Context)
+		assert.Equal(t, "flushall", entries[0].Object)
+	}
+}
+
+func TestACLUsers(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, ok, execute(e, "ACL SETUSER alice on >pw ~k* &* +get"))
+	assert.Equal(t, &resp.Error{Value: "ERR Error in ACL SETUSER modifier '+@nope': unknown command category 'nope'"},
+		execute(e, "ACL SETUSER alice +@nope"))
+
+	assert.Equal(t, bulks(
+		"user alice on #"+acl.HashPassword("pw")+" ~k* &* -@all +get",
+		"user default on nopass ~* &* +@all",
+	), execute(e, "ACL LIST"))
+	assert.Equal(t, bulks("alice", "default"), execute(e, "ACL USERS"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("flags")},
+		bulks("on"),
+		&resp.BulkString{Value: []byte("passwords")},
+		bulks(acl.HashPassword("pw")),
+		&resp.BulkString{Value: []byte("commands")},
+		&resp.BulkString{Value: []byte("-@all +get")},
+		&resp.BulkString{Value: []byte("keys")},
+		&resp.BulkString{Value: []byte("~k*")},
+		&resp.BulkString{Value: []byte("channels")},
+		&resp.BulkString{Value: []byte("&*")},
+		&resp.BulkString{Value: []byte("selectors")},
+		&resp.Array{Value: []resp.Message{}},
+	}}, execute(e, "ACL GETUSER alice"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ACL GETUSER nobody"))
+
+	assert.Equal(t, &resp.Error{Value: "ERR The 'default' user cannot be removed"}, execute(e, "ACL DELUSER default"))
+	assert.Equal(t, unknownSubcommand(ACL, []byte("NOPE")), execute(e, "ACL NOPE"))
+}
+
+func TestACLCat(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	cats, _ := execute(e, "ACL CAT").(*resp.Array)
+	assert.Contains(t, cats.Value, &resp.BulkString{Value: []byte("sortedset")})
+	assert.Equal(t, bulks("pfadd", "pfcount", "pfmerge"), execute(e, "ACL CAT hyperloglog"))
+	assert.Equal(t, &resp.Error{Value: "ERR Unknown category 'nope'"}, execute(e, "ACL CAT nope"))
+}
+
+func TestACLLog(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "ACL SETUSER alice on >pw")
+	executeAs(e, c, "AUTH alice wrong")
+	executeAs(e, c, "AUTH alice pw")
+	executeAs(e, c, "GET k")
+	executeAs(e, c, "GET k")
+
+	entries, _ := execute(e, "ACL LOG").(*resp.Array)
+	if assert.Len(t, entries.Value, 2) {
+		entry := entries.Value[0].(*resp.Array).Value
+		assert.Equal(t, &resp.Int{Value: 2}, entry[1])
+		assert.Equal(t, &resp.BulkString{Value: []byte("command")}, entry[3])
+		assert.Equal(t, &resp.BulkString{Value: []byte("get")}, entry[7])
+		assert.Equal(t, &resp.BulkString{Value: []byte("alice")}, entry[9])
+
+		entry = entries.Value[1].(*resp.Array).Value
+		assert.Equal(t, &resp.BulkString{Value: []byte("auth")}, entry[3])
+	}
+	assert.Len(t, execute(e, "ACL LOG 1").(*resp.Array).Value, 1)
+
+	assert.Equal(t, ok, execute(e, "ACL LOG RESET"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, execute(e, "ACL LOG"))
+}
@@ -0,0 +1,659 @@
+package executor
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+)
+
+const (
+	SETBIT      = "SETBIT"
+	GETBIT      = "GETBIT"
+	BITCOUNT    = "BITCOUNT"
+	BITPOS      = "BITPOS"
+	BITOP       = "BITOP"
+	BITFIELD    = "BITFIELD"
+	BITFIELD_RO = "BITFIELD_RO"
+)
+
+var (
+	errBitOffset     = &resp.Error{Value: "ERR bit offset is not an integer or out of range"}
+	errBitValue      = &resp.Error{Value: "ERR bit is not an integer or out of range"}
+	errBitfieldType  = &resp.Error{Value: "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."}
+	errBitfieldRO    = &resp.Error{Value: "ERR BITFIELD_RO only supports the GET subcommand"}
+	errBitopNotArity = &resp.Error{Value: "ERR BITOP NOT must be called with a single source key."}
+	errBitOverflow   = &resp.Error{Value: "ERR Invalid OVERFLOW type specified"}
+)
+
+// Bits are addressed most significant bit first: bit 0 is the highest bit of
+// the first byte, matching Redis so that bitmaps are interchangeable.
+
+// parseBitOffset parses a bit offset that must address a bit within the
+// largest allowed string. When hashAllowed is set, an offset of the form #N
+// is multiplied by width, as accepted by BITFIELD.
+func parseBitOffset(b []byte, hashAllowed bool, width int64) (int64, bool) {
+	multiply := false
+	if hashAllowed && len(b) > 0 && b[0] == '#' {
+		multiply = true
+		b = b[1:]
+	}
+
+	offset, ok := parseInt(b)
+	if !ok || offset < 0 {
+		return 0, false
+	}
+
+	if multiply {
+		if offset > math.MaxInt64/width {
+			return 0, false
+		}
+		offset *= width
+	}
+
+	if offset>>3 >= maxStringLength {
+		return 0, false
+	}
+
+	return offset, true
+}
+
+// writableString returns the string stored at key grown to at least size
+// bytes. The returned slice may be modified in place, but must be stored
+// back with db.Set since growing or decoding it can allocate.
+func writableString(db storage.Storage, key string, size int) ([]byte, resp.Message) {
+	val, _, errMsg := getString(db, key)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	return growZeroed(val, size), nil
+}
+
+func getBit(val []byte, offset int64) int64 {
+	byteIdx := offset >> 3
+	if byteIdx >= int64(len(val)) {
+		return 0
+	}
+
+	return int64(val[byteIdx]>>(7-uint(offset&7))) & 1
+}
+
+func setBit(val []byte, offset int64, on bool) {
+	mask := byte(1 << (7 - uint(offset&7)))
+	if on {
+		val[offset>>3] |= mask
+	} else {
+		val[offset>>3] &^= mask
+	}
+}
+
+func executeSetBit(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawOffset := ae.ExtractAt(1)
+	rawBit := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	offset, ok := parseBitOffset(rawOffset, false, 0)
+	if !ok {
+		return errBitOffset
+	}
+
+	if len(rawBit) != 1 || (rawBit[0] != '0' && rawBit[0] != '1') {
+		return errBitValue
+	}
+
+	val, errMsg := writableString(db, key, int(offset>>3)+1)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	old := getBit(val, offset)
+	setBit(val, offset, rawBit[0] == '1')
+	db.Set(key, storage.NewNode(val))
+
+	return &resp.Int{Value: old}
+}
+
+func executeGetBit(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawOffset := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	offset, ok := parseBitOffset(rawOffset, false, 0)
+	if !ok {
+		return errBitOffset
+	}
+
+	val, _, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	return &resp.Int{Value: getBit(val, offset)}
+}
+
+// popcount counts the set bits in b, eight bytes at a time where possible.
+func popcount(b []byte) int64 {
+	var count int
+	for len(b) >= 8 {
+		count += bits.OnesCount64(binary.LittleEndian.Uint64(b))
+		b = b[8:]
+	}
+	for _, c := range b {
+		count += bits.OnesCount8(c)
+	}
+
+	return int64(count)
+}
+
+// parseBitRange parses the optional "start end [BYTE|BIT]" arguments shared by
+// BITCOUNT and BITPOS, normalizing negative indexes against length bytes.
+// The returned range is always in bits. ok is false if the range is empty.
+func parseBitRange(rawStart, rawEnd, rawUnit []byte, length int64) (start, end int64, ok bool, errMsg resp.Message) {
+	start, ok = parseInt(rawStart)
+	if !ok {
+		return 0, 0, false, errNotInteger
+	}
+
+	end, ok = parseInt(rawEnd)
+	if !ok {
+		return 0, 0, false, errNotInteger
+	}
+
+	isBit := false
+	if rawUnit != nil {
+		switch strings.ToUpper(string(rawUnit)) {
+		case "BIT":
+			isBit = true
+		case "BYTE":
+		default:
+			return 0, 0, false, errSyntax
+		}
+	}
+
+	total := length
+	if isBit {
+		total = length * 8
+	}
+
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+
+	if start > end || total == 0 {
+		return 0, 0, false, nil
+	}
+
+	if !isBit {
+		start *= 8
+		end = end*8 + 7
+	}
+
+	return start, end, true, nil
+}
+
+func executeBitCount(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		return errSyntax
+	}
+
+	val, _, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if len(args) == 1 {
+		return &resp.Int{Value: popcount(val)}
+	}
+
+	var rawUnit []byte
+	if len(args) == 4 {
+		rawUnit = args[3]
+	}
+
+	start, end, ok, errMsg := parseBitRange(args[1], args[2], rawUnit, int64(len(val)))
+	if errMsg != nil {
+		return errMsg
+	}
+	if !ok {
+		return &resp.Int{Value: 0}
+	}
+
+	count := popcount(val[start>>3 : (end>>3)+1])
+	// discount the bits of the first and last byte that fall outside the range
+	count -= int64(bits.OnesCount8(val[start>>3] &^ byte(0xff>>uint(start&7))))
+	count -= int64(bits.OnesCount8(val[end>>3] & byte(0xff>>uint((end&7)+1))))
+
+	return &resp.Int{Value: count}
+}
+
+func executeBitPos(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawBit := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 5 {
+		return errSyntax
+	}
+
+	if len(rawBit) != 1 || (rawBit[0] != '0' && rawBit[0] != '1') {
+		return &resp.Error{Value: "ERR The bit argument must be 1 or 0."}
+	}
+	bit := rawBit[0] == '1'
+
+	val, exists, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if !exists {
+		if bit {
+			return &resp.Int{Value: -1}
+		}
+		return &resp.Int{Value: 0}
+	}
+
+	length := int64(len(val))
+	start, end, ok := int64(0), length*8-1, length > 0
+	endGiven := len(args) > 3
+
+	if len(args) > 2 {
+		rawEnd := []byte("-1")
+		if endGiven {
+			rawEnd = args[3]
+		}
+		var rawUnit []byte
+		if len(args) == 5 {
+			rawUnit = args[4]
+		}
+
+		start, end, ok, errMsg = parseBitRange(args[2], rawEnd, rawUnit, length)
+		if errMsg != nil {
+			return errMsg
+		}
+	}
+
+	if !ok {
+		return &resp.Int{Value: -1}
+	}
+
+	for i := start; i <= end; {
+		// skip whole bytes that can't contain the bit we're looking for
+		if i&7 == 0 && i+7 <= end {
+			b := val[i>>3]
+			if (bit && b == 0) || (!bit && b == 0xff) {
+				i += 8
+				continue
+			}
+		}
+
+		if (getBit(val, i) == 1) == bit {
+			return &resp.Int{Value: i}
+		}
+		i++
+	}
+
+	// a string is considered padded with infinite zeros on the right, unless
+	// the caller asked for an explicit end of the range
+	if !bit && !endGiven {
+		return &resp.Int{Value: end + 1}
+	}
+
+	return &resp.Int{Value: -1}
+}
+
+func executeBitOp(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	op := strings.ToUpper(ae.ExtractStringAt(0))
+	dest := ae.ExtractStringAt(1)
+	srcKeys := ae.ExtractStringsFrom(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(srcKeys) != 1 {
+			return errBitopNotArity
+		}
+	default:
+		return errSyntax
+	}
+
+	srcs := make([][]byte, 0, len(srcKeys))
+	maxLen := 0
+	for _, key := range srcKeys {
+		val, _, errMsg := getString(db, key)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		srcs = append(srcs, val)
+		if len(val) > maxLen {
+			maxLen = len(val)
+		}
+	}
+
+	if maxLen == 0 {
+		db.Del(dest)
+		return &resp.Int{Value: 0}
+	}
+
+	// missing bytes of shorter strings are treated as zero
+	result := make([]byte, maxLen)
+	copy(result, srcs[0])
+
+	switch op {
+	case "NOT":
+		for i := range result {
+			result[i] = ^result[i]
+		}
+	case "AND":
+		for _, src := range srcs[1:] {
+			for i := range result {
+				if i < len(src) {
+					result[i] &= src[i]
+				} else {
+					result[i] = 0
+				}
+			}
+		}
+	case "OR":
+		for _, src := range srcs[1:] {
+			for i := range src {
+				result[i] |= src[i]
+			}
+		}
+	case "XOR":
+		for _, src := range srcs[1:] {
+			for i := range src {
+				result[i] ^= src[i]
+			}
+		}
+	}
+
+	db.Set(dest, storage.NewNode(result))
+
+	return &resp.Int{Value: int64(maxLen)}
+}
+
+type bitfieldOverflow int
+
+const (
+	overflowWrap bitfieldOverflow = iota
+	overflowSat
+	overflowFail
+)
+
+type bitfieldOp struct {
+	op       string
+	signed   bool
+	width    uint
+	offset   int64
+	value    int64
+	overflow bitfieldOverflow
+}
+
+func parseBitfieldType(b []byte) (signed bool, width uint, ok bool) {
+	if len(b) < 2 {
+		return false, 0, false
+	}
+
+	switch b[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, false
+	}
+
+	n, err := strconv.Atoi(string(b[1:]))
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, false
+	}
+
+	return signed, uint(n), true
+}
+
+func executeBitfield(args [][]byte, db storage.Storage) resp.Message {
+	return bitfield(args, db, false)
+}
+
+func executeBitfieldRO(args [][]byte, db storage.Storage) resp.Message {
+	return bitfield(args, db, true)
+}
+
+func bitfield(args [][]byte, db storage.Storage, readOnly bool) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	var (
+		ops       []bitfieldOp
+		overflow  = overflowWrap
+		writeSize int64
+	)
+	for i := 1; i < len(args); i++ {
+		sub := strings.ToUpper(string(args[i]))
+		remaining := len(args) - i - 1
+
+		switch {
+		case sub == "OVERFLOW" && remaining >= 1:
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return errBitOverflow
+			}
+			i++
+		case (sub == "GET" && remaining >= 2) || ((sub == "SET" || sub == "INCRBY") && remaining >= 3):
+			if readOnly && sub != "GET" {
+				return errBitfieldRO
+			}
+
+			signed, width, ok := parseBitfieldType(args[i+1])
+			if !ok {
+				return errBitfieldType
+			}
+
+			offset, ok := parseBitOffset(args[i+2], true, int64(width))
+			if !ok {
+				return errBitOffset
+			}
+
+			op := bitfieldOp{op: sub, signed: signed, width: width, offset: offset, overflow: overflow}
+			if sub != "GET" {
+				op.value, ok = parseInt(args[i+3])
+				if !ok {
+					return errNotInteger
+				}
+
+				if end := offset + int64(width); end > writeSize {
+					writeSize = end
+				}
+				i++
+			}
+
+			ops = append(ops, op)
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+
+	var val []byte
+	if writeSize > 0 {
+		var errMsg resp.Message
+		val, errMsg = writableString(db, key, int((writeSize+7)/8))
+		if errMsg != nil {
+			return errMsg
+		}
+	} else {
+		var errMsg resp.Message
+		val, _, errMsg = getString(db, key)
+		if errMsg != nil {
+			return errMsg
+		}
+	}
+
+	replies := make([]resp.Message, 0, len(ops))
+	for _, op := range ops {
+		old := getBitfield(val, op.offset, op.width)
+		if op.signed {
+			old = signExtend(old, op.width)
+		}
+
+		if op.op == "GET" {
+			replies = append(replies, &resp.Int{Value: int64(old)})
+			continue
+		}
+
+		var (
+			newVal   int64
+			overflow bool
+		)
+		if op.op == "INCRBY" {
+			newVal, overflow = addBitfield(int64(old), op.value, op.width, op.signed, op.overflow)
+		} else {
+			newVal, overflow = addBitfield(op.value, 0, op.width, op.signed, op.overflow)
+		}
+
+		if overflow && op.overflow == overflowFail {
+			replies = append(replies, &resp.BulkString{})
+			continue
+		}
+
+		setBitfield(val, op.offset, op.width, uint64(newVal))
+
+		if op.op == "INCRBY" {
+			replies = append(replies, &resp.Int{Value: newVal})
+		} else {
+			replies = append(replies, &resp.Int{Value: int64(old)})
+		}
+	}
+
+	if writeSize > 0 {
+		db.Set(key, storage.NewNode(val))
+	}
+
+	return &resp.Array{Value: replies}
+}
+
+func getBitfield(val []byte, offset int64, width uint) uint64 {
+	var v uint64
+	for i := uint(0); i < width; i++ {
+		v = v<<1 | uint64(getBit(val, offset+int64(i)))
+	}
+
+	return v
+}
+
+func setBitfield(val []byte, offset int64, width uint, v uint64) {
+	for i := uint(0); i < width; i++ {
+		setBit(val, offset+int64(i), v&(1<<(width-1-i)) != 0)
+	}
+}
+
+func signExtend(v uint64, width uint) uint64 {
+	if width < 64 && v&(1<<(width-1)) != 0 {
+		v |= ^uint64(0) << width
+	}
+
+	return v
+}
+
+// addBitfield computes value+incr for an integer of the given width,
+// reporting whether the result overflowed. On overflow the result is wrapped
+// or saturated according to the overflow policy; it is meaningless for
+// overflowFail.
+func addBitfield(value, incr int64, width uint, signed bool, policy bitfieldOverflow) (int64, bool) {
+	if signed {
+		max := int64(math.MaxInt64)
+		if width < 64 {
+			max = int64(1)<<(width-1) - 1
+		}
+		min := -max - 1
+
+		sum := value + incr
+		over := (incr > 0 && (sum < value || sum > max)) || value > max
+		under := (incr < 0 && (sum > value || sum < min)) || value < min
+		if !over && !under {
+			return sum, false
+		}
+
+		switch {
+		case policy == overflowSat && over:
+			return max, true
+		case policy == overflowSat:
+			return min, true
+		default:
+			return int64(signExtend(uint64(sum)&widthMask(width), width)), true
+		}
+	}
+
+	max := uint64(1)<<width - 1
+	uvalue := uint64(value)
+	sum := uvalue + uint64(incr)
+
+	over := uvalue > max || (incr > 0 && uint64(incr) > max-uvalue)
+	under := incr < 0 && uint64(-incr) > uvalue
+	if !over && !under {
+		return int64(sum), false
+	}
+
+	switch {
+	case policy == overflowSat && over:
+		return int64(max), true
+	case policy == overflowSat:
+		return 0, true
+	default:
+		return int64(sum & max), true
+	}
+}
+
+func widthMask(width uint) uint64 {
+	if width >= 64 {
+		return ^uint64(0)
+	}
+
+	return uint64(1)<<width - 1
+}
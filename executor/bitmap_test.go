@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetBitGetBit(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SETBIT mykey 7 1"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SETBIT mykey 7 0"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SETBIT mykey 7 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GETBIT mykey 0"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "GETBIT mykey 7"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GETBIT mykey 100"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("\x01")}, execute(e, "GET mykey"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SETBIT mykey 17 1"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("\x01\x00\x40")}, execute(e, "GET mykey"))
+}
+
+func TestSetBitErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, errBitOffset, execute(e, "SETBIT k -1 1"))
+	assert.Equal(t, errBitOffset, execute(e, "SETBIT k 4294967296 1"))
+	assert.Equal(t, errBitValue, execute(e, "SETBIT k 0 2"))
+	assert.Equal(t, errBitOffset, execute(e, "GETBIT k x"))
+}
+
+func TestBitCount(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET mykey foobar")
+
+	assert.Equal(t, &resp.Int{Value: 26}, execute(e, "BITCOUNT mykey"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "BITCOUNT mykey 0 0"))
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "BITCOUNT mykey 1 1"))
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "BITCOUNT mykey 1 1 BYTE"))
+	assert.Equal(t, &resp.Int{Value: 17}, execute(e, "BITCOUNT mykey 5 30 BIT"))
+	assert.Equal(t, &resp.Int{Value: 26}, execute(e, "BITCOUNT mykey 0 -1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "BITCOUNT mykey 3 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "BITCOUNT missing"))
+	assert.Equal(t, errSyntax, execute(e, "BITCOUNT mykey 0"))
+	assert.Equal(t, errSyntax, execute(e, "BITCOUNT mykey 0 1 WORD"))
+}
+
+func TestBitPos(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	execute(e, "SET mykey \xff\xf0\x00")
+	assert.Equal(t, &resp.Int{Value: 12}, execute(e, "BITPOS mykey 0"))
+
+	execute(e, "SET mykey \x00\xff\xf0")
+	assert.Equal(t, &resp.Int{Value: 8}, execute(e, "BITPOS mykey 1 0"))
+	assert.Equal(t, &resp.Int{Value: 16}, execute(e, "BITPOS mykey 1 2"))
+	assert.Equal(t, &resp.Int{Value: 16}, execute(e, "BITPOS mykey 1 2 -1 BYTE"))
+	assert.Equal(t, &resp.Int{Value: 8}, execute(e, "BITPOS mykey 1 7 15 BIT"))
+
+	execute(e, "SET mykey \x00\x00\x00")
+	assert.Equal(t, &resp.Int{Value: -1}, execute(e, "BITPOS mykey 1"))
+	assert.Equal(t, &resp.Int{Value: -1}, execute(e, "BITPOS mykey 1 7 -3 BIT"))
+
+	execute(e, "SET ones \xff\xff")
+	assert.Equal(t, &resp.Int{Value: 16}, execute(e, "BITPOS ones 0"))
+	assert.Equal(t, &resp.Int{Value: -1}, execute(e, "BITPOS ones 0 0 -1"))
+
+	assert.Equal(t, &resp.Int{Value: -1}, execute(e, "BITPOS missing 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "BITPOS missing 0"))
+}
+
+func TestBitOp(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET key1 foobar key2 abcdef")
+
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "BITOP AND dest key1 key2"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("`bc`ab")}, execute(e, "GET dest"))
+
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "BITOP OR dest key1 key2"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("goofev")}, execute(e, "GET dest"))
+
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "BITOP XOR dest key1 key2"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("\x07\x0d\x0c\x06\x04\x14")}, execute(e, "GET dest"))
+
+	execute(e, "SET short \x0f")
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "BITOP NOT dest short"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("\xf0")}, execute(e, "GET dest"))
+
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "BITOP AND dest short key1"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("\x06\x00\x00\x00\x00\x00")}, execute(e, "GET dest"))
+}
+
+func TestBitOpErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET dest x")
+
+	assert.Equal(t, errBitopNotArity, execute(e, "BITOP NOT dest a b"))
+	assert.Equal(t, errSyntax, execute(e, "BITOP NAND dest a b"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "BITOP OR dest a b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS dest"))
+}
+
+func TestBitfield(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 1},
+		&resp.Int{Value: 0},
+	}}, execute(e, "BITFIELD mykey INCRBY i5 100 1 GET u4 0"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 0},
+		&resp.Int{Value: 255},
+		&resp.Int{Value: -1},
+	}}, execute(e, "BITFIELD other SET i8 #1 -1 GET u8 8 GET i8 #1"))
+}
+
+func TestBitfieldOverflow(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	expected := [][2]int64{{1, 1}, {2, 2}, {3, 3}, {0, 3}}
+	for _, exp := range expected {
+		assert.Equal(t, &resp.Array{Value: []resp.Message{
+			&resp.Int{Value: exp[0]},
+			&resp.Int{Value: exp[1]},
+		}}, execute(e, "BITFIELD mykey INCRBY u2 100 1 OVERFLOW SAT INCRBY u2 102 1"))
+	}
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{},
+	}}, execute(e, "BITFIELD mykey OVERFLOW FAIL INCRBY u2 102 1"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 0},
+		&resp.Int{Value: -128},
+		&resp.Int{Value: -128},
+	}}, execute(e, "BITFIELD signed SET i8 0 127 OVERFLOW WRAP INCRBY i8 0 1 OVERFLOW SAT INCRBY i8 0 -1000"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 0},
+		&resp.Int{Value: -9223372036854775808},
+	}}, execute(e, "BITFIELD big SET i64 0 9223372036854775807 INCRBY i64 0 1"))
+}
+
+func TestBitfieldErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, errBitfieldType, execute(e, "BITFIELD k GET u64 0"))
+	assert.Equal(t, errBitfieldType, execute(e, "BITFIELD k GET x8 0"))
+	assert.Equal(t, errBitOffset, execute(e, "BITFIELD k GET u8 -1"))
+	assert.Equal(t, errBitOverflow, execute(e, "BITFIELD k OVERFLOW NOPE"))
+	assert.Equal(t, errSyntax, execute(e, "BITFIELD k GET u8"))
+	assert.Equal(t, errBitfieldRO, execute(e, "BITFIELD_RO k SET u8 0 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS k"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 0},
+	}}, execute(e, "BITFIELD_RO k GET u8 0"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS k"))
+}
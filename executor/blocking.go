@@ -0,0 +1,227 @@
+package executor
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+)
+
+// blockingFunc executes a command that may have to wait for another client to
+// write to one of its keys. If the command can be answered straight away the
+// reply is returned and the blockSpec is nil. Otherwise the returned
+// blockSpec describes what to wait for.
+type blockingFunc func(args [][]byte, db storage.Storage) (resp.Message, *blockSpec)
+
+type blockSpec struct {
+	keys []string
+
+	// timeout is how long to wait before replying with timeoutReply. Zero
+	// waits forever.
+	timeout      time.Duration
+	timeoutReply resp.Message
+
+	// serve tries to answer the command now that key may be ready. ok is
+	// false if the command still can't be served, in which case it keeps
+	// waiting. It is called with the storage lock held.
+	serve func(db storage.Storage, key string) (msg resp.Message, ok bool)
+}
+
+// waiter is a client blocked on one or more keys.
+type waiter struct {
+	spec *blockSpec
+
+	// elems holds the waiter's position in the queue of every key it waits
+	// on, so that it can be removed once served or timed out.
+	elems map[string]*list.Element
+
+	// reply receives the reply once the waiter is served. served is guarded
+	// by the registry mutex.
+	reply  chan resp.Message
+	served bool
+}
+
+// blockingRegistry tracks clients blocked on keys. Clients blocked on the same
+// key are served in the order they blocked.
+//
+// Clients are registered and served while the storage lock is held, which
+// guarantees that no write is missed between a blocking command finding its
+// keys empty and it being registered.
+type blockingRegistry struct {
+	mu sync.Mutex
+
+	waiters map[string]*list.List
+	// blocked is the number of clients blocked, each of which may wait on
+	// several keys.
+	blocked int
+}
+
+func newBlockingRegistry() *blockingRegistry {
+	return &blockingRegistry{waiters: make(map[string]*list.List)}
+}
+
+// empty reports whether any client is blocked.
+func (r *blockingRegistry) empty() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.waiters) == 0
+}
+
+// count returns the number of clients blocked.
+func (r *blockingRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.blocked
+}
+
+// keys returns the keys clients are blocked on.
+func (r *blockingRegistry) keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.waiters))
+	for key := range r.waiters {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (r *blockingRegistry) block(spec *blockSpec) *waiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w := &waiter{
+		spec:  spec,
+		elems: make(map[string]*list.Element, len(spec.keys)),
+		reply: make(chan resp.Message, 1),
+	}
+	for _, key := range spec.keys {
+		if _, ok := w.elems[key]; ok {
+			continue
+		}
+
+		q, ok := r.waiters[key]
+		if !ok {
+			q = list.New()
+			r.waiters[key] = q
+		}
+		w.elems[key] = q.PushBack(w)
+	}
+	r.blocked++
+
+	return w
+}
+
+// remove unregisters w from every key it is blocked on. The registry mutex
+// must be held.
+func (r *blockingRegistry) remove(w *waiter) {
+	for key, elem := range w.elems {
+		q := r.waiters[key]
+		q.Remove(elem)
+		if q.Len() == 0 {
+			delete(r.waiters, key)
+		}
+	}
+	w.elems = nil
+	r.blocked--
+}
+
+// wait blocks until w is served, its timeout expires or ctx is done. If the
+// waiter isn't served it gets the timeout reply, which nobody will read when
+// ctx is done because the client has gone away.
+func (r *blockingRegistry) wait(ctx context.Context, w *waiter) resp.Message {
+	var timeout <-chan time.Time
+	if w.spec.timeout > 0 {
+		timer := time.NewTimer(w.spec.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case msg := <-w.reply:
+		return msg
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the waiter may have been served while we were giving up on it, in
+	// which case the reply has already been decided
+	if w.served {
+		return <-w.reply
+	}
+
+	r.remove(w)
+
+	return w.spec.timeoutReply
+}
+
+// serve serves the clients blocked on keys, in the order they blocked, for
+// as long as the keys can satisfy them. It must be called with the storage
+// lock held.
+func (r *blockingRegistry) serve(db storage.Storage, keys []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		q, ok := r.waiters[key]
+		if !ok {
+			continue
+		}
+
+		for e := q.Front(); e != nil; {
+			next := e.Next()
+
+			w := e.Value.(*waiter)
+			msg, ok := w.spec.serve(db, key)
+			if ok {
+				w.served = true
+				w.reply <- msg
+				r.remove(w)
+			}
+
+			// a client that can't be served doesn't mean the ones behind
+			// it can't either, e.g. XREAD waiting for entries past a later
+			// ID than theirs
+			e = next
+			if _, ok := r.waiters[key]; !ok {
+				break
+			}
+		}
+	}
+}
+
+// keyTracker wraps the storage handed to a command and records the keys it
+// sets, so that clients blocked on those keys can be served afterwards.
+// A key becoming ready always goes through Set: lists, for instance, are
+// only waited on while they don't exist, and XADD sets streams again even
+// when they already exist.
+type keyTracker struct {
+	storage.Storage
+
+	keys []string
+}
+
+func (t *keyTracker) Set(key string, node storage.Node) {
+	t.Storage.Set(key, node)
+	t.keys = append(t.keys, key)
+}
+
+func (t *keyTracker) Atomic(fn func(storage.Storage)) {
+	fn(t)
+}
+
+// flush returns the keys recorded since the last call.
+func (t *keyTracker) flush() []string {
+	keys := t.keys
+	t.keys = nil
+	return keys
+}
@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// executeAsync runs command in the background and waits for it to block on
+// keys before returning a channel that receives its reply.
+func executeAsync(t *testing.T, e *compositeExecutor, ctx context.Context, command string) <-chan resp.Message {
+	before := blockedCount(e)
+
+	replies := make(chan resp.Message, 1)
+	go func() {
+		parts := strings.Split(command, " ")
+		replies <- e.Execute(ctx, Command{Name: parts[0], Args: asArgs(parts[1:]...)})
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for blockedCount(e) == before {
+		require.True(t, time.Now().Before(deadline), "command did not block")
+		time.Sleep(time.Millisecond)
+	}
+
+	return replies
+}
+
+func blockedCount(e *compositeExecutor) int {
+	e.blocked[0].mu.Lock()
+	defer e.blocked[0].mu.Unlock()
+
+	waiters := make(map[*waiter]bool)
+	for _, q := range e.blocked[0].waiters {
+		for el := q.Front(); el != nil; el = el.Next() {
+			waiters[el.Value.(*waiter)] = true
+		}
+	}
+	return len(waiters)
+}
+
+func receive(t *testing.T, replies <-chan resp.Message) resp.Message {
+	select {
+	case msg := <-replies:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for reply")
+		return nil
+	}
+}
+
+func TestBLPopServesImmediately(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH b x y")
+
+	assert.Equal(t, bulks("b", "x"), execute(e, "BLPOP a b 0"))
+	assert.Equal(t, bulks("b", "y"), execute(e, "BRPOP b 0"))
+}
+
+func TestBLPopBlocksUntilPush(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	replies := executeAsync(t, e, context.Background(), "BLPOP q1 q2 0")
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "RPUSH q2 a b"))
+
+	assert.Equal(t, bulks("q2", "a"), receive(t, replies))
+	assert.Equal(t, bulks("b"), execute(e, "LRANGE q2 0 -1"))
+	assert.Equal(t, 0, blockedCount(e))
+}
+
+func TestBlockedClientsAreServedInOrder(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	first := executeAsync(t, e, context.Background(), "BRPOP q 0")
+	second := executeAsync(t, e, context.Background(), "BRPOP q 0")
+
+	execute(e, "LPUSH q a")
+	assert.Equal(t, bulks("q", "a"), receive(t, first))
+	assert.Equal(t, 1, blockedCount(e))
+
+	execute(e, "LPUSH q b c")
+	assert.Equal(t, bulks("q", "b"), receive(t, second))
+	assert.Equal(t, bulks("c"), execute(e, "LRANGE q 0 -1"))
+}
+
+func TestBLPopTimeout(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	start := time.Now()
+	assert.Equal(t, &resp.Array{}, execute(e, "BLPOP q 0.05"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, 0, blockedCount(e))
+}
+
+func TestBLPopClientGoesAway(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replies := executeAsync(t, e, ctx, "BLPOP q 0")
+	cancel()
+	receive(t, replies)
+
+	// the element must not be handed to the departed client
+	execute(e, "RPUSH q a")
+	assert.Equal(t, bulks("a"), execute(e, "LRANGE q 0 -1"))
+	assert.Equal(t, 0, blockedCount(e))
+}
+
+func TestBLPopErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET s x")
+
+	assert.Equal(t, errTimeoutNegative, execute(e, "BLPOP q -1"))
+	assert.Equal(t, errTimeout, execute(e, "BLPOP q soon"))
+	// 1<<63 nanoseconds, one past the largest time.Duration
+	assert.Equal(t, errTimeout, execute(e, "BLPOP q 9223372036.854775808"))
+	assert.Equal(t, errWrongType, execute(e, "BLPOP s 0"))
+}
+
+func TestBLMove(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH src a")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("a")}, execute(e, "BLMOVE src dst LEFT LEFT 0"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "BLMOVE src dst LEFT LEFT 0.01"))
+}
+
+func TestBLMoveWakesClientsBlockedOnDestination(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	popper := executeAsync(t, e, context.Background(), "BLPOP dst 0")
+	mover := executeAsync(t, e, context.Background(), "BLMOVE src dst RIGHT LEFT 0")
+
+	execute(e, "RPUSH src job")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("job")}, receive(t, mover))
+	assert.Equal(t, bulks("dst", "job"), receive(t, popper))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS src dst"))
+}
+
+func TestLMPop(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH b 1 2 3")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("b")},
+		bulks("3", "2"),
+	}}, execute(e, "LMPOP 2 a b RIGHT COUNT 2"))
+	assert.Equal(t, &resp.Array{}, execute(e, "LMPOP 1 a LEFT"))
+	assert.Equal(t, &resp.Error{Value: "ERR numkeys should be greater than 0"}, execute(e, "LMPOP 0 a LEFT"))
+	assert.Equal(t, &resp.Error{Value: "ERR count should be greater than 0"}, execute(e, "LMPOP 1 a LEFT COUNT 0"))
+	assert.Equal(t, errSyntax, execute(e, "LMPOP 1 a UP"))
+}
+
+func TestBLMPop(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	replies := executeAsync(t, e, context.Background(), "BLMPOP 0 2 a b LEFT COUNT 5")
+	execute(e, "RPUSH b 1 2")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("b")},
+		bulks("1", "2"),
+	}}, receive(t, replies))
+
+	assert.Equal(t, &resp.Array{}, execute(e, "BLMPOP 0.01 1 a LEFT"))
+}
@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/scnewma/godb/resp"
+)
+
+// clientFunc is a command that acts on the client that sent it or on the
+// server rather than on the keyspace. c is nil for commands that weren't
+// received over a connection.
+type clientFunc func(c *Client, args [][]byte) resp.Message
+
+// Client holds the state of a client connection that outlives its
+// commands, such as its subscriptions. Commands are executed without a
+// client when they aren't received over a connection, in which case the
+// commands that need one fail.
+type Client struct {
+	conn resp.Conn
+
+	mu     sync.Mutex
+	closed bool
+	// closers are called when the client is closed, keyed by what they
+	// release.
+	closers map[string]func()
+
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+	// monitoring is set while the client receives the commands executed by
+	// every client, as enabled by MONITOR.
+	monitoring bool
+
+	// authenticated is set once the client authenticated as user, either
+	// with AUTH or HELLO, or implicitly as the default user. name is set by
+	// HELLO SETNAME. Like the database below, they are only used by the
+	// client's own commands.
+	authenticated bool
+	user          string
+	name          string
+
+	// db is the index of the selected database. Like the transaction state
+	// below, it is only used by the client's own commands.
+	db int
+
+	// multi is set between MULTI and EXEC or DISCARD, while commands are
+	// queued. multiFailed is set if a command couldn't be queued. They are
+	// only used by the client's own commands, which run one at a time.
+	multi       bool
+	multiFailed bool
+	queued      []Command
+}
+
+func NewClient(conn resp.Conn) *Client {
+	return &Client{
+		conn:          conn,
+		closers:       make(map[string]func()),
+		channels:      make(map[string]struct{}),
+		patterns:      make(map[string]struct{}),
+		shardChannels: make(map[string]struct{}),
+	}
+}
+
+// ID returns the ID of the client's connection.
+func (c *Client) ID() uint64 {
+	return c.conn.ID()
+}
+
+// Close releases the resources held by the client once its connection is
+// closed.
+func (c *Client) Close() {
+	c.mu.Lock()
+	c.closed = true
+	closers := c.closers
+	c.closers = nil
+	c.mu.Unlock()
+
+	for _, fn := range closers {
+		fn()
+	}
+}
+
+// onClose registers fn to be called when the client is closed, unless a
+// function was already registered for the same purpose. It must be called
+// with the client locked.
+func (c *Client) onClose(purpose string, fn func()) {
+	if c.closed {
+		return
+	}
+
+	if _, ok := c.closers[purpose]; !ok {
+		c.closers[purpose] = fn
+	}
+}
+
+// info describes the client for the ACL log.
+func (c *Client) info() string {
+	return fmt.Sprintf("id=%d name=%s db=%d user=%s", c.ID(), c.name, c.db, c.user)
+}
+
+// selected returns the index of the client's database. Commands executed
+// without a client run against database 0.
+func (c *Client) selected() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.db
+}
+
+// push writes msg to the client outside of a reply.
+func (c *Client) push(msg resp.Message) {
+	c.conn.Push(msg)
+}
+
+// subscribed reports whether the client is subscribed to any channel or
+// pattern.
+func (c *Client) subscribed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.subscriptions() > 0
+}
+
+// subscriptions returns the number of channels, shard channels and patterns
+// the client is subscribed to. It must be called with the client locked.
+func (c *Client) subscriptions() int {
+	return len(c.channels) + len(c.patterns) + len(c.shardChannels)
+}
@@ -0,0 +1,402 @@
+package executor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commandSpec describes a command the way Redis's command table does, for
+// the checks that happen before a command runs, such as validating
+// commands queued by MULTI, and for finding the keys a command modifies.
+type commandSpec struct {
+	// arity is the number of arguments including the command name, or its
+	// negation if the command takes at least that many.
+	arity int
+
+	// write is set for commands that may modify their keys.
+	write bool
+	// denyoom is set for the write commands that may use more memory, which
+	// are refused once maxmemory is exceeded. Commands that only delete,
+	// such as DEL, keep working so that memory can be freed.
+	denyoom bool
+
+	// keys returns the keys among the command's arguments.
+	keys keySpec
+
+	// flush is set for commands that may modify keys not among their
+	// arguments, such as FLUSHDB.
+	flush bool
+}
+
+// keySpec extracts keys from the arguments of a command, not including its
+// name.
+type keySpec func(args [][]byte) []string
+
+// keyRange returns the arguments from first to last, both inclusive, every
+// step arguments. A negative last counts from the end, -1 being the last
+// argument.
+func keyRange(first, last, step int) keySpec {
+	return func(args [][]byte) []string {
+		end := last
+		if end < 0 {
+			end += len(args)
+		}
+		if end >= len(args) {
+			end = len(args) - 1
+		}
+
+		var keys []string
+		for i := first; i <= end; i += step {
+			keys = append(keys, string(args[i]))
+		}
+
+		return keys
+	}
+}
+
+// numKeys returns the keys following the argument at index i, which holds
+// how many there are.
+func numKeys(i int) keySpec {
+	return func(args [][]byte) []string {
+		if i >= len(args) {
+			return nil
+		}
+
+		n, err := strconv.Atoi(string(args[i]))
+		if err != nil || n <= 0 {
+			return nil
+		}
+
+		return keyRange(i+1, i+n, 1)(args)
+	}
+}
+
+// keySpecs concatenates the keys of several specs.
+func keySpecs(specs ...keySpec) keySpec {
+	return func(args [][]byte) []string {
+		var keys []string
+		for _, spec := range specs {
+			keys = append(keys, spec(args)...)
+		}
+
+		return keys
+	}
+}
+
+// streamKeys returns the keys of XREAD and XREADGROUP: the first half of the
+// arguments following STREAMS.
+func streamKeys(args [][]byte) []string {
+	for i, arg := range args {
+		if strings.ToUpper(string(arg)) == "STREAMS" {
+			rest := args[i+1:]
+			return keyRange(0, len(rest)/2-1, 1)(rest)
+		}
+	}
+
+	return nil
+}
+
+var (
+	noKeys        keySpec = func([][]byte) []string { return nil }
+	firstKey              = keyRange(0, 0, 1)
+	firstTwoKeys          = keyRange(0, 1, 1)
+	secondKey             = keyRange(1, 1, 1)
+	allKeys               = keyRange(0, -1, 1)
+	keyValuePairs         = keyRange(0, -1, 2)
+)
+
+var commandTable = map[string]commandSpec{
+	GET:    {arity: 2, keys: firstKey},
+	SET:    {arity: -3, write: true, denyoom: true, keys: firstKey},
+	DEL:    {arity: -2, write: true, keys: allKeys},
+	MGET:   {arity: -2, keys: allKeys},
+	MSET:   {arity: -3, write: true, denyoom: true, keys: keyValuePairs},
+	MSETNX: {arity: -3, write: true, denyoom: true, keys: keyValuePairs},
+	EXISTS: {arity: -2, keys: allKeys},
+	TOUCH:  {arity: -2, keys: allKeys},
+	UNLINK: {arity: -2, write: true, keys: allKeys},
+	TYPE:   {arity: 2, keys: firstKey},
+
+	INCR:        {arity: 2, write: true, denyoom: true, keys: firstKey},
+	DECR:        {arity: 2, write: true, denyoom: true, keys: firstKey},
+	INCRBY:      {arity: 3, write: true, denyoom: true, keys: firstKey},
+	DECRBY:      {arity: 3, write: true, denyoom: true, keys: firstKey},
+	INCRBYFLOAT: {arity: 3, write: true, denyoom: true, keys: firstKey},
+	APPEND:      {arity: 3, write: true, denyoom: true, keys: firstKey},
+	STRLEN:      {arity: 2, keys: firstKey},
+	GETRANGE:    {arity: 4, keys: firstKey},
+	SETRANGE:    {arity: 4, write: true, denyoom: true, keys: firstKey},
+	LCS:         {arity: -3, keys: firstTwoKeys},
+
+	SETBIT:      {arity: 4, write: true, denyoom: true, keys: firstKey},
+	GETBIT:      {arity: 3, keys: firstKey},
+	BITCOUNT:    {arity: -2, keys: firstKey},
+	BITPOS:      {arity: -3, keys: firstKey},
+	BITOP:       {arity: -4, write: true, denyoom: true, keys: keyRange(1, -1, 1)},
+	BITFIELD:    {arity: -2, write: true, denyoom: true, keys: firstKey},
+	BITFIELD_RO: {arity: -2, keys: firstKey},
+
+	LPUSH:   {arity: -3, write: true, denyoom: true, keys: firstKey},
+	RPUSH:   {arity: -3, write: true, denyoom: true, keys: firstKey},
+	LPUSHX:  {arity: -3, write: true, denyoom: true, keys: firstKey},
+	RPUSHX:  {arity: -3, write: true, denyoom: true, keys: firstKey},
+	LPOP:    {arity: -2, write: true, keys: firstKey},
+	RPOP:    {arity: -2, write: true, keys: firstKey},
+	LRANGE:  {arity: 4, keys: firstKey},
+	LINDEX:  {arity: 3, keys: firstKey},
+	LSET:    {arity: 4, write: true, denyoom: true, keys: firstKey},
+	LINSERT: {arity: 5, write: true, denyoom: true, keys: firstKey},
+	LREM:    {arity: 4, write: true, keys: firstKey},
+	LTRIM:   {arity: 4, write: true, keys: firstKey},
+	LLEN:    {arity: 2, keys: firstKey},
+	LPOS:    {arity: -3, keys: firstKey},
+	LMOVE:   {arity: 5, write: true, denyoom: true, keys: firstTwoKeys},
+	LMPOP:   {arity: -4, write: true, keys: numKeys(0)},
+	BLPOP:   {arity: -3, write: true, keys: keyRange(0, -2, 1)},
+	BRPOP:   {arity: -3, write: true, keys: keyRange(0, -2, 1)},
+	BLMOVE:  {arity: 6, write: true, denyoom: true, keys: firstTwoKeys},
+	BLMPOP:  {arity: -5, write: true, keys: numKeys(1)},
+
+	HSET:         {arity: -4, write: true, denyoom: true, keys: firstKey},
+	HSETNX:       {arity: 4, write: true, denyoom: true, keys: firstKey},
+	HGET:         {arity: 3, keys: firstKey},
+	HMGET:        {arity: -3, keys: firstKey},
+	HGETALL:      {arity: 2, keys: firstKey},
+	HDEL:         {arity: -3, write: true, keys: firstKey},
+	HEXISTS:      {arity: 3, keys: firstKey},
+	HLEN:         {arity: 2, keys: firstKey},
+	HKEYS:        {arity: 2, keys: firstKey},
+	HVALS:        {arity: 2, keys: firstKey},
+	HINCRBY:      {arity: 4, write: true, denyoom: true, keys: firstKey},
+	HINCRBYFLOAT: {arity: 4, write: true, denyoom: true, keys: firstKey},
+	HSTRLEN:      {arity: 3, keys: firstKey},
+	HRANDFIELD:   {arity: -2, keys: firstKey},
+	HSCAN:        {arity: -3, keys: firstKey},
+	HEXPIRE:      {arity: -6, write: true, denyoom: true, keys: firstKey},
+	HPEXPIRE:     {arity: -6, write: true, denyoom: true, keys: firstKey},
+	HEXPIREAT:    {arity: -6, write: true, denyoom: true, keys: firstKey},
+	HTTL:         {arity: -5, keys: firstKey},
+	HPTTL:        {arity: -5, keys: firstKey},
+	HPERSIST:     {arity: -5, write: true, keys: firstKey},
+
+	SADD:        {arity: -3, write: true, denyoom: true, keys: firstKey},
+	SREM:        {arity: -3, write: true, keys: firstKey},
+	SISMEMBER:   {arity: 3, keys: firstKey},
+	SMISMEMBER:  {arity: -3, keys: firstKey},
+	SMEMBERS:    {arity: 2, keys: firstKey},
+	SCARD:       {arity: 2, keys: firstKey},
+	SPOP:        {arity: -2, write: true, keys: firstKey},
+	SRANDMEMBER: {arity: -2, keys: firstKey},
+	SMOVE:       {arity: 4, write: true, keys: firstTwoKeys},
+	SINTER:      {arity: -2, keys: allKeys},
+	SINTERSTORE: {arity: -3, write: true, denyoom: true, keys: allKeys},
+	SINTERCARD:  {arity: -3, keys: numKeys(0)},
+	SUNION:      {arity: -2, keys: allKeys},
+	SUNIONSTORE: {arity: -3, write: true, denyoom: true, keys: allKeys},
+	SDIFF:       {arity: -2, keys: allKeys},
+	SDIFFSTORE:  {arity: -3, write: true, denyoom: true, keys: allKeys},
+	SSCAN:       {arity: -3, keys: firstKey},
+
+	ZADD:        {arity: -4, write: true, denyoom: true, keys: firstKey},
+	ZREM:        {arity: -3, write: true, keys: firstKey},
+	ZSCORE:      {arity: 3, keys: firstKey},
+	ZMSCORE:     {arity: -3, keys: firstKey},
+	ZINCRBY:     {arity: 4, write: true, denyoom: true, keys: firstKey},
+	ZCARD:       {arity: 2, keys: firstKey},
+	ZCOUNT:      {arity: 4, keys: firstKey},
+	ZRANK:       {arity: -3, keys: firstKey},
+	ZREVRANK:    {arity: -3, keys: firstKey},
+	ZRANGE:      {arity: -4, keys: firstKey},
+	ZRANGESTORE: {arity: -5, write: true, denyoom: true, keys: firstTwoKeys},
+	ZPOPMIN:     {arity: -2, write: true, keys: firstKey},
+	ZPOPMAX:     {arity: -2, write: true, keys: firstKey},
+	ZUNIONSTORE: {arity: -4, write: true, denyoom: true, keys: keySpecs(firstKey, numKeys(1))},
+	ZINTERSTORE: {arity: -4, write: true, denyoom: true, keys: keySpecs(firstKey, numKeys(1))},
+	ZDIFFSTORE:  {arity: -4, write: true, denyoom: true, keys: keySpecs(firstKey, numKeys(1))},
+	ZSCAN:       {arity: -3, keys: firstKey},
+
+	XADD:       {arity: -5, write: true, denyoom: true, keys: firstKey},
+	XRANGE:     {arity: -4, keys: firstKey},
+	XREVRANGE:  {arity: -4, keys: firstKey},
+	XLEN:       {arity: 2, keys: firstKey},
+	XDEL:       {arity: -3, write: true, keys: firstKey},
+	XTRIM:      {arity: -4, write: true, keys: firstKey},
+	XINFO:      {arity: -2, keys: secondKey},
+	XREAD:      {arity: -4, keys: streamKeys},
+	XGROUP:     {arity: -2, write: true, keys: secondKey},
+	XREADGROUP: {arity: -7, write: true, keys: streamKeys},
+	XACK:       {arity: -4, write: true, keys: firstKey},
+	XPENDING:   {arity: -3, keys: firstKey},
+	XCLAIM:     {arity: -6, write: true, keys: firstKey},
+	XAUTOCLAIM: {arity: -6, write: true, keys: firstKey},
+
+	PFADD:   {arity: -2, write: true, denyoom: true, keys: firstKey},
+	PFCOUNT: {arity: -2, keys: allKeys},
+	PFMERGE: {arity: -2, write: true, denyoom: true, keys: allKeys},
+
+	GEOADD:         {arity: -5, write: true, denyoom: true, keys: firstKey},
+	GEOPOS:         {arity: -2, keys: firstKey},
+	GEODIST:        {arity: -4, keys: firstKey},
+	GEOHASH:        {arity: -2, keys: firstKey},
+	GEOSEARCH:      {arity: -7, keys: firstKey},
+	GEOSEARCHSTORE: {arity: -8, write: true, denyoom: true, keys: firstTwoKeys},
+
+	KEYS:      {arity: 2, keys: noKeys},
+	SCAN:      {arity: -2, keys: noKeys},
+	RENAME:    {arity: 3, write: true, keys: firstTwoKeys},
+	RENAMENX:  {arity: 3, write: true, keys: firstTwoKeys},
+	COPY:      {arity: -3, write: true, denyoom: true, keys: firstTwoKeys},
+	RANDOMKEY: {arity: 1, keys: noKeys},
+	DBSIZE:    {arity: 1, keys: noKeys},
+	FLUSHDB:   {arity: -1, write: true, keys: noKeys, flush: true},
+	FLUSHALL:  {arity: -1, write: true, keys: noKeys, flush: true},
+	OBJECT:    {arity: -2, keys: secondKey},
+
+	SELECT: {arity: 2, keys: noKeys},
+	SWAPDB: {arity: 3, write: true, keys: noKeys},
+	MOVE:   {arity: 3, write: true, keys: firstKey},
+
+	SUBSCRIBE:    {arity: -2, keys: noKeys},
+	UNSUBSCRIBE:  {arity: -1, keys: noKeys},
+	PSUBSCRIBE:   {arity: -2, keys: noKeys},
+	PUNSUBSCRIBE: {arity: -1, keys: noKeys},
+	SSUBSCRIBE:   {arity: -2, keys: noKeys},
+	SUNSUBSCRIBE: {arity: -1, keys: noKeys},
+	PUBLISH:      {arity: 3, keys: noKeys},
+	SPUBLISH:     {arity: 3, keys: noKeys},
+	PUBSUB:       {arity: -2, keys: noKeys},
+
+	MULTI:   {arity: 1, keys: noKeys},
+	EXEC:    {arity: 1, keys: noKeys},
+	DISCARD: {arity: 1, keys: noKeys},
+	WATCH:   {arity: -2, keys: allKeys},
+	UNWATCH: {arity: 1, keys: noKeys},
+
+	AUTH: {arity: -2, keys: noKeys},
+	ACL:  {arity: -2, keys: noKeys},
+
+	PING:  {arity: -1, keys: noKeys},
+	ECHO:  {arity: 2, keys: noKeys},
+	QUIT:  {arity: -1, keys: noKeys},
+	RESET: {arity: 1, keys: noKeys},
+	HELLO: {arity: -1, keys: noKeys},
+
+	INFO:    {arity: -1, keys: noKeys},
+	CONFIG:  {arity: -2, keys: noKeys},
+	SLOWLOG: {arity: -2, keys: noKeys},
+	MONITOR: {arity: 1, keys: noKeys},
+}
+
+// commandCategories lists the commands in each ACL category, except for
+// read and write, which follow from the command table.
+var commandCategories = map[string][]string{
+	"keyspace": {
+		DEL, EXISTS, TOUCH, UNLINK, TYPE, KEYS, SCAN, RENAME, RENAMENX, COPY,
+		RANDOMKEY, DBSIZE, FLUSHDB, FLUSHALL, OBJECT, MOVE, SWAPDB,
+	},
+	"string": {
+		GET, SET, MGET, MSET, MSETNX, INCR, DECR, INCRBY, DECRBY, INCRBYFLOAT,
+		APPEND, STRLEN, GETRANGE, SETRANGE, LCS,
+	},
+	"bitmap": {SETBIT, GETBIT, BITCOUNT, BITPOS, BITOP, BITFIELD, BITFIELD_RO},
+	"list": {
+		LPUSH, RPUSH, LPUSHX, RPUSHX, LPOP, RPOP, LRANGE, LINDEX, LSET,
+		LINSERT, LREM, LTRIM, LLEN, LPOS, LMOVE, LMPOP, BLPOP, BRPOP, BLMOVE,
+		BLMPOP,
+	},
+	"hash": {
+		HSET, HSETNX, HGET, HMGET, HGETALL, HDEL, HEXISTS, HLEN, HKEYS, HVALS,
+		HINCRBY, HINCRBYFLOAT, HSTRLEN, HRANDFIELD, HSCAN, HEXPIRE, HPEXPIRE,
+		HEXPIREAT, HTTL, HPTTL, HPERSIST,
+	},
+	"set": {
+		SADD, SREM, SISMEMBER, SMISMEMBER, SMEMBERS, SCARD, SPOP, SRANDMEMBER,
+		SMOVE, SINTER, SINTERSTORE, SINTERCARD, SUNION, SUNIONSTORE, SDIFF,
+		SDIFFSTORE, SSCAN,
+	},
+	"sortedset": {
+		ZADD, ZREM, ZSCORE, ZMSCORE, ZINCRBY, ZCARD, ZCOUNT, ZRANK, ZREVRANK,
+		ZRANGE, ZRANGESTORE, ZPOPMIN, ZPOPMAX, ZUNIONSTORE, ZINTERSTORE,
+		ZDIFFSTORE, ZSCAN,
+	},
+	"stream": {
+		XADD, XRANGE, XREVRANGE, XLEN, XDEL, XTRIM, XINFO, XREAD, XGROUP,
+		XREADGROUP, XACK, XPENDING, XCLAIM, XAUTOCLAIM,
+	},
+	"hyperloglog": {PFADD, PFCOUNT, PFMERGE},
+	"geo":         {GEOADD, GEOPOS, GEODIST, GEOHASH, GEOSEARCH, GEOSEARCHSTORE},
+	"pubsub": {
+		SUBSCRIBE, UNSUBSCRIBE, PSUBSCRIBE, PUNSUBSCRIBE, SSUBSCRIBE,
+		SUNSUBSCRIBE, PUBLISH, SPUBLISH, PUBSUB,
+	},
+	"transaction": {MULTI, EXEC, DISCARD, WATCH, UNWATCH},
+	"connection":  {SELECT, AUTH, PING, ECHO, QUIT, RESET, HELLO},
+	"blocking":    {BLPOP, BRPOP, BLMOVE, BLMPOP, XREAD, XREADGROUP},
+	"admin":       {ACL, CONFIG, SLOWLOG, MONITOR},
+	"dangerous":   {KEYS, FLUSHDB, FLUSHALL, SWAPDB, ACL, INFO, CONFIG, SLOWLOG, MONITOR},
+}
+
+// categories holds the sorted ACL categories of each command.
+var categories = categorize()
+
+// categorize assigns commands to categories. Commands are in the write
+// category if they may modify keys, and in the read category if they read
+// keys without modifying them.
+func categorize() map[string][]string {
+	byCommand := make(map[string][]string)
+	for category, names := range commandCategories {
+		for _, name := range names {
+			byCommand[name] = append(byCommand[name], category)
+		}
+	}
+
+	for name, spec := range commandTable {
+		cats := byCommand[name]
+		switch {
+		case spec.write:
+			cats = append(cats, "write")
+		case readsKeys(cats):
+			cats = append(cats, "read")
+		}
+
+		sort.Strings(cats)
+		byCommand[name] = cats
+	}
+
+	return byCommand
+}
+
+// readsKeys reports whether commands in any of the given categories access
+// the keyspace.
+func readsKeys(cats []string) bool {
+	for _, category := range cats {
+		switch category {
+		case "pubsub", "transaction", "connection", "admin", "dangerous", "blocking":
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasCategory(cats []string, category string) bool {
+	for _, c := range cats {
+		if c == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkArity reports whether args satisfy the command's arity.
+func (spec commandSpec) checkArity(args [][]byte) bool {
+	argc := len(args) + 1
+	if spec.arity < 0 {
+		return argc >= -spec.arity
+	}
+
+	return argc == spec.arity
+}
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandTableCoversCommands(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	var names []string
+	for name := range e.executorLookup {
+		names = append(names, name)
+	}
+	for name := range e.blockingLookup {
+		names = append(names, name)
+	}
+	for name := range e.clientLookup {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		_, ok := commandTable[name]
+		assert.True(t, ok, "%s is missing from the command table", name)
+	}
+}
+
+func TestCommandsHaveCategories(t *testing.T) {
+	for name := range commandTable {
+		assert.NotEmpty(t, categories[name], "%s has no ACL category", name)
+	}
+
+	assert.Equal(t, []string{"read", "string"}, categories[GET])
+	assert.Equal(t, []string{"list", "write"}, categories[LPUSH])
+	assert.Equal(t, []string{"blocking", "list", "write"}, categories[BLPOP])
+	assert.Equal(t, []string{"dangerous", "keyspace", "read"}, categories[KEYS])
+	assert.Equal(t, []string{"pubsub"}, categories[PUBLISH])
+}
+
+func TestKeySpecs(t *testing.T) {
+	keys := func(name string, args ...string) []string {
+		return commandTable[name].keys(asArgs(args...))
+	}
+
+	assert.Equal(t, []string{"a"}, keys(SET, "a", "1", "NX"))
+	assert.Equal(t, []string{"a", "b"}, keys(MSET, "a", "1", "b", "2"))
+	assert.Equal(t, []string{"a", "b"}, keys(BLPOP, "a", "b", "0"))
+	assert.Equal(t, []string{"d", "a", "b"}, keys(ZUNIONSTORE, "d", "2", "a", "b", "WEIGHTS", "1", "2"))
+	assert.Equal(t, []string{"a", "b"}, keys(BLMPOP, "0", "2", "a", "b", "LEFT"))
+	assert.Equal(t, []string{"a", "b"}, keys(XREADGROUP, "GROUP", "g", "c", "STREAMS", "a", "b", ">", ">"))
+	assert.Equal(t, []string{"d", "a"}, keys(BITOP, "NOT", "d", "a"))
+	assert.Nil(t, keys(LMPOP, "x", "a"))
+}
+
+func TestCheckArity(t *testing.T) {
+	assert.True(t, commandTable[GET].checkArity(asArgs("a")))
+	assert.False(t, commandTable[GET].checkArity(asArgs("a", "b")))
+	assert.True(t, commandTable[SET].checkArity(asArgs("a", "b", "NX")))
+	assert.False(t, commandTable[SET].checkArity(asArgs("a")))
+	assert.True(t, commandTable[EXEC].checkArity(nil))
+}
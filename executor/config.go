@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"sort"
+	"strings"
+
+	"github.com/scnewma/godb/config"
+	"github.com/scnewma/godb/resp"
+)
+
+const CONFIG = "CONFIG"
+
+var errOOM = &resp.Error{Value: "OOM command not allowed when used memory > 'maxmemory'."}
+
+// Config returns the parameters the executor reads and CONFIG changes.
+func (ce *compositeExecutor) Config() *config.Config {
+	return ce.config
+}
+
+// SetConfig sets the parameters the executor reads and CONFIG changes. The
+// executor applies the parameters it is concerned with, such as maxmemory
+// and the slow log's, whenever they change.
+func (ce *compositeExecutor) SetConfig(cfg *config.Config) {
+	ce.config = cfg
+	cfg.Watch(config.MaxMemory, func() {
+		ce.maxMemory.Store(cfg.Int(config.MaxMemory))
+	})
+	cfg.Watch(config.SlowLogLogSlowerThan, func() {
+		ce.slowLogThreshold.Store(cfg.Int(config.SlowLogLogSlowerThan))
+	})
+	cfg.Watch(config.SlowLogMaxLen, func() {
+		ce.slowLog.setMaxLen(int(cfg.Int(config.SlowLogMaxLen)))
+	})
+}
+
+// usedMemorySample reads the memory held by live objects, which maxmemory
+// limits. Reading it doesn't stop the world, unlike runtime.ReadMemStats.
+const usedMemorySample = "/memory/classes/heap/objects:bytes"
+
+func usedMemory() int64 {
+	sample := []metrics.Sample{{Name: usedMemorySample}}
+	metrics.Read(sample)
+
+	return int64(sample[0].Value.Uint64())
+}
+
+// outOfMemory reports whether the command called name must be refused
+// because it may use more memory while the memory used exceeds maxmemory.
+// Like Redis's noeviction policy, nothing is evicted to make room.
+func (ce *compositeExecutor) outOfMemory(name string) bool {
+	max := ce.maxMemory.Load()
+	if max == 0 || !commandTable[name].denyoom {
+		return false
+	}
+
+	return usedMemory() > max
+}
+
+func (ce *compositeExecutor) executeConfig(_ *Client, args [][]byte) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(CONFIG)
+	}
+
+	sub := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	switch sub {
+	case "GET":
+		if len(args) == 0 {
+			return wrongNumberOfArgs(CONFIG + "|" + sub)
+		}
+		return ce.executeConfigGet(args)
+	case "SET":
+		if len(args) == 0 || len(args)%2 != 0 {
+			return wrongNumberOfArgs(CONFIG + "|" + sub)
+		}
+		pairs := make([]string, 0, len(args))
+		for _, arg := range args {
+			pairs = append(pairs, string(arg))
+		}
+		if err := ce.config.Set(pairs...); err != nil {
+			return configSetError(err)
+		}
+		return &resp.SimpleString{Value: "OK"}
+	case "REWRITE":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(CONFIG + "|" + sub)
+		}
+		if err := ce.config.Rewrite(); err != nil {
+			return &resp.Error{Value: "ERR " + err.Error()}
+		}
+		return &resp.SimpleString{Value: "OK"}
+	case "RESETSTAT":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(CONFIG + "|" + sub)
+		}
+		ce.stats.reset()
+		ce.serverStats().Reset()
+		return &resp.SimpleString{Value: "OK"}
+	default:
+		return unknownSubcommand(CONFIG, []byte(sub))
+	}
+}
+
+// executeConfigGet replies with the names and values of the parameters
+// matching any of the patterns, sorted by name.
+func (ce *compositeExecutor) executeConfigGet(patterns [][]byte) resp.Message {
+	values := make(map[string]string)
+	for _, pattern := range patterns {
+		for name, value := range ce.config.Get(string(pattern)) {
+			values[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reply := make([]string, 0, len(values)*2)
+	for _, name := range names {
+		reply = append(reply, name, values[name])
+	}
+
+	return bulkStrings(reply)
+}
+
+func configSetError(err error) *resp.Error {
+	var paramErr *config.ParamError
+	if !errors.As(err, &paramErr) {
+		return &resp.Error{Value: "ERR " + err.Error()}
+	}
+
+	if errors.Is(err, config.ErrUnknownParam) {
+		return &resp.Error{Value: fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", paramErr.Name)}
+	}
+
+	return &resp.Error{Value: fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %v", paramErr.Name, paramErr.Err)}
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scnewma/godb/config"
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigGetSet(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, bulks("maxclients", "10000", "maxmemory", "0"), execute(e, "CONFIG GET max* MAXCLIENTS"))
+	assert.Equal(t, bulks(), execute(e, "CONFIG GET nope"))
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET maxclients 5 timeout 0"))
+	assert.Equal(t, bulks("maxclients", "5", "timeout", "0"), execute(e, "CONFIG GET maxclients timeout"))
+
+	assert.Equal(t, &resp.Error{Value: "ERR Unknown option or number of arguments for CONFIG SET - 'nope'"},
+		execute(e, "CONFIG SET nope 1"))
+	assert.Equal(t, &resp.Error{Value: "ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"},
+		execute(e, "CONFIG SET port 1"))
+	assert.Equal(t, &resp.Error{Value: "ERR CONFIG SET failed (possibly related to argument 'maxclients') - argument couldn't be parsed into an integer"},
+		execute(e, "CONFIG SET maxclients x"))
+	assert.Equal(t, wrongNumberOfArgs("CONFIG|SET"), execute(e, "CONFIG SET maxclients"))
+	assert.Equal(t, unknownSubcommand(CONFIG, []byte("NOPE")), execute(e, "CONFIG NOPE"))
+}
+
+func TestConfigMaxMemory(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET maxmemory 1b"))
+	assert.Equal(t, errOOM, execute(e, "SET k v"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "GET k"))
+	assert.Equal(t, "1", info(t, e, "INFO memory")["maxmemory"])
+
+	c, _ := newTestClient(1)
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, errOOM, executeAs(e, c, "SET k v"))
+	assert.Equal(t, errExecAbort, executeAs(e, c, "EXEC"))
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET maxmemory 0"))
+	assert.Equal(t, ok, execute(e, "SET k v"))
+}
+
+func TestConfigMaxMemoryAllowsFreeingMemory(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET a 1 b 2")
+	execute(e, "RPUSH l x y")
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET maxmemory 1b"))
+	assert.Equal(t, errOOM, execute(e, "RPUSH l z"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "DEL a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("x")}, execute(e, "LPOP l"))
+
+	c, _ := newTestClient(1)
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, queued, executeAs(e, c, "UNLINK b"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{&resp.Int{Value: 1}}}, executeAs(e, c, "EXEC"))
+
+	assert.Equal(t, ok, execute(e, "FLUSHALL"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "DBSIZE"))
+}
+
+func TestConfigRewrite(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	assert.Equal(t, &resp.Error{Value: "ERR The server is running without a config file"}, execute(e, "CONFIG REWRITE"))
+
+	path := filepath.Join(t.TempDir(), "godb.conf")
+	assert.NoError(t, os.WriteFile(path, []byte("maxclients 10\n"), 0644))
+	cfg := config.New(config.Params...)
+	assert.NoError(t, cfg.LoadFile(path))
+	e.SetConfig(cfg)
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET maxclients 20"))
+	assert.Equal(t, ok, execute(e, "CONFIG REWRITE"))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "maxclients 20\n", string(content))
+	assert.Equal(t, path, info(t, e, "INFO server")["config_file"])
+}
+
+func TestConfigResetStat(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	srv := &testServer{}
+	srv.stats.ConnectedClients.Store(2)
+	srv.stats.TotalConnections.Store(5)
+	e.SetServer(srv)
+
+	execute(e, "SET k v")
+	execute(e, "NOPE")
+	assert.Equal(t, ok, execute(e, "CONFIG RESETSTAT"))
+
+	fields := info(t, e, "INFO stats clients commandstats errorstats")
+	assert.Equal(t, "0", fields["total_connections_received"])
+	assert.Equal(t, "2", fields["connected_clients"])
+	assert.Equal(t, "0", fields["total_error_replies"])
+	assert.NotContains(t, fields, "cmdstat_set")
+	assert.NotContains(t, fields, "errorstat_ERR")
+}
@@ -0,0 +1,168 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/scnewma/godb/resp"
+)
+
+const (
+	PING  = "PING"
+	ECHO  = "ECHO"
+	QUIT  = "QUIT"
+	RESET = "RESET"
+	HELLO = "HELLO"
+)
+
+// serverVersion is the version of Redis whose commands are implemented,
+// reported to clients that adapt to the server's version.
+const serverVersion = "7.2.0"
+
+var (
+	errNoProto     = &resp.Error{Value: "NOPROTO sorry, this protocol version is not supported"}
+	errHelloNoAuth = &resp.Error{Value: "NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time"}
+)
+
+// executePing replies PONG, or echoes its argument. Subscribed clients get
+// the reply as a push-like array, so that it can be told apart from the
+// messages they receive.
+func executePing(c *Client, args [][]byte) resp.Message {
+	if len(args) > 1 {
+		return wrongNumberOfArgs(PING)
+	}
+
+	if c != nil && c.subscribed() {
+		var message []byte
+		if len(args) == 1 {
+			message = args[0]
+		}
+
+		return &resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("pong")},
+			&resp.BulkString{Value: append([]byte{}, message...)},
+		}}
+	}
+
+	if len(args) == 1 {
+		return &resp.BulkString{Value: args[0]}
+	}
+
+	return &resp.SimpleString{Value: "PONG"}
+}
+
+func executeEcho(_ *Client, args [][]byte) resp.Message {
+	if len(args) != 1 {
+		return wrongNumberOfArgs(ECHO)
+	}
+
+	return &resp.BulkString{Value: args[0]}
+}
+
+// executeQuit replies OK, after which the connection is closed.
+func executeQuit(c *Client, _ [][]byte) resp.Message {
+	if c != nil {
+		c.conn.CloseAfterReply()
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// executeReset returns the client to the state it connected in: it leaves
+// any transaction, unwatches every key, unsubscribes from everything, stops
+// monitoring, selects database 0, forgets its name and must authenticate
+// again unless the default user needs no password.
+func (ce *compositeExecutor) executeReset(c *Client, args [][]byte) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(RESET)
+	}
+	if c == nil {
+		return errNoClient
+	}
+
+	c.resetMulti()
+	ce.watches.unwatch(c)
+
+	ce.unsubscribeAll(c)
+	ce.unmonitor(c)
+	c.conn.KeepAlive(false)
+
+	c.db = 0
+	c.authenticated = false
+	c.user = ""
+	c.name = ""
+
+	return &resp.SimpleString{Value: "RESET"}
+}
+
+// executeHello optionally authenticates the client and names it, then
+// describes the server. Only RESP2 is supported.
+func (ce *compositeExecutor) executeHello(c *Client, args [][]byte) resp.Message {
+	if c == nil {
+		return errNoClient
+	}
+
+	if len(args) > 0 {
+		proto, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			return &resp.Error{Value: "ERR Protocol version is not an integer or out of range"}
+		}
+		if proto != 2 {
+			return errNoProto
+		}
+	}
+
+	var (
+		user, password string
+		auth           bool
+		name           *string
+	)
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "AUTH" && i+2 < len(args):
+			user, password, auth = string(args[i+1]), string(args[i+2]), true
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			setname := string(args[i+1])
+			name = &setname
+			i++
+		default:
+			return &resp.Error{Value: fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i])}
+		}
+	}
+
+	if auth {
+		if errMsg := ce.authenticate(c, user, password); errMsg != nil {
+			return errMsg
+		}
+	}
+	if ce.user(c) == nil {
+		return errHelloNoAuth
+	}
+	if name != nil {
+		c.name = *name
+	}
+
+	return helloReply(c)
+}
+
+// helloReply describes the server and the client's connection to it.
+func helloReply(c *Client) resp.Message {
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("server")},
+		&resp.BulkString{Value: []byte("godb")},
+		&resp.BulkString{Value: []byte("version")},
+		&resp.BulkString{Value: []byte(serverVersion)},
+		&resp.BulkString{Value: []byte("proto")},
+		&resp.Int{Value: 2},
+		&resp.BulkString{Value: []byte("id")},
+		&resp.Int{Value: int64(c.ID())},
+		&resp.BulkString{Value: []byte("mode")},
+		&resp.BulkString{Value: []byte("standalone")},
+		&resp.BulkString{Value: []byte("role")},
+		&resp.BulkString{Value: []byte("master")},
+		&resp.BulkString{Value: []byte("modules")},
+		&resp.Array{Value: []resp.Message{}},
+	}}
+}
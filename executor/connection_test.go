@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, conn := newTestClient(1)
+
+	assert.Equal(t, &resp.SimpleString{Value: "PONG"}, executeAs(e, c, "PING"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("hi")}, executeAs(e, c, "PING hi"))
+	assert.Equal(t, wrongNumberOfArgs(PING), executeAs(e, c, "PING a b"))
+	assert.Equal(t, &resp.SimpleString{Value: "PONG"}, execute(e, "PING"))
+
+	// subscribed clients get an array, like the messages they receive
+	executeAs(e, c, "SUBSCRIBE news")
+	conn.Flush()
+	assert.Equal(t, bulks("pong", ""), executeAs(e, c, "PING"))
+	assert.Equal(t, bulks("pong", "hi"), executeAs(e, c, "ping hi"))
+}
+
+func TestEcho(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("hello")}, execute(e, "ECHO hello"))
+	assert.Equal(t, wrongNumberOfArgs(ECHO), execute(e, "ECHO"))
+}
+
+func TestQuit(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, conn := newTestClient(1)
+
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, ok, executeAs(e, c, "QUIT"))
+	assert.True(t, conn.Closing)
+}
+
+func TestReset(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, conn := newTestClient(1)
+
+	executeAs(e, c, "HELLO 2 SETNAME worker")
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "WATCH k")
+	executeAs(e, c, "SUBSCRIBE news")
+	assert.Equal(t, &resp.SimpleString{Value: "RESET"}, executeAs(e, c, "RESET"))
+
+	assert.False(t, conn.KeptAlive)
+	assert.Empty(t, c.name)
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBLISH news hello"))
+	assert.True(t, e.watches.empty())
+
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "SET k v")
+	assert.Equal(t, &resp.SimpleString{Value: "RESET"}, executeAs(e, c, "RESET"))
+	assert.Equal(t, errExecWithoutMulti, executeAs(e, c, "EXEC"))
+	assert.Equal(t, &resp.BulkString{}, executeAs(e, c, "GET k"), "the transaction was discarded in database 0")
+
+	// the client must authenticate again once the default user has a
+	// password
+	execute(e, "ACL SETUSER alice on >pw +@all ~*")
+	execute(e, "ACL SETUSER default resetpass >secret")
+	executeAs(e, c, "AUTH alice pw")
+	assert.Equal(t, &resp.SimpleString{Value: "RESET"}, executeAs(e, c, "RESET"))
+	assert.Equal(t, errNoAuth, executeAs(e, c, "PING"))
+}
+
+func TestHello(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(7)
+
+	reply := &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("server")},
+		&resp.BulkString{Value: []byte("godb")},
+		&resp.BulkString{Value: []byte("version")},
+		&resp.BulkString{Value: []byte(serverVersion)},
+		&resp.BulkString{Value: []byte("proto")},
+		&resp.Int{Value: 2},
+		&resp.BulkString{Value: []byte("id")},
+		&resp.Int{Value: 7},
+		&resp.BulkString{Value: []byte("mode")},
+		&resp.BulkString{Value: []byte("standalone")},
+		&resp.BulkString{Value: []byte("role")},
+		&resp.BulkString{Value: []byte("master")},
+		&resp.BulkString{Value: []byte("modules")},
+		&resp.Array{Value: []resp.Message{}},
+	}}
+	assert.Equal(t, reply, executeAs(e, c, "HELLO"))
+	assert.Equal(t, errNoProto, executeAs(e, c, "HELLO 3"))
+
+	execute(e, "ACL SETUSER default resetpass >secret")
+	c, _ = newTestClient(7)
+	assert.Equal(t, errHelloNoAuth, executeAs(e, c, "HELLO 2"))
+	assert.Equal(t, errWrongPass, executeAs(e, c, "HELLO 2 AUTH default wrong"))
+	assert.Equal(t, reply, executeAs(e, c, "HELLO 2 AUTH default secret SETNAME app"))
+	assert.Equal(t, "app", c.name)
+	assert.Equal(t, &resp.Error{Value: "ERR Syntax error in HELLO option 'AUTH'"}, executeAs(e, c, "HELLO 2 AUTH default"))
+}
@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+)
+
+const (
+	SELECT = "SELECT"
+	SWAPDB = "SWAPDB"
+	MOVE   = "MOVE"
+)
+
+var (
+	errDBOutOfRange    = &resp.Error{Value: "ERR DB index is out of range"}
+	errInvalidFirstDB  = &resp.Error{Value: "ERR invalid first DB index"}
+	errInvalidSecondDB = &resp.Error{Value: "ERR invalid second DB index"}
+)
+
+// parseDBIndex parses the index of a database.
+func (ce *compositeExecutor) parseDBIndex(arg []byte) (int, resp.Message) {
+	index, ok := parseInt(arg)
+	if !ok {
+		return 0, errNotInteger
+	}
+	if index < 0 || index >= int64(len(ce.dbs)) {
+		return 0, errDBOutOfRange
+	}
+
+	return int(index), nil
+}
+
+// atomicallyPair calls fn with exclusive access to the databases at from and
+// to, which may be the same. dbsMu must be held for writing, which rules out
+// deadlocks with other commands locking both databases.
+func (ce *compositeExecutor) atomicallyPair(from, to int, fn func(src, dst storage.Storage)) {
+	ce.atomically(from, func(src storage.Storage) {
+		if from == to {
+			fn(src, src)
+			return
+		}
+
+		ce.atomically(to, func(dst storage.Storage) {
+			fn(src, dst)
+		})
+	})
+}
+
+func (ce *compositeExecutor) executeSelect(c *Client, args [][]byte) resp.Message {
+	if len(args) != 1 {
+		return wrongNumberOfArgs(SELECT)
+	}
+	if c == nil {
+		return errNoClient
+	}
+
+	index, errMsg := ce.parseDBIndex(args[0])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	c.db = index
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// executeSwapDB swaps two databases, so that clients that selected one of
+// them see the data of the other. Clients blocked on keys of either database
+// are served if the keys they wait for are ready after the swap.
+func (ce *compositeExecutor) executeSwapDB(_ *Client, args [][]byte) resp.Message {
+	if len(args) != 2 {
+		return wrongNumberOfArgs(SWAPDB)
+	}
+
+	first, ok := parseInt(args[0])
+	if !ok {
+		return errInvalidFirstDB
+	}
+	second, ok := parseInt(args[1])
+	if !ok {
+		return errInvalidSecondDB
+	}
+	if first < 0 || first >= int64(len(ce.dbs)) || second < 0 || second >= int64(len(ce.dbs)) {
+		return errDBOutOfRange
+	}
+
+	i, j := int(first), int(second)
+	if i == j {
+		return &resp.SimpleString{Value: "OK"}
+	}
+
+	ce.dbs[i], ce.dbs[j] = ce.dbs[j], ce.dbs[i]
+
+	for _, index := range []int{i, j} {
+		ce.watches.touchAll(index)
+
+		// clients are served through atomically's loop, whose storage
+		// records the keys they write: a served BLMOVE pushing to another
+		// key serves the clients blocked on that key in turn
+		blocked := ce.blocked[index]
+		ce.atomically(index, func(tx storage.Storage) {
+			blocked.serve(tx, blocked.keys())
+		})
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// executeMove moves a key from the selected database to another one, unless
+// the other database already holds the key.
+func (ce *compositeExecutor) executeMove(c *Client, args [][]byte) resp.Message {
+	if len(args) != 2 {
+		return wrongNumberOfArgs(MOVE)
+	}
+
+	key := string(args[0])
+	from := c.selected()
+	to, errMsg := ce.parseDBIndex(args[1])
+	if errMsg != nil {
+		return errMsg
+	}
+	if from == to {
+		return errSameObject
+	}
+
+	var moved bool
+	ce.atomicallyPair(from, to, func(src, dst storage.Storage) {
+		node, err := src.Get(key)
+		if err != nil {
+			return
+		}
+		if _, err := dst.Get(key); err == nil {
+			return
+		}
+
+		src.Del(key)
+		dst.Set(key, node)
+		moved = true
+	})
+
+	if !moved {
+		return &resp.Int{Value: 0}
+	}
+
+	ce.watches.touch(from, []string{key})
+	ce.watches.touch(to, []string{key})
+
+	return &resp.Int{Value: 1}
+}
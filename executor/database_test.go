@@ -0,0 +1,185 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDatabases(n int) []storage.Storage {
+	dbs := make([]storage.Storage, n)
+	for i := range dbs {
+		dbs[i] = inmem.NewStorage()
+	}
+	return dbs
+}
+
+func TestSelect(t *testing.T) {
+	e := NewExecutor(newDatabases(3)...)
+	a, _ := newTestClient(1)
+	b, _ := newTestClient(2)
+
+	executeAs(e, a, "SET k 0")
+	assert.Equal(t, ok, executeAs(e, a, "SELECT 1"))
+	assert.Equal(t, &resp.BulkString{}, executeAs(e, a, "GET k"))
+	executeAs(e, a, "SET k 1")
+
+	// each client has its own selected database
+	assert.Equal(t, &resp.BulkString{Value: []byte("0")}, executeAs(e, b, "GET k"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1")}, executeAs(e, a, "GET k"))
+	assert.Equal(t, &resp.Int{Value: 1}, executeAs(e, a, "DBSIZE"))
+
+	assert.Equal(t, errDBOutOfRange, executeAs(e, a, "SELECT 3"))
+	assert.Equal(t, errDBOutOfRange, executeAs(e, a, "SELECT -1"))
+	assert.Equal(t, errNotInteger, executeAs(e, a, "SELECT x"))
+	assert.Equal(t, errNoClient, execute(e, "SELECT 1"))
+	assert.Equal(t, wrongNumberOfArgs(SELECT), executeAs(e, a, "SELECT"))
+}
+
+func TestFlushDBIsScopedToSelectedDatabase(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, _ := newTestClient(1)
+
+	execute(e, "SET k 0")
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "SET k 1")
+
+	assert.Equal(t, ok, executeAs(e, c, "FLUSHDB"))
+	assert.Equal(t, &resp.Int{Value: 0}, executeAs(e, c, "DBSIZE"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "DBSIZE"))
+
+	executeAs(e, c, "SET k 1")
+	assert.Equal(t, ok, executeAs(e, c, "FLUSHALL"))
+	assert.Equal(t, &resp.Int{Value: 0}, executeAs(e, c, "DBSIZE"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "DBSIZE"))
+}
+
+func TestMove(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, _ := newTestClient(1)
+
+	execute(e, "RPUSH k a b")
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "MOVE k 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS k"))
+
+	executeAs(e, c, "SELECT 1")
+	assert.Equal(t, bulks("a", "b"), executeAs(e, c, "LRANGE k 0 -1"))
+
+	// keys already present in the destination are left alone
+	execute(e, "SET k x")
+	assert.Equal(t, &resp.Int{Value: 0}, executeAs(e, c, "MOVE k 0"))
+	assert.Equal(t, &resp.Int{Value: 0}, executeAs(e, c, "MOVE nokey 0"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("x")}, execute(e, "GET k"))
+
+	assert.Equal(t, errSameObject, executeAs(e, c, "MOVE k 1"))
+	assert.Equal(t, errDBOutOfRange, executeAs(e, c, "MOVE k 2"))
+	assert.Equal(t, wrongNumberOfArgs(MOVE), executeAs(e, c, "MOVE k"))
+}
+
+func TestCopyToDatabase(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, _ := newTestClient(1)
+
+	execute(e, "SET k v")
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "COPY k k DB 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "COPY k k DB 1"))
+
+	executeAs(e, c, "SELECT 1")
+	assert.Equal(t, &resp.BulkString{Value: []byte("v")}, executeAs(e, c, "GET k"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("v")}, execute(e, "GET k"))
+	assert.Equal(t, errSameObject, executeAs(e, c, "COPY k k DB 1"))
+}
+
+func TestSwapDB(t *testing.T) {
+	e := NewExecutor(newDatabases(3)...)
+	c, _ := newTestClient(1)
+
+	execute(e, "SET k 0")
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "SET k 1")
+
+	assert.Equal(t, ok, execute(e, "SWAPDB 0 1"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1")}, execute(e, "GET k"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("0")}, executeAs(e, c, "GET k"))
+	assert.Equal(t, ok, execute(e, "SWAPDB 2 2"))
+
+	assert.Equal(t, errInvalidFirstDB, execute(e, "SWAPDB x 1"))
+	assert.Equal(t, errInvalidSecondDB, execute(e, "SWAPDB 1 x"))
+	assert.Equal(t, errDBOutOfRange, execute(e, "SWAPDB 0 3"))
+}
+
+func TestSwapDBServesBlockedClients(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, _ := newTestClient(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reply := executeAsync(t, e, ctx, "BLPOP k 0")
+
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "RPUSH k x")
+	assert.Equal(t, 1, blockedCount(e))
+
+	execute(e, "SWAPDB 0 1")
+	assert.Equal(t, bulks("k", "x"), receive(t, reply))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS k"))
+}
+
+func TestSwapDBServesChainedBlockedClients(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, _ := newTestClient(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	moved := executeAsync(t, e, ctx, "BLMOVE src dst LEFT RIGHT 0")
+	popped := executeAsync(t, e, ctx, "BLPOP dst 0")
+
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "RPUSH src x")
+
+	// the element moved by the first client is popped by the second
+	execute(e, "SWAPDB 0 1")
+	assert.Equal(t, &resp.BulkString{Value: []byte("x")}, receive(t, moved))
+	assert.Equal(t, bulks("dst", "x"), receive(t, popped))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS dst"))
+}
+
+func TestWatchIsScopedToDatabase(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	a, _ := newTestClient(1)
+	b, _ := newTestClient(2)
+
+	executeAs(e, a, "WATCH k")
+	executeAs(e, b, "SELECT 1")
+	executeAs(e, b, "SET k 1")
+
+	executeAs(e, a, "MULTI")
+	executeAs(e, a, "GET k")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{&resp.BulkString{}}}, executeAs(e, a, "EXEC"))
+
+	executeAs(e, a, "WATCH k")
+	execute(e, "SWAPDB 0 1")
+	executeAs(e, a, "MULTI")
+	executeAs(e, a, "GET k")
+	assert.Equal(t, &resp.Array{}, executeAs(e, a, "EXEC"))
+}
+
+func TestSelectInTransaction(t *testing.T) {
+	e := NewExecutor(newDatabases(2)...)
+	c, _ := newTestClient(1)
+
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "SET k 0")
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "SET k 1")
+	executeAs(e, c, "SWAPDB 0 1")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{ok, ok, ok, ok}}, executeAs(e, c, "EXEC"))
+
+	// the client is left in database 1, which now holds database 0's keys
+	assert.Equal(t, &resp.BulkString{Value: []byte("0")}, executeAs(e, c, "GET k"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1")}, execute(e, "GET k"))
+}
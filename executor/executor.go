@@ -1,57 +1,459 @@
 package executor
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/scnewma/godb/acl"
+	"github.com/scnewma/godb/config"
+	"github.com/scnewma/godb/pubsub"
 	"github.com/scnewma/godb/resp"
 	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/hash"
+	"github.com/scnewma/godb/storage/quicklist"
+	"github.com/scnewma/godb/storage/set"
+	"github.com/scnewma/godb/storage/stream"
+	"github.com/scnewma/godb/storage/zset"
 )
 
 const (
-	GET = "GET"
-	SET = "SET"
-	DEL = "DEL"
+	GET    = "GET"
+	SET    = "SET"
+	DEL    = "DEL"
+	MGET   = "MGET"
+	MSET   = "MSET"
+	MSETNX = "MSETNX"
+	EXISTS = "EXISTS"
+	TOUCH  = "TOUCH"
+	UNLINK = "UNLINK"
+	TYPE   = "TYPE"
 )
 
 // var genericErrorMessage = resp.NewErrorMessage("something went wrong")
-var genericErrorMessage = &resp.Error{"something went wrong"}
+var genericErrorMessage = &resp.Error{Value: "something went wrong"}
+
+var errWrongType = &resp.Error{Value: "WRONGTYPE Operation against a key holding the wrong kind of value"}
 
 type Command struct {
 	Name string
 	Args [][]byte
+
+	// Client is the client that sent the command, if any.
+	Client *Client
 }
 
 type Executor interface {
-	Execute(command Command) resp.Message
+	// Execute runs command and returns its reply. ctx is used by commands
+	// that block until another client writes to a key, which give up once
+	// ctx is done.
+	Execute(ctx context.Context, command Command) resp.Message
 }
 
 type compositeExecutor struct {
-	executorLookup map[string]executorFunc
+	executorLookup  map[string]executorFunc
+	blockingLookup  map[string]blockingFunc
+	clientLookup    map[string]clientFunc
+	exclusiveLookup map[string]clientFunc
+
+	// dbs are the logical databases, numbered by their index. dbsMu is held
+	// for reading by the commands that run against a single database, and
+	// for writing by the ones in exclusiveLookup, which span databases, and
+	// by EXEC.
+	dbsMu sync.RWMutex
+	dbs   []storage.Storage
+
+	// blocked holds the clients blocked on the keys of each database.
+	blocked []*blockingRegistry
+	watches *watchRegistry
+
+	hub      *pubsub.Hub
+	shardHub *pubsub.Hub
 
-	db storage.Storage
+	acl *acl.ACL
+
+	// server is the server receiving the commands, if any, started is when
+	// the executor was created and stats counts the commands run, all
+	// reported by INFO.
+	server  Server
+	started time.Time
+	stats   *executorStats
+
+	config *config.Config
+	// maxMemory is the maxmemory parameter, read by every write command.
+	maxMemory atomic.Int64
+
+	// slowLog holds the commands that ran for longer than
+	// slowLogThreshold, in microseconds.
+	slowLog          *slowLog
+	slowLogThreshold atomic.Int64
+
+	monitors *monitorRegistry
 }
 
-func NewExecutor(db storage.Storage) *compositeExecutor {
-	return &compositeExecutor{
+// NewExecutor returns an executor running commands against the given
+// databases, which clients select by their index. Clients start out in
+// database 0.
+func NewExecutor(dbs ...storage.Storage) *compositeExecutor {
+	ce := &compositeExecutor{
 		executorLookup: map[string]executorFunc{
-			GET: executorFunc(executeGet),
-			SET: executorFunc(executeSet),
-			DEL: executorFunc(executeDel),
+			GET:    executorFunc(executeGet),
+			SET:    executorFunc(executeSet),
+			DEL:    executorFunc(executeDel),
+			MGET:   executorFunc(executeMGet),
+			MSET:   executorFunc(executeMSet),
+			MSETNX: executorFunc(executeMSetNX),
+			EXISTS: executorFunc(executeExists),
+			TOUCH:  executorFunc(executeTouch),
+			UNLINK: executorFunc(executeUnlink),
+			TYPE:   executorFunc(executeType),
+
+			INCR:        executorFunc(executeIncr),
+			DECR:        executorFunc(executeDecr),
+			INCRBY:      executorFunc(executeIncrBy),
+			DECRBY:      executorFunc(executeDecrBy),
+			INCRBYFLOAT: executorFunc(executeIncrByFloat),
+			APPEND:      executorFunc(executeAppend),
+			STRLEN:      executorFunc(executeStrlen),
+			GETRANGE:    executorFunc(executeGetRange),
+			SETRANGE:    executorFunc(executeSetRange),
+			LCS:         executorFunc(executeLCS),
+
+			SETBIT:      executorFunc(executeSetBit),
+			GETBIT:      executorFunc(executeGetBit),
+			BITCOUNT:    executorFunc(executeBitCount),
+			BITPOS:      executorFunc(executeBitPos),
+			BITOP:       executorFunc(executeBitOp),
+			BITFIELD:    executorFunc(executeBitfield),
+			BITFIELD_RO: executorFunc(executeBitfieldRO),
+
+			LPUSH:   executorFunc(executeLPush),
+			RPUSH:   executorFunc(executeRPush),
+			LPUSHX:  executorFunc(executeLPushX),
+			RPUSHX:  executorFunc(executeRPushX),
+			LPOP:    executorFunc(executeLPop),
+			RPOP:    executorFunc(executeRPop),
+			LRANGE:  executorFunc(executeLRange),
+			LINDEX:  executorFunc(executeLIndex),
+			LSET:    executorFunc(executeLSet),
+			LINSERT: executorFunc(executeLInsert),
+			LREM:    executorFunc(executeLRem),
+			LTRIM:   executorFunc(executeLTrim),
+			LLEN:    executorFunc(executeLLen),
+			LPOS:    executorFunc(executeLPos),
+			LMOVE:   executorFunc(executeLMove),
+			LMPOP:   executorFunc(executeLMPop),
+
+			HSET:         executorFunc(executeHSet),
+			HSETNX:       executorFunc(executeHSetNX),
+			HGET:         executorFunc(executeHGet),
+			HMGET:        executorFunc(executeHMGet),
+			HGETALL:      executorFunc(executeHGetAll),
+			HDEL:         executorFunc(executeHDel),
+			HEXISTS:      executorFunc(executeHExists),
+			HLEN:         executorFunc(executeHLen),
+			HKEYS:        executorFunc(executeHKeys),
+			HVALS:        executorFunc(executeHVals),
+			HINCRBY:      executorFunc(executeHIncrBy),
+			HINCRBYFLOAT: executorFunc(executeHIncrByFloat),
+			HSTRLEN:      executorFunc(executeHStrlen),
+			HRANDFIELD:   executorFunc(executeHRandField),
+			HSCAN:        executorFunc(executeHScan),
+			HEXPIRE:      executorFunc(executeHExpire),
+			HPEXPIRE:     executorFunc(executeHPExpire),
+			HEXPIREAT:    executorFunc(executeHExpireAt),
+			HTTL:         executorFunc(executeHTTL),
+			HPTTL:        executorFunc(executeHPTTL),
+			HPERSIST:     executorFunc(executeHPersist),
+
+			SADD:        executorFunc(executeSAdd),
+			SREM:        executorFunc(executeSRem),
+			SISMEMBER:   executorFunc(executeSIsMember),
+			SMISMEMBER:  executorFunc(executeSMIsMember),
+			SMEMBERS:    executorFunc(executeSMembers),
+			SCARD:       executorFunc(executeSCard),
+			SPOP:        executorFunc(executeSPop),
+			SRANDMEMBER: executorFunc(executeSRandMember),
+			SMOVE:       executorFunc(executeSMove),
+			SINTER:      executorFunc(executeSInter),
+			SINTERSTORE: executorFunc(executeSInterStore),
+			SINTERCARD:  executorFunc(executeSInterCard),
+			SUNION:      executorFunc(executeSUnion),
+			SUNIONSTORE: executorFunc(executeSUnionStore),
+			SDIFF:       executorFunc(executeSDiff),
+			SDIFFSTORE:  executorFunc(executeSDiffStore),
+			SSCAN:       executorFunc(executeSScan),
+
+			ZADD:        executorFunc(executeZAdd),
+			ZREM:        executorFunc(executeZRem),
+			ZSCORE:      executorFunc(executeZScore),
+			ZMSCORE:     executorFunc(executeZMScore),
+			ZINCRBY:     executorFunc(executeZIncrBy),
+			ZCARD:       executorFunc(executeZCard),
+			ZCOUNT:      executorFunc(executeZCount),
+			ZRANK:       executorFunc(executeZRank),
+			ZREVRANK:    executorFunc(executeZRevRank),
+			ZRANGE:      executorFunc(executeZRange),
+			ZRANGESTORE: executorFunc(executeZRangeStore),
+			ZPOPMIN:     executorFunc(executeZPopMin),
+			ZPOPMAX:     executorFunc(executeZPopMax),
+			ZUNIONSTORE: executorFunc(executeZUnionStore),
+			ZINTERSTORE: executorFunc(executeZInterStore),
+			ZDIFFSTORE:  executorFunc(executeZDiffStore),
+			ZSCAN:       executorFunc(executeZScan),
+
+			XADD:       executorFunc(executeXAdd),
+			XRANGE:     executorFunc(executeXRange),
+			XREVRANGE:  executorFunc(executeXRevRange),
+			XLEN:       executorFunc(executeXLen),
+			XDEL:       executorFunc(executeXDel),
+			XTRIM:      executorFunc(executeXTrim),
+			XINFO:      executorFunc(executeXInfo),
+			XGROUP:     executorFunc(executeXGroup),
+			XACK:       executorFunc(executeXAck),
+			XPENDING:   executorFunc(executeXPending),
+			XCLAIM:     executorFunc(executeXClaim),
+			XAUTOCLAIM: executorFunc(executeXAutoClaim),
+
+			PFADD:   executorFunc(executePFAdd),
+			PFCOUNT: executorFunc(executePFCount),
+			PFMERGE: executorFunc(executePFMerge),
+
+			GEOADD:         executorFunc(executeGeoAdd),
+			GEOPOS:         executorFunc(executeGeoPos),
+			GEODIST:        executorFunc(executeGeoDist),
+			GEOHASH:        executorFunc(executeGeoHash),
+			GEOSEARCH:      executorFunc(executeGeoSearch),
+			GEOSEARCHSTORE: executorFunc(executeGeoSearchStore),
+
+			KEYS:      executorFunc(executeKeys),
+			SCAN:      executorFunc(executeScan),
+			RENAME:    executorFunc(executeRename),
+			RENAMENX:  executorFunc(executeRenameNX),
+			RANDOMKEY: executorFunc(executeRandomKey),
+			DBSIZE:    executorFunc(executeDBSize),
+			FLUSHDB:   executorFunc(executeFlushDB),
+			OBJECT:    executorFunc(executeObject),
 		},
-		db: db,
+		blockingLookup: map[string]blockingFunc{
+			BLPOP:      blockingFunc(executeBLPop),
+			BRPOP:      blockingFunc(executeBRPop),
+			BLMOVE:     blockingFunc(executeBLMove),
+			BLMPOP:     blockingFunc(executeBLMPop),
+			XREAD:      blockingFunc(executeXRead),
+			XREADGROUP: blockingFunc(executeXReadGroup),
+		},
+		dbs:      dbs,
+		blocked:  make([]*blockingRegistry, len(dbs)),
+		watches:  newWatchRegistry(),
+		hub:      pubsub.New(),
+		shardHub: pubsub.New(),
+		acl:      acl.New(),
+		started:  time.Now(),
+		stats:    newExecutorStats(),
+		slowLog:  &slowLog{},
+		monitors: newMonitorRegistry(),
+	}
+
+	for i := range ce.blocked {
+		ce.blocked[i] = newBlockingRegistry()
+	}
+	ce.notifyExpired()
+	ce.SetConfig(config.New(config.Params...))
+
+	ce.clientLookup = map[string]clientFunc{
+		SUBSCRIBE:    ce.executeSubscribe,
+		UNSUBSCRIBE:  ce.executeUnsubscribe,
+		PSUBSCRIBE:   ce.executePSubscribe,
+		PUNSUBSCRIBE: ce.executePUnsubscribe,
+		SSUBSCRIBE:   ce.executeSSubscribe,
+		SUNSUBSCRIBE: ce.executeSUnsubscribe,
+		PUBLISH:      ce.executePublish,
+		SPUBLISH:     ce.executeSPublish,
+		PUBSUB:       ce.executePubSub,
+
+		MULTI:   ce.executeMulti,
+		EXEC:    ce.executeExec,
+		DISCARD: ce.executeDiscard,
+		WATCH:   ce.executeWatch,
+		UNWATCH: ce.executeUnwatch,
+
+		SELECT: ce.executeSelect,
+
+		AUTH: ce.executeAuth,
+		ACL:  ce.executeACL,
+
+		PING:  executePing,
+		ECHO:  executeEcho,
+		QUIT:  executeQuit,
+		RESET: ce.executeReset,
+		HELLO: ce.executeHello,
+
+		CONFIG:  ce.executeConfig,
+		SLOWLOG: ce.executeSlowLog,
+		MONITOR: ce.executeMonitor,
+	}
+
+	ce.exclusiveLookup = map[string]clientFunc{
+		SWAPDB:   ce.executeSwapDB,
+		MOVE:     ce.executeMove,
+		COPY:     ce.executeCopy,
+		FLUSHALL: ce.executeFlushAll,
+		INFO:     ce.executeInfo,
 	}
+
+	return ce
 }
 
-func (ce *compositeExecutor) Execute(command Command) resp.Message {
+// Execute runs the command atomically with respect to every other command
+// executed against the same storage.
+func (ce *compositeExecutor) Execute(ctx context.Context, command Command) resp.Message {
 	commandName := strings.ToUpper(command.Name)
-	executorFunc, ok := ce.executorLookup[commandName]
-	if !ok {
-		return &resp.Error{"unknown command"}
+	c := command.Client
+	if errMsg := ce.authorize(c, commandName, command.Args); errMsg != nil {
+		if c.multi {
+			c.multiFailed = true
+		}
+		ce.stats.reject(commandName, errMsg)
+		return errMsg
+	}
+
+	if c != nil && c.multi && !transactionCommands[commandName] {
+		msg := ce.queue(c, commandName, command)
+		if errMsg, failed := msg.(*resp.Error); failed {
+			ce.stats.reject(commandName, errMsg)
+		}
+		return msg
 	}
 
-	return executorFunc(command.Args, ce.db)
+	executorFunc, isExecutor := ce.executorLookup[commandName]
+	blockingFunc, isBlocking := ce.blockingLookup[commandName]
+	clientFunc, isClient := ce.clientLookup[commandName]
+	exclusiveFunc, isExclusive := ce.exclusiveLookup[commandName]
+	if !isExecutor && !isBlocking && !isClient && !isExclusive {
+		errMsg := &resp.Error{Value: "unknown command"}
+		ce.stats.reject(commandName, errMsg)
+		return errMsg
+	}
+
+	if c != nil && c.subscribed() && !subscriberCommands[commandName] {
+		errMsg := subscriberModeError(command.Name)
+		ce.stats.reject(commandName, errMsg)
+		return errMsg
+	}
+
+	if ce.outOfMemory(commandName) {
+		ce.stats.reject(commandName, errOOM)
+		return errOOM
+	}
+
+	ce.feedMonitors(c, c.selected(), commandName, command.Args)
+
+	// blocking commands are timed including the time they spend blocked
+	start := time.Now()
+	var msg resp.Message
+	switch {
+	case isClient:
+		msg = clientFunc(c, command.Args)
+	case isExclusive:
+		ce.exclusively(func() {
+			msg = exclusiveFunc(c, command.Args)
+		})
+	case isBlocking:
+		msg = ce.executeBlocking(ctx, c.selected(), commandName, blockingFunc, command.Args)
+	default:
+		msg = ce.executeKeyspace(c.selected(), commandName, executorFunc, command.Args)
+	}
+	d := time.Since(start)
+	ce.stats.record(commandName, d, msg)
+	// blocking commands are slow by waiting, not by running
+	if !isBlocking {
+		ce.logSlow(c, commandName, command.Args, d)
+	}
+
+	return msg
+}
+
+// executeKeyspace runs a command against the database at index.
+func (ce *compositeExecutor) executeKeyspace(index int, name string, executorFunc executorFunc, args [][]byte) resp.Message {
+	ce.dbsMu.RLock()
+	defer ce.dbsMu.RUnlock()
+
+	var msg resp.Message
+	ce.atomically(index, func(tx storage.Storage) {
+		msg = executorFunc(args, tx)
+		ce.touchWritten(index, name, args, msg)
+	})
+
+	return msg
+}
+
+// executeBlocking runs a command that may need to wait for other clients.
+// The command is registered as blocked in the same atomic step that found
+// it couldn't be served, so no write can slip in between.
+func (ce *compositeExecutor) executeBlocking(ctx context.Context, index int, name string, blockingFunc blockingFunc, args [][]byte) resp.Message {
+	blocked := ce.blocked[index]
+
+	var (
+		msg resp.Message
+		w   *waiter
+	)
+	ce.dbsMu.RLock()
+	ce.atomically(index, func(tx storage.Storage) {
+		var spec *blockSpec
+		msg, spec = blockingFunc(args, tx)
+		if spec != nil {
+			w = blocked.block(spec)
+			return
+		}
+
+		ce.touchWritten(index, name, args, msg)
+	})
+	ce.dbsMu.RUnlock()
+
+	if w == nil {
+		return msg
+	}
+
+	return blocked.wait(ctx, w)
+}
+
+// exclusively calls fn with no other command running.
+func (ce *compositeExecutor) exclusively(fn func()) {
+	ce.dbsMu.Lock()
+	defer ce.dbsMu.Unlock()
+
+	fn()
+}
+
+// atomically calls fn with exclusive access to the database at index and
+// then, before releasing it, serves the clients blocked on any keys fn made
+// ready. dbsMu must be held.
+func (ce *compositeExecutor) atomically(index int, fn func(tx storage.Storage)) {
+	blocked := ce.blocked[index]
+	ce.dbs[index].Atomic(func(tx storage.Storage) {
+		// clients only block while the storage is locked, so if nobody is
+		// blocked now nobody can be made ready by fn
+		if blocked.empty() {
+			fn(tx)
+			return
+		}
+
+		tracker := &keyTracker{Storage: tx}
+		fn(tracker)
+
+		// serving a client can make further keys ready, e.g. BLMOVE
+		// pushing to a list another client is blocked on
+		for keys := tracker.flush(); len(keys) > 0; keys = tracker.flush() {
+			ce.watches.touch(index, keys)
+			blocked.serve(tracker, keys)
+		}
+	})
 }
 
 type executorFunc func(args [][]byte, db storage.Storage) resp.Message
@@ -79,6 +481,22 @@ func (e *argExtractor) ExtractAt(idx int) []byte {
 	return e.args[idx]
 }
 
+// ExtractStringsFrom returns every argument starting at idx. At least one
+// argument must be present.
+func (e *argExtractor) ExtractStringsFrom(idx int) []string {
+	if idx >= len(e.args) {
+		e.err = multierror.Append(e.err, errors.New("not enough arguments"))
+		return nil
+	}
+
+	strs := make([]string, 0, len(e.args)-idx)
+	for _, arg := range e.args[idx:] {
+		strs = append(strs, string(arg))
+	}
+
+	return strs
+}
+
 func (e *argExtractor) Err() error {
 	return e.err
 }
@@ -87,11 +505,19 @@ func (e *argExtractor) Error() string {
 	return e.err.Error()
 }
 
+func wrongNumberOfArgs(command string) *resp.Error {
+	return &resp.Error{Value: fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command))}
+}
+
+func unknownSubcommand(command string, subcommand []byte) *resp.Error {
+	return &resp.Error{Value: fmt.Sprintf("ERR unknown subcommand '%s'. Try %s HELP.", subcommand, strings.ToUpper(command))}
+}
+
 func executeGet(args [][]byte, db storage.Storage) resp.Message {
 	ae := newArgExtractor(args)
 	key := ae.ExtractStringAt(0)
 	if ae.Err() != nil {
-		return &resp.Error{ae.Error()}
+		return &resp.Error{Value: ae.Error()}
 	}
 
 	node, err := db.Get(key)
@@ -103,7 +529,12 @@ func executeGet(args [][]byte, db storage.Storage) resp.Message {
 		return genericErrorMessage
 	}
 
-	return &resp.BulkString{node.Value().([]byte)}
+	val, ok := stringValue(node)
+	if !ok {
+		return errWrongType
+	}
+
+	return bulkCopy(val)
 }
 
 func executeSet(args [][]byte, db storage.Storage) resp.Message {
@@ -112,22 +543,175 @@ func executeSet(args [][]byte, db storage.Storage) resp.Message {
 	val := ae.ExtractAt(1)
 
 	if ae.Err() != nil {
-		return &resp.Error{ae.Error()}
+		return &resp.Error{Value: ae.Error()}
 	}
 
-	db.Set(key, storage.NewNode(val))
+	db.Set(key, newStringNode(val))
 
-	return &resp.SimpleString{"OK"}
+	return &resp.SimpleString{Value: "OK"}
 }
 
 func executeDel(args [][]byte, db storage.Storage) resp.Message {
+	return deleteKeys(DEL, args, db)
+}
+
+// executeUnlink behaves exactly like DEL. Redis reclaims the memory of
+// unlinked values in a background thread; here the garbage collector already
+// does that for us.
+func executeUnlink(args [][]byte, db storage.Storage) resp.Message {
+	return deleteKeys(UNLINK, args, db)
+}
+
+// deleteKeys deletes the keys among args for the command called name and
+// replies with how many existed.
+func deleteKeys(name string, args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	keys := ae.ExtractStringsFrom(0)
+	if ae.Err() != nil {
+		return wrongNumberOfArgs(name)
+	}
+
+	var delCount int
+	for _, key := range keys {
+		delCount += db.Del(key)
+	}
+
+	return &resp.Int{Value: int64(delCount)}
+}
+
+func executeMGet(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	keys := ae.ExtractStringsFrom(0)
+	if ae.Err() != nil {
+		return wrongNumberOfArgs(MGET)
+	}
+
+	vals := make([]resp.Message, 0, len(keys))
+	for _, key := range keys {
+		node, err := db.Get(key)
+		if err != nil {
+			vals = append(vals, &resp.BulkString{})
+			continue
+		}
+
+		// keys holding non-string values are reported as missing
+		val, ok := stringValue(node)
+		if !ok {
+			vals = append(vals, &resp.BulkString{})
+			continue
+		}
+
+		vals = append(vals, bulkCopy(val))
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+func executeMSet(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return wrongNumberOfArgs(MSET)
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		db.Set(string(args[i]), newStringNode(args[i+1]))
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// executeMSetNX sets every given key only if none of them exist yet.
+func executeMSetNX(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return wrongNumberOfArgs(MSETNX)
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if _, err := db.Get(string(args[i])); err == nil {
+			return &resp.Int{Value: 0}
+		}
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		db.Set(string(args[i]), newStringNode(args[i+1]))
+	}
+
+	return &resp.Int{Value: 1}
+}
+
+// executeExists counts how many of the given keys exist. A key that is
+// repeated is counted once per occurrence.
+func executeExists(args [][]byte, db storage.Storage) resp.Message {
+	return countExisting(EXISTS, args, func(key string) bool {
+		// unlike TOUCH, EXISTS doesn't count as an access to the key
+		_, err := db.Idle(key)
+		return err == nil
+	})
+}
+
+// executeTouch reports how many of the given keys exist, counting as an
+// access to them.
+func executeTouch(args [][]byte, db storage.Storage) resp.Message {
+	return countExisting(TOUCH, args, func(key string) bool {
+		_, err := db.Get(key)
+		return err == nil
+	})
+}
+
+// countExisting counts the keys among args for which exists reports true,
+// for the command called name.
+func countExisting(name string, args [][]byte, exists func(key string) bool) resp.Message {
+	ae := newArgExtractor(args)
+	keys := ae.ExtractStringsFrom(0)
+	if ae.Err() != nil {
+		return wrongNumberOfArgs(name)
+	}
+
+	var count int64
+	for _, key := range keys {
+		if exists(key) {
+			count++
+		}
+	}
+
+	return &resp.Int{Value: count}
+}
+
+// typeName returns the name of the type of the value held by node, as
+// reported by TYPE.
+func typeName(node storage.Node) string {
+	switch node.Value().(type) {
+	case []byte, int64:
+		return "string"
+	case *quicklist.List:
+		return "list"
+	case *hash.Hash:
+		return "hash"
+	case *set.Set:
+		return "set"
+	case *zset.ZSet:
+		return "zset"
+	case *stream.Stream:
+		return "stream"
+	default:
+		return "none"
+	}
+}
+
+func executeType(args [][]byte, db storage.Storage) resp.Message {
 	ae := newArgExtractor(args)
 	key := ae.ExtractStringAt(0)
 	if ae.Err() != nil {
-		return &resp.Error{ae.Error()}
+		return &resp.Error{Value: ae.Error()}
 	}
 
-	delCount := db.Del(key)
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return &resp.SimpleString{Value: "none"}
+		}
+
+		return genericErrorMessage
+	}
 
-	return &resp.Int{int64(delCount)}
+	return &resp.SimpleString{Value: typeName(node)}
 }
@@ -1,11 +1,13 @@
 package executor
 
 import (
+	"context"
 	"strings"
 	"testing"
 
 	"github.com/scnewma/godb/resp"
 	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/inmem"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +27,7 @@ func TestGet(t *testing.T) {
 	msg := executeGet(args, db)
 
 	assert.True(called)
-	assert.Equal(&resp.BulkString{[]byte("value")}, msg)
+	assert.Equal(&resp.BulkString{Value: []byte("value")}, msg)
 }
 
 func TestGetNotFound(t *testing.T) {
@@ -78,7 +80,7 @@ func TestSet(t *testing.T) {
 	msg := executeSet(args, db)
 
 	assert.True(called)
-	assert.Equal(&resp.SimpleString{"OK"}, msg)
+	assert.Equal(&resp.SimpleString{Value: "OK"}, msg)
 }
 
 func TestSetNoKey(t *testing.T) {
@@ -127,7 +129,7 @@ func TestDel(t *testing.T) {
 	msg := executeDel(args, db)
 
 	assert.True(called)
-	assert.Equal(&resp.Int{1}, msg)
+	assert.Equal(&resp.Int{Value: 1}, msg)
 }
 
 func TestDelNoKey(t *testing.T) {
@@ -140,10 +142,8 @@ func TestDelNoKey(t *testing.T) {
 	}
 	msg := executeDel([][]byte{}, db)
 
-	err, ok := msg.(*resp.Error)
-	assert.True(ok)
-
-	assert.True(strings.Contains(err.Value, "not enough arguments"))
+	assert.Equal(wrongNumberOfArgs(DEL), msg)
+	assert.Equal(wrongNumberOfArgs(UNLINK), executeUnlink([][]byte{}, db))
 }
 
 func asArgs(argStrs ...string) [][]byte {
@@ -153,3 +153,93 @@ func asArgs(argStrs ...string) [][]byte {
 	}
 	return args
 }
+
+func TestDelMultipleKeys(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET a 1 b 2")
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "DEL a b c"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS a b"))
+}
+
+func TestUnlink(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET a 1 b 2")
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "UNLINK a"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "EXISTS a b"))
+}
+
+func TestMGet(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a 1")
+	execute(e, "SET c 3")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("1")},
+		&resp.BulkString{},
+		&resp.BulkString{Value: []byte("3")},
+	}}, execute(e, "MGET a b c"))
+}
+
+func TestMGetNoKeys(t *testing.T) {
+	msg := executeMGet([][]byte{}, &storage.MockStorage{})
+
+	assert.Equal(t, wrongNumberOfArgs(MGET), msg)
+	assert.Equal(t, wrongNumberOfArgs(EXISTS), executeExists([][]byte{}, &storage.MockStorage{}))
+	assert.Equal(t, wrongNumberOfArgs(TOUCH), executeTouch([][]byte{}, &storage.MockStorage{}))
+}
+
+func TestMSet(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "MSET a 1 b 2"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("2")}, execute(e, "GET b"))
+}
+
+func TestMSetOddArgs(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Error{Value: "ERR wrong number of arguments for 'mset' command"}, execute(e, "MSET a 1 b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS a"))
+}
+
+func TestMSetNX(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "MSETNX a 1 b 2"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "MSETNX b 3 c 4"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("2")}, execute(e, "GET b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS c"))
+}
+
+func TestExistsCountsDuplicates(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a 1")
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "EXISTS a a b"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "TOUCH a b"))
+}
+
+func TestExecuteIsAtomic(t *testing.T) {
+	atomic := false
+	db := &storage.MockStorage{
+		AtomicFn: func(fn func(storage.Storage)) {
+			atomic = true
+			fn(&storage.MockStorage{
+				GetFn: func(key string) (storage.Node, error) {
+					return storage.NewStringNode("value"), nil
+				},
+			})
+		},
+	}
+	e := NewExecutor(db)
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("value")}, execute(e, "GET a"))
+	assert.True(t, atomic)
+}
+
+func execute(e Executor, command string) resp.Message {
+	parts := strings.Split(command, " ")
+	return e.Execute(context.Background(), Command{Name: parts[0], Args: asArgs(parts[1:]...)})
+}
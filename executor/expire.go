@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/scnewma/godb/storage"
+)
+
+// ExpireEvery runs an expire cycle on every database each interval until the
+// returned function is called. Keys whose values lose elements to expiry,
+// such as hash fields, count as modified for the clients watching them.
+func (ce *compositeExecutor) ExpireEvery(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				ce.deleteExpired(now)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// deleteExpired runs an expire cycle on every database that supports one.
+// dbsMu is held for each database in turn rather than for the whole cycle,
+// which would hold up commands spanning databases.
+func (ce *compositeExecutor) deleteExpired(now time.Time) {
+	for i := range ce.dbs {
+		ce.dbsMu.RLock()
+		if expirer, ok := ce.dbs[i].(storage.Expirer); ok {
+			expirer.DeleteExpired(now)
+		}
+		ce.dbsMu.RUnlock()
+	}
+}
+
+// notifyExpired makes the databases report the keys losing elements to
+// expiry, which are then touched for the clients watching them.
+func (ce *compositeExecutor) notifyExpired() {
+	for _, db := range ce.dbs {
+		expirer, ok := db.(storage.Expirer)
+		if !ok {
+			continue
+		}
+
+		db := db
+		expirer.NotifyExpired(func(key string) {
+			ce.touchExpired(db, key)
+		})
+	}
+}
+
+// touchExpired touches key of db for the clients watching it. Databases are
+// watched by their index, which SWAPDB changes, so it is looked up each time.
+// dbsMu must be held, as it is by everything reading the databases.
+func (ce *compositeExecutor) touchExpired(db storage.Storage, key string) {
+	for index := range ce.dbs {
+		if ce.dbs[index] == db {
+			ce.watches.touch(index, []string{key})
+			return
+		}
+	}
+}
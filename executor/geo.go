@@ -0,0 +1,496 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scnewma/godb/geohash"
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/zset"
+)
+
+const (
+	GEOADD         = "GEOADD"
+	GEOPOS         = "GEOPOS"
+	GEODIST        = "GEODIST"
+	GEOHASH        = "GEOHASH"
+	GEOSEARCH      = "GEOSEARCH"
+	GEOSEARCHSTORE = "GEOSEARCHSTORE"
+)
+
+var (
+	errGeoUnit            = &resp.Error{Value: "ERR unsupported unit provided. please use M, KM, FT, MI"}
+	errGeoMember          = &resp.Error{Value: "ERR could not decode requested zset member"}
+	errGeoRadius          = &resp.Error{Value: "ERR radius cannot be negative"}
+	errGeoBox             = &resp.Error{Value: "ERR height or width cannot be negative"}
+	errGeoAnyCount        = &resp.Error{Value: "ERR the ANY argument requires COUNT argument"}
+	errGeoSearchFrom      = &resp.Error{Value: "ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH"}
+	errGeoSearchBy        = &resp.Error{Value: "ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH"}
+	errGeoSearchStoreWith = &resp.Error{Value: "ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options"}
+)
+
+func invalidLocation(lon, lat float64) *resp.Error {
+	return &resp.Error{Value: fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lon, lat)}
+}
+
+// parseGeoUnit returns the number of meters in the given unit.
+func parseGeoUnit(b []byte) (float64, resp.Message) {
+	switch strings.ToLower(string(b)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	default:
+		return 0, errGeoUnit
+	}
+}
+
+// parseLocation parses a longitude and latitude, checking that they can be
+// encoded.
+func parseLocation(rawLon, rawLat []byte) (lon, lat float64, errMsg resp.Message) {
+	lon, ok := parseFloat(rawLon)
+	if !ok {
+		return 0, 0, errNotFloat
+	}
+	lat, ok = parseFloat(rawLat)
+	if !ok {
+		return 0, 0, errNotFloat
+	}
+
+	if !geohash.Valid(lon, lat) {
+		return 0, 0, invalidLocation(lon, lat)
+	}
+
+	return lon, lat, nil
+}
+
+// formatCoordinate formats a coordinate the way Redis replies with it: with
+// 17 decimals, leaving out trailing zeros.
+func formatCoordinate(v float64) []byte {
+	s := strconv.FormatFloat(v, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+
+	return []byte(s)
+}
+
+func coordinatesReply(lon, lat float64) resp.Message {
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: formatCoordinate(lon)},
+		&resp.BulkString{Value: formatCoordinate(lat)},
+	}}
+}
+
+func distanceReply(meters, unit float64) resp.Message {
+	return &resp.BulkString{Value: []byte(fmt.Sprintf("%.4f", meters/unit))}
+}
+
+// executeGeoAdd adds locations to a sorted set by rewriting the command as a
+// ZADD of their geohashes.
+func executeGeoAdd(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 4 {
+		return wrongNumberOfArgs(GEOADD)
+	}
+
+	zaddArgs := [][]byte{args[0]}
+	i := 1
+	var nx, xx bool
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "CH":
+		default:
+			break options
+		}
+		zaddArgs = append(zaddArgs, args[i])
+	}
+
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 || (nx && xx) {
+		return errSyntax
+	}
+
+	for j := 0; j < len(triples); j += 3 {
+		lon, lat, errMsg := parseLocation(triples[j], triples[j+1])
+		if errMsg != nil {
+			return errMsg
+		}
+
+		score, _ := geohash.Score(lon, lat)
+		zaddArgs = append(zaddArgs, formatFloat(score), triples[j+2])
+	}
+
+	return executeZAdd(zaddArgs, db)
+}
+
+func executeGeoPos(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	reply := make([]resp.Message, 0, len(args)-1)
+	for _, member := range args[1:] {
+		var score float64
+		exists := false
+		if z != nil {
+			score, exists = z.Score(string(member))
+		}
+
+		if !exists {
+			reply = append(reply, &resp.Array{})
+			continue
+		}
+
+		reply = append(reply, coordinatesReply(geohash.Location(score)))
+	}
+
+	return &resp.Array{Value: reply}
+}
+
+func executeGeoDist(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 3 {
+		return wrongNumberOfArgs(GEODIST)
+	}
+	if len(args) > 4 {
+		return errSyntax
+	}
+
+	unit := 1.0
+	if len(args) == 4 {
+		var errMsg resp.Message
+		if unit, errMsg = parseGeoUnit(args[3]); errMsg != nil {
+			return errMsg
+		}
+	}
+
+	z, errMsg := getZSet(db, string(args[0]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return &resp.BulkString{}
+	}
+
+	score1, ok1 := z.Score(string(args[1]))
+	score2, ok2 := z.Score(string(args[2]))
+	if !ok1 || !ok2 {
+		return &resp.BulkString{}
+	}
+
+	lon1, lat1 := geohash.Location(score1)
+	lon2, lat2 := geohash.Location(score2)
+
+	return distanceReply(geohash.Distance(lon1, lat1, lon2, lat2), unit)
+}
+
+func executeGeoHash(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	reply := make([]resp.Message, 0, len(args)-1)
+	for _, member := range args[1:] {
+		var score float64
+		exists := false
+		if z != nil {
+			score, exists = z.Score(string(member))
+		}
+
+		if !exists {
+			reply = append(reply, &resp.BulkString{})
+			continue
+		}
+
+		reply = append(reply, &resp.BulkString{Value: []byte(geohash.String(geohash.Location(score)))})
+	}
+
+	return &resp.Array{Value: reply}
+}
+
+// geoSearch holds the options of GEOSEARCH and GEOSEARCHSTORE.
+type geoSearch struct {
+	fromMember []byte
+	fromLonLat bool
+	byRadius   bool
+	byBox      bool
+	shape      geohash.Shape
+	// unit is the number of meters in the unit distances are given in.
+	unit float64
+
+	count     int64
+	any       bool
+	sort      int // 0 for none, 1 ascending, -1 descending
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+// geoLocation is a member found by a search.
+type geoLocation struct {
+	member   string
+	score    float64
+	distance float64
+}
+
+// parseGeoSearch parses the options of GEOSEARCH following the source key,
+// or of GEOSEARCHSTORE if store is set.
+func parseGeoSearch(args [][]byte, store bool) (*geoSearch, resp.Message) {
+	search := &geoSearch{unit: 1}
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "WITHCOORD":
+			search.withCoord = true
+		case opt == "WITHDIST":
+			search.withDist = true
+		case opt == "WITHHASH":
+			search.withHash = true
+		case opt == "ANY":
+			search.any = true
+		case opt == "ASC":
+			search.sort = 1
+		case opt == "DESC":
+			search.sort = -1
+		case opt == "STOREDIST" && store:
+			search.storeDist = true
+		case opt == "COUNT" && remaining >= 1:
+			count, ok := parseInt(args[i+1])
+			if !ok {
+				return nil, errNotInteger
+			}
+			if count <= 0 {
+				return nil, errCountPositive
+			}
+			search.count = count
+			i++
+		case opt == "FROMMEMBER" && remaining >= 1 && search.fromMember == nil:
+			search.fromMember = args[i+1]
+			i++
+		case opt == "FROMLONLAT" && remaining >= 2 && !search.fromLonLat:
+			lon, lat, errMsg := parseLocation(args[i+1], args[i+2])
+			if errMsg != nil {
+				return nil, errMsg
+			}
+			search.shape.Longitude, search.shape.Latitude = lon, lat
+			search.fromLonLat = true
+			i += 2
+		case opt == "BYRADIUS" && remaining >= 2 && !search.byRadius:
+			radius, ok := parseFloat(args[i+1])
+			if !ok {
+				return nil, errNotFloat
+			}
+			if radius < 0 {
+				return nil, errGeoRadius
+			}
+			unit, errMsg := parseGeoUnit(args[i+2])
+			if errMsg != nil {
+				return nil, errMsg
+			}
+			search.shape.Radius = radius * unit
+			search.unit = unit
+			search.byRadius = true
+			i += 2
+		case opt == "BYBOX" && remaining >= 3 && !search.byBox:
+			width, ok := parseFloat(args[i+1])
+			if !ok {
+				return nil, errNotFloat
+			}
+			height, ok := parseFloat(args[i+2])
+			if !ok {
+				return nil, errNotFloat
+			}
+			if width < 0 || height < 0 {
+				return nil, errGeoBox
+			}
+			unit, errMsg := parseGeoUnit(args[i+3])
+			if errMsg != nil {
+				return nil, errMsg
+			}
+			search.shape.Width, search.shape.Height = width*unit, height*unit
+			search.shape.Box = true
+			search.unit = unit
+			search.byBox = true
+			i += 3
+		default:
+			return nil, errSyntax
+		}
+	}
+
+	if store && (search.withCoord || search.withDist || search.withHash) {
+		return nil, errGeoSearchStoreWith
+	}
+	if (search.fromMember != nil) == search.fromLonLat {
+		return nil, errGeoSearchFrom
+	}
+	if search.byRadius == search.byBox {
+		return nil, errGeoSearchBy
+	}
+	if search.any && search.count == 0 {
+		return nil, errGeoAnyCount
+	}
+
+	// the nearest locations are returned when counting without ANY
+	if search.count > 0 && !search.any && search.sort == 0 {
+		search.sort = 1
+	}
+
+	return search, nil
+}
+
+// run finds the locations of z within the search's shape, sorted and
+// limited as requested. The center is looked up in z when searching from a
+// member.
+func (search *geoSearch) run(z *zset.ZSet) ([]geoLocation, resp.Message) {
+	if search.fromMember != nil {
+		score, ok := z.Score(string(search.fromMember))
+		if !ok {
+			return nil, errGeoMember
+		}
+		search.shape.Longitude, search.shape.Latitude = geohash.Location(score)
+	}
+
+	var found []geoLocation
+	full := func() bool {
+		return search.any && int64(len(found)) >= search.count
+	}
+
+	for _, area := range search.shape.Areas() {
+		min, max := area.ScoreRange()
+		r := zset.ScoreRange{Min: min, Max: max, MaxExclusive: true}
+		z.RangeByScore(r, false, func(member string, score float64) bool {
+			distance, ok := search.shape.Contains(geohash.Location(score))
+			if ok {
+				found = append(found, geoLocation{member: member, score: score, distance: distance})
+			}
+			return !full()
+		})
+
+		if full() {
+			break
+		}
+	}
+
+	switch search.sort {
+	case 1:
+		sort.SliceStable(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	case -1:
+		sort.SliceStable(found, func(i, j int) bool { return found[i].distance > found[j].distance })
+	}
+
+	if search.count > 0 && int64(len(found)) > search.count {
+		found = found[:search.count]
+	}
+
+	return found, nil
+}
+
+func executeGeoSearch(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 6 {
+		return wrongNumberOfArgs(GEOSEARCH)
+	}
+
+	search, errMsg := parseGeoSearch(args[1:], false)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	z, errMsg := getZSet(db, string(args[0]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return &resp.Array{Value: []resp.Message{}}
+	}
+
+	found, errMsg := search.run(z)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	reply := make([]resp.Message, 0, len(found))
+	for _, loc := range found {
+		member := &resp.BulkString{Value: []byte(loc.member)}
+		if !search.withDist && !search.withHash && !search.withCoord {
+			reply = append(reply, member)
+			continue
+		}
+
+		item := []resp.Message{member}
+		if search.withDist {
+			item = append(item, distanceReply(loc.distance, search.unit))
+		}
+		if search.withHash {
+			item = append(item, &resp.Int{Value: int64(loc.score)})
+		}
+		if search.withCoord {
+			item = append(item, coordinatesReply(geohash.Location(loc.score)))
+		}
+		reply = append(reply, &resp.Array{Value: item})
+	}
+
+	return &resp.Array{Value: reply}
+}
+
+func executeGeoSearchStore(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 7 {
+		return wrongNumberOfArgs(GEOSEARCHSTORE)
+	}
+
+	dest := string(args[0])
+	search, errMsg := parseGeoSearch(args[2:], true)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	z, errMsg := getZSet(db, string(args[1]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	result := zset.New()
+	if z != nil {
+		found, errMsg := search.run(z)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		for _, loc := range found {
+			score := loc.score
+			if search.storeDist {
+				score = loc.distance / search.unit
+			}
+			result.Add(loc.member, score)
+		}
+	}
+
+	return storeZSet(db, dest, result)
+}
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSicily() Executor {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "GEOADD Sicily 13.361389 38.115556 Palermo 15.087269 37.502669 Catania")
+	return e
+}
+
+func TestGeoAdd(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "GEOADD Sicily 13.361389 38.115556 Palermo 15.087269 37.502669 Catania"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GEOADD Sicily 13.361389 38.115556 Palermo"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "GEOADD Sicily CH 13.4 38.1 Palermo"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GEOADD Sicily XX 13.361389 38.115556 Palermo 13.583333 37.316667 Agrigento"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GEOADD Sicily NX 0 0 Palermo"))
+
+	// locations are sorted set members scored by their geohash
+	execute(e, "GEOADD Sicily 13.361389 38.115556 Palermo")
+	assert.Equal(t, bulks("Palermo", "3479099956230698", "Catania", "3479447370796909"), execute(e, "ZRANGE Sicily 0 -1 WITHSCORES"))
+	assert.Equal(t, &resp.SimpleString{Value: "zset"}, execute(e, "TYPE Sicily"))
+}
+
+func TestGeoAddErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, wrongNumberOfArgs(GEOADD), execute(e, "GEOADD k 1 2"))
+	assert.Equal(t, errSyntax, execute(e, "GEOADD k 1 2 a 3"))
+	assert.Equal(t, errSyntax, execute(e, "GEOADD k NX XX 1 2 a"))
+	assert.Equal(t, errSyntax, execute(e, "GEOADD k GT 1 2 a"))
+	assert.Equal(t, errNotFloat, execute(e, "GEOADD k x 2 a"))
+	assert.Equal(t, &resp.Error{Value: "ERR invalid longitude,latitude pair 181.000000,2.000000"}, execute(e, "GEOADD k 181 2 a"))
+	assert.Equal(t, &resp.Error{Value: "ERR invalid longitude,latitude pair 1.000000,86.000000"}, execute(e, "GEOADD k 1 86 a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS k"))
+
+	execute(e, "SET s v")
+	assert.Equal(t, errWrongType, execute(e, "GEOADD s 1 2 a"))
+}
+
+func TestGeoPos(t *testing.T) {
+	e := newSicily()
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		bulks("13.36138933897018433", "38.11555639549629859"),
+		bulks("15.08726745843887329", "37.50266842333162032"),
+		&resp.Array{},
+	}}, execute(e, "GEOPOS Sicily Palermo Catania NonExisting"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{&resp.Array{}}}, execute(e, "GEOPOS nokey a"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, execute(e, "GEOPOS Sicily"))
+}
+
+func TestGeoDist(t *testing.T) {
+	e := newSicily()
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("166274.1516")}, execute(e, "GEODIST Sicily Palermo Catania"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("166.2742")}, execute(e, "GEODIST Sicily Palermo Catania KM"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("103.3182")}, execute(e, "GEODIST Sicily Palermo Catania mi"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "GEODIST Sicily Foo Bar"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "GEODIST nokey Foo Bar"))
+
+	assert.Equal(t, errGeoUnit, execute(e, "GEODIST Sicily Palermo Catania yd"))
+	assert.Equal(t, errSyntax, execute(e, "GEODIST Sicily Palermo Catania km m"))
+	assert.Equal(t, wrongNumberOfArgs(GEODIST), execute(e, "GEODIST Sicily Palermo"))
+}
+
+func TestGeoHash(t *testing.T) {
+	e := newSicily()
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("sqc8b49rny0")},
+		&resp.BulkString{Value: []byte("sqdtr74hyu0")},
+		&resp.BulkString{},
+	}}, execute(e, "GEOHASH Sicily Palermo Catania NonExisting"))
+}
+
+func TestGeoSearch(t *testing.T) {
+	e := newSicily()
+	execute(e, "GEOADD Sicily 12.758489 38.788135 edge1 17.241510 38.788135 edge2")
+
+	assert.Equal(t, bulks("Catania", "Palermo"), execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km ASC"))
+	assert.Equal(t, bulks("Palermo", "Catania"), execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km DESC"))
+	assert.Equal(t, bulks("Catania"), execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 100 km"))
+	assert.Equal(t, bulks("Catania", "Palermo", "edge2", "edge1"), execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("Catania")},
+			&resp.BulkString{Value: []byte("56.4413")},
+			&resp.Int{Value: 3479447370796909},
+			bulks("15.08726745843887329", "37.50266842333162032"),
+		}},
+	}}, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km COUNT 1 WITHCOORD WITHHASH WITHDIST"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("Palermo")},
+			&resp.BulkString{Value: []byte("0.0000")},
+		}},
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("Catania")},
+			&resp.BulkString{Value: []byte("166.2742")},
+		}},
+	}}, execute(newSicily(), "GEOSEARCH Sicily FROMMEMBER Palermo BYRADIUS 170 km ASC WITHDIST"))
+
+	// ANY returns as soon as enough locations are found, in no given order
+	reply := execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYBOX 400 400 km COUNT 2 ANY").(*resp.Array)
+	assert.Len(t, reply.Value, 2)
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, execute(e, "GEOSEARCH nokey FROMLONLAT 15 37 BYRADIUS 200 km"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, execute(e, "GEOSEARCH Sicily FROMLONLAT 0 0 BYRADIUS 200 km"))
+}
+
+func TestGeoSearchErrors(t *testing.T) {
+	e := newSicily()
+
+	assert.Equal(t, wrongNumberOfArgs(GEOSEARCH), execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS"))
+	assert.Equal(t, errGeoSearchFrom, execute(e, "GEOSEARCH Sicily BYRADIUS 200 km ASC WITHDIST"))
+	assert.Equal(t, errGeoSearchFrom, execute(e, "GEOSEARCH Sicily FROMMEMBER Palermo FROMLONLAT 15 37 BYRADIUS 200 km"))
+	assert.Equal(t, errGeoSearchBy, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 WITHDIST ASC"))
+	assert.Equal(t, errGeoSearchBy, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 1 km BYBOX 1 1 km"))
+	assert.Equal(t, errGeoAnyCount, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km ANY"))
+	assert.Equal(t, errCountPositive, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km COUNT 0"))
+	assert.Equal(t, errGeoRadius, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS -1 km"))
+	assert.Equal(t, errGeoBox, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYBOX 1 -1 km"))
+	assert.Equal(t, errGeoUnit, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 1 yd"))
+	assert.Equal(t, errGeoMember, execute(e, "GEOSEARCH Sicily FROMMEMBER Rome BYRADIUS 200 km"))
+	assert.Equal(t, errSyntax, execute(e, "GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km STOREDIST"))
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	e := newSicily()
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "GEOSEARCHSTORE dest Sicily FROMLONLAT 15 37 BYRADIUS 200 km"))
+	assert.Equal(t, execute(e, "ZRANGE Sicily 0 -1 WITHSCORES"), execute(e, "ZRANGE dest 0 -1 WITHSCORES"))
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "GEOSEARCHSTORE dist Sicily FROMLONLAT 15 37 BYRADIUS 200 km COUNT 1 STOREDIST"))
+	assert.Equal(t, bulks("Catania", "56.4412578701582"), execute(e, "ZRANGE dist 0 -1 WITHSCORES"))
+
+	// an empty result deletes the destination
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GEOSEARCHSTORE dest Sicily FROMLONLAT 0 0 BYRADIUS 200 km"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS dest"))
+	execute(e, "SET dest v")
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "GEOSEARCHSTORE dest nokey FROMLONLAT 0 0 BYRADIUS 200 km"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS dest"))
+
+	assert.Equal(t, errGeoSearchStoreWith, execute(e, "GEOSEARCHSTORE dest Sicily FROMLONLAT 15 37 BYRADIUS 200 km WITHDIST"))
+	assert.Equal(t, wrongNumberOfArgs(GEOSEARCHSTORE), execute(e, "GEOSEARCHSTORE dest Sicily FROMLONLAT 15 37 BYRADIUS"))
+}
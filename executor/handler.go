@@ -1,23 +1,66 @@
 package executor
 
 import (
+	"sync"
+
 	"github.com/scnewma/godb/resp"
 )
 
 func NewHandler(executor Executor) *handler {
 	return &handler{
 		executor: executor,
+		clients:  make(map[uint64]*Client),
 	}
 }
 
 type handler struct {
 	executor Executor
+
+	mu      sync.Mutex
+	clients map[uint64]*Client
 }
 
 func (h *handler) Serve(w resp.ResponseWriter, r *resp.Request) {
-	response := h.executor.Execute(Command{
-		Name: r.Command(),
-		Args: r.Args(),
+	response := h.executor.Execute(r.Context(), Command{
+		Name:   r.Command(),
+		Args:   r.Args(),
+		Client: h.client(r),
 	})
-	w.WriteMessage(response)
+
+	// commands with several replies, such as SUBSCRIBE, push them instead
+	if response != nil {
+		w.WriteMessage(response)
+	}
+}
+
+// client returns the client of the connection the request was received on,
+// creating it for the connection's first request. The client is closed
+// once the request context, which lasts as long as the connection, is done.
+func (h *handler) client(r *resp.Request) *Client {
+	conn := r.Conn()
+	if conn == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c, ok := h.clients[conn.ID()]; ok {
+		return c
+	}
+
+	c := NewClient(conn)
+	h.clients[conn.ID()] = c
+
+	go func() {
+		<-r.Context().Done()
+
+		h.mu.Lock()
+		delete(h.clients, conn.ID())
+		h.mu.Unlock()
+
+		c.Close()
+	}()
+
+	return c
 }
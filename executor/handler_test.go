@@ -1,7 +1,9 @@
 package executor
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/scnewma/godb/resp"
 	"github.com/scnewma/godb/resp/resptest"
@@ -11,10 +13,10 @@ import (
 func TestHandler(t *testing.T) {
 	var got Command
 	e := MockExecutor{
-		ExecuteFn: func(command Command) resp.Message {
+		ExecuteFn: func(ctx context.Context, command Command) resp.Message {
 			got = command
 
-			return &resp.SimpleString{"OK"}
+			return &resp.SimpleString{Value: "OK"}
 		},
 	}
 	h := NewHandler(e)
@@ -28,5 +30,46 @@ func TestHandler(t *testing.T) {
 		[]byte("blah"),
 	}}, got)
 	assert.Equal(1, rec.MessageCount())
-	assert.Equal(&resp.SimpleString{"OK"}, rec.MessageAt(0))
+	assert.Equal(&resp.SimpleString{Value: "OK"}, rec.MessageAt(0))
+}
+
+func TestHandlerClients(t *testing.T) {
+	var clients []*Client
+	e := MockExecutor{
+		ExecuteFn: func(ctx context.Context, command Command) resp.Message {
+			clients = append(clients, command.Client)
+
+			return nil
+		},
+	}
+	h := NewHandler(e)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := resptest.NewConnRecorder(1)
+	rec := resptest.NewRecorder()
+	h.Serve(rec, resptest.NewRequest("SUBSCRIBE a").WithConn(conn).WithContext(ctx))
+	h.Serve(rec, resptest.NewRequest("SUBSCRIBE b").WithConn(conn).WithContext(ctx))
+	h.Serve(rec, resptest.NewRequest("SUBSCRIBE c"))
+
+	assert := assert.New(t)
+	assert.Equal(0, rec.MessageCount())
+	assert.Len(clients, 3)
+	assert.NotNil(clients[0])
+	assert.True(clients[0] == clients[1])
+	assert.Nil(clients[2])
+
+	// the client is forgotten once its connection goes away
+	cancel()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		h.mu.Lock()
+		n := len(h.clients)
+		h.mu.Unlock()
+
+		if n == 0 {
+			break
+		}
+		if !assert.True(time.Now().Before(deadline), "client was not released") {
+			break
+		}
+	}
 }
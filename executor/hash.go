@@ -0,0 +1,824 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/hash"
+)
+
+const (
+	HSET         = "HSET"
+	HSETNX       = "HSETNX"
+	HGET         = "HGET"
+	HMGET        = "HMGET"
+	HGETALL      = "HGETALL"
+	HDEL         = "HDEL"
+	HEXISTS      = "HEXISTS"
+	HLEN         = "HLEN"
+	HKEYS        = "HKEYS"
+	HVALS        = "HVALS"
+	HINCRBY      = "HINCRBY"
+	HINCRBYFLOAT = "HINCRBYFLOAT"
+	HSTRLEN      = "HSTRLEN"
+	HRANDFIELD   = "HRANDFIELD"
+	HSCAN        = "HSCAN"
+	HEXPIRE      = "HEXPIRE"
+	HPEXPIRE     = "HPEXPIRE"
+	HEXPIREAT    = "HEXPIREAT"
+	HTTL         = "HTTL"
+	HPTTL        = "HPTTL"
+	HPERSIST     = "HPERSIST"
+)
+
+// maxFieldExpiry is the latest deadline a hash field can be given, in unix
+// milliseconds, matching the limit Redis imposes.
+const maxFieldExpiry = 1<<48 - 1
+
+var (
+	errHashNotInteger = &resp.Error{Value: "ERR hash value is not an integer"}
+	errHashNotFloat   = &resp.Error{Value: "ERR hash value is not a float"}
+	errFieldsMissing  = &resp.Error{Value: "ERR Mandatory argument FIELDS is missing or not at the right position"}
+	errNumFields      = &resp.Error{Value: "ERR Parameter `numFields` should be greater than 0"}
+	errNumFieldsMatch = &resp.Error{Value: "ERR The `numfields` parameter must match the number of arguments"}
+	errExpireNegative = &resp.Error{Value: "ERR invalid expire time, must be >= 0"}
+)
+
+// getHash looks up the hash stored at key. The hash is nil if the key does
+// not exist. Fields whose deadline has passed were deleted by the storage's
+// lookup, along with the key if no fields remained.
+func getHash(db storage.Storage, key string) (*hash.Hash, resp.Message) {
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, nil
+		}
+
+		return nil, genericErrorMessage
+	}
+
+	h, ok := node.Value().(*hash.Hash)
+	if !ok {
+		return nil, errWrongType
+	}
+
+	return h, nil
+}
+
+// getOrCreateHash looks up the hash stored at key, creating an empty one if
+// the key does not exist.
+func getOrCreateHash(db storage.Storage, key string) (*hash.Hash, resp.Message) {
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+
+	if h == nil {
+		h = hash.New()
+		db.Set(key, storage.NewHashNode(h))
+	}
+
+	return h, nil
+}
+
+func executeHSet(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return wrongNumberOfArgs(HSET)
+	}
+
+	h, errMsg := getOrCreateHash(db, string(args[0]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	var added int64
+	for i := 1; i < len(args); i += 2 {
+		if h.Set(string(args[i]), args[i+1]) {
+			added++
+		}
+	}
+
+	return &resp.Int{Value: added}
+}
+
+func executeHSetNX(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	field := ae.ExtractStringAt(1)
+	val := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getOrCreateHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if _, ok := h.Get(field); ok {
+		return &resp.Int{Value: 0}
+	}
+
+	h.Set(field, val)
+
+	return &resp.Int{Value: 1}
+}
+
+func executeHGet(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	field := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if h == nil {
+		return &resp.BulkString{}
+	}
+
+	val, _ := h.Get(field)
+
+	return &resp.BulkString{Value: val}
+}
+
+func executeHMGet(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	fields := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := make([]resp.Message, 0, len(fields))
+	for _, field := range fields {
+		var val []byte
+		if h != nil {
+			val, _ = h.Get(field)
+		}
+		vals = append(vals, &resp.BulkString{Value: val})
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+func executeHGetAll(args [][]byte, db storage.Storage) resp.Message {
+	return hashContents(args, db, true, true)
+}
+
+func executeHKeys(args [][]byte, db storage.Storage) resp.Message {
+	return hashContents(args, db, true, false)
+}
+
+func executeHVals(args [][]byte, db storage.Storage) resp.Message {
+	return hashContents(args, db, false, true)
+}
+
+// hashContents replies with the fields and/or values of the hash stored at
+// the key in args.
+func hashContents(args [][]byte, db storage.Storage, fields, values bool) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := []resp.Message{}
+	if h == nil {
+		return &resp.Array{Value: vals}
+	}
+
+	h.Range(func(field string, val []byte) bool {
+		if fields {
+			vals = append(vals, &resp.BulkString{Value: []byte(field)})
+		}
+		if values {
+			vals = append(vals, &resp.BulkString{Value: val})
+		}
+		return true
+	})
+
+	return &resp.Array{Value: vals}
+}
+
+func executeHDel(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	fields := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if h == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	var deleted int64
+	for _, field := range fields {
+		if h.Delete(field) {
+			deleted++
+		}
+	}
+
+	if h.Len() == 0 {
+		db.Del(key)
+	}
+
+	return &resp.Int{Value: deleted}
+}
+
+func executeHExists(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	field := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if h == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	if _, ok := h.Get(field); !ok {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: 1}
+}
+
+func executeHLen(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if h == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(h.Len())}
+}
+
+func executeHStrlen(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	field := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if h == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	val, _ := h.Get(field)
+
+	return &resp.Int{Value: int64(len(val))}
+}
+
+func executeHIncrBy(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	field := ae.ExtractStringAt(1)
+	rawIncr := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	incr, ok := parseInt(rawIncr)
+	if !ok {
+		return errNotInteger
+	}
+
+	h, errMsg := getOrCreateHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	var current int64
+	if val, exists := h.Get(field); exists {
+		current, ok = parseInt(val)
+		if !ok {
+			return errHashNotInteger
+		}
+	}
+
+	if (incr < 0 && current < math.MinInt64-incr) || (incr > 0 && current > math.MaxInt64-incr) {
+		return errOverflow
+	}
+
+	current += incr
+	h.SetKeepExpiry(field, strconv.AppendInt(nil, current, 10))
+
+	return &resp.Int{Value: current}
+}
+
+func executeHIncrByFloat(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	field := ae.ExtractStringAt(1)
+	rawIncr := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	incr, ok := parseFloat(rawIncr)
+	if !ok {
+		return errNotFloat
+	}
+
+	h, errMsg := getOrCreateHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	var current float64
+	if val, exists := h.Get(field); exists {
+		current, ok = parseFloat(val)
+		if !ok {
+			return errHashNotFloat
+		}
+	}
+
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return errNaNOrInf
+	}
+
+	val := formatFloat(current)
+	h.SetKeepExpiry(field, val)
+
+	return &resp.BulkString{Value: val}
+}
+
+// executeHRandField returns random fields from a hash. Without a count a
+// single field is returned. A positive count returns up to count distinct
+// fields, while a negative count returns exactly -count fields which may
+// repeat.
+func executeHRandField(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 3 || (len(args) == 3 && strings.ToUpper(string(args[2])) != "WITHVALUES") {
+		return errSyntax
+	}
+
+	hasCount := len(args) >= 2
+	withValues := len(args) == 3
+
+	var count int64
+	if hasCount {
+		var ok bool
+		count, ok = parseInt(args[1])
+		if !ok {
+			return errNotInteger
+		}
+		if count < -maxRandomCount {
+			return errRandomCount
+		}
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if !hasCount {
+		if h == nil {
+			return &resp.BulkString{}
+		}
+		field, _, _ := h.Random()
+		return &resp.BulkString{Value: []byte(field)}
+	}
+
+	vals := []resp.Message{}
+	if h == nil || count == 0 {
+		return &resp.Array{Value: vals}
+	}
+
+	add := func(field string, val []byte) {
+		vals = append(vals, &resp.BulkString{Value: []byte(field)})
+		if withValues {
+			vals = append(vals, &resp.BulkString{Value: val})
+		}
+	}
+
+	if count < 0 {
+		for ; count < 0; count++ {
+			field, val, _ := h.Random()
+			add(field, val)
+		}
+		return &resp.Array{Value: vals}
+	}
+
+	if count >= int64(h.Len()) {
+		h.Range(func(field string, val []byte) bool {
+			add(field, val)
+			return true
+		})
+		return &resp.Array{Value: vals}
+	}
+
+	// when most of the hash is requested, picking distinct random fields
+	// would keep hitting the ones already picked, so remove random fields
+	// from a copy instead
+	if count*3 > int64(h.Len()) {
+		sample := h.Clone()
+		for int64(sample.Len()) > count {
+			field, _, _ := sample.Random()
+			sample.Delete(field)
+		}
+		sample.Range(func(field string, val []byte) bool {
+			add(field, val)
+			return true
+		})
+		return &resp.Array{Value: vals}
+	}
+
+	picked := make(map[string]bool, count)
+	for int64(len(picked)) < count {
+		field, val, _ := h.Random()
+		if picked[field] {
+			continue
+		}
+		picked[field] = true
+		add(field, val)
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+// executeHScan incrementally iterates over the fields of a hash.
+func executeHScan(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	var noValues bool
+	opts, errMsg := parseScanOptions(args[1:], func(opt string, _ [][]byte) (int, bool) {
+		if opt != "NOVALUES" {
+			return 0, false
+		}
+		noValues = true
+		return 0, true
+	})
+	if errMsg != nil {
+		return errMsg
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if h == nil {
+		return scanReply(0, nil)
+	}
+
+	var vals []resp.Message
+	cursor := opts.scan(func(cursor uint64) (uint64, int) {
+		n := 0
+		cursor = h.Scan(cursor, func(field string, val []byte) {
+			n++
+			if !opts.matches(field) {
+				return
+			}
+			vals = append(vals, &resp.BulkString{Value: []byte(field)})
+			if !noValues {
+				vals = append(vals, &resp.BulkString{Value: val})
+			}
+		})
+		return cursor, n
+	})
+
+	return scanReply(cursor, vals)
+}
+
+// parseHashFields parses the "FIELDS numfields field [field ...]" arguments
+// that end the field expiration commands.
+func parseHashFields(args [][]byte) ([]string, resp.Message) {
+	if len(args) < 2 || strings.ToUpper(string(args[0])) != "FIELDS" {
+		return nil, errFieldsMissing
+	}
+
+	numFields, ok := parseInt(args[1])
+	if !ok || numFields < 1 {
+		return nil, errNumFields
+	}
+
+	if numFields != int64(len(args)-2) {
+		return nil, errNumFieldsMatch
+	}
+
+	fields := make([]string, 0, numFields)
+	for _, field := range args[2:] {
+		fields = append(fields, string(field))
+	}
+
+	return fields, nil
+}
+
+// nowMillis returns the current time in unix milliseconds.
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// expireCondition restricts when a new deadline replaces the current one.
+type expireCondition int
+
+const (
+	expireAlways expireCondition = iota
+	// expireNX sets the deadline only if there is none.
+	expireNX
+	// expireXX sets the deadline only if there is one.
+	expireXX
+	// expireGT sets the deadline only if it is later than the current one.
+	// No deadline counts as infinitely late.
+	expireGT
+	// expireLT sets the deadline only if it is earlier than the current one.
+	expireLT
+)
+
+func (cond expireCondition) allows(current int64, hasCurrent bool, at int64) bool {
+	switch cond {
+	case expireNX:
+		return !hasCurrent
+	case expireXX:
+		return hasCurrent
+	case expireGT:
+		return hasCurrent && at > current
+	case expireLT:
+		return !hasCurrent || at < current
+	default:
+		return true
+	}
+}
+
+func executeHExpire(args [][]byte, db storage.Storage) resp.Message {
+	return hashExpire(HEXPIRE, args, db, time.Second, false)
+}
+
+func executeHPExpire(args [][]byte, db storage.Storage) resp.Message {
+	return hashExpire(HPEXPIRE, args, db, time.Millisecond, false)
+}
+
+func executeHExpireAt(args [][]byte, db storage.Storage) resp.Message {
+	return hashExpire(HEXPIREAT, args, db, time.Second, true)
+}
+
+// hashExpire sets the deadline of hash fields. The time argument is in the
+// given unit and is relative to now unless absolute is set. Each field is
+// replied with -2 if it doesn't exist, 0 if the condition prevented the
+// update, 1 if the deadline was set and 2 if the field was deleted because
+// the deadline has already passed.
+func hashExpire(command string, args [][]byte, db storage.Storage, unit time.Duration, absolute bool) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawTime := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	t, ok := parseInt(rawTime)
+	if !ok {
+		return errNotInteger
+	}
+
+	errExpireTime := &resp.Error{Value: fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(command))}
+	if t < 0 {
+		return errExpireNegative
+	}
+	if unit == time.Second {
+		if t > maxFieldExpiry/1000 {
+			return errExpireTime
+		}
+		t *= 1000
+	}
+
+	now := nowMillis()
+	at := t
+	if !absolute {
+		if t > maxFieldExpiry-now {
+			return errExpireTime
+		}
+		at += now
+	}
+	if at > maxFieldExpiry {
+		return errExpireTime
+	}
+
+	rest := args[2:]
+	cond := expireAlways
+	if len(rest) > 0 {
+		switch strings.ToUpper(string(rest[0])) {
+		case "NX":
+			cond = expireNX
+		case "XX":
+			cond = expireXX
+		case "GT":
+			cond = expireGT
+		case "LT":
+			cond = expireLT
+		}
+		if cond != expireAlways {
+			rest = rest[1:]
+		}
+	}
+
+	fields, errMsg := parseHashFields(rest)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	replies := make([]resp.Message, 0, len(fields))
+	if h == nil {
+		for range fields {
+			replies = append(replies, &resp.Int{Value: -2})
+		}
+		return &resp.Array{Value: replies}
+	}
+
+	var updated bool
+	for _, field := range fields {
+		if _, ok := h.Get(field); !ok {
+			replies = append(replies, &resp.Int{Value: -2})
+			continue
+		}
+
+		current, hasCurrent := h.Expiry(field)
+		if !cond.allows(current, hasCurrent, at) {
+			replies = append(replies, &resp.Int{Value: 0})
+			continue
+		}
+
+		if at <= now {
+			h.Delete(field)
+			replies = append(replies, &resp.Int{Value: 2})
+			continue
+		}
+
+		h.SetExpiry(field, at)
+		updated = true
+		replies = append(replies, &resp.Int{Value: 1})
+	}
+
+	if h.Len() == 0 {
+		db.Del(key)
+	} else if updated {
+		// set the hash again so that the storage notices it has fields
+		// that need expiring
+		db.Set(key, storage.NewHashNode(h))
+	}
+
+	return &resp.Array{Value: replies}
+}
+
+func executeHTTL(args [][]byte, db storage.Storage) resp.Message {
+	return hashTTL(args, db, time.Second)
+}
+
+func executeHPTTL(args [][]byte, db storage.Storage) resp.Message {
+	return hashTTL(args, db, time.Millisecond)
+}
+
+// hashTTL replies with the time left before each field expires in the given
+// unit, -1 if the field has no deadline or -2 if it doesn't exist.
+func hashTTL(args [][]byte, db storage.Storage, unit time.Duration) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	fields, errMsg := parseHashFields(args[1:])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	now := nowMillis()
+	replies := make([]resp.Message, 0, len(fields))
+	for _, field := range fields {
+		if h == nil {
+			replies = append(replies, &resp.Int{Value: -2})
+			continue
+		}
+
+		if _, ok := h.Get(field); !ok {
+			replies = append(replies, &resp.Int{Value: -2})
+			continue
+		}
+
+		at, ok := h.Expiry(field)
+		if !ok {
+			replies = append(replies, &resp.Int{Value: -1})
+			continue
+		}
+
+		ttl := at - now
+		if unit == time.Second {
+			ttl = (ttl + 999) / 1000
+		}
+		replies = append(replies, &resp.Int{Value: ttl})
+	}
+
+	return &resp.Array{Value: replies}
+}
+
+// executeHPersist removes the deadline of hash fields, replying 1 for each
+// field whose deadline was removed, -1 if it had none or -2 if it doesn't
+// exist.
+func executeHPersist(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	fields, errMsg := parseHashFields(args[1:])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	h, errMsg := getHash(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	replies := make([]resp.Message, 0, len(fields))
+	for _, field := range fields {
+		if h == nil {
+			replies = append(replies, &resp.Int{Value: -2})
+			continue
+		}
+
+		if _, ok := h.Get(field); !ok {
+			replies = append(replies, &resp.Int{Value: -2})
+			continue
+		}
+
+		if !h.Persist(field) {
+			replies = append(replies, &resp.Int{Value: -1})
+			continue
+		}
+
+		replies = append(replies, &resp.Int{Value: 1})
+	}
+
+	return &resp.Array{Value: replies}
+}
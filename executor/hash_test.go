@@ -0,0 +1,323 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/hash"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sortedBulks returns the values of an array of bulk strings in sorted
+// order, for replies whose order is unspecified.
+func sortedBulks(t *testing.T, msg resp.Message) []string {
+	arr, ok := msg.(*resp.Array)
+	require.True(t, ok, "expected array, got %#v", msg)
+
+	vals := make([]string, 0, len(arr.Value))
+	for _, m := range arr.Value {
+		vals = append(vals, string(m.(*resp.BulkString).Value))
+	}
+	sort.Strings(vals)
+	return vals
+}
+
+func TestHSetAndGet(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "HSET h name ada lang go"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "HSET h name grace age 36"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("grace")}, execute(e, "HGET h name"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "HGET h missing"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "HGET nokey name"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("go")},
+		&resp.BulkString{},
+		&resp.BulkString{Value: []byte("36")},
+	}}, execute(e, "HMGET h lang missing age"))
+	assert.Equal(t, bulks("name", "grace", "lang", "go", "age", "36"), execute(e, "HGETALL h"))
+	assert.Equal(t, bulks("name", "lang", "age"), execute(e, "HKEYS h"))
+	assert.Equal(t, bulks("grace", "go", "36"), execute(e, "HVALS h"))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "HLEN h"))
+	assert.Equal(t, &resp.Int{Value: 5}, execute(e, "HSTRLEN h name"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "HEXISTS h age"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "HEXISTS h missing"))
+	assert.Equal(t, bulks(), execute(e, "HGETALL nokey"))
+
+	assert.Equal(t, wrongNumberOfArgs(HSET), execute(e, "HSET h name"))
+}
+
+func TestHSetNX(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "HSETNX h f a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "HSETNX h f b"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("a")}, execute(e, "HGET h f"))
+}
+
+func TestHDelRemovesEmptyHash(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1 b 2")
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "HDEL h a missing"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "HDEL h b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS h"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "HDEL h b"))
+}
+
+func TestHIncrBy(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 5}, execute(e, "HINCRBY h n 5"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "HINCRBY h n -3"))
+	assert.Equal(t, errNotInteger, execute(e, "HINCRBY h n x"))
+
+	execute(e, "HSET h s abc")
+	assert.Equal(t, errHashNotInteger, execute(e, "HINCRBY h s 1"))
+
+	execute(e, "HSET h big 9223372036854775807")
+	assert.Equal(t, errOverflow, execute(e, "HINCRBY h big 1"))
+}
+
+func TestHIncrByFloat(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("10.5")}, execute(e, "HINCRBYFLOAT h f 10.5"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("10.6")}, execute(e, "HINCRBYFLOAT h f 0.1"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("10.6")}, execute(e, "HGET h f"))
+	assert.Equal(t, errNotFloat, execute(e, "HINCRBYFLOAT h f x"))
+
+	execute(e, "HSET h s abc")
+	assert.Equal(t, errHashNotFloat, execute(e, "HINCRBYFLOAT h s 1"))
+}
+
+func TestHRandField(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1 b 2 c 3")
+
+	field := execute(e, "HRANDFIELD h").(*resp.BulkString)
+	assert.Contains(t, []string{"a", "b", "c"}, string(field.Value))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "HRANDFIELD nokey"))
+	assert.Equal(t, bulks(), execute(e, "HRANDFIELD nokey 2"))
+	assert.Equal(t, bulks(), execute(e, "HRANDFIELD h 0"))
+
+	assert.Equal(t, []string{"a", "b", "c"}, sortedBulks(t, execute(e, "HRANDFIELD h 10")))
+	assert.Len(t, execute(e, "HRANDFIELD h -10").(*resp.Array).Value, 10)
+	assert.Equal(t, errRandomCount, execute(e, fmt.Sprintf("HRANDFIELD h %d", -maxRandomCount-1)))
+	assert.Equal(t, errRandomCount, execute(e, fmt.Sprintf("HRANDFIELD h %d WITHVALUES", int64(math.MinInt64))))
+
+	distinct := sortedBulks(t, execute(e, "HRANDFIELD h 2"))
+	assert.Len(t, distinct, 2)
+	assert.NotEqual(t, distinct[0], distinct[1])
+
+	withValues := execute(e, "HRANDFIELD h -1 WITHVALUES").(*resp.Array).Value
+	require.Len(t, withValues, 2)
+	f := string(withValues[0].(*resp.BulkString).Value)
+	assert.Equal(t, execute(e, "HGET h "+f), withValues[1])
+
+	assert.Equal(t, errSyntax, execute(e, "HRANDFIELD h 1 WITHSCORES"))
+}
+
+func TestHRandFieldDistinctFromLargeHash(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 200; i++ {
+		execute(e, fmt.Sprintf("HSET h f%d v", i))
+	}
+
+	for _, count := range []int{10, 150} {
+		fields := sortedBulks(t, execute(e, fmt.Sprintf("HRANDFIELD h %d", count)))
+		require.Len(t, fields, count)
+		for i := 1; i < len(fields); i++ {
+			assert.NotEqual(t, fields[i-1], fields[i])
+		}
+	}
+}
+
+func TestHScan(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 300; i++ {
+		execute(e, fmt.Sprintf("HSET h f%d v%d", i, i))
+	}
+
+	seen := make(map[string]string)
+	cursor := "0"
+	for {
+		reply := execute(e, "HSCAN h "+cursor+" COUNT 20").(*resp.Array).Value
+		cursor = string(reply[0].(*resp.BulkString).Value)
+		elements := reply[1].(*resp.Array).Value
+		for i := 0; i < len(elements); i += 2 {
+			seen[string(elements[i].(*resp.BulkString).Value)] = string(elements[i+1].(*resp.BulkString).Value)
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	require.Len(t, seen, 300)
+	assert.Equal(t, "v42", seen["f42"])
+}
+
+func TestHScanMatchAndNoValues(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h foo 1 bar 2 food 3")
+
+	reply := execute(e, "HSCAN h 0 MATCH foo* NOVALUES").(*resp.Array).Value
+	assert.Equal(t, &resp.BulkString{Value: []byte("0")}, reply[0])
+	assert.Equal(t, []string{"foo", "food"}, sortedBulks(t, reply[1]))
+
+	assert.Equal(t, scanReply(0, nil), execute(e, "HSCAN nokey 0"))
+	assert.Equal(t, errInvalidCursor, execute(e, "HSCAN h x"))
+	assert.Equal(t, errSyntax, execute(e, "HSCAN h 0 COUNT 0"))
+	assert.Equal(t, errSyntax, execute(e, "HSCAN h 0 BOGUS"))
+}
+
+func TestHashEncoding(t *testing.T) {
+	db := inmem.NewStorage()
+	e := NewExecutor(db)
+
+	execute(e, "HSET h f short")
+	node, err := db.Get("h")
+	require.NoError(t, err)
+	assert.Equal(t, hash.EncodingCompact, node.Value().(*hash.Hash).Encoding())
+
+	execute(e, "HSET h f "+strings.Repeat("x", hash.MaxCompactValue+1))
+	assert.Equal(t, hash.EncodingHashtable, node.Value().(*hash.Hash).Encoding())
+}
+
+func TestHashWrongType(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET s v")
+	execute(e, "HSET h f v")
+
+	for _, cmd := range []string{"HSET s f v", "HGET s f", "HGETALL s", "HDEL s f", "HLEN s", "HINCRBY s f 1", "HSCAN s 0", "HRANDFIELD s"} {
+		assert.Equal(t, errWrongType, execute(e, cmd), cmd)
+	}
+	assert.Equal(t, errWrongType, execute(e, "GET h"))
+	assert.Equal(t, errWrongType, execute(e, "LPUSH h a"))
+}
+
+func TestType(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET s v")
+	execute(e, "SET n 1")
+	execute(e, "RPUSH l a")
+	execute(e, "HSET h f v")
+
+	assert.Equal(t, &resp.SimpleString{Value: "string"}, execute(e, "TYPE s"))
+	assert.Equal(t, &resp.SimpleString{Value: "string"}, execute(e, "TYPE n"))
+	assert.Equal(t, &resp.SimpleString{Value: "list"}, execute(e, "TYPE l"))
+	assert.Equal(t, &resp.SimpleString{Value: "hash"}, execute(e, "TYPE h"))
+	assert.Equal(t, &resp.SimpleString{Value: "none"}, execute(e, "TYPE missing"))
+}
+
+func TestHExpire(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1 b 2 c 3")
+
+	assert.Equal(t, ints(1, -2), execute(e, "HEXPIRE h 100 FIELDS 2 a missing"))
+	assert.Equal(t, ints(-2, -2), execute(e, "HEXPIRE nokey 100 FIELDS 2 a b"))
+	assert.Equal(t, ints(-1, -2), execute(e, "HTTL h FIELDS 2 b missing"))
+	assert.Equal(t, ints(100), execute(e, "HTTL h FIELDS 1 a"))
+
+	pttl := execute(e, "HPTTL h FIELDS 1 a").(*resp.Array).Value[0].(*resp.Int).Value
+	assert.True(t, pttl > 99000 && pttl <= 100000, "pttl %d", pttl)
+
+	assert.Equal(t, ints(1, -1, -2), execute(e, "HPERSIST h FIELDS 3 a b missing"))
+	assert.Equal(t, ints(-1), execute(e, "HTTL h FIELDS 1 a"))
+}
+
+func TestHExpireConditions(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1 b 2")
+
+	assert.Equal(t, ints(0), execute(e, "HEXPIRE h 100 XX FIELDS 1 a"))
+	assert.Equal(t, ints(0), execute(e, "HEXPIRE h 100 GT FIELDS 1 a"))
+	assert.Equal(t, ints(1), execute(e, "HEXPIRE h 100 NX FIELDS 1 a"))
+	assert.Equal(t, ints(0), execute(e, "HEXPIRE h 200 NX FIELDS 1 a"))
+	assert.Equal(t, ints(1), execute(e, "HEXPIRE h 200 GT FIELDS 1 a"))
+	assert.Equal(t, ints(0), execute(e, "HEXPIRE h 300 LT FIELDS 1 a"))
+	assert.Equal(t, ints(1), execute(e, "HEXPIRE h 50 LT FIELDS 1 a"))
+	assert.Equal(t, ints(1), execute(e, "HEXPIRE h 50 LT FIELDS 1 b"))
+	assert.Equal(t, ints(1), execute(e, "HEXPIRE h 70 XX FIELDS 1 b"))
+	assert.Equal(t, ints(50, 70), execute(e, "HTTL h FIELDS 2 a b"))
+}
+
+func TestHExpireInThePastDeletesField(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1 b 2")
+
+	assert.Equal(t, ints(2), execute(e, "HEXPIREAT h 1 FIELDS 1 a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "HEXISTS h a"))
+	assert.Equal(t, ints(2), execute(e, "HPEXPIRE h 0 FIELDS 1 b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS h"))
+}
+
+func TestExpiredFieldsAreHidden(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1 b 2")
+
+	assert.Equal(t, ints(1), execute(e, "HPEXPIRE h 1 FIELDS 1 a"))
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, &resp.BulkString{}, execute(e, "HGET h a"))
+	assert.Equal(t, bulks("b", "2"), execute(e, "HGETALL h"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "HLEN h"))
+
+	assert.Equal(t, ints(1), execute(e, "HPEXPIRE h 1 FIELDS 1 b"))
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "HLEN h"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS h"))
+}
+
+func TestHashOfExpiredFieldsIsGone(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1")
+	execute(e, "SET s x")
+
+	assert.Equal(t, ints(1), execute(e, "HPEXPIRE h 1 FIELDS 1 a"))
+	time.Sleep(5 * time.Millisecond)
+
+	// the key is gone to every command, not only to hash commands
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "DBSIZE"))
+	assert.Equal(t, bulks("s"), execute(e, "KEYS *"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS h"))
+	assert.Equal(t, &resp.SimpleString{Value: "none"}, execute(e, "TYPE h"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "LPUSH h x"))
+}
+
+func TestHashWritesAndFieldExpiry(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h n 1 s x")
+	execute(e, "HEXPIRE h 100 FIELDS 2 n s")
+
+	// incrementing keeps the deadline while overwriting removes it
+	execute(e, "HINCRBY h n 1")
+	execute(e, "HSET h s y")
+	assert.Equal(t, ints(100, -1), execute(e, "HTTL h FIELDS 2 n s"))
+}
+
+func TestHExpireErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h a 1")
+
+	assert.Equal(t, errFieldsMissing, execute(e, "HEXPIRE h 10 a"))
+	assert.Equal(t, errFieldsMissing, execute(e, "HEXPIRE h 10 NX XX FIELDS 1 a"))
+	assert.Equal(t, errNumFields, execute(e, "HEXPIRE h 10 FIELDS 0 a"))
+	assert.Equal(t, errNumFieldsMatch, execute(e, "HEXPIRE h 10 FIELDS 2 a"))
+	assert.Equal(t, errNotInteger, execute(e, "HEXPIRE h x FIELDS 1 a"))
+	assert.Equal(t, errExpireNegative, execute(e, "HEXPIRE h -1 FIELDS 1 a"))
+	assert.Equal(t, &resp.Error{Value: "ERR invalid expire time in 'hexpire' command"}, execute(e, "HEXPIRE h 9223372036854775807 FIELDS 1 a"))
+	assert.Equal(t, errNumFieldsMatch, execute(e, "HTTL h FIELDS 1 a b"))
+	assert.Equal(t, errFieldsMissing, execute(e, "HPERSIST h a"))
+
+	execute(e, "SET s v")
+	assert.Equal(t, errWrongType, execute(e, "HEXPIRE s 10 FIELDS 1 a"))
+	assert.Equal(t, errWrongType, execute(e, "HTTL s FIELDS 1 a"))
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/hyperloglog"
+)
+
+const (
+	PFADD   = "PFADD"
+	PFCOUNT = "PFCOUNT"
+	PFMERGE = "PFMERGE"
+)
+
+var (
+	errNotHLL       = &resp.Error{Value: "WRONGTYPE Key is not a valid HyperLogLog string value."}
+	errCorruptedHLL = &resp.Error{Value: "INVALIDOBJ Corrupted HLL object detected"}
+)
+
+// getHLL looks up the HyperLogLog stored as a string at key. The
+// HyperLogLog is nil if the key does not exist.
+func getHLL(db storage.Storage, key string) (*hyperloglog.HLL, resp.Message) {
+	val, exists, errMsg := getString(db, key)
+	if errMsg != nil || !exists {
+		return nil, errMsg
+	}
+
+	h, err := hyperloglog.Parse(val)
+	switch err {
+	case nil:
+		return h, nil
+	case hyperloglog.ErrCorrupted:
+		return nil, errCorruptedHLL
+	default:
+		return nil, errNotHLL
+	}
+}
+
+func executePFAdd(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	h, errMsg := getHLL(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	created := h == nil
+	if created {
+		h = hyperloglog.New()
+	}
+
+	if !h.Add(args[1:]...) && !created {
+		return &resp.Int{Value: 0}
+	}
+
+	db.Set(key, storage.NewNode(h.Bytes()))
+
+	return &resp.Int{Value: 1}
+}
+
+func executePFCount(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(PFCOUNT)
+	}
+
+	if len(args) == 1 {
+		h, errMsg := getHLL(db, string(args[0]))
+		if errMsg != nil {
+			return errMsg
+		}
+
+		if h == nil {
+			return &resp.Int{Value: 0}
+		}
+
+		// the cardinality is cached in the stored value, which Count
+		// updates in place
+		return &resp.Int{Value: int64(h.Count())}
+	}
+
+	union := hyperloglog.New()
+	for _, arg := range args {
+		h, errMsg := getHLL(db, string(arg))
+		if errMsg != nil {
+			return errMsg
+		}
+
+		if h != nil {
+			union.Merge(h)
+		}
+	}
+
+	return &resp.Int{Value: int64(union.Count())}
+}
+
+func executePFMerge(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	dest := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	// every source is checked before the destination is written
+	sources := make([]*hyperloglog.HLL, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		h, errMsg := getHLL(db, string(arg))
+		if errMsg != nil {
+			return errMsg
+		}
+
+		if h != nil {
+			sources = append(sources, h)
+		}
+	}
+
+	merged, errMsg := getHLL(db, dest)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if merged == nil {
+		merged = hyperloglog.New()
+	}
+
+	for _, h := range sources {
+		merged.Merge(h)
+	}
+
+	db.Set(dest, storage.NewNode(merged.Bytes()))
+
+	return &resp.SimpleString{Value: "OK"}
+}
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPFAddAndCount(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "PFADD h a b c"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PFADD h a"))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "PFCOUNT h"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PFCOUNT nokey"))
+
+	// creating an empty HyperLogLog counts as an update
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "PFADD empty"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PFADD empty"))
+	assert.Equal(t, &resp.SimpleString{Value: "string"}, execute(e, "TYPE empty"))
+
+	// HyperLogLogs are strings and survive being copied with GET and SET
+	val := execute(e, "GET h").(*resp.BulkString).Value
+	e.Execute(context.Background(), Command{Name: SET, Args: asArgs("copy", string(val))})
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "PFCOUNT copy"))
+}
+
+func TestPFCountUnion(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "PFADD a 1 2 3")
+	execute(e, "PFADD b 3 4")
+
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "PFCOUNT a b nokey"))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "PFCOUNT a"))
+}
+
+func TestPFMerge(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "PFADD a 1 2 3")
+	execute(e, "PFADD b 3 4")
+	execute(e, "PFADD dest 5")
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "PFMERGE dest a b nokey"))
+	assert.Equal(t, &resp.Int{Value: 5}, execute(e, "PFCOUNT dest"))
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "PFMERGE new"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PFCOUNT new"))
+}
+
+func TestPFRejectsOtherValues(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET s hello")
+	execute(e, "SET n 10")
+	execute(e, "RPUSH l a")
+
+	assert.Equal(t, errNotHLL, execute(e, "PFADD s a"))
+	assert.Equal(t, errNotHLL, execute(e, "PFCOUNT n"))
+	assert.Equal(t, errWrongType, execute(e, "PFCOUNT l"))
+	assert.Equal(t, errNotHLL, execute(e, "PFMERGE dest s"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS dest"))
+
+	// the sparse registers must add up
+	e.Execute(context.Background(), Command{Name: SET, Args: asArgs("bad", "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")})
+	assert.Equal(t, errCorruptedHLL, execute(e, "PFCOUNT bad"))
+}
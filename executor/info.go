@@ -0,0 +1,306 @@
+package executor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+)
+
+const INFO = "INFO"
+
+// Server is the server receiving the commands the executor runs, which INFO
+// reports on.
+type Server interface {
+	// Port returns the TCP port the server listens on.
+	Port() int
+	// MaxClients returns the number of connections the server accepts at
+	// once.
+	MaxClients() int
+	Stats() *resp.Stats
+}
+
+// SetServer sets the server receiving the commands the executor runs.
+func (ce *compositeExecutor) SetServer(srv Server) {
+	ce.server = srv
+}
+
+// infoSection writes a section of INFO.
+type infoSection struct {
+	name  string
+	write func(ce *compositeExecutor, w *infoWriter)
+	// all is set for the sections that are only included when asked for
+	// explicitly or with "all" or "everything".
+	all bool
+}
+
+var infoSections = []infoSection{
+	{name: "server", write: (*compositeExecutor).infoServer},
+	{name: "clients", write: (*compositeExecutor).infoClients},
+	{name: "memory", write: (*compositeExecutor).infoMemory},
+	{name: "persistence", write: (*compositeExecutor).infoPersistence},
+	{name: "stats", write: (*compositeExecutor).infoStats},
+	{name: "replication", write: (*compositeExecutor).infoReplication},
+	{name: "cpu", write: (*compositeExecutor).infoCPU},
+	{name: "commandstats", write: (*compositeExecutor).infoCommandStats, all: true},
+	{name: "errorstats", write: (*compositeExecutor).infoErrorStats},
+	{name: "keyspace", write: (*compositeExecutor).infoKeyspace},
+}
+
+// replicationID identifies the dataset of the server's current run.
+var replicationID = newReplicationID()
+
+func newReplicationID() string {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// infoWriter builds the reply of INFO in the format of Redis: sections
+// titled "# Name" holding "field:value" lines, separated by blank lines.
+type infoWriter struct {
+	b        strings.Builder
+	sections int
+}
+
+func (w *infoWriter) section(title string) {
+	if w.sections > 0 {
+		w.b.WriteString("\r\n")
+	}
+	w.sections++
+
+	fmt.Fprintf(&w.b, "# %s\r\n", title)
+}
+
+func (w *infoWriter) field(name string, value interface{}) {
+	fmt.Fprintf(&w.b, "%s:%v\r\n", name, value)
+}
+
+// executeInfo describes the server. Without arguments it includes the
+// default sections; "all" and "everything" include every section. It runs
+// exclusively, as it reports on every database.
+func (ce *compositeExecutor) executeInfo(_ *Client, args [][]byte) resp.Message {
+	requested := make(map[string]bool, len(args))
+	for _, arg := range args {
+		requested[strings.ToLower(string(arg))] = true
+	}
+	everything := requested["all"] || requested["everything"]
+	defaults := len(args) == 0 || requested["default"]
+
+	w := &infoWriter{}
+	for _, section := range infoSections {
+		if everything || requested[section.name] || (defaults && !section.all) {
+			section.write(ce, w)
+		}
+	}
+
+	return &resp.BulkString{Value: []byte(w.b.String())}
+}
+
+func (ce *compositeExecutor) serverStats() *resp.Stats {
+	if ce.server == nil {
+		return &resp.Stats{}
+	}
+
+	return ce.server.Stats()
+}
+
+func (ce *compositeExecutor) infoServer(w *infoWriter) {
+	uptime := time.Since(ce.started)
+	executable, _ := os.Executable()
+
+	var port int
+	if ce.server != nil {
+		port = ce.server.Port()
+	}
+
+	w.section("Server")
+	w.field("redis_version", serverVersion)
+	w.field("redis_mode", "standalone")
+	w.field("os", runtime.GOOS+" "+runtime.GOARCH)
+	w.field("arch_bits", strconv.IntSize)
+	w.field("go_version", runtime.Version())
+	w.field("process_id", os.Getpid())
+	w.field("run_id", replicationID)
+	w.field("tcp_port", port)
+	w.field("server_time_usec", time.Now().UnixNano()/int64(time.Microsecond))
+	w.field("uptime_in_seconds", int64(uptime.Seconds()))
+	w.field("uptime_in_days", int64(uptime.Hours()/24))
+	// hash fields are expired ten times a second
+	w.field("hz", 10)
+	w.field("executable", executable)
+	w.field("config_file", ce.config.File())
+}
+
+func (ce *compositeExecutor) infoClients(w *infoWriter) {
+	var maxClients, blocked int
+	if ce.server != nil {
+		maxClients = ce.server.MaxClients()
+	}
+	for _, registry := range ce.blocked {
+		blocked += registry.count()
+	}
+
+	w.section("Clients")
+	w.field("connected_clients", ce.serverStats().ConnectedClients.Load())
+	w.field("cluster_connections", 0)
+	w.field("maxclients", maxClients)
+	w.field("blocked_clients", blocked)
+	w.field("tracking_clients", 0)
+}
+
+// rssSample reads the memory the runtime obtained from the OS, standing in
+// for the resident set size. It is runtime.MemStats.Sys, read without
+// stopping the world.
+const rssSample = "/memory/classes/total:bytes"
+
+func (ce *compositeExecutor) infoMemory(w *infoWriter) {
+	sample := []metrics.Sample{{Name: rssSample}}
+	metrics.Read(sample)
+	rss := sample[0].Value.Uint64()
+
+	// used memory is measured the way maxmemory is enforced
+	used := usedMemory()
+
+	w.section("Memory")
+	w.field("used_memory", used)
+	w.field("used_memory_human", humanBytes(uint64(used)))
+	w.field("used_memory_rss", rss)
+	w.field("used_memory_rss_human", humanBytes(rss))
+	w.field("total_system_memory", 0)
+	maxMemory := ce.maxMemory.Load()
+	w.field("maxmemory", maxMemory)
+	w.field("maxmemory_human", humanBytes(uint64(maxMemory)))
+	w.field("maxmemory_policy", "noeviction")
+	w.field("mem_allocator", "go")
+}
+
+// humanBytes formats a number of bytes the way Redis does, e.g. 1.50M.
+func humanBytes(n uint64) string {
+	units := []string{"B", "K", "M", "G", "T", "P"}
+
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	return fmt.Sprintf("%.2f%s", value, units[i])
+}
+
+// infoPersistence reports that nothing is persisted, with the last save as
+// the server's start.
+func (ce *compositeExecutor) infoPersistence(w *infoWriter) {
+	w.section("Persistence")
+	w.field("loading", 0)
+	w.field("async_loading", 0)
+	w.field("rdb_changes_since_last_save", 0)
+	w.field("rdb_bgsave_in_progress", 0)
+	w.field("rdb_last_save_time", ce.started.Unix())
+	w.field("rdb_last_bgsave_status", "ok")
+	w.field("aof_enabled", 0)
+	w.field("aof_rewrite_in_progress", 0)
+}
+
+func (ce *compositeExecutor) infoStats(w *infoWriter) {
+	stats := ce.serverStats()
+
+	var errors int64
+	for _, n := range ce.stats.errorCounts() {
+		errors += n
+	}
+
+	w.section("Stats")
+	w.field("total_connections_received", stats.TotalConnections.Load())
+	w.field("total_commands_processed", ce.stats.processed.Load())
+	w.field("total_net_input_bytes", stats.NetInputBytes.Load())
+	w.field("total_net_output_bytes", stats.NetOutputBytes.Load())
+	w.field("rejected_connections", stats.RejectedConnections.Load())
+	w.field("pubsub_channels", len(ce.hub.Channels("")))
+	w.field("pubsub_patterns", ce.hub.NumPat())
+	w.field("pubsubshard_channels", len(ce.shardHub.Channels("")))
+	w.field("total_error_replies", errors)
+}
+
+func (ce *compositeExecutor) infoReplication(w *infoWriter) {
+	w.section("Replication")
+	w.field("role", "master")
+	w.field("connected_slaves", 0)
+	w.field("master_failover_state", "no-failover")
+	w.field("master_replid", replicationID)
+	w.field("master_repl_offset", 0)
+}
+
+func (ce *compositeExecutor) infoCPU(w *infoWriter) {
+	sys, user := cpuTimes()
+
+	w.section("CPU")
+	w.field("used_cpu_sys", fmt.Sprintf("%.6f", sys.Seconds()))
+	w.field("used_cpu_user", fmt.Sprintf("%.6f", user.Seconds()))
+}
+
+// infoCommandStats reports every command that was called at least once.
+func (ce *compositeExecutor) infoCommandStats(w *infoWriter) {
+	names := make([]string, 0, len(ce.stats.commands))
+	for name := range ce.stats.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.section("Commandstats")
+	for _, name := range names {
+		stats := ce.stats.commands[name]
+		calls, rejected, failed := stats.calls.Load(), stats.rejected.Load(), stats.failed.Load()
+		if calls == 0 && rejected == 0 {
+			continue
+		}
+
+		usec := stats.usec.Load()
+		var perCall float64
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+
+		w.field("cmdstat_"+strings.ToLower(name), fmt.Sprintf(
+			"calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+			calls, usec, perCall, rejected, failed))
+	}
+}
+
+func (ce *compositeExecutor) infoErrorStats(w *infoWriter) {
+	counts := ce.stats.errorCounts()
+	prefixes := make([]string, 0, len(counts))
+	for prefix := range counts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	w.section("Errorstats")
+	for _, prefix := range prefixes {
+		w.field("errorstat_"+prefix, fmt.Sprintf("count=%d", counts[prefix]))
+	}
+}
+
+// infoKeyspace reports the number of keys of every database holding any.
+// Keys don't expire, so none have a TTL.
+func (ce *compositeExecutor) infoKeyspace(w *infoWriter) {
+	w.section("Keyspace")
+	for i, db := range ce.dbs {
+		if n := db.Len(); n > 0 {
+			w.field(fmt.Sprintf("db%d", i), fmt.Sprintf("keys=%d,expires=0,avg_ttl=0", n))
+		}
+	}
+}
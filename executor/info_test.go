@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	stats resp.Stats
+}
+
+func (s *testServer) Port() int          { return 1123 }
+func (s *testServer) MaxClients() int    { return 10 }
+func (s *testServer) Stats() *resp.Stats { return &s.stats }
+
+// info executes INFO and returns the fields of the sections it replied with.
+func info(t *testing.T, e Executor, command string) map[string]string {
+	reply, ok := execute(e, command).(*resp.BulkString)
+	if !assert.True(t, ok) {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(reply.Value), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			fields[line] = ""
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if assert.Len(t, parts, 2, line) {
+			fields[parts[0]] = parts[1]
+		}
+	}
+
+	return fields
+}
+
+func TestInfo(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage(), inmem.NewStorage())
+	srv := &testServer{}
+	srv.stats.ConnectedClients.Store(3)
+	srv.stats.NetInputBytes.Store(42)
+	e.SetServer(srv)
+
+	execute(e, "SET a 1")
+	execute(e, "SET b 2")
+	execute(e, "INCR a")
+	execute(e, "NOPE")
+	execute(e, "LPUSH a x")
+
+	fields := info(t, e, "INFO")
+	assert.Contains(t, fields, "# Server")
+	assert.Contains(t, fields, "# Keyspace")
+	assert.NotContains(t, fields, "# Commandstats")
+	assert.Equal(t, "1123", fields["tcp_port"])
+	assert.Equal(t, "3", fields["connected_clients"])
+	assert.Equal(t, "10", fields["maxclients"])
+	assert.Equal(t, "42", fields["total_net_input_bytes"])
+	assert.Equal(t, "5", fields["total_commands_processed"])
+	assert.Equal(t, "2", fields["total_error_replies"])
+	assert.Equal(t, "count=1", fields["errorstat_ERR"])
+	assert.Equal(t, "count=1", fields["errorstat_WRONGTYPE"])
+	assert.Equal(t, "keys=2,expires=0,avg_ttl=0", fields["db0"])
+	assert.NotContains(t, fields, "db1")
+
+	fields = info(t, e, "INFO commandstats KEYSPACE")
+	assert.Len(t, fields, 7)
+	assert.Regexp(t, `^calls=2,usec=\d+,usec_per_call=\d+\.\d\d,rejected_calls=0,failed_calls=0$`, fields["cmdstat_set"])
+	assert.Regexp(t, `^calls=1,usec=\d+,usec_per_call=\d+\.\d\d,rejected_calls=0,failed_calls=0$`, fields["cmdstat_incr"])
+	assert.Regexp(t, `^calls=1,.*,failed_calls=1$`, fields["cmdstat_lpush"])
+	assert.Contains(t, fields, "cmdstat_info")
+
+	assert.Contains(t, info(t, e, "INFO all"), "# Commandstats")
+	assert.Equal(t, &resp.BulkString{Value: []byte{}}, execute(e, "INFO nope"))
+}
+
+func TestInfoRejectedCalls(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "ACL SETUSER alice on nopass +@read")
+	executeAs(e, c, "AUTH alice x")
+	executeAs(e, c, "SET k v")
+	executeAs(e, c, "GET")
+	executeAs(e, c, "NOPE")
+
+	fields := info(t, e, "INFO commandstats errorstats")
+	assert.Regexp(t, `^calls=0,.*,rejected_calls=1,failed_calls=0$`, fields["cmdstat_set"])
+	assert.Equal(t, "count=1", fields["errorstat_NOPERM"])
+	assert.Equal(t, "count=2", fields["errorstat_ERR"])
+}
+
+func TestHumanBytes(t *testing.T) {
+	assert.Equal(t, "0B", humanBytes(0))
+	assert.Equal(t, "1023B", humanBytes(1023))
+	assert.Equal(t, "1.50K", humanBytes(1536))
+	assert.Equal(t, "2.00M", humanBytes(2<<20))
+}
+
+func TestInfoInTransaction(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	executeAs(e, c, "SET a 1")
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, queued, executeAs(e, c, "INFO keyspace"))
+
+	reply, ok := executeAs(e, c, "EXEC").(*resp.Array)
+	if assert.True(t, ok) && assert.Len(t, reply.Value, 1) {
+		assert.Contains(t, string(reply.Value[0].(*resp.BulkString).Value), "db0:keys=1")
+	}
+
+	// the server isn't left locked
+	assert.Equal(t, &resp.BulkString{Value: []byte("1")}, executeAs(e, c, "GET a"))
+}
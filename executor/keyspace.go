@@ -0,0 +1,363 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/scnewma/godb/glob"
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/hash"
+	"github.com/scnewma/godb/storage/quicklist"
+	"github.com/scnewma/godb/storage/set"
+	"github.com/scnewma/godb/storage/stream"
+	"github.com/scnewma/godb/storage/zset"
+)
+
+const (
+	KEYS      = "KEYS"
+	SCAN      = "SCAN"
+	RENAME    = "RENAME"
+	RENAMENX  = "RENAMENX"
+	COPY      = "COPY"
+	RANDOMKEY = "RANDOMKEY"
+	DBSIZE    = "DBSIZE"
+	FLUSHDB   = "FLUSHDB"
+	FLUSHALL  = "FLUSHALL"
+	OBJECT    = "OBJECT"
+)
+
+// embstrSizeLimit is the length up to which Redis stores strings in a
+// single allocation with their header, reported by OBJECT ENCODING.
+const embstrSizeLimit = 44
+
+var (
+	errSameObject     = &resp.Error{Value: "ERR source and destination objects are the same"}
+	errFreqNotTracked = &resp.Error{Value: "ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."}
+)
+
+// executeKeys returns every key matching a glob pattern. It visits the
+// whole keyspace with the storage locked, so SCAN should be preferred on
+// large databases.
+func executeKeys(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) != 1 {
+		return wrongNumberOfArgs(KEYS)
+	}
+
+	pattern := args[0]
+	matchAll := len(pattern) == 1 && pattern[0] == '*'
+
+	// nothing is modified while the keys are collected, so no key is
+	// visited twice
+	keys := []resp.Message{}
+	cursor := uint64(0)
+	for {
+		cursor = db.Scan(cursor, func(key string, _ storage.Node) {
+			if matchAll || glob.Match(pattern, []byte(key)) {
+				keys = append(keys, &resp.BulkString{Value: []byte(key)})
+			}
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return &resp.Array{Value: keys}
+}
+
+// executeScan incrementally iterates over the keyspace.
+func executeScan(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(SCAN)
+	}
+
+	var valueType string
+	opts, errMsg := parseScanOptions(args, func(opt string, rest [][]byte) (int, bool) {
+		if opt != "TYPE" || len(rest) == 0 {
+			return 0, false
+		}
+		valueType = strings.ToLower(string(rest[0]))
+		return 1, true
+	})
+	if errMsg != nil {
+		return errMsg
+	}
+
+	var keys []resp.Message
+	cursor := opts.scan(func(cursor uint64) (uint64, int) {
+		n := 0
+		cursor = db.Scan(cursor, func(key string, node storage.Node) {
+			n++
+			if !opts.matches(key) || (valueType != "" && typeName(node) != valueType) {
+				return
+			}
+			keys = append(keys, &resp.BulkString{Value: []byte(key)})
+		})
+		return cursor, n
+	})
+
+	return scanReply(cursor, keys)
+}
+
+func executeRename(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) != 2 {
+		return wrongNumberOfArgs(RENAME)
+	}
+
+	if _, errMsg := rename(db, string(args[0]), string(args[1]), false); errMsg != nil {
+		return errMsg
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func executeRenameNX(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) != 2 {
+		return wrongNumberOfArgs(RENAMENX)
+	}
+
+	renamed, errMsg := rename(db, string(args[0]), string(args[1]), true)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if !renamed {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: 1}
+}
+
+// rename moves the value of src to dst, overwriting dst unless nx is set.
+// The value itself is moved rather than copied, so the deadlines of hash
+// fields are kept.
+func rename(db storage.Storage, src, dst string, nx bool) (bool, resp.Message) {
+	node, err := db.Get(src)
+	if err != nil {
+		return false, errNoSuchKey
+	}
+
+	if src == dst {
+		return !nx, nil
+	}
+
+	if nx {
+		if _, err := db.Get(dst); err == nil {
+			return false, nil
+		}
+	}
+
+	db.Del(src)
+	db.Set(dst, node)
+
+	return true, nil
+}
+
+// executeCopy copies the value of a key to another key, in the selected
+// database unless another one is given.
+func (ce *compositeExecutor) executeCopy(c *Client, args [][]byte) resp.Message {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(COPY)
+	}
+
+	src, dst := string(args[0]), string(args[1])
+	from := c.selected()
+	to := from
+
+	var replace bool
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "REPLACE":
+			replace = true
+		case opt == "DB" && i+1 < len(args):
+			index, errMsg := ce.parseDBIndex(args[i+1])
+			if errMsg != nil {
+				return errMsg
+			}
+			to = index
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	if src == dst && from == to {
+		return errSameObject
+	}
+
+	var copied bool
+	ce.atomicallyPair(from, to, func(srcDB, dstDB storage.Storage) {
+		node, err := srcDB.Get(src)
+		if err != nil {
+			return
+		}
+
+		if !replace {
+			if _, err := dstDB.Get(dst); err == nil {
+				return
+			}
+		}
+
+		dstDB.Set(dst, copyValue(node))
+		copied = true
+	})
+
+	if !copied {
+		return &resp.Int{Value: 0}
+	}
+
+	ce.watches.touch(to, []string{dst})
+
+	return &resp.Int{Value: 1}
+}
+
+// copyValue returns a node holding a copy of the value of node that shares
+// nothing the commands modify in place with the original.
+func copyValue(node storage.Node) storage.Node {
+	switch v := node.Value().(type) {
+	case []byte:
+		return storage.NewNode(append([]byte(nil), v...))
+	case int64:
+		return storage.NewIntNode(v)
+	case *quicklist.List:
+		return storage.NewListNode(v.Clone())
+	case *hash.Hash:
+		return storage.NewHashNode(v.Clone())
+	case *set.Set:
+		return storage.NewSetNode(v.Clone())
+	case *zset.ZSet:
+		return storage.NewZSetNode(v.Clone())
+	default:
+		return storage.NewStreamNode(v.(*stream.Stream).Clone())
+	}
+}
+
+func executeRandomKey(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(RANDOMKEY)
+	}
+
+	key, err := db.RandomKey()
+	if err != nil {
+		return &resp.BulkString{}
+	}
+
+	return &resp.BulkString{Value: []byte(key)}
+}
+
+func executeDBSize(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(DBSIZE)
+	}
+
+	return &resp.Int{Value: int64(db.Len())}
+}
+
+// executeFlushDB deletes every key of the selected database. ASYNC is
+// accepted but flushes synchronously: dropping the keyspace is cheap, and
+// the garbage collector reclaims the memory in the background either way.
+func executeFlushDB(args [][]byte, db storage.Storage) resp.Message {
+	if errMsg := checkFlushMode(args); errMsg != nil {
+		return errMsg
+	}
+
+	db.Flush()
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// executeFlushAll deletes every key of every database.
+func (ce *compositeExecutor) executeFlushAll(_ *Client, args [][]byte) resp.Message {
+	if errMsg := checkFlushMode(args); errMsg != nil {
+		return errMsg
+	}
+
+	for i := range ce.dbs {
+		ce.atomically(i, func(tx storage.Storage) {
+			tx.Flush()
+		})
+		ce.watches.touchAll(i)
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// checkFlushMode checks the optional SYNC or ASYNC argument of FLUSHDB and
+// FLUSHALL.
+func checkFlushMode(args [][]byte) resp.Message {
+	if len(args) > 1 {
+		return errSyntax
+	}
+	if len(args) == 1 {
+		if mode := strings.ToUpper(string(args[0])); mode != "SYNC" && mode != "ASYNC" {
+			return errSyntax
+		}
+	}
+
+	return nil
+}
+
+// objectEncoding returns the name Redis gives to the representation of the
+// value held by node.
+func objectEncoding(node storage.Node) string {
+	switch v := node.Value().(type) {
+	case int64:
+		return "int"
+	case []byte:
+		if len(v) <= embstrSizeLimit {
+			return "embstr"
+		}
+		return "raw"
+	case *quicklist.List:
+		return "quicklist"
+	case *hash.Hash:
+		return v.Encoding()
+	case *set.Set:
+		return v.Encoding()
+	case *zset.ZSet:
+		return v.Encoding()
+	case *stream.Stream:
+		return "stream"
+	default:
+		return "unknown"
+	}
+}
+
+// executeObject inspects the value of a key. Access frequencies are only
+// tracked by Redis under an LFU eviction policy, and there is no eviction,
+// so OBJECT FREQ always fails. Values are never shared, so their reference
+// count is always 1.
+func executeObject(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(OBJECT)
+	}
+
+	sub := strings.ToUpper(string(args[0]))
+	switch sub {
+	case "ENCODING", "IDLETIME", "FREQ", "REFCOUNT":
+	default:
+		return unknownSubcommand(OBJECT, args[0])
+	}
+
+	if len(args) != 2 {
+		return wrongNumberOfArgs(OBJECT + "|" + sub)
+	}
+
+	key := string(args[1])
+	idle, err := db.Idle(key)
+	if err != nil {
+		return &resp.BulkString{}
+	}
+
+	switch sub {
+	case "ENCODING":
+		// inspecting the key doesn't count as an access, like IDLETIME
+		node, _ := db.Peek(key)
+		return &resp.BulkString{Value: []byte(objectEncoding(node))}
+	case "IDLETIME":
+		return &resp.Int{Value: int64(idle.Seconds())}
+	case "FREQ":
+		return errFreqNotTracked
+	default:
+		return &resp.Int{Value: 1}
+	}
+}
@@ -0,0 +1,307 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeys(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, execute(e, "KEYS *"))
+
+	execute(e, "MSET one 1 two 2 three 3")
+	execute(e, "RPUSH four 4")
+
+	assert.Equal(t, []string{"four", "one", "three", "two"}, sortedBulks(t, execute(e, "KEYS *")))
+	assert.Equal(t, []string{"three", "two"}, sortedBulks(t, execute(e, "KEYS t*")))
+	assert.Equal(t, []string{"one", "two"}, sortedBulks(t, execute(e, "KEYS ?[nw]*")))
+	assert.Equal(t, wrongNumberOfArgs(KEYS), execute(e, "KEYS a b"))
+}
+
+// scanAll runs a SCAN to completion, calling between after each call.
+func scanAll(t *testing.T, e Executor, options string, between func()) map[string]int {
+	seen := make(map[string]int)
+	cursor := "0"
+	for {
+		reply, ok := execute(e, "SCAN "+cursor+options).(*resp.Array)
+		require.True(t, ok)
+		cursor = string(reply.Value[0].(*resp.BulkString).Value)
+		for _, key := range reply.Value[1].(*resp.Array).Value {
+			seen[string(key.(*resp.BulkString).Value)]++
+		}
+		if cursor == "0" {
+			return seen
+		}
+		between()
+	}
+}
+
+func TestScan(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 500; i++ {
+		execute(e, fmt.Sprintf("SET k%d %d", i, i))
+	}
+
+	seen := scanAll(t, e, " COUNT 20", func() {})
+	assert.Len(t, seen, 500)
+}
+
+func TestScanWhileGrowing(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 100; i++ {
+		execute(e, fmt.Sprintf("SET k%d %d", i, i))
+	}
+
+	// the keyspace is rehashed into bigger tables several times during the
+	// scan, and every original key must still be returned
+	added := 0
+	seen := scanAll(t, e, "", func() {
+		for i := 0; i < 50; i++ {
+			execute(e, fmt.Sprintf("SET added%d x", added))
+			added++
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		assert.Contains(t, seen, fmt.Sprintf("k%d", i))
+	}
+}
+
+func TestScanWhileShrinking(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 1000; i++ {
+		execute(e, fmt.Sprintf("SET d%d x", i))
+	}
+	for i := 0; i < 10; i++ {
+		execute(e, fmt.Sprintf("SET k%d x", i))
+	}
+
+	deleted := 0
+	seen := scanAll(t, e, " COUNT 5", func() {
+		for i := 0; i < 100 && deleted < 1000; i++ {
+			execute(e, fmt.Sprintf("DEL d%d", deleted))
+			deleted++
+		}
+	})
+
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, seen, fmt.Sprintf("k%d", i))
+	}
+}
+
+func TestScanMatchAndType(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET user:1 a user:2 b item:1 c")
+	execute(e, "RPUSH user:list x")
+	execute(e, "HSET user:hash f v")
+
+	keys := func(seen map[string]int) []string {
+		var ks []string
+		for k := range seen {
+			ks = append(ks, k)
+		}
+		return sortedBulks(t, bulks(ks...))
+	}
+
+	assert.Equal(t, []string{"user:1", "user:2", "user:hash", "user:list"}, keys(scanAll(t, e, " MATCH user:*", func() {})))
+	assert.Equal(t, []string{"user:list"}, keys(scanAll(t, e, " TYPE list", func() {})))
+	assert.Equal(t, []string{"user:1", "user:2"}, keys(scanAll(t, e, " MATCH user:* TYPE STRING", func() {})))
+	assert.Empty(t, scanAll(t, e, " TYPE zset", func() {}))
+
+	assert.Equal(t, scanReply(0, nil), execute(NewExecutor(inmem.NewStorage()), "SCAN 0"))
+	assert.Equal(t, errInvalidCursor, execute(e, "SCAN -1"))
+	assert.Equal(t, errSyntax, execute(e, "SCAN 0 TYPE"))
+	assert.Equal(t, errSyntax, execute(e, "SCAN 0 NOVALUES"))
+	assert.Equal(t, wrongNumberOfArgs(SCAN), execute(e, "SCAN"))
+}
+
+func TestRename(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH a x y")
+	execute(e, "SET b 1")
+
+	assert.Equal(t, ok, execute(e, "RENAME a c"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS a"))
+	assert.Equal(t, bulks("x", "y"), execute(e, "LRANGE c 0 -1"))
+
+	// the destination is overwritten whatever its type
+	assert.Equal(t, ok, execute(e, "RENAME c b"))
+	assert.Equal(t, bulks("x", "y"), execute(e, "LRANGE b 0 -1"))
+	assert.Equal(t, ok, execute(e, "RENAME b b"))
+
+	assert.Equal(t, errNoSuchKey, execute(e, "RENAME nokey d"))
+	assert.Equal(t, wrongNumberOfArgs(RENAME), execute(e, "RENAME a"))
+}
+
+func TestRenameNX(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET a 1 b 2")
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "RENAMENX a b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "RENAMENX a a"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "RENAMENX a c"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "GET a"))
+	assert.Equal(t, bulks("2", "1"), execute(e, "MGET b c"))
+	assert.Equal(t, errNoSuchKey, execute(e, "RENAMENX a d"))
+}
+
+func TestRenameKeepsFieldDeadlines(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "HSET h f v g w")
+	execute(e, "HEXPIRE h 100 FIELDS 1 f")
+
+	execute(e, "RENAME h h2")
+	assert.Equal(t, ints(100, -1), execute(e, "HTTL h2 FIELDS 2 f g"))
+}
+
+func TestCopy(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	for _, setup := range []string{
+		"SET src 12",
+		"SET src hello",
+		"RPUSH src a b",
+		"HSET src f v",
+		"SADD src 1 2",
+		"SADD src a b",
+		"ZADD src 1 a",
+		"XADD src 1-0 f v",
+		"PFADD src a b",
+	} {
+		execute(e, "DEL src dst")
+		execute(e, setup)
+
+		assert.Equal(t, &resp.Int{Value: 1}, execute(e, "COPY src dst"), setup)
+		assert.Equal(t, execute(e, "TYPE src"), execute(e, "TYPE dst"), setup)
+		assert.Equal(t, execute(e, "OBJECT ENCODING src"), execute(e, "OBJECT ENCODING dst"), setup)
+	}
+
+	// the copy is independent of the original
+	execute(e, "DEL src dst")
+	execute(e, "RPUSH src a")
+	execute(e, "COPY src dst")
+	execute(e, "RPUSH dst b")
+	assert.Equal(t, bulks("a"), execute(e, "LRANGE src 0 -1"))
+
+	execute(e, "XGROUP CREATE src2 g $ MKSTREAM")
+	execute(e, "XADD src2 1-0 f v")
+	execute(e, "XREADGROUP GROUP g c STREAMS src2 >")
+	execute(e, "COPY src2 dst2")
+	execute(e, "XACK dst2 g 1-0")
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XACK src2 g 1-0"))
+
+	// the destination is only overwritten with REPLACE
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "COPY src dst"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "COPY src dst REPLACE DB 0"))
+	assert.Equal(t, bulks("a"), execute(e, "LRANGE dst 0 -1"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "COPY nokey dst"))
+	assert.Equal(t, errSameObject, execute(e, "COPY src src"))
+	assert.Equal(t, errDBOutOfRange, execute(e, "COPY src dst DB 1"))
+	assert.Equal(t, errNotInteger, execute(e, "COPY src dst DB x"))
+	assert.Equal(t, errSyntax, execute(e, "COPY src dst BOGUS"))
+	assert.Equal(t, wrongNumberOfArgs(COPY), execute(e, "COPY src"))
+}
+
+func TestRandomKeyAndDBSize(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	assert.Equal(t, &resp.BulkString{}, execute(e, "RANDOMKEY"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "DBSIZE"))
+
+	execute(e, "MSET a 1 b 2")
+	execute(e, "SADD c x")
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "DBSIZE"))
+
+	key := string(execute(e, "RANDOMKEY").(*resp.BulkString).Value)
+	assert.Contains(t, []string{"a", "b", "c"}, key)
+	assert.Equal(t, wrongNumberOfArgs(DBSIZE), execute(e, "DBSIZE x"))
+}
+
+func TestFlush(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	for _, cmd := range []string{"FLUSHDB", "FLUSHDB ASYNC", "FLUSHALL", "flushall sync"} {
+		execute(e, "MSET a 1 b 2")
+		assert.Equal(t, ok, execute(e, cmd), cmd)
+		assert.Equal(t, &resp.Int{Value: 0}, execute(e, "DBSIZE"), cmd)
+	}
+
+	assert.Equal(t, errSyntax, execute(e, "FLUSHDB LAZY"))
+	assert.Equal(t, errSyntax, execute(e, "FLUSHALL SYNC ASYNC"))
+}
+
+func TestFlushTouchesWatchedKeys(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	executeAs(e, c, "WATCH a")
+	execute(e, "FLUSHALL")
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "SET a 1")
+	assert.Equal(t, &resp.Array{}, executeAs(e, c, "EXEC"))
+}
+
+func TestObject(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET int 12")
+	execute(e, "SET short hello")
+	execute(e, "SET long "+fmt.Sprintf("%045d", 0))
+	execute(e, "RPUSH list a")
+	execute(e, "HSET hash f v")
+	execute(e, "SADD intset 1")
+	execute(e, "SADD set a")
+	execute(e, "ZADD zset 1 a")
+	execute(e, "XADD stream 1-0 f v")
+
+	for key, encoding := range map[string]string{
+		"int":    "int",
+		"short":  "embstr",
+		"long":   "raw",
+		"list":   "quicklist",
+		"hash":   "listpack",
+		"intset": "intset",
+		"set":    "hashtable",
+		"zset":   "skiplist",
+		"stream": "stream",
+	} {
+		assert.Equal(t, &resp.BulkString{Value: []byte(encoding)}, execute(e, "OBJECT ENCODING "+key), key)
+	}
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "OBJECT IDLETIME int"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "OBJECT REFCOUNT int"))
+	assert.Equal(t, errFreqNotTracked, execute(e, "OBJECT FREQ int"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "OBJECT ENCODING nokey"))
+
+	assert.Equal(t, wrongNumberOfArgs("OBJECT|ENCODING"), execute(e, "OBJECT ENCODING"))
+	assert.Equal(t, unknownSubcommand(OBJECT, []byte("foo")), execute(e, "OBJECT foo x"))
+}
+
+func TestExistsDoesntCountAsAccess(t *testing.T) {
+	db := inmem.NewStorage()
+	e := NewExecutor(db)
+	execute(e, "SET k v")
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "EXISTS k"))
+	idle, _ := db.Idle("k")
+	assert.True(t, idle >= 20*time.Millisecond, idle)
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "TOUCH k"))
+	idle, _ = db.Idle("k")
+	assert.True(t, idle < 20*time.Millisecond, idle)
+}
+
+func TestObjectEncodingDoesntCountAsAccess(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET k v")
+	time.Sleep(time.Second)
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("embstr")}, execute(e, "OBJECT ENCODING k"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "OBJECT IDLETIME k"))
+}
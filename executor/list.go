@@ -0,0 +1,909 @@
+package executor
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/quicklist"
+)
+
+const (
+	LPUSH   = "LPUSH"
+	RPUSH   = "RPUSH"
+	LPUSHX  = "LPUSHX"
+	RPUSHX  = "RPUSHX"
+	LPOP    = "LPOP"
+	RPOP    = "RPOP"
+	LRANGE  = "LRANGE"
+	LINDEX  = "LINDEX"
+	LSET    = "LSET"
+	LINSERT = "LINSERT"
+	LREM    = "LREM"
+	LTRIM   = "LTRIM"
+	LLEN    = "LLEN"
+	LPOS    = "LPOS"
+	LMOVE   = "LMOVE"
+	LMPOP   = "LMPOP"
+	BLPOP   = "BLPOP"
+	BRPOP   = "BRPOP"
+	BLMOVE  = "BLMOVE"
+	BLMPOP  = "BLMPOP"
+)
+
+var (
+	errNoSuchKey       = &resp.Error{Value: "ERR no such key"}
+	errIndexOutOfRange = &resp.Error{Value: "ERR index out of range"}
+	errNotPositive     = &resp.Error{Value: "ERR value is out of range, must be positive"}
+	errTimeout         = &resp.Error{Value: "ERR timeout is not a float or out of range"}
+	errTimeoutNegative = &resp.Error{Value: "ERR timeout is negative"}
+)
+
+// getList looks up the list stored at key. The list is nil if the key does
+// not exist.
+func getList(db storage.Storage, key string) (*quicklist.List, resp.Message) {
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, nil
+		}
+
+		return nil, genericErrorMessage
+	}
+
+	l, ok := node.Value().(*quicklist.List)
+	if !ok {
+		return nil, errWrongType
+	}
+
+	return l, nil
+}
+
+// listEnd is the side of a list that an element is pushed to or popped from.
+type listEnd int
+
+const (
+	listHead listEnd = iota
+	listTail
+)
+
+func parseListEnd(b []byte) (listEnd, bool) {
+	switch strings.ToUpper(string(b)) {
+	case "LEFT":
+		return listHead, true
+	case "RIGHT":
+		return listTail, true
+	default:
+		return 0, false
+	}
+}
+
+func push(l *quicklist.List, end listEnd, val []byte) {
+	if end == listHead {
+		l.PushFront(val)
+	} else {
+		l.PushBack(val)
+	}
+}
+
+// pop removes an element from the given end of the list stored at key,
+// deleting the key when the list becomes empty.
+func pop(db storage.Storage, key string, l *quicklist.List, end listEnd) []byte {
+	var val []byte
+	if end == listHead {
+		val, _ = l.PopFront()
+	} else {
+		val, _ = l.PopBack()
+	}
+
+	if l.Len() == 0 {
+		db.Del(key)
+	}
+
+	return val
+}
+
+func executeLPush(args [][]byte, db storage.Storage) resp.Message {
+	return pushGeneric(args, db, listHead, false)
+}
+
+func executeRPush(args [][]byte, db storage.Storage) resp.Message {
+	return pushGeneric(args, db, listTail, false)
+}
+
+func executeLPushX(args [][]byte, db storage.Storage) resp.Message {
+	return pushGeneric(args, db, listHead, true)
+}
+
+func executeRPushX(args [][]byte, db storage.Storage) resp.Message {
+	return pushGeneric(args, db, listTail, true)
+}
+
+func pushGeneric(args [][]byte, db storage.Storage, end listEnd, onlyExisting bool) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		if onlyExisting {
+			return &resp.Int{Value: 0}
+		}
+
+		l = quicklist.New()
+		db.Set(key, storage.NewListNode(l))
+	}
+
+	for _, val := range args[1:] {
+		push(l, end, val)
+	}
+
+	return &resp.Int{Value: int64(l.Len())}
+}
+
+func executeLPop(args [][]byte, db storage.Storage) resp.Message {
+	return popGeneric(args, db, listHead)
+}
+
+func executeRPop(args [][]byte, db storage.Storage) resp.Message {
+	return popGeneric(args, db, listTail)
+}
+
+func popGeneric(args [][]byte, db storage.Storage, end listEnd) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 2 {
+		return errSyntax
+	}
+
+	hasCount := len(args) == 2
+	var count int64
+	if hasCount {
+		var ok bool
+		count, ok = parseInt(args[1])
+		if !ok || count < 0 {
+			return errNotPositive
+		}
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		if hasCount {
+			return &resp.Array{}
+		}
+		return &resp.BulkString{}
+	}
+
+	if !hasCount {
+		return &resp.BulkString{Value: pop(db, key, l, end)}
+	}
+
+	vals := make([]resp.Message, 0, minInt64(count, int64(l.Len())))
+	for ; count > 0 && l.Len() > 0; count-- {
+		vals = append(vals, &resp.BulkString{Value: pop(db, key, l, end)})
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+// listRange converts the inclusive range [start, stop], where negative
+// indexes count back from the end of the list, into absolute indexes. ok is
+// false if the range is empty.
+func listRange(start, stop int64, length int) (int, int, bool) {
+	llen := int64(length)
+	if start < 0 {
+		start += llen
+	}
+	if stop < 0 {
+		stop += llen
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	if start > stop || start >= llen {
+		return 0, 0, false
+	}
+
+	if stop >= llen {
+		stop = llen - 1
+	}
+
+	return int(start), int(stop), true
+}
+
+// listIndex converts index, which counts back from the end of the list when
+// negative, into an absolute index.
+func listIndex(index int64, length int) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+
+	return int(index), true
+}
+
+func executeLRange(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawStart := ae.ExtractAt(1)
+	rawStop := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	start, ok := parseInt(rawStart)
+	if !ok {
+		return errNotInteger
+	}
+
+	stop, ok := parseInt(rawStop)
+	if !ok {
+		return errNotInteger
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := []resp.Message{}
+	if l == nil {
+		return &resp.Array{Value: vals}
+	}
+
+	from, to, ok := listRange(start, stop, l.Len())
+	if !ok {
+		return &resp.Array{Value: vals}
+	}
+
+	l.Range(from, to, func(_ int, val []byte) bool {
+		vals = append(vals, &resp.BulkString{Value: val})
+		return true
+	})
+
+	return &resp.Array{Value: vals}
+}
+
+func executeLIndex(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawIndex := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	index, ok := parseInt(rawIndex)
+	if !ok {
+		return errNotInteger
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		return &resp.BulkString{}
+	}
+
+	i, ok := listIndex(index, l.Len())
+	if !ok {
+		return &resp.BulkString{}
+	}
+
+	val, _ := l.Index(i)
+	return &resp.BulkString{Value: val}
+}
+
+func executeLSet(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawIndex := ae.ExtractAt(1)
+	val := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	index, ok := parseInt(rawIndex)
+	if !ok {
+		return errNotInteger
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		return errNoSuchKey
+	}
+
+	i, ok := listIndex(index, l.Len())
+	if !ok {
+		return errIndexOutOfRange
+	}
+
+	l.Set(i, val)
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func executeLInsert(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	where := strings.ToUpper(ae.ExtractStringAt(1))
+	pivot := ae.ExtractAt(2)
+	val := ae.ExtractAt(3)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if where != "BEFORE" && where != "AFTER" {
+		return errSyntax
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	pivotIdx := -1
+	l.Range(0, l.Len()-1, func(i int, el []byte) bool {
+		if bytes.Equal(el, pivot) {
+			pivotIdx = i
+			return false
+		}
+		return true
+	})
+
+	if pivotIdx < 0 {
+		return &resp.Int{Value: -1}
+	}
+
+	if where == "AFTER" {
+		pivotIdx++
+	}
+
+	l.Insert(pivotIdx, val)
+	return &resp.Int{Value: int64(l.Len())}
+}
+
+func executeLRem(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawCount := ae.ExtractAt(1)
+	val := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	count, ok := parseInt(rawCount)
+	if !ok {
+		return errNotInteger
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	// a negative count removes matches starting from the tail
+	reverse := count < 0
+	if reverse {
+		count = -count
+	}
+	if count < 0 || count > math.MaxInt32 {
+		count = 0
+	}
+
+	removed := l.RemoveFunc(int(count), reverse, func(el []byte) bool {
+		return bytes.Equal(el, val)
+	})
+
+	if l.Len() == 0 {
+		db.Del(key)
+	}
+
+	return &resp.Int{Value: int64(removed)}
+}
+
+func executeLTrim(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawStart := ae.ExtractAt(1)
+	rawStop := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	start, ok := parseInt(rawStart)
+	if !ok {
+		return errNotInteger
+	}
+
+	stop, ok := parseInt(rawStop)
+	if !ok {
+		return errNotInteger
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		return &resp.SimpleString{Value: "OK"}
+	}
+
+	from, to, ok := listRange(start, stop, l.Len())
+	if !ok {
+		db.Del(key)
+		return &resp.SimpleString{Value: "OK"}
+	}
+
+	l.Trim(from, l.Len()-1-to)
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func executeLLen(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if l == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(l.Len())}
+}
+
+func executeLPos(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	val := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	var (
+		rank     int64 = 1
+		count    int64
+		maxLen   int64
+		hasCount bool
+	)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+
+		n, ok := parseInt(args[i+1])
+		if !ok {
+			return errNotInteger
+		}
+
+		switch strings.ToUpper(string(args[i])) {
+		case "RANK":
+			if n == 0 || n == math.MinInt64 {
+				return &resp.Error{Value: "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the last match"}
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return &resp.Error{Value: "ERR COUNT can't be negative"}
+			}
+			count = n
+			hasCount = true
+		case "MAXLEN":
+			if n < 0 {
+				return &resp.Error{Value: "ERR MAXLEN can't be negative"}
+			}
+			maxLen = n
+		default:
+			return errSyntax
+		}
+	}
+
+	l, errMsg := getList(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	var matches []resp.Message
+	if l != nil && l.Len() > 0 {
+		// a negative rank searches from the tail, skipping -rank-1 matches
+		skip := rank - 1
+		if rank < 0 {
+			skip = -rank - 1
+		}
+
+		var scanned int64
+		match := func(i int, el []byte) bool {
+			if maxLen > 0 && scanned >= maxLen {
+				return false
+			}
+			scanned++
+
+			if !bytes.Equal(el, val) {
+				return true
+			}
+			if skip > 0 {
+				skip--
+				return true
+			}
+
+			matches = append(matches, &resp.Int{Value: int64(i)})
+			return (hasCount && count == 0) || int64(len(matches)) < count
+		}
+
+		if rank > 0 {
+			l.Range(0, l.Len()-1, match)
+		} else {
+			l.ReverseRange(l.Len()-1, 0, match)
+		}
+	}
+
+	if !hasCount {
+		if len(matches) == 0 {
+			return &resp.BulkString{}
+		}
+		return matches[0]
+	}
+
+	if matches == nil {
+		matches = []resp.Message{}
+	}
+
+	return &resp.Array{Value: matches}
+}
+
+func executeLMove(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	src := ae.ExtractStringAt(0)
+	dest := ae.ExtractStringAt(1)
+	rawFrom := ae.ExtractAt(2)
+	rawTo := ae.ExtractAt(3)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	from, ok := parseListEnd(rawFrom)
+	if !ok {
+		return errSyntax
+	}
+
+	to, ok := parseListEnd(rawTo)
+	if !ok {
+		return errSyntax
+	}
+
+	return lmove(db, src, dest, from, to)
+}
+
+func lmove(db storage.Storage, src, dest string, from, to listEnd) resp.Message {
+	srcList, errMsg := getList(db, src)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if srcList == nil {
+		return &resp.BulkString{}
+	}
+
+	// check the destination type before popping so that a WRONGTYPE error
+	// leaves the source untouched
+	if _, errMsg := getList(db, dest); errMsg != nil {
+		return errMsg
+	}
+
+	val := pop(db, src, srcList, from)
+
+	// src and dest may be the same key, which the pop could have just
+	// deleted, so the destination is only looked up now
+	destList, _ := getList(db, dest)
+	if destList == nil {
+		destList = quicklist.New()
+		db.Set(dest, storage.NewListNode(destList))
+	}
+
+	push(destList, to, val)
+
+	return &resp.BulkString{Value: val}
+}
+
+// parseTimeout parses a blocking command timeout given in seconds.
+func parseTimeout(b []byte) (time.Duration, resp.Message) {
+	secs, ok := parseFloat(b)
+	// math.MaxInt64 rounds up to 1<<63 as a float64, which doesn't fit a
+	// time.Duration
+	if !ok || math.IsInf(secs, 0) || secs*float64(time.Second) >= math.MaxInt64 {
+		return 0, errTimeout
+	}
+
+	if secs < 0 {
+		return 0, errTimeoutNegative
+	}
+
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+func executeBLPop(args [][]byte, db storage.Storage) (resp.Message, *blockSpec) {
+	return blockingPop(args, db, listHead)
+}
+
+func executeBRPop(args [][]byte, db storage.Storage) (resp.Message, *blockSpec) {
+	return blockingPop(args, db, listTail)
+}
+
+func blockingPop(args [][]byte, db storage.Storage, end listEnd) (resp.Message, *blockSpec) {
+	ae := newArgExtractor(args)
+	ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}, nil
+	}
+
+	keys := make([]string, 0, len(args)-1)
+	for _, arg := range args[:len(args)-1] {
+		keys = append(keys, string(arg))
+	}
+
+	timeout, errMsg := parseTimeout(args[len(args)-1])
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	serve := func(db storage.Storage, key string) (resp.Message, bool) {
+		l, errMsg := getList(db, key)
+		if errMsg != nil || l == nil {
+			return nil, false
+		}
+
+		val := pop(db, key, l, end)
+		return &resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte(key)},
+			&resp.BulkString{Value: val},
+		}}, true
+	}
+
+	for _, key := range keys {
+		if _, errMsg := getList(db, key); errMsg != nil {
+			return errMsg, nil
+		}
+
+		if msg, ok := serve(db, key); ok {
+			return msg, nil
+		}
+	}
+
+	return nil, &blockSpec{
+		keys:         keys,
+		timeout:      timeout,
+		timeoutReply: &resp.Array{},
+		serve:        serve,
+	}
+}
+
+func executeBLMove(args [][]byte, db storage.Storage) (resp.Message, *blockSpec) {
+	ae := newArgExtractor(args)
+	src := ae.ExtractStringAt(0)
+	dest := ae.ExtractStringAt(1)
+	rawFrom := ae.ExtractAt(2)
+	rawTo := ae.ExtractAt(3)
+	rawTimeout := ae.ExtractAt(4)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}, nil
+	}
+
+	from, ok := parseListEnd(rawFrom)
+	if !ok {
+		return errSyntax, nil
+	}
+
+	to, ok := parseListEnd(rawTo)
+	if !ok {
+		return errSyntax, nil
+	}
+
+	timeout, errMsg := parseTimeout(rawTimeout)
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	srcList, errMsg := getList(db, src)
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	if srcList != nil {
+		return lmove(db, src, dest, from, to), nil
+	}
+
+	return nil, &blockSpec{
+		keys:         []string{src},
+		timeout:      timeout,
+		timeoutReply: &resp.BulkString{},
+		serve: func(db storage.Storage, key string) (resp.Message, bool) {
+			srcList, errMsg := getList(db, src)
+			if errMsg != nil || srcList == nil {
+				return nil, false
+			}
+
+			// a destination of the wrong type keeps the client blocked
+			// rather than losing the element
+			if _, errMsg := getList(db, dest); errMsg != nil {
+				return nil, false
+			}
+
+			return lmove(db, src, dest, from, to), true
+		},
+	}
+}
+
+// mpopArgs are the arguments shared by LMPOP and BLMPOP.
+type mpopArgs struct {
+	keys  []string
+	end   listEnd
+	count int64
+}
+
+// parseMPopArgs parses "numkeys key [key ...] LEFT|RIGHT [COUNT count]".
+func parseMPopArgs(command string, args [][]byte) (*mpopArgs, resp.Message) {
+	if len(args) < 3 {
+		return nil, wrongNumberOfArgs(command)
+	}
+
+	numKeys, ok := parseInt(args[0])
+	if !ok {
+		return nil, errNotInteger
+	}
+
+	if numKeys <= 0 {
+		return nil, &resp.Error{Value: "ERR numkeys should be greater than 0"}
+	}
+
+	if numKeys > int64(len(args)-2) {
+		return nil, errSyntax
+	}
+
+	mpop := &mpopArgs{count: 1}
+	for _, key := range args[1 : numKeys+1] {
+		mpop.keys = append(mpop.keys, string(key))
+	}
+
+	rest := args[numKeys+1:]
+	mpop.end, ok = parseListEnd(rest[0])
+	if !ok {
+		return nil, errSyntax
+	}
+
+	switch {
+	case len(rest) == 1:
+	case len(rest) == 3 && strings.ToUpper(string(rest[1])) == "COUNT":
+		mpop.count, ok = parseInt(rest[2])
+		if !ok || mpop.count <= 0 {
+			return nil, &resp.Error{Value: "ERR count should be greater than 0"}
+		}
+	default:
+		return nil, errSyntax
+	}
+
+	return mpop, nil
+}
+
+// serve pops from the first key holding a non-empty list.
+func (mpop *mpopArgs) serve(db storage.Storage, key string) (resp.Message, bool) {
+	l, errMsg := getList(db, key)
+	if errMsg != nil || l == nil {
+		return nil, false
+	}
+
+	vals := make([]resp.Message, 0, minInt64(mpop.count, int64(l.Len())))
+	for count := mpop.count; count > 0 && l.Len() > 0; count-- {
+		vals = append(vals, &resp.BulkString{Value: pop(db, key, l, mpop.end)})
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(key)},
+		&resp.Array{Value: vals},
+	}}, true
+}
+
+// first serves the command from the first of its keys that holds a list,
+// failing if any key checked before it has the wrong type.
+func (mpop *mpopArgs) first(db storage.Storage) (resp.Message, bool) {
+	for _, key := range mpop.keys {
+		if _, errMsg := getList(db, key); errMsg != nil {
+			return errMsg, true
+		}
+
+		if msg, ok := mpop.serve(db, key); ok {
+			return msg, true
+		}
+	}
+
+	return nil, false
+}
+
+func executeLMPop(args [][]byte, db storage.Storage) resp.Message {
+	mpop, errMsg := parseMPopArgs(LMPOP, args)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if msg, ok := mpop.first(db); ok {
+		return msg
+	}
+
+	return &resp.Array{}
+}
+
+func executeBLMPop(args [][]byte, db storage.Storage) (resp.Message, *blockSpec) {
+	ae := newArgExtractor(args)
+	rawTimeout := ae.ExtractAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}, nil
+	}
+
+	timeout, errMsg := parseTimeout(rawTimeout)
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	mpop, errMsg := parseMPopArgs(BLMPOP, args[1:])
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	if msg, ok := mpop.first(db); ok {
+		return msg, nil
+	}
+
+	return nil, &blockSpec{
+		keys:         mpop.keys,
+		timeout:      timeout,
+		timeoutReply: &resp.Array{},
+		serve:        mpop.serve,
+	}
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
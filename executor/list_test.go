@@ -0,0 +1,176 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func bulks(vals ...string) *resp.Array {
+	msgs := []resp.Message{}
+	for _, v := range vals {
+		msgs = append(msgs, &resp.BulkString{Value: []byte(v)})
+	}
+	return &resp.Array{Value: msgs}
+}
+
+func ints(vals ...int64) *resp.Array {
+	msgs := []resp.Message{}
+	for _, v := range vals {
+		msgs = append(msgs, &resp.Int{Value: v})
+	}
+	return &resp.Array{Value: msgs}
+}
+
+func TestPushAndRange(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "RPUSH l b c"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "LPUSH l a z"))
+	assert.Equal(t, bulks("z", "a", "b", "c"), execute(e, "LRANGE l 0 -1"))
+	assert.Equal(t, bulks("a", "b"), execute(e, "LRANGE l 1 2"))
+	assert.Equal(t, bulks("c"), execute(e, "LRANGE l -1 100"))
+	assert.Equal(t, bulks(), execute(e, "LRANGE l 5 10"))
+	assert.Equal(t, bulks(), execute(e, "LRANGE missing 0 -1"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "LLEN l"))
+}
+
+func TestPushX(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "LPUSHX l a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS l"))
+
+	execute(e, "RPUSH l a")
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "LPUSHX l b"))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "RPUSHX l c"))
+	assert.Equal(t, bulks("b", "a", "c"), execute(e, "LRANGE l 0 -1"))
+}
+
+func TestPop(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH l a b c d e")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("a")}, execute(e, "LPOP l"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("e")}, execute(e, "RPOP l"))
+	assert.Equal(t, bulks("b", "c"), execute(e, "LPOP l 2"))
+	assert.Equal(t, bulks(), execute(e, "LPOP l 0"))
+	assert.Equal(t, bulks("d"), execute(e, "RPOP l 10"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS l"))
+
+	assert.Equal(t, &resp.BulkString{}, execute(e, "LPOP l"))
+	assert.Equal(t, &resp.Array{}, execute(e, "LPOP l 1"))
+	assert.Equal(t, errNotPositive, execute(e, "LPOP l -1"))
+}
+
+func TestLIndexAndLSet(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH l a b c")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("a")}, execute(e, "LINDEX l 0"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("c")}, execute(e, "LINDEX l -1"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "LINDEX l 3"))
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "LSET l -2 x"))
+	assert.Equal(t, bulks("a", "x", "c"), execute(e, "LRANGE l 0 -1"))
+	assert.Equal(t, errIndexOutOfRange, execute(e, "LSET l 3 x"))
+	assert.Equal(t, errNoSuchKey, execute(e, "LSET missing 0 x"))
+}
+
+func TestLInsert(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH l a c")
+
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "LINSERT l BEFORE c b"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "LINSERT l after c d"))
+	assert.Equal(t, &resp.Int{Value: -1}, execute(e, "LINSERT l AFTER z y"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "LINSERT missing AFTER z y"))
+	assert.Equal(t, errSyntax, execute(e, "LINSERT l AROUND c y"))
+	assert.Equal(t, bulks("a", "b", "c", "d"), execute(e, "LRANGE l 0 -1"))
+}
+
+func TestLRem(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH l a b a c a")
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "LREM l -1 a"))
+	assert.Equal(t, bulks("a", "b", "a", "c"), execute(e, "LRANGE l 0 -1"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "LREM l 1 a"))
+	assert.Equal(t, bulks("b", "a", "c"), execute(e, "LRANGE l 0 -1"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "LREM l 0 a"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "LREM l 0 b"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "LREM l 0 c"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS l"))
+}
+
+func TestLTrim(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH l a b c d e")
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "LTRIM l 1 -2"))
+	assert.Equal(t, bulks("b", "c", "d"), execute(e, "LRANGE l 0 -1"))
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "LTRIM l 5 10"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS l"))
+}
+
+func TestLPos(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH l a b c 1 2 3 c c")
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "LPOS l c"))
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "LPOS l c RANK 2"))
+	assert.Equal(t, &resp.Int{Value: 7}, execute(e, "LPOS l c RANK -1"))
+	assert.Equal(t, ints(2, 6), execute(e, "LPOS l c COUNT 2"))
+	assert.Equal(t, ints(2, 6, 7), execute(e, "LPOS l c COUNT 0"))
+	assert.Equal(t, ints(7, 6), execute(e, "LPOS l c RANK -1 COUNT 2"))
+	assert.Equal(t, ints(2), execute(e, "LPOS l c COUNT 0 MAXLEN 6"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "LPOS l z"))
+	assert.Equal(t, ints(), execute(e, "LPOS l z COUNT 1"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "LPOS missing z"))
+
+	assert.Equal(t, &resp.Error{Value: "ERR COUNT can't be negative"}, execute(e, "LPOS l c COUNT -1"))
+	assert.Equal(t, errSyntax, execute(e, "LPOS l c RANK"))
+}
+
+func TestLMove(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "RPUSH src a b c")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("c")}, execute(e, "LMOVE src dst RIGHT LEFT"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("a")}, execute(e, "LMOVE src dst LEFT RIGHT"))
+	assert.Equal(t, bulks("b"), execute(e, "LRANGE src 0 -1"))
+	assert.Equal(t, bulks("c", "a"), execute(e, "LRANGE dst 0 -1"))
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("c")}, execute(e, "LMOVE dst dst LEFT RIGHT"))
+	assert.Equal(t, bulks("a", "c"), execute(e, "LRANGE dst 0 -1"))
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("b")}, execute(e, "LMOVE src src LEFT RIGHT"))
+	assert.Equal(t, bulks("b"), execute(e, "LRANGE src 0 -1"))
+
+	assert.Equal(t, &resp.BulkString{}, execute(e, "LMOVE missing dst LEFT LEFT"))
+	assert.Equal(t, errSyntax, execute(e, "LMOVE src dst UP LEFT"))
+}
+
+func TestListWrongType(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET s value")
+	execute(e, "RPUSH l a")
+
+	assert.Equal(t, errWrongType, execute(e, "LPUSH s a"))
+	assert.Equal(t, errWrongType, execute(e, "LRANGE s 0 -1"))
+	assert.Equal(t, errWrongType, execute(e, "LMOVE l s LEFT LEFT"))
+	assert.Equal(t, bulks("a"), execute(e, "LRANGE l 0 -1"))
+
+	assert.Equal(t, errWrongType, execute(e, "GET l"))
+	assert.Equal(t, errWrongType, execute(e, "INCR l"))
+	assert.Equal(t, errWrongType, execute(e, "APPEND l x"))
+
+	// MGET reports keys holding other types as missing
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("value")},
+		&resp.BulkString{},
+	}}, execute(e, "MGET s l"))
+}
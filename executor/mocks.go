@@ -1,11 +1,15 @@
 package executor
 
-import "github.com/scnewma/godb/resp"
+import (
+	"context"
+
+	"github.com/scnewma/godb/resp"
+)
 
 type MockExecutor struct {
-	ExecuteFn func(Command) resp.Message
+	ExecuteFn func(context.Context, Command) resp.Message
 }
 
-func (e MockExecutor) Execute(command Command) resp.Message {
-	return e.ExecuteFn(command)
+func (e MockExecutor) Execute(ctx context.Context, command Command) resp.Message {
+	return e.ExecuteFn(ctx, command)
 }
@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+)
+
+const MONITOR = "MONITOR"
+
+// monitorRegistry tracks the clients that receive every command executed,
+// as enabled by MONITOR.
+type monitorRegistry struct {
+	// count is the number of monitoring clients, read without locking so
+	// that commands cost nothing more while nobody is monitoring.
+	count atomic.Int64
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+}
+
+func newMonitorRegistry() *monitorRegistry {
+	return &monitorRegistry{clients: make(map[*Client]struct{})}
+}
+
+func (r *monitorRegistry) add(c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients[c] = struct{}{}
+	r.count.Store(int64(len(r.clients)))
+}
+
+func (r *monitorRegistry) remove(c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.clients, c)
+	r.count.Store(int64(len(r.clients)))
+}
+
+// push pushes msg to every monitoring client.
+func (r *monitorRegistry) push(msg resp.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for c := range r.clients {
+		c.push(msg)
+	}
+}
+
+// executeMonitor makes the client receive a line for every command executed
+// by any client from now on, until it is reset or closed.
+func (ce *compositeExecutor) executeMonitor(c *Client, _ [][]byte) resp.Message {
+	if c == nil {
+		return errNoClient
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.onClose("monitor", func() { ce.monitors.remove(c) })
+	c.monitoring = true
+	c.conn.KeepAlive(true)
+
+	// the reply is pushed before monitoring so that it can't be overtaken
+	// by the line of a command, which is pushed too
+	c.push(&resp.SimpleString{Value: "OK"})
+	ce.monitors.add(c)
+
+	return nil
+}
+
+// unmonitor stops the client receiving the commands executed.
+func (ce *compositeExecutor) unmonitor(c *Client) {
+	ce.monitors.remove(c)
+
+	c.mu.Lock()
+	c.monitoring = false
+	c.mu.Unlock()
+}
+
+// feedMonitors sends the command called name, about to run against the
+// database at index db, to the monitoring clients. Admin commands aren't
+// sent, and passwords are redacted.
+func (ce *compositeExecutor) feedMonitors(c *Client, db int, name string, args [][]byte) {
+	if ce.monitors.count.Load() == 0 || hasCategory(categories[name], "admin") {
+		return
+	}
+
+	var addr string
+	if c != nil {
+		if a := c.conn.RemoteAddr(); a != nil {
+			addr = " " + a.String()
+		}
+	}
+
+	now := time.Now()
+	var line strings.Builder
+	fmt.Fprintf(&line, "%d.%06d [%d%s]", now.Unix(), now.Nanosecond()/1000, db, addr)
+	line.WriteString(" " + quoteArg([]byte(strings.ToLower(name))))
+	for _, arg := range redact(name, args) {
+		line.WriteString(" " + quoteArg(arg))
+	}
+
+	ce.monitors.push(&resp.SimpleString{Value: line.String()})
+}
+
+// quoteArg quotes arg the way MONITOR shows arguments, escaping quotes,
+// backslashes and unprintable bytes.
+func quoteArg(arg []byte) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range arg {
+		switch c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if c < ' ' || c > '~' {
+				fmt.Fprintf(&b, `\x%02x`, c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
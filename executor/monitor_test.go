@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+// monitorLines returns the lines pushed to a monitoring client, without
+// their timestamps.
+func monitorLines(t *testing.T, pushed []resp.Message) []string {
+	lines := []string{}
+	for _, msg := range pushed {
+		line := msg.(*resp.SimpleString).Value
+		if assert.Regexp(t, `^\d+\.\d{6} \[`, line) {
+			lines = append(lines, line[len("1700000000.000000 "):])
+		}
+	}
+
+	return lines
+}
+
+func TestMonitor(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage(), inmem.NewStorage())
+	monitor, monitorConn := newTestClient(1)
+	c, conn := newTestClient(2)
+	conn.Addr = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}
+
+	assert.Nil(t, executeAs(e, monitor, "MONITOR"))
+	assert.Equal(t, []resp.Message{ok}, monitorConn.Flush())
+	assert.True(t, monitorConn.KeptAlive)
+
+	executeAs(e, c, "SELECT 1")
+	e.Execute(context.Background(), Command{Name: "set", Args: asArgs("k", "a \"b\"\n\x01"), Client: c})
+	executeAs(e, c, "AUTH secret")
+	executeAs(e, c, "CONFIG GET *")
+	executeAs(e, c, "NOPE")
+	execute(e, "GET k")
+
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "INCR n")
+	executeAs(e, c, "EXEC")
+
+	assert.Equal(t, []string{
+		`[0 10.0.0.1:4000] "select" "1"`,
+		`[1 10.0.0.1:4000] "set" "k" "a \"b\"\n\x01"`,
+		`[1 10.0.0.1:4000] "auth" "(redacted)"`,
+		`[0] "get" "k"`,
+		`[1 10.0.0.1:4000] "multi"`,
+		`[1 10.0.0.1:4000] "exec"`,
+		`[1 10.0.0.1:4000] "incr" "n"`,
+	}, monitorLines(t, monitorConn.Flush()))
+
+	// the monitoring client stays kept alive until it is reset
+	executeAs(e, monitor, "SUBSCRIBE news")
+	executeAs(e, monitor, "UNSUBSCRIBE news")
+	assert.True(t, monitorConn.KeptAlive)
+	monitorConn.Flush()
+
+	assert.Equal(t, &resp.SimpleString{Value: "RESET"}, executeAs(e, monitor, "RESET"))
+	assert.False(t, monitorConn.KeptAlive)
+	assert.Equal(t, []string{`[0] "reset"`}, monitorLines(t, monitorConn.Flush()))
+	executeAs(e, c, "PING")
+	assert.Empty(t, monitorConn.Flush())
+	assert.Equal(t, int64(0), e.monitors.count.Load())
+}
+
+func TestMonitorClose(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	monitor, monitorConn := newTestClient(1)
+
+	executeAs(e, monitor, "MONITOR")
+	monitorConn.Flush()
+	executeAs(e, monitor, "PING")
+	assert.Equal(t, []string{`[0] "ping"`}, monitorLines(t, monitorConn.Flush()))
+
+	monitor.Close()
+	assert.Equal(t, int64(0), e.monitors.count.Load())
+	execute(e, "PING")
+	assert.Empty(t, monitorConn.Flush())
+
+	c, _ := newTestClient(2)
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, errNotInMulti, executeAs(e, c, "MONITOR"))
+	assert.Equal(t, errNoClient, execute(e, "MONITOR"))
+}
@@ -0,0 +1,327 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scnewma/godb/pubsub"
+	"github.com/scnewma/godb/resp"
+)
+
+const (
+	SUBSCRIBE    = "SUBSCRIBE"
+	UNSUBSCRIBE  = "UNSUBSCRIBE"
+	PSUBSCRIBE   = "PSUBSCRIBE"
+	PUNSUBSCRIBE = "PUNSUBSCRIBE"
+	SSUBSCRIBE   = "SSUBSCRIBE"
+	SUNSUBSCRIBE = "SUNSUBSCRIBE"
+	PUBLISH      = "PUBLISH"
+	SPUBLISH     = "SPUBLISH"
+	PUBSUB       = "PUBSUB"
+)
+
+var errNoClient = &resp.Error{Value: "ERR this command requires a client connection"}
+
+// subscriberCommands are the commands a RESP2 client may run while it is
+// subscribed to a channel or pattern.
+var subscriberCommands = map[string]bool{
+	SUBSCRIBE:    true,
+	UNSUBSCRIBE:  true,
+	PSUBSCRIBE:   true,
+	PUNSUBSCRIBE: true,
+	SSUBSCRIBE:   true,
+	SUNSUBSCRIBE: true,
+	PING:         true,
+	QUIT:         true,
+	RESET:        true,
+}
+
+func subscriberModeError(command string) *resp.Error {
+	return &resp.Error{Value: fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(command))}
+}
+
+// Deliver pushes a message published to a channel or pattern the client is
+// subscribed to.
+func (c *Client) Deliver(msg pubsub.Message) {
+	if msg.Pattern != "" {
+		c.push(messagePush("pmessage", msg.Pattern, msg.Channel, string(msg.Payload)))
+		return
+	}
+
+	c.push(messagePush("message", msg.Channel, string(msg.Payload)))
+}
+
+// shardSubscriber receives the messages of the shard channels a client is
+// subscribed to, which are told apart from the others in the pushes.
+type shardSubscriber struct {
+	c *Client
+}
+
+func (s shardSubscriber) Deliver(msg pubsub.Message) {
+	s.c.push(messagePush("smessage", msg.Channel, string(msg.Payload)))
+}
+
+// messagePush builds the push of a published message from its fields.
+func messagePush(fields ...string) *resp.Array {
+	msgs := make([]resp.Message, 0, len(fields))
+	for _, f := range fields {
+		msgs = append(msgs, &resp.BulkString{Value: []byte(f)})
+	}
+
+	return &resp.Array{Value: msgs}
+}
+
+// subscriptionKind describes one of the kinds of subscriptions: channels,
+// patterns and shard channels.
+type subscriptionKind struct {
+	subscribe, unsubscribe string
+
+	// names returns the client's subscriptions of this kind.
+	names func(c *Client) map[string]struct{}
+	// count returns the number of subscriptions reported in replies.
+	count func(c *Client) int
+
+	add    func(ce *compositeExecutor, c *Client, name string)
+	remove func(ce *compositeExecutor, c *Client, name string)
+}
+
+var (
+	channelSubscriptions = &subscriptionKind{
+		subscribe:   "subscribe",
+		unsubscribe: "unsubscribe",
+		names:       func(c *Client) map[string]struct{} { return c.channels },
+		count:       func(c *Client) int { return len(c.channels) + len(c.patterns) },
+		add:         func(ce *compositeExecutor, c *Client, name string) { ce.hub.Subscribe(c, name) },
+		remove:      func(ce *compositeExecutor, c *Client, name string) { ce.hub.Unsubscribe(c, name) },
+	}
+
+	patternSubscriptions = &subscriptionKind{
+		subscribe:   "psubscribe",
+		unsubscribe: "punsubscribe",
+		names:       func(c *Client) map[string]struct{} { return c.patterns },
+		count:       func(c *Client) int { return len(c.channels) + len(c.patterns) },
+		add:         func(ce *compositeExecutor, c *Client, name string) { ce.hub.PSubscribe(c, name) },
+		remove:      func(ce *compositeExecutor, c *Client, name string) { ce.hub.PUnsubscribe(c, name) },
+	}
+
+	shardSubscriptions = &subscriptionKind{
+		subscribe:   "ssubscribe",
+		unsubscribe: "sunsubscribe",
+		names:       func(c *Client) map[string]struct{} { return c.shardChannels },
+		count:       func(c *Client) int { return len(c.shardChannels) },
+		add:         func(ce *compositeExecutor, c *Client, name string) { ce.shardHub.Subscribe(shardSubscriber{c}, name) },
+		remove:      func(ce *compositeExecutor, c *Client, name string) { ce.shardHub.Unsubscribe(shardSubscriber{c}, name) },
+	}
+)
+
+func subscriptionReply(kind string, name *string, count int) resp.Message {
+	var nameReply resp.Message = &resp.BulkString{}
+	if name != nil {
+		nameReply = &resp.BulkString{Value: []byte(*name)}
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(kind)},
+		nameReply,
+		&resp.Int{Value: int64(count)},
+	}}
+}
+
+func (ce *compositeExecutor) executeSubscribe(c *Client, args [][]byte) resp.Message {
+	return ce.subscribe(SUBSCRIBE, channelSubscriptions, c, args)
+}
+
+func (ce *compositeExecutor) executePSubscribe(c *Client, args [][]byte) resp.Message {
+	return ce.subscribe(PSUBSCRIBE, patternSubscriptions, c, args)
+}
+
+func (ce *compositeExecutor) executeSSubscribe(c *Client, args [][]byte) resp.Message {
+	return ce.subscribe(SSUBSCRIBE, shardSubscriptions, c, args)
+}
+
+func (ce *compositeExecutor) executeUnsubscribe(c *Client, args [][]byte) resp.Message {
+	return ce.unsubscribe(channelSubscriptions, c, args)
+}
+
+func (ce *compositeExecutor) executePUnsubscribe(c *Client, args [][]byte) resp.Message {
+	return ce.unsubscribe(patternSubscriptions, c, args)
+}
+
+func (ce *compositeExecutor) executeSUnsubscribe(c *Client, args [][]byte) resp.Message {
+	return ce.unsubscribe(shardSubscriptions, c, args)
+}
+
+// subscribe subscribes the client to each of the names in args. There is
+// one reply per name, so they are pushed rather than returned.
+func (ce *compositeExecutor) subscribe(command string, kind *subscriptionKind, c *Client, args [][]byte) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(command)
+	}
+	if c == nil {
+		return errNoClient
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.onClose("pubsub", func() { ce.unsubscribeAll(c) })
+
+	names := kind.names(c)
+	for _, arg := range args {
+		name := string(arg)
+		_, exists := names[name]
+		names[name] = struct{}{}
+
+		// the reply is pushed before subscribing so that it can't be
+		// overtaken by a message published to the new subscription
+		c.push(subscriptionReply(kind.subscribe, &name, kind.count(c)))
+		if !exists {
+			kind.add(ce, c, name)
+		}
+	}
+
+	c.conn.KeepAlive(true)
+
+	return nil
+}
+
+// unsubscribe unsubscribes the client from each of the names in args, or
+// from every subscription of the kind if there are none.
+func (ce *compositeExecutor) unsubscribe(kind *subscriptionKind, c *Client, args [][]byte) resp.Message {
+	if c == nil {
+		return errNoClient
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := kind.names(c)
+	if len(args) == 0 {
+		if len(names) == 0 {
+			c.push(subscriptionReply(kind.unsubscribe, nil, kind.count(c)))
+			return nil
+		}
+
+		for _, name := range sortedNames(names) {
+			args = append(args, []byte(name))
+		}
+	}
+
+	for _, arg := range args {
+		name := string(arg)
+		if _, ok := names[name]; ok {
+			delete(names, name)
+			kind.remove(ce, c, name)
+		}
+
+		c.push(subscriptionReply(kind.unsubscribe, &name, kind.count(c)))
+	}
+
+	if c.subscriptions() == 0 && !c.monitoring {
+		c.conn.KeepAlive(false)
+	}
+
+	return nil
+}
+
+// unsubscribeAll removes every subscription of a client that went away.
+func (ce *compositeExecutor) unsubscribeAll(c *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, kind := range []*subscriptionKind{channelSubscriptions, patternSubscriptions, shardSubscriptions} {
+		names := kind.names(c)
+		for name := range names {
+			delete(names, name)
+			kind.remove(ce, c, name)
+		}
+	}
+}
+
+func sortedNames(names map[string]struct{}) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func (ce *compositeExecutor) executePublish(_ *Client, args [][]byte) resp.Message {
+	if len(args) != 2 {
+		return wrongNumberOfArgs(PUBLISH)
+	}
+
+	return &resp.Int{Value: int64(ce.hub.Publish(string(args[0]), args[1]))}
+}
+
+func (ce *compositeExecutor) executeSPublish(_ *Client, args [][]byte) resp.Message {
+	if len(args) != 2 {
+		return wrongNumberOfArgs(SPUBLISH)
+	}
+
+	return &resp.Int{Value: int64(ce.shardHub.Publish(string(args[0]), args[1]))}
+}
+
+func (ce *compositeExecutor) executePubSub(_ *Client, args [][]byte) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(PUBSUB)
+	}
+
+	rawSub := args[0]
+	sub := strings.ToUpper(string(rawSub))
+	args = args[1:]
+	switch sub {
+	case "CHANNELS", "SHARDCHANNELS":
+		if len(args) > 1 {
+			return wrongNumberOfArgs(PUBSUB + "|" + sub)
+		}
+
+		hub := ce.hub
+		if sub == "SHARDCHANNELS" {
+			hub = ce.shardHub
+		}
+
+		var pattern string
+		if len(args) == 1 {
+			pattern = string(args[0])
+		}
+
+		channels := hub.Channels(pattern)
+		reply := make([]resp.Message, 0, len(channels))
+		for _, channel := range channels {
+			reply = append(reply, &resp.BulkString{Value: []byte(channel)})
+		}
+
+		return &resp.Array{Value: reply}
+	case "NUMSUB", "SHARDNUMSUB":
+		hub := ce.hub
+		if sub == "SHARDNUMSUB" {
+			hub = ce.shardHub
+		}
+
+		reply := make([]resp.Message, 0, len(args)*2)
+		for _, channel := range args {
+			reply = append(reply,
+				&resp.BulkString{Value: channel},
+				&resp.Int{Value: int64(hub.NumSub(string(channel)))},
+			)
+		}
+
+		return &resp.Array{Value: reply}
+	case "NUMPAT":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(PUBSUB + "|" + sub)
+		}
+
+		return &resp.Int{Value: int64(ce.hub.NumPat())}
+	default:
+		return unknownSubcommand(PUBSUB, rawSub)
+	}
+}
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/resp/resptest"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+func executeAs(e Executor, c *Client, command string) resp.Message {
+	parts := strings.Split(command, " ")
+	return e.Execute(context.Background(), Command{Name: parts[0], Args: asArgs(parts[1:]...), Client: c})
+}
+
+func newTestClient(id uint64) (*Client, *resptest.ConnRecorder) {
+	conn := resptest.NewConnRecorder(id)
+	return NewClient(conn), conn
+}
+
+func subscription(kind, name string, count int64) resp.Message {
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(kind)},
+		&resp.BulkString{Value: []byte(name)},
+		&resp.Int{Value: count},
+	}}
+}
+
+func TestSubscribeAndPublish(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	sub, conn := newTestClient(1)
+
+	assert.Nil(t, executeAs(e, sub, "SUBSCRIBE news sports news"))
+	assert.Equal(t, []resp.Message{
+		subscription("subscribe", "news", 1),
+		subscription("subscribe", "sports", 2),
+		subscription("subscribe", "news", 2),
+	}, conn.Flush())
+	assert.True(t, conn.KeptAlive)
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "PUBLISH news hello"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBLISH weather sunny"))
+	assert.Equal(t, []resp.Message{bulks("message", "news", "hello")}, conn.Flush())
+
+	assert.Nil(t, executeAs(e, sub, "UNSUBSCRIBE news"))
+	assert.Equal(t, []resp.Message{subscription("unsubscribe", "news", 1)}, conn.Flush())
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBLISH news hello"))
+
+	// without arguments every channel is unsubscribed from
+	executeAs(e, sub, "SUBSCRIBE a")
+	conn.Flush()
+	assert.Nil(t, executeAs(e, sub, "UNSUBSCRIBE"))
+	assert.Equal(t, []resp.Message{
+		subscription("unsubscribe", "a", 1),
+		subscription("unsubscribe", "sports", 0),
+	}, conn.Flush())
+	assert.False(t, conn.KeptAlive)
+
+	assert.Nil(t, executeAs(e, sub, "UNSUBSCRIBE"))
+	assert.Equal(t, []resp.Message{
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("unsubscribe")},
+			&resp.BulkString{},
+			&resp.Int{Value: 0},
+		}},
+	}, conn.Flush())
+}
+
+func TestPatternSubscriptions(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	sub, conn := newTestClient(1)
+
+	executeAs(e, sub, "SUBSCRIBE news.tech")
+	assert.Nil(t, executeAs(e, sub, "PSUBSCRIBE news.* s?orts"))
+	assert.Equal(t, []resp.Message{
+		subscription("subscribe", "news.tech", 1),
+		subscription("psubscribe", "news.*", 2),
+		subscription("psubscribe", "s?orts", 3),
+	}, conn.Flush())
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "PUBLISH news.tech go"))
+	assert.Equal(t, []resp.Message{
+		bulks("message", "news.tech", "go"),
+		bulks("pmessage", "news.*", "news.tech", "go"),
+	}, conn.Flush())
+
+	assert.Nil(t, executeAs(e, sub, "PUNSUBSCRIBE"))
+	assert.Equal(t, []resp.Message{
+		subscription("punsubscribe", "news.*", 2),
+		subscription("punsubscribe", "s?orts", 1),
+	}, conn.Flush())
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBLISH sports ball"))
+}
+
+func TestShardSubscriptions(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	sub, conn := newTestClient(1)
+
+	executeAs(e, sub, "SUBSCRIBE news")
+	assert.Nil(t, executeAs(e, sub, "SSUBSCRIBE news"))
+	assert.Equal(t, []resp.Message{
+		subscription("subscribe", "news", 1),
+		subscription("ssubscribe", "news", 1),
+	}, conn.Flush())
+
+	// shard channels are separate from the other channels
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SPUBLISH news a"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "PUBLISH news b"))
+	assert.Equal(t, []resp.Message{
+		bulks("smessage", "news", "a"),
+		bulks("message", "news", "b"),
+	}, conn.Flush())
+
+	assert.Nil(t, executeAs(e, sub, "SUNSUBSCRIBE news"))
+	assert.Equal(t, []resp.Message{subscription("sunsubscribe", "news", 0)}, conn.Flush())
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SPUBLISH news a"))
+}
+
+func TestSubscriberMode(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	sub, _ := newTestClient(1)
+
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, executeAs(e, sub, "SET a b"))
+
+	executeAs(e, sub, "PSUBSCRIBE *")
+	assert.Equal(t, &resp.Error{Value: "ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"}, executeAs(e, sub, "get a"))
+	assert.Equal(t, &resp.Error{Value: "unknown command"}, executeAs(e, sub, "NOSUCHCOMMAND"))
+	assert.Nil(t, executeAs(e, sub, "SUBSCRIBE a"))
+
+	executeAs(e, sub, "PUNSUBSCRIBE")
+	executeAs(e, sub, "UNSUBSCRIBE")
+	assert.Equal(t, &resp.BulkString{Value: []byte("b")}, executeAs(e, sub, "GET a"))
+}
+
+func TestClientCloseUnsubscribes(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	sub, _ := newTestClient(1)
+
+	executeAs(e, sub, "SUBSCRIBE a")
+	executeAs(e, sub, "PSUBSCRIBE *")
+	executeAs(e, sub, "SSUBSCRIBE a")
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "PUBLISH a x"))
+
+	sub.Close()
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBLISH a x"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SPUBLISH a x"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBSUB NUMPAT"))
+
+	// a closed client can't subscribe again
+	executeAs(e, sub, "SUBSCRIBE a")
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "PUBLISH a x"))
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	a, _ := newTestClient(1)
+	b, _ := newTestClient(2)
+
+	executeAs(e, a, "SUBSCRIBE news.tech sports")
+	executeAs(e, b, "SUBSCRIBE news.tech")
+	executeAs(e, a, "PSUBSCRIBE news.*")
+	executeAs(e, b, "PSUBSCRIBE news.* *")
+	executeAs(e, b, "SSUBSCRIBE shard")
+
+	assert.Equal(t, bulks("news.tech", "sports"), execute(e, "PUBSUB CHANNELS"))
+	assert.Equal(t, bulks("news.tech"), execute(e, "PUBSUB channels news.*"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("news.tech")}, &resp.Int{Value: 2},
+		&resp.BulkString{Value: []byte("weather")}, &resp.Int{Value: 0},
+	}}, execute(e, "PUBSUB NUMSUB news.tech weather"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, execute(e, "PUBSUB NUMSUB"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "PUBSUB NUMPAT"))
+	assert.Equal(t, bulks("shard"), execute(e, "PUBSUB SHARDCHANNELS"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("shard")}, &resp.Int{Value: 1},
+	}}, execute(e, "PUBSUB SHARDNUMSUB shard"))
+
+	assert.Equal(t, wrongNumberOfArgs("PUBSUB|CHANNELS"), execute(e, "PUBSUB CHANNELS a b"))
+	assert.Equal(t, wrongNumberOfArgs("PUBSUB|NUMPAT"), execute(e, "PUBSUB NUMPAT a"))
+	assert.Equal(t, &resp.Error{Value: "ERR unknown subcommand 'foo'. Try PUBSUB HELP."}, execute(e, "PUBSUB foo"))
+}
+
+func TestPubSubErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, errNoClient, execute(e, "SUBSCRIBE a"))
+	assert.Equal(t, errNoClient, execute(e, "UNSUBSCRIBE"))
+	assert.Equal(t, wrongNumberOfArgs(SUBSCRIBE), execute(e, "SUBSCRIBE"))
+	assert.Equal(t, wrongNumberOfArgs(PUBLISH), execute(e, "PUBLISH a"))
+	assert.Equal(t, wrongNumberOfArgs(SPUBLISH), execute(e, "SPUBLISH a b c"))
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package executor
+
+import "time"
+
+// cpuTimes returns the system and user CPU time used by the process, which
+// is only known on Unix systems.
+func cpuTimes() (sys, user time.Duration) {
+	return 0, 0
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/scnewma/godb/glob"
+	"github.com/scnewma/godb/resp"
+)
+
+var errInvalidCursor = &resp.Error{Value: "ERR invalid cursor"}
+
+// scanOptions are the arguments shared by the SCAN family of commands.
+type scanOptions struct {
+	cursor uint64
+	match  []byte
+	count  int
+}
+
+// parseScanOptions parses a cursor followed by MATCH and COUNT options.
+// extra is called with any other option and the arguments following it, and
+// returns how many of those arguments it consumed, or false if it doesn't
+// recognize the option.
+func parseScanOptions(args [][]byte, extra func(opt string, rest [][]byte) (int, bool)) (*scanOptions, resp.Message) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	opts := &scanOptions{cursor: cursor, count: 10}
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "MATCH" && i+1 < len(args):
+			opts.match = args[i+1]
+			i++
+		case opt == "COUNT" && i+1 < len(args):
+			count, ok := parseInt(args[i+1])
+			if !ok {
+				return nil, errNotInteger
+			}
+			if count < 1 {
+				return nil, errSyntax
+			}
+			opts.count = int(count)
+			i++
+		default:
+			if extra == nil {
+				return nil, errSyntax
+			}
+			n, ok := extra(opt, args[i+1:])
+			if !ok {
+				return nil, errSyntax
+			}
+			i += n
+		}
+	}
+
+	return opts, nil
+}
+
+// matches reports whether key passes the MATCH filter.
+func (opts *scanOptions) matches(key string) bool {
+	if opts.match == nil {
+		return true
+	}
+
+	// "*" matches everything, skip the work
+	if len(opts.match) == 1 && opts.match[0] == '*' {
+		return true
+	}
+
+	return glob.Match(opts.match, []byte(key))
+}
+
+// scan calls step until it has returned at least count elements or the
+// iteration completes. step visits a batch of elements starting at the
+// cursor and returns the next cursor along with how many elements it
+// visited. The number of steps is bounded so that a sparse table can't keep
+// the storage locked for long.
+func (opts *scanOptions) scan(step func(cursor uint64) (uint64, int)) uint64 {
+	cursor := opts.cursor
+	maxSteps := opts.count * 10
+	visited := 0
+	for steps := 0; steps < maxSteps; steps++ {
+		var n int
+		cursor, n = step(cursor)
+		visited += n
+		if cursor == 0 || visited >= opts.count {
+			break
+		}
+	}
+
+	return cursor
+}
+
+// scanReply builds the two element reply of the SCAN family of commands.
+func scanReply(cursor uint64, elements []resp.Message) resp.Message {
+	if elements == nil {
+		elements = []resp.Message{}
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: strconv.AppendUint(nil, cursor, 10)},
+		&resp.Array{Value: elements},
+	}}
+}
@@ -0,0 +1,651 @@
+package executor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/set"
+)
+
+const (
+	SADD        = "SADD"
+	SREM        = "SREM"
+	SISMEMBER   = "SISMEMBER"
+	SMISMEMBER  = "SMISMEMBER"
+	SMEMBERS    = "SMEMBERS"
+	SCARD       = "SCARD"
+	SPOP        = "SPOP"
+	SRANDMEMBER = "SRANDMEMBER"
+	SMOVE       = "SMOVE"
+	SINTER      = "SINTER"
+	SINTERSTORE = "SINTERSTORE"
+	SINTERCARD  = "SINTERCARD"
+	SUNION      = "SUNION"
+	SUNIONSTORE = "SUNIONSTORE"
+	SDIFF       = "SDIFF"
+	SDIFFSTORE  = "SDIFFSTORE"
+	SSCAN       = "SSCAN"
+)
+
+var (
+	errNumKeys         = &resp.Error{Value: "ERR numkeys should be greater than 0"}
+	errNumKeysTooLarge = &resp.Error{Value: "ERR Number of keys can't be greater than number of args"}
+	errLimitNegative   = &resp.Error{Value: "ERR LIMIT can't be negative"}
+	errRandomCount     = &resp.Error{Value: "ERR value is out of range"}
+)
+
+// maxRandomCount bounds the negative counts of SRANDMEMBER and HRANDFIELD.
+// Those may repeat elements, so the reply holds -count of them however small
+// the value is, and it is built while the database is locked.
+const maxRandomCount = 1 << 20
+
+// getSet looks up the set stored at key. The set is nil if the key does not
+// exist.
+func getSet(db storage.Storage, key string) (*set.Set, resp.Message) {
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, nil
+		}
+
+		return nil, genericErrorMessage
+	}
+
+	s, ok := node.Value().(*set.Set)
+	if !ok {
+		return nil, errWrongType
+	}
+
+	return s, nil
+}
+
+// getSets looks up the sets stored at each of keys. Missing keys are
+// returned as nil sets.
+func getSets(db storage.Storage, keys []string) ([]*set.Set, resp.Message) {
+	sets := make([]*set.Set, 0, len(keys))
+	for _, key := range keys {
+		s, errMsg := getSet(db, key)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		sets = append(sets, s)
+	}
+
+	return sets, nil
+}
+
+// setMembers builds a reply holding every member of s.
+func setMembers(s *set.Set) *resp.Array {
+	vals := make([]resp.Message, 0, s.Len())
+	s.Range(func(member string) bool {
+		vals = append(vals, &resp.BulkString{Value: []byte(member)})
+		return true
+	})
+
+	return &resp.Array{Value: vals}
+}
+
+func executeSAdd(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	members := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		s = set.New()
+		db.Set(key, storage.NewSetNode(s))
+	}
+
+	var added int64
+	for _, member := range members {
+		if s.Add(member) {
+			added++
+		}
+	}
+
+	return &resp.Int{Value: added}
+}
+
+func executeSRem(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	members := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	var removed int64
+	for _, member := range members {
+		if s.Remove(member) {
+			removed++
+		}
+	}
+
+	if s.Len() == 0 {
+		db.Del(key)
+	}
+
+	return &resp.Int{Value: removed}
+}
+
+func executeSIsMember(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	member := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil || !s.Contains(member) {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: 1}
+}
+
+func executeSMIsMember(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	members := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := make([]resp.Message, 0, len(members))
+	for _, member := range members {
+		if s != nil && s.Contains(member) {
+			vals = append(vals, &resp.Int{Value: 1})
+		} else {
+			vals = append(vals, &resp.Int{Value: 0})
+		}
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+func executeSMembers(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Array{Value: []resp.Message{}}
+	}
+
+	return setMembers(s)
+}
+
+func executeSCard(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(s.Len())}
+}
+
+// executeSPop removes and returns random members of a set. Without a count a
+// single member is returned.
+func executeSPop(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 2 {
+		return errSyntax
+	}
+
+	hasCount := len(args) == 2
+	var count int64
+	if hasCount {
+		var ok bool
+		count, ok = parseInt(args[1])
+		if !ok || count < 0 {
+			return errNotPositive
+		}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		if hasCount {
+			return &resp.Array{Value: []resp.Message{}}
+		}
+		return &resp.BulkString{}
+	}
+
+	if !hasCount {
+		member, _ := s.Pop()
+		if s.Len() == 0 {
+			db.Del(key)
+		}
+		return &resp.BulkString{Value: []byte(member)}
+	}
+
+	if count >= int64(s.Len()) {
+		db.Del(key)
+		return setMembers(s)
+	}
+
+	vals := make([]resp.Message, 0, count)
+	for ; count > 0; count-- {
+		member, _ := s.Pop()
+		vals = append(vals, &resp.BulkString{Value: []byte(member)})
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+// executeSRandMember returns random members of a set. Without a count a
+// single member is returned. A positive count returns up to count distinct
+// members, while a negative count returns exactly -count members which may
+// repeat.
+func executeSRandMember(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 2 {
+		return errSyntax
+	}
+
+	hasCount := len(args) == 2
+	var count int64
+	if hasCount {
+		var ok bool
+		count, ok = parseInt(args[1])
+		if !ok {
+			return errNotInteger
+		}
+		if count < -maxRandomCount {
+			return errRandomCount
+		}
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if !hasCount {
+		if s == nil {
+			return &resp.BulkString{}
+		}
+		member, _ := s.Random()
+		return &resp.BulkString{Value: []byte(member)}
+	}
+
+	vals := []resp.Message{}
+	if s == nil || count == 0 {
+		return &resp.Array{Value: vals}
+	}
+
+	if count < 0 {
+		for ; count < 0; count++ {
+			member, _ := s.Random()
+			vals = append(vals, &resp.BulkString{Value: []byte(member)})
+		}
+		return &resp.Array{Value: vals}
+	}
+
+	if count >= int64(s.Len()) {
+		return setMembers(s)
+	}
+
+	// when most of the set is requested, picking distinct random members
+	// would keep hitting the ones already picked, so remove random members
+	// from a copy instead
+	if count*3 > int64(s.Len()) {
+		sample := s.Clone()
+		for int64(sample.Len()) > count {
+			sample.Pop()
+		}
+		return setMembers(sample)
+	}
+
+	picked := make(map[string]bool, count)
+	for int64(len(picked)) < count {
+		member, _ := s.Random()
+		if picked[member] {
+			continue
+		}
+		picked[member] = true
+		vals = append(vals, &resp.BulkString{Value: []byte(member)})
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+func executeSMove(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	src := ae.ExtractStringAt(0)
+	dest := ae.ExtractStringAt(1)
+	member := ae.ExtractStringAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	srcSet, errMsg := getSet(db, src)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	destSet, errMsg := getSet(db, dest)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if srcSet == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	if src == dest {
+		if srcSet.Contains(member) {
+			return &resp.Int{Value: 1}
+		}
+		return &resp.Int{Value: 0}
+	}
+
+	if !srcSet.Remove(member) {
+		return &resp.Int{Value: 0}
+	}
+
+	if srcSet.Len() == 0 {
+		db.Del(src)
+	}
+
+	if destSet == nil {
+		destSet = set.New()
+		db.Set(dest, storage.NewSetNode(destSet))
+	}
+	destSet.Add(member)
+
+	return &resp.Int{Value: 1}
+}
+
+// setOperation computes a new set from the sets stored at some keys.
+type setOperation func(sets []*set.Set) *set.Set
+
+// setInter returns the members present in every set. A nil set is empty.
+func setInter(sets []*set.Set) *set.Set {
+	result := set.New()
+	for _, s := range sets {
+		if s == nil {
+			return result
+		}
+	}
+
+	// check the members of the smallest set against the others
+	sorted := append([]*set.Set(nil), sets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Len() < sorted[j].Len() })
+
+	sorted[0].Range(func(member string) bool {
+		for _, other := range sorted[1:] {
+			if !other.Contains(member) {
+				return true
+			}
+		}
+		result.Add(member)
+		return true
+	})
+
+	return result
+}
+
+// setUnion returns the members present in any set.
+func setUnion(sets []*set.Set) *set.Set {
+	result := set.New()
+	for _, s := range sets {
+		if s == nil {
+			continue
+		}
+		s.Range(func(member string) bool {
+			result.Add(member)
+			return true
+		})
+	}
+
+	return result
+}
+
+// setDiff returns the members of the first set that are in none of the
+// others.
+func setDiff(sets []*set.Set) *set.Set {
+	if sets[0] == nil {
+		return set.New()
+	}
+
+	result := sets[0].Clone()
+	for _, s := range sets[1:] {
+		if s == nil {
+			continue
+		}
+		s.Range(func(member string) bool {
+			result.Remove(member)
+			return result.Len() > 0
+		})
+	}
+
+	return result
+}
+
+func executeSInter(args [][]byte, db storage.Storage) resp.Message {
+	return setAlgebra(args, db, setInter)
+}
+
+func executeSUnion(args [][]byte, db storage.Storage) resp.Message {
+	return setAlgebra(args, db, setUnion)
+}
+
+func executeSDiff(args [][]byte, db storage.Storage) resp.Message {
+	return setAlgebra(args, db, setDiff)
+}
+
+// setAlgebra replies with the members of the set computed by op from the
+// sets stored at the keys in args.
+func setAlgebra(args [][]byte, db storage.Storage, op setOperation) resp.Message {
+	ae := newArgExtractor(args)
+	keys := ae.ExtractStringsFrom(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	sets, errMsg := getSets(db, keys)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	return setMembers(op(sets))
+}
+
+func executeSInterStore(args [][]byte, db storage.Storage) resp.Message {
+	return setAlgebraStore(args, db, setInter)
+}
+
+func executeSUnionStore(args [][]byte, db storage.Storage) resp.Message {
+	return setAlgebraStore(args, db, setUnion)
+}
+
+func executeSDiffStore(args [][]byte, db storage.Storage) resp.Message {
+	return setAlgebraStore(args, db, setDiff)
+}
+
+// setAlgebraStore stores the set computed by op at the destination key,
+// replacing whatever it held, and replies with its size.
+func setAlgebraStore(args [][]byte, db storage.Storage, op setOperation) resp.Message {
+	ae := newArgExtractor(args)
+	dest := ae.ExtractStringAt(0)
+	keys := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	sets, errMsg := getSets(db, keys)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	result := op(sets)
+	if result.Len() == 0 {
+		db.Del(dest)
+	} else {
+		db.Set(dest, storage.NewSetNode(result))
+	}
+
+	return &resp.Int{Value: int64(result.Len())}
+}
+
+// executeSInterCard counts the members of the intersection of sets without
+// building it, stopping early once LIMIT is reached.
+func executeSInterCard(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(SINTERCARD)
+	}
+
+	numKeys, ok := parseInt(args[0])
+	if !ok || numKeys <= 0 {
+		return errNumKeys
+	}
+
+	if numKeys > int64(len(args)-1) {
+		return errNumKeysTooLarge
+	}
+
+	keys := make([]string, 0, numKeys)
+	for _, key := range args[1 : 1+numKeys] {
+		keys = append(keys, string(key))
+	}
+
+	var limit int64
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		if strings.ToUpper(string(rest[i])) != "LIMIT" || i+1 >= len(rest) {
+			return errSyntax
+		}
+
+		limit, ok = parseInt(rest[i+1])
+		if !ok {
+			return errNotInteger
+		}
+		if limit < 0 {
+			return errLimitNegative
+		}
+		i++
+	}
+
+	sets, errMsg := getSets(db, keys)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	for _, s := range sets {
+		if s == nil {
+			return &resp.Int{Value: 0}
+		}
+	}
+
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Len() < sets[j].Len() })
+
+	var count int64
+	sets[0].Range(func(member string) bool {
+		for _, other := range sets[1:] {
+			if !other.Contains(member) {
+				return true
+			}
+		}
+		count++
+		return limit == 0 || count < limit
+	})
+
+	return &resp.Int{Value: count}
+}
+
+// executeSScan incrementally iterates over the members of a set.
+func executeSScan(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	opts, errMsg := parseScanOptions(args[1:], nil)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	s, errMsg := getSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return scanReply(0, nil)
+	}
+
+	var vals []resp.Message
+	cursor := opts.scan(func(cursor uint64) (uint64, int) {
+		n := 0
+		cursor = s.Scan(cursor, func(member string) {
+			n++
+			if opts.matches(member) {
+				vals = append(vals, &resp.BulkString{Value: []byte(member)})
+			}
+		})
+		return cursor, n
+	})
+
+	return scanReply(cursor, vals)
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/scnewma/godb/storage/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSAddAndMembers(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "SADD s c a b"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SADD s a d"))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, sortedBulks(t, execute(e, "SMEMBERS s")))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "SCARD s"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SISMEMBER s a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SISMEMBER s z"))
+	assert.Equal(t, ints(1, 0, 1), execute(e, "SMISMEMBER s a z d"))
+	assert.Equal(t, ints(0), execute(e, "SMISMEMBER nokey a"))
+	assert.Equal(t, bulks(), execute(e, "SMEMBERS nokey"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SCARD nokey"))
+}
+
+func TestIntegerSetsUseIntset(t *testing.T) {
+	db := inmem.NewStorage()
+	e := NewExecutor(db)
+
+	execute(e, "SADD tags 30 10 20")
+	assert.Equal(t, bulks("10", "20", "30"), execute(e, "SMEMBERS tags"))
+
+	node, err := db.Get("tags")
+	require.NoError(t, err)
+	assert.Equal(t, set.EncodingIntset, node.Value().(*set.Set).Encoding())
+
+	execute(e, "SADD tags abc")
+	assert.Equal(t, set.EncodingHashtable, node.Value().(*set.Set).Encoding())
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SISMEMBER tags 10"))
+}
+
+func TestSRemRemovesEmptySet(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SADD s a b")
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SREM s a z"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SREM s b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS s"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SREM s b"))
+}
+
+func TestSPop(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SADD s a b c d")
+
+	member := execute(e, "SPOP s").(*resp.BulkString)
+	assert.Contains(t, []string{"a", "b", "c", "d"}, string(member.Value))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SISMEMBER s "+string(member.Value)))
+
+	assert.Len(t, sortedBulks(t, execute(e, "SPOP s 2")), 2)
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SCARD s"))
+	assert.Len(t, sortedBulks(t, execute(e, "SPOP s 5")), 1)
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS s"))
+
+	assert.Equal(t, &resp.BulkString{}, execute(e, "SPOP s"))
+	assert.Equal(t, bulks(), execute(e, "SPOP s 3"))
+	assert.Equal(t, errNotPositive, execute(e, "SPOP s -1"))
+}
+
+func TestSRandMember(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SADD s a b c")
+
+	member := execute(e, "SRANDMEMBER s").(*resp.BulkString)
+	assert.Contains(t, []string{"a", "b", "c"}, string(member.Value))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "SRANDMEMBER nokey"))
+	assert.Equal(t, bulks(), execute(e, "SRANDMEMBER nokey 2"))
+	assert.Equal(t, []string{"a", "b", "c"}, sortedBulks(t, execute(e, "SRANDMEMBER s 5")))
+	assert.Len(t, execute(e, "SRANDMEMBER s -5").(*resp.Array).Value, 5)
+	assert.Len(t, execute(e, fmt.Sprintf("SRANDMEMBER s %d", -maxRandomCount)).(*resp.Array).Value, maxRandomCount)
+	assert.Equal(t, errRandomCount, execute(e, fmt.Sprintf("SRANDMEMBER s %d", -maxRandomCount-1)))
+	assert.Equal(t, errRandomCount, execute(e, fmt.Sprintf("SRANDMEMBER s %d", int64(math.MinInt64))))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "SCARD s"))
+
+	for i := 0; i < 200; i++ {
+		execute(e, fmt.Sprintf("SADD big m%d", i))
+	}
+	for _, count := range []int{10, 150} {
+		members := sortedBulks(t, execute(e, fmt.Sprintf("SRANDMEMBER big %d", count)))
+		require.Len(t, members, count)
+		for i := 1; i < len(members); i++ {
+			assert.NotEqual(t, members[i-1], members[i])
+		}
+	}
+}
+
+func TestSMove(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SADD src a b")
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SMOVE src dest a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SMOVE src dest z"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SMOVE src src b"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SMOVE src dest b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS src"))
+	assert.Equal(t, []string{"a", "b"}, sortedBulks(t, execute(e, "SMEMBERS dest")))
+
+	execute(e, "SET str v")
+	assert.Equal(t, errWrongType, execute(e, "SMOVE dest str a"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "SCARD dest"))
+}
+
+func TestSetAlgebra(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SADD a 1 2 3 4")
+	execute(e, "SADD b 3 4 5")
+	execute(e, "SADD c 4 x")
+
+	assert.Equal(t, []string{"4"}, sortedBulks(t, execute(e, "SINTER a b c")))
+	assert.Equal(t, []string{"3", "4"}, sortedBulks(t, execute(e, "SINTER a b")))
+	assert.Equal(t, bulks(), execute(e, "SINTER a missing"))
+	assert.Equal(t, []string{"1", "2", "3", "4", "5", "x"}, sortedBulks(t, execute(e, "SUNION a b c missing")))
+	assert.Equal(t, []string{"1", "2"}, sortedBulks(t, execute(e, "SDIFF a b c")))
+	assert.Equal(t, bulks(), execute(e, "SDIFF missing a"))
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "SINTERSTORE dest a b"))
+	assert.Equal(t, []string{"3", "4"}, sortedBulks(t, execute(e, "SMEMBERS dest")))
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "SUNIONSTORE dest a b c"))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "SDIFFSTORE a a c"))
+	assert.Equal(t, []string{"1", "2", "3"}, sortedBulks(t, execute(e, "SMEMBERS a")))
+
+	// an empty result deletes the destination, whatever it held
+	execute(e, "SET str v")
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SINTERSTORE str a missing"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS str"))
+
+	execute(e, "SET str v")
+	assert.Equal(t, errWrongType, execute(e, "SUNION a str"))
+}
+
+func TestSInterCard(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SADD a 1 2 3 4")
+	execute(e, "SADD b 2 3 4 5")
+
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "SINTERCARD 2 a b"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "SINTERCARD 2 a b LIMIT 2"))
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "SINTERCARD 2 a b LIMIT 0"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SINTERCARD 2 a missing"))
+
+	assert.Equal(t, errNumKeys, execute(e, "SINTERCARD 0 a"))
+	assert.Equal(t, errNumKeysTooLarge, execute(e, "SINTERCARD 3 a b"))
+	assert.Equal(t, errLimitNegative, execute(e, "SINTERCARD 2 a b LIMIT -1"))
+	assert.Equal(t, errSyntax, execute(e, "SINTERCARD 1 a b"))
+	assert.Equal(t, wrongNumberOfArgs(SINTERCARD), execute(e, "SINTERCARD 1"))
+}
+
+func TestSScan(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 300; i++ {
+		execute(e, fmt.Sprintf("SADD s m%d", i))
+	}
+
+	seen := make(map[string]bool)
+	cursor := "0"
+	for {
+		reply := execute(e, "SSCAN s "+cursor+" MATCH m1*").(*resp.Array).Value
+		cursor = string(reply[0].(*resp.BulkString).Value)
+		for _, member := range reply[1].(*resp.Array).Value {
+			seen[string(member.(*resp.BulkString).Value)] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	// m1, m10-m19 and m100-m199
+	assert.Len(t, seen, 111)
+	assert.Equal(t, scanReply(0, nil), execute(e, "SSCAN missing 0"))
+}
+
+func TestSetWrongType(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET str v")
+	execute(e, "SADD s a")
+
+	for _, cmd := range []string{"SADD str a", "SREM str a", "SISMEMBER str a", "SMEMBERS str", "SCARD str", "SPOP str", "SRANDMEMBER str", "SINTERCARD 1 str", "SSCAN str 0"} {
+		assert.Equal(t, errWrongType, execute(e, cmd), cmd)
+	}
+	assert.Equal(t, errWrongType, execute(e, "HGET s a"))
+	assert.Equal(t, &resp.SimpleString{Value: "set"}, execute(e, "TYPE s"))
+}
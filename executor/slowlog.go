@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+)
+
+const SLOWLOG = "SLOWLOG"
+
+const (
+	// slowLogMaxArgs is the number of arguments a slow log entry keeps, the
+	// last one kept counting the ones dropped.
+	slowLogMaxArgs = 32
+	// slowLogMaxArgLen is the number of bytes kept of each argument.
+	slowLogMaxArgLen = 128
+
+	// defaultSlowLogCount is the number of entries SLOWLOG GET replies with
+	// by default.
+	defaultSlowLogCount = 10
+)
+
+// slowLogEntry records a command that ran for longer than the
+// slowlog-log-slower-than parameter.
+type slowLogEntry struct {
+	id       int64
+	time     time.Time
+	duration time.Duration
+	// args are the command's name and arguments, truncated.
+	args       []string
+	clientAddr string
+	clientName string
+}
+
+// slowLog holds the latest slow log entries in a ring buffer. It is safe for
+// concurrent use.
+type slowLog struct {
+	mu sync.Mutex
+	// entries holds up to maxLen entries, next being the index the next
+	// entry is written to once it is full.
+	entries []*slowLogEntry
+	next    int
+	maxLen  int
+	nextID  int64
+}
+
+func (l *slowLog) add(entry *slowLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.id = l.nextID
+	l.nextID++
+
+	if l.maxLen == 0 {
+		return
+	}
+	if len(l.entries) < l.maxLen {
+		l.entries = append(l.entries, entry)
+		return
+	}
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % l.maxLen
+}
+
+// latest returns up to count entries, newest first, or every entry if count
+// is negative.
+func (l *slowLog) latest(count int) []*slowLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.latestLocked(count)
+}
+
+func (l *slowLog) latestLocked(count int) []*slowLogEntry {
+	if count < 0 || count > len(l.entries) {
+		count = len(l.entries)
+	}
+
+	// the newest entry is the one before next, wrapping around
+	entries := make([]*slowLogEntry, 0, count)
+	for i := 0; i < count; i++ {
+		index := (l.next - 1 - i + 2*len(l.entries)) % len(l.entries)
+		entries = append(entries, l.entries[index])
+	}
+
+	return entries
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.entries)
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = nil
+	l.next = 0
+}
+
+// setMaxLen changes the number of entries kept, dropping the oldest ones.
+func (l *slowLog) setMaxLen(maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the ring starts over with the oldest entry kept first
+	kept := l.latestLocked(maxLen)
+	l.entries = make([]*slowLogEntry, 0, len(kept))
+	for i := len(kept) - 1; i >= 0; i-- {
+		l.entries = append(l.entries, kept[i])
+	}
+	l.next = 0
+	l.maxLen = maxLen
+}
+
+// logSlow records the command called name if it ran for longer than the
+// slowlog-log-slower-than parameter.
+func (ce *compositeExecutor) logSlow(c *Client, name string, args [][]byte, d time.Duration) {
+	threshold := ce.slowLogThreshold.Load()
+	if threshold < 0 || d.Microseconds() < threshold {
+		return
+	}
+
+	entry := &slowLogEntry{
+		time:     time.Now(),
+		duration: d,
+		args:     slowLogArgs(name, redact(name, args)),
+	}
+	if c != nil {
+		if addr := c.conn.RemoteAddr(); addr != nil {
+			entry.clientAddr = addr.String()
+		}
+		entry.clientName = c.name
+	}
+
+	ce.slowLog.add(entry)
+}
+
+// slowLogArgs truncates the command called name and its arguments to keep
+// slow log entries small.
+func slowLogArgs(name string, args [][]byte) []string {
+	all := append([][]byte{[]byte(strings.ToLower(name))}, args...)
+
+	n := len(all)
+	if n > slowLogMaxArgs {
+		n = slowLogMaxArgs - 1
+	}
+
+	truncated := make([]string, 0, slowLogMaxArgs)
+	for _, arg := range all[:n] {
+		if len(arg) > slowLogMaxArgLen {
+			truncated = append(truncated, fmt.Sprintf("%s... (%d more bytes)", arg[:slowLogMaxArgLen], len(arg)-slowLogMaxArgLen))
+			continue
+		}
+		truncated = append(truncated, string(arg))
+	}
+	if n < len(all) {
+		truncated = append(truncated, fmt.Sprintf("... (%d more arguments)", len(all)-n))
+	}
+
+	return truncated
+}
+
+// redactedArg replaces the arguments holding passwords in slow log entries.
+var redactedArg = []byte("(redacted)")
+
+// redact returns the arguments of the command called name with the ones
+// that may hold passwords replaced.
+func redact(name string, args [][]byte) [][]byte {
+	redacted, copied := args, false
+	for i := range args {
+		if !secretArg(name, args, i) {
+			continue
+		}
+
+		if !copied {
+			redacted, copied = append([][]byte(nil), args...), true
+		}
+		redacted[i] = redactedArg
+	}
+
+	return redacted
+}
+
+// secretArg reports whether the argument at index i of the command called
+// name may hold a password.
+func secretArg(name string, args [][]byte, i int) bool {
+	switch name {
+	case AUTH:
+		return true
+	case HELLO:
+		// HELLO protover AUTH username password
+		return i >= 2 && strings.EqualFold(string(args[i-2]), "AUTH")
+	case ACL:
+		// ACL SETUSER username rule...
+		return i >= 2 && strings.EqualFold(string(args[0]), "SETUSER")
+	}
+
+	return false
+}
+
+func (ce *compositeExecutor) executeSlowLog(_ *Client, args [][]byte) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(SLOWLOG)
+	}
+
+	sub := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	switch sub {
+	case "GET":
+		if len(args) > 1 {
+			return wrongNumberOfArgs(SLOWLOG + "|" + sub)
+		}
+		count := defaultSlowLogCount
+		if len(args) == 1 {
+			n, err := strconv.Atoi(string(args[0]))
+			if err != nil || n < -1 {
+				return &resp.Error{Value: "ERR count should be greater than or equal to -1"}
+			}
+			count = n
+		}
+		return slowLogReply(ce.slowLog.latest(count))
+	case "LEN":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(SLOWLOG + "|" + sub)
+		}
+		return &resp.Int{Value: int64(ce.slowLog.len())}
+	case "RESET":
+		if len(args) != 0 {
+			return wrongNumberOfArgs(SLOWLOG + "|" + sub)
+		}
+		ce.slowLog.reset()
+		return &resp.SimpleString{Value: "OK"}
+	default:
+		return unknownSubcommand(SLOWLOG, []byte(sub))
+	}
+}
+
+// slowLogReply describes entries the way SLOWLOG GET does: the id, the unix
+// time, the duration in microseconds, the arguments, the client's address
+// and the client's name.
+func slowLogReply(entries []*slowLogEntry) resp.Message {
+	reply := make([]resp.Message, 0, len(entries))
+	for _, entry := range entries {
+		reply = append(reply, &resp.Array{Value: []resp.Message{
+			&resp.Int{Value: entry.id},
+			&resp.Int{Value: entry.time.Unix()},
+			&resp.Int{Value: entry.duration.Microseconds()},
+			bulkStrings(entry.args),
+			&resp.BulkString{Value: []byte(entry.clientAddr)},
+			&resp.BulkString{Value: []byte(entry.clientName)},
+		}})
+	}
+
+	return &resp.Array{Value: reply}
+}
@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowLogArgsAt returns the arguments of the entry at index of a SLOWLOG GET
+// reply.
+func slowLogArgsAt(reply resp.Message, index int) resp.Message {
+	return reply.(*resp.Array).Value[index].(*resp.Array).Value[3]
+}
+
+func TestSlowLog(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, conn := newTestClient(1)
+	conn.Addr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+
+	// nothing is slow enough by default
+	execute(e, "SET k v")
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SLOWLOG LEN"))
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET slowlog-log-slower-than 0"))
+	executeAs(e, c, "HELLO 2 SETNAME worker")
+	executeAs(e, c, "GET k")
+
+	reply := execute(e, "SLOWLOG GET")
+	entries := reply.(*resp.Array).Value
+	if assert.Len(t, entries, 3) {
+		entry := entries[0].(*resp.Array).Value
+		assert.Equal(t, &resp.Int{Value: 2}, entry[0])
+		assert.Equal(t, bulks("get", "k"), entry[3])
+		assert.Equal(t, &resp.BulkString{Value: []byte("127.0.0.1:5000")}, entry[4])
+		assert.Equal(t, &resp.BulkString{Value: []byte("worker")}, entry[5])
+	}
+	// SLOWLOG GET logs itself once it ran
+	assert.Equal(t, bulks("slowlog", "GET"), slowLogArgsAt(execute(e, "SLOWLOG GET 1"), 0))
+	assert.Len(t, execute(e, "SLOWLOG GET -1").(*resp.Array).Value, 5)
+
+	assert.Equal(t, &resp.Error{Value: "ERR count should be greater than or equal to -1"}, execute(e, "SLOWLOG GET -2"))
+	assert.Equal(t, unknownSubcommand(SLOWLOG, []byte("NOPE")), execute(e, "SLOWLOG NOPE"))
+
+	assert.Equal(t, ok, execute(e, "SLOWLOG RESET"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "SLOWLOG LEN"))
+
+	assert.Equal(t, ok, execute(e, "CONFIG SET slowlog-log-slower-than -1"))
+	execute(e, "SLOWLOG RESET")
+	execute(e, "GET k")
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SLOWLOG LEN"))
+}
+
+func TestSlowLogMaxLen(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "CONFIG SET slowlog-log-slower-than 0 slowlog-max-len 3")
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		execute(e, "GET "+key)
+	}
+	reply := execute(e, "SLOWLOG GET")
+	assert.Equal(t, bulks("get", "e"), slowLogArgsAt(reply, 0))
+	assert.Equal(t, bulks("get", "c"), slowLogArgsAt(reply, 2))
+
+	// shrinking the log keeps the newest entries
+	execute(e, "CONFIG SET slowlog-max-len 2")
+	execute(e, "GET f")
+	reply = execute(e, "SLOWLOG GET")
+	assert.Len(t, reply.(*resp.Array).Value, 2)
+	assert.Equal(t, bulks("get", "f"), slowLogArgsAt(reply, 0))
+	assert.Equal(t, bulks("config", "SET", "slowlog-max-len", "2"), slowLogArgsAt(reply, 1))
+}
+
+func TestSlowLogArgs(t *testing.T) {
+	args := asArgs(strings.Repeat("x", 130))
+	for i := 0; i < 40; i++ {
+		args = append(args, []byte("v"))
+	}
+
+	truncated := slowLogArgs(MSET, args)
+	assert.Len(t, truncated, slowLogMaxArgs)
+	assert.Equal(t, "mset", truncated[0])
+	assert.Equal(t, strings.Repeat("x", 128)+"... (2 more bytes)", truncated[1])
+	assert.Equal(t, "... (11 more arguments)", truncated[31])
+
+	assert.Equal(t, asArgs("(redacted)", "(redacted)"), redact(AUTH, asArgs("alice", "pw")))
+	assert.Equal(t, asArgs("3", "AUTH", "alice", "(redacted)", "SETNAME", "x"),
+		redact(HELLO, asArgs("3", "AUTH", "alice", "pw", "SETNAME", "x")))
+	assert.Equal(t, asArgs("SETUSER", "alice", "(redacted)", "(redacted)"),
+		redact(ACL, asArgs("SETUSER", "alice", "on", ">pw")))
+
+	args = asArgs("k", "v")
+	assert.Equal(t, asArgs("k", "v"), redact(SET, args))
+}
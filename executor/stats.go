@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+)
+
+// commandStats counts the calls of a command, as reported by INFO
+// commandstats.
+type commandStats struct {
+	calls atomic.Int64
+	usec  atomic.Int64
+	// rejected counts the calls refused before running, e.g. for lacking
+	// permissions, and failed the ones that ran and replied with an error.
+	rejected atomic.Int64
+	failed   atomic.Int64
+}
+
+// executorStats counts the commands executed and the errors they replied
+// with. It is safe for concurrent use.
+type executorStats struct {
+	// commands holds the stats of every known command. It is filled in
+	// once, so it is read without locking.
+	commands map[string]*commandStats
+
+	processed atomic.Int64
+
+	errorsMu sync.Mutex
+	// errors counts the error replies by their prefix, such as ERR or
+	// WRONGTYPE.
+	errors map[string]int64
+}
+
+func newExecutorStats() *executorStats {
+	stats := &executorStats{
+		commands: make(map[string]*commandStats, len(commandTable)),
+		errors:   make(map[string]int64),
+	}
+	for name := range commandTable {
+		stats.commands[name] = &commandStats{}
+	}
+
+	return stats
+}
+
+// record counts a call of the named command that took d and replied with
+// reply.
+func (s *executorStats) record(name string, d time.Duration, reply resp.Message) {
+	s.processed.Add(1)
+
+	stats, ok := s.commands[name]
+	if ok {
+		stats.calls.Add(1)
+		stats.usec.Add(d.Microseconds())
+	}
+
+	if errMsg, failed := reply.(*resp.Error); failed {
+		if ok {
+			stats.failed.Add(1)
+		}
+		s.recordError(errMsg)
+	}
+}
+
+// reject counts a call of the named command refused with errMsg before it
+// ran.
+func (s *executorStats) reject(name string, errMsg *resp.Error) {
+	s.processed.Add(1)
+
+	if stats, ok := s.commands[name]; ok {
+		stats.rejected.Add(1)
+	}
+	s.recordError(errMsg)
+}
+
+func (s *executorStats) recordError(errMsg *resp.Error) {
+	s.errorsMu.Lock()
+	defer s.errorsMu.Unlock()
+
+	s.errors[errorPrefix(errMsg)]++
+}
+
+// errorPrefix returns the code an error reply starts with, or ERR for
+// replies that don't start with one.
+func errorPrefix(errMsg *resp.Error) string {
+	prefix := errMsg.Value
+	if i := strings.IndexByte(prefix, ' '); i >= 0 {
+		prefix = prefix[:i]
+	}
+
+	if prefix == "" {
+		return "ERR"
+	}
+	for _, r := range prefix {
+		if r < 'A' || r > 'Z' {
+			return "ERR"
+		}
+	}
+
+	return prefix
+}
+
+// reset zeroes every count, as CONFIG RESETSTAT does.
+func (s *executorStats) reset() {
+	for _, stats := range s.commands {
+		stats.calls.Store(0)
+		stats.usec.Store(0)
+		stats.rejected.Store(0)
+		stats.failed.Store(0)
+	}
+	s.processed.Store(0)
+
+	s.errorsMu.Lock()
+	s.errors = make(map[string]int64)
+	s.errorsMu.Unlock()
+}
+
+// errorCounts returns a copy of the error counts.
+func (s *executorStats) errorCounts() map[string]int64 {
+	s.errorsMu.Lock()
+	defer s.errorsMu.Unlock()
+
+	counts := make(map[string]int64, len(s.errors))
+	for prefix, n := range s.errors {
+		counts[prefix] = n
+	}
+
+	return counts
+}
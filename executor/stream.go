@@ -0,0 +1,1637 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/stream"
+)
+
+const (
+	XADD      = "XADD"
+	XRANGE    = "XRANGE"
+	XREVRANGE = "XREVRANGE"
+	XLEN      = "XLEN"
+	XDEL      = "XDEL"
+	XTRIM     = "XTRIM"
+	XINFO     = "XINFO"
+	XREAD     = "XREAD"
+
+	XGROUP     = "XGROUP"
+	XREADGROUP = "XREADGROUP"
+	XACK       = "XACK"
+	XPENDING   = "XPENDING"
+	XCLAIM     = "XCLAIM"
+	XAUTOCLAIM = "XAUTOCLAIM"
+)
+
+var (
+	errStreamID          = &resp.Error{Value: "ERR Invalid stream ID specified as stream command argument"}
+	errStreamIDTooSmall  = &resp.Error{Value: "ERR The ID specified in XADD is equal or smaller than the target stream top item"}
+	errStreamIDZero      = &resp.Error{Value: "ERR The ID specified in XADD must be greater than 0-0"}
+	errStreamExhausted   = &resp.Error{Value: "ERR The stream has exhausted the last possible ID, unable to add more items"}
+	errStreamMaxLen      = &resp.Error{Value: "ERR The MAXLEN argument must be >= 0."}
+	errStreamLimit       = &resp.Error{Value: "ERR The LIMIT argument must be >= 0."}
+	errStreamLimitExact  = &resp.Error{Value: "ERR syntax error, LIMIT cannot be used without the special ~ option"}
+	errStreamStartID     = &resp.Error{Value: "ERR invalid start ID for the interval"}
+	errStreamEndID       = &resp.Error{Value: "ERR invalid end ID for the interval"}
+	errStreamNoSuchKey   = &resp.Error{Value: "ERR no such key"}
+	errTimeoutNotInteger = &resp.Error{Value: "ERR timeout is not an integer or out of range"}
+	errXReadGreaterID    = &resp.Error{Value: "ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option."}
+	errXReadGroupOption  = &resp.Error{Value: "ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead."}
+	errXReadGroupMissing = &resp.Error{Value: "ERR Missing GROUP option for XREADGROUP"}
+	errXReadGroupDollar  = &resp.Error{Value: "ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set."}
+	errXGroupNoKey       = &resp.Error{Value: "ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."}
+	errBusyGroup         = &resp.Error{Value: "BUSYGROUP Consumer Group name already exists"}
+	errGroupDestroyed    = &resp.Error{Value: "NOGROUP the consumer group this client was blocked on no longer exists"}
+	errEntriesRead       = &resp.Error{Value: "ERR value for ENTRIESREAD must be positive or -1"}
+	errCountPositive     = &resp.Error{Value: "ERR COUNT must be > 0"}
+)
+
+// getStream looks up the stream stored at key. The stream is nil if the key
+// does not exist.
+func getStream(db storage.Storage, key string) (*stream.Stream, resp.Message) {
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, nil
+		}
+
+		return nil, genericErrorMessage
+	}
+
+	s, ok := node.Value().(*stream.Stream)
+	if !ok {
+		return nil, errWrongType
+	}
+
+	return s, nil
+}
+
+// parseStreamID parses an ID given as a command argument. A missing sequence
+// number defaults to missingSeq.
+func parseStreamID(b []byte, missingSeq uint64) (stream.ID, resp.Message) {
+	id, _, ok := stream.ParseID(string(b), missingSeq)
+	if !ok {
+		return stream.ID{}, errStreamID
+	}
+
+	return id, nil
+}
+
+func streamEntryReply(e stream.Entry) resp.Message {
+	fields := make([]resp.Message, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, &resp.BulkString{Value: f})
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(e.ID.String())},
+		&resp.Array{Value: fields},
+	}}
+}
+
+// streamRange replies with up to count entries between start and end, or
+// every entry if count is negative.
+func streamRange(s *stream.Stream, start, end stream.ID, reverse bool, count int64) *resp.Array {
+	entries := []resp.Message{}
+	if count == 0 {
+		return &resp.Array{Value: entries}
+	}
+
+	s.Range(start, end, reverse, func(e stream.Entry) bool {
+		entries = append(entries, streamEntryReply(e))
+		return int64(len(entries)) != count
+	})
+
+	return &resp.Array{Value: entries}
+}
+
+// streamTrim describes how XADD and XTRIM trim a stream.
+type streamTrim struct {
+	// strategy is MAXLEN or MINID, or empty if the stream isn't trimmed.
+	strategy string
+	maxLen   int64
+	minID    stream.ID
+
+	// approx only removes whole nodes, removing at most limit entries. A
+	// negative limit uses the default.
+	approx bool
+	limit  int64
+}
+
+// parseOption parses the trimming option starting at args[i], if there is
+// one, and returns the index of the argument following it.
+func (t *streamTrim) parseOption(args [][]byte, i int) (next int, matched bool, errMsg resp.Message) {
+	opt := strings.ToUpper(string(args[i]))
+	switch opt {
+	case "MAXLEN", "MINID":
+		if i+1 >= len(args) {
+			return 0, true, errSyntax
+		}
+
+		t.strategy = opt
+		i++
+		switch string(args[i]) {
+		case "~":
+			t.approx = true
+			i++
+		case "=":
+			i++
+		}
+		if i >= len(args) {
+			return 0, true, errSyntax
+		}
+
+		if opt == "MAXLEN" {
+			maxLen, ok := parseInt(args[i])
+			if !ok {
+				return 0, true, errNotInteger
+			}
+			if maxLen < 0 {
+				return 0, true, errStreamMaxLen
+			}
+			t.maxLen = maxLen
+		} else {
+			minID, errMsg := parseStreamID(args[i], 0)
+			if errMsg != nil {
+				return 0, true, errMsg
+			}
+			t.minID = minID
+		}
+
+		return i + 1, true, nil
+	case "LIMIT":
+		if i+1 >= len(args) {
+			return 0, true, errSyntax
+		}
+
+		limit, ok := parseInt(args[i+1])
+		if !ok {
+			return 0, true, errNotInteger
+		}
+		if limit < 0 {
+			return 0, true, errStreamLimit
+		}
+		t.limit = limit
+
+		return i + 2, true, nil
+	default:
+		return i, false, nil
+	}
+}
+
+// validate checks the combination of parsed options.
+func (t *streamTrim) validate() resp.Message {
+	if t.limit >= 0 && !t.approx {
+		return errStreamLimitExact
+	}
+
+	return nil
+}
+
+// apply trims s and returns how many entries were removed.
+func (t *streamTrim) apply(s *stream.Stream) int {
+	limit := t.limit
+	if limit < 0 {
+		limit = 0
+		if t.approx {
+			limit = 100 * stream.MaxNodeEntries
+		}
+	}
+
+	switch t.strategy {
+	case "MAXLEN":
+		return s.TrimMaxLen(int(t.maxLen), t.approx, int(limit))
+	case "MINID":
+		return s.TrimMinID(t.minID, t.approx, int(limit))
+	default:
+		return 0
+	}
+}
+
+// nextStreamID works out the ID of the entry XADD appends to s from the ID
+// argument, which is "*" to generate it from the current time, "<ms>-*" to
+// generate only the sequence number, or an explicit ID.
+func nextStreamID(s *stream.Stream, raw []byte) (stream.ID, resp.Message) {
+	var last stream.ID
+	if s != nil {
+		last = s.LastID()
+	}
+
+	if string(raw) == "*" {
+		if ms := uint64(nowMillis()); ms > last.Ms {
+			return stream.ID{Ms: ms}, nil
+		}
+
+		id, ok := last.Next()
+		if !ok {
+			return stream.ID{}, errStreamExhausted
+		}
+		return id, nil
+	}
+
+	if rawMs := strings.TrimSuffix(string(raw), "-*"); rawMs != string(raw) {
+		id, seqGiven, ok := stream.ParseID(rawMs, 0)
+		if !ok || seqGiven {
+			return stream.ID{}, errStreamID
+		}
+
+		switch {
+		case id.Ms < last.Ms:
+			return stream.ID{}, errStreamIDTooSmall
+		case id.Ms == last.Ms:
+			if last.Seq == math.MaxUint64 {
+				return stream.ID{}, errStreamIDTooSmall
+			}
+			id.Seq = last.Seq + 1
+		}
+		return id, nil
+	}
+
+	id, errMsg := parseStreamID(raw, 0)
+	if errMsg != nil {
+		return stream.ID{}, errMsg
+	}
+
+	if id == stream.MinID {
+		return stream.ID{}, errStreamIDZero
+	}
+
+	if !last.Less(id) {
+		return stream.ID{}, errStreamIDTooSmall
+	}
+
+	return id, nil
+}
+
+func executeXAdd(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	var (
+		noMkStream bool
+		trim       = streamTrim{limit: -1}
+		i          = 1
+	)
+	for i < len(args) {
+		if strings.ToUpper(string(args[i])) == "NOMKSTREAM" {
+			noMkStream = true
+			i++
+			continue
+		}
+
+		next, matched, errMsg := trim.parseOption(args, i)
+		if errMsg != nil {
+			return errMsg
+		}
+		if !matched {
+			break
+		}
+		i = next
+	}
+
+	if errMsg := trim.validate(); errMsg != nil {
+		return errMsg
+	}
+
+	// the ID must be followed by at least one field/value pair
+	if len(args)-i < 3 || (len(args)-i-1)%2 != 0 {
+		return wrongNumberOfArgs(XADD)
+	}
+
+	s, errMsg := getStream(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil && noMkStream {
+		return &resp.BulkString{}
+	}
+
+	id, errMsg := nextStreamID(s, args[i])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		s = stream.New()
+	}
+
+	s.Add(id, args[i+1:])
+	trim.apply(s)
+
+	// the stream is set even if it already existed so that clients blocked
+	// reading it are served
+	db.Set(key, storage.NewStreamNode(s))
+
+	return &resp.BulkString{Value: []byte(id.String())}
+}
+
+// parseStreamRange parses the bounds of XRANGE and XREVRANGE. "-" and "+"
+// are the smallest and largest IDs, and a bound prefixed with "(" is
+// exclusive.
+func parseStreamRange(rawStart, rawEnd []byte) (start, end stream.ID, errMsg resp.Message) {
+	start, ok, errMsg := parseStreamBound(rawStart, false)
+	if errMsg != nil {
+		return start, end, errMsg
+	}
+	if !ok {
+		return start, end, errStreamStartID
+	}
+
+	end, ok, errMsg = parseStreamBound(rawEnd, true)
+	if errMsg != nil {
+		return start, end, errMsg
+	}
+	if !ok {
+		return start, end, errStreamEndID
+	}
+
+	return start, end, nil
+}
+
+// parseStreamBound parses a range bound. ok is false if the bound is
+// exclusive and there is no ID past it.
+func parseStreamBound(b []byte, isEnd bool) (id stream.ID, ok bool, errMsg resp.Message) {
+	switch string(b) {
+	case "-":
+		return stream.MinID, true, nil
+	case "+":
+		return stream.MaxID, true, nil
+	}
+
+	exclusive := len(b) > 0 && b[0] == '('
+	if exclusive {
+		b = b[1:]
+	}
+
+	var missingSeq uint64
+	if isEnd {
+		missingSeq = math.MaxUint64
+	}
+
+	id, errMsg = parseStreamID(b, missingSeq)
+	if errMsg != nil {
+		return id, false, errMsg
+	}
+
+	if !exclusive {
+		return id, true, nil
+	}
+
+	if isEnd {
+		id, ok = id.Prev()
+	} else {
+		id, ok = id.Next()
+	}
+	return id, ok, nil
+}
+
+func executeXRange(args [][]byte, db storage.Storage) resp.Message {
+	return xrangeGeneric(XRANGE, args, db, false)
+}
+
+func executeXRevRange(args [][]byte, db storage.Storage) resp.Message {
+	return xrangeGeneric(XREVRANGE, args, db, true)
+}
+
+func xrangeGeneric(command string, args [][]byte, db storage.Storage, reverse bool) resp.Message {
+	if len(args) != 3 && len(args) != 5 {
+		return wrongNumberOfArgs(command)
+	}
+
+	key := string(args[0])
+	rawStart, rawEnd := args[1], args[2]
+	if reverse {
+		rawStart, rawEnd = rawEnd, rawStart
+	}
+
+	start, end, errMsg := parseStreamRange(rawStart, rawEnd)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	count := int64(-1)
+	if len(args) == 5 {
+		if strings.ToUpper(string(args[3])) != "COUNT" {
+			return errSyntax
+		}
+
+		var ok bool
+		count, ok = parseInt(args[4])
+		if !ok {
+			return errNotInteger
+		}
+		if count < 0 {
+			count = 0
+		}
+	}
+
+	s, errMsg := getStream(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Array{Value: []resp.Message{}}
+	}
+
+	return streamRange(s, start, end, reverse, count)
+}
+
+func executeXLen(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) != 1 {
+		return wrongNumberOfArgs(XLEN)
+	}
+
+	s, errMsg := getStream(db, string(args[0]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(s.Len())}
+}
+
+func executeXDel(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(XDEL)
+	}
+
+	// every ID is parsed before anything is deleted so that a bad ID leaves
+	// the stream untouched
+	ids := make([]stream.ID, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		id, errMsg := parseStreamID(arg, 0)
+		if errMsg != nil {
+			return errMsg
+		}
+		ids = append(ids, id)
+	}
+
+	s, errMsg := getStream(db, string(args[0]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	// unlike other types, streams are kept when they become empty so that
+	// their last ID isn't lost
+	var deleted int64
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+
+	return &resp.Int{Value: deleted}
+}
+
+func executeXTrim(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 3 {
+		return wrongNumberOfArgs(XTRIM)
+	}
+
+	key := string(args[0])
+	trim := streamTrim{limit: -1}
+	for i := 1; i < len(args); {
+		next, matched, errMsg := trim.parseOption(args, i)
+		if errMsg != nil {
+			return errMsg
+		}
+		if !matched {
+			return errSyntax
+		}
+		i = next
+	}
+
+	if trim.strategy == "" {
+		return errSyntax
+	}
+
+	if errMsg := trim.validate(); errMsg != nil {
+		return errMsg
+	}
+
+	s, errMsg := getStream(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(trim.apply(s))}
+}
+
+func executeXInfo(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(XINFO)
+	}
+
+	subcommand := strings.ToUpper(string(args[0]))
+	switch subcommand {
+	case "STREAM", "GROUPS":
+		if len(args) != 2 {
+			return wrongNumberOfArgs(XINFO + "|" + subcommand)
+		}
+		if subcommand == "GROUPS" {
+			return xinfoGroups(string(args[1]), db)
+		}
+		return xinfoStream(string(args[1]), db)
+	case "CONSUMERS":
+		if len(args) != 3 {
+			return wrongNumberOfArgs(XINFO + "|" + subcommand)
+		}
+		return xinfoConsumers(string(args[1]), string(args[2]), db)
+	default:
+		return unknownSubcommand(XINFO, args[0])
+	}
+}
+
+func xinfoStream(key string, db storage.Storage) resp.Message {
+	s, errMsg := getStream(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return errStreamNoSuchKey
+	}
+
+	var (
+		firstID    stream.ID
+		firstEntry resp.Message = &resp.BulkString{}
+		lastEntry  resp.Message = &resp.BulkString{}
+	)
+	if e, ok := s.First(); ok {
+		firstID = e.ID
+		firstEntry = streamEntryReply(e)
+	}
+	if e, ok := s.Last(); ok {
+		lastEntry = streamEntryReply(e)
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("length")},
+		&resp.Int{Value: int64(s.Len())},
+		&resp.BulkString{Value: []byte("radix-tree-keys")},
+		&resp.Int{Value: int64(s.Nodes())},
+		&resp.BulkString{Value: []byte("radix-tree-nodes")},
+		&resp.Int{Value: int64(s.IndexNodes())},
+		&resp.BulkString{Value: []byte("last-generated-id")},
+		&resp.BulkString{Value: []byte(s.LastID().String())},
+		&resp.BulkString{Value: []byte("max-deleted-entry-id")},
+		&resp.BulkString{Value: []byte(s.MaxDeletedID().String())},
+		&resp.BulkString{Value: []byte("entries-added")},
+		&resp.Int{Value: int64(s.EntriesAdded())},
+		&resp.BulkString{Value: []byte("recorded-first-entry-id")},
+		&resp.BulkString{Value: []byte(firstID.String())},
+		&resp.BulkString{Value: []byte("groups")},
+		&resp.Int{Value: int64(s.Groups())},
+		&resp.BulkString{Value: []byte("first-entry")},
+		firstEntry,
+		&resp.BulkString{Value: []byte("last-entry")},
+		lastEntry,
+	}}
+}
+
+// parseBlockTimeout parses the BLOCK argument of XREAD and XREADGROUP, given
+// in milliseconds.
+func parseBlockTimeout(b []byte) (time.Duration, resp.Message) {
+	ms, ok := parseInt(b)
+	// larger timeouts would overflow once converted to a time.Duration
+	if !ok || ms > math.MaxInt64/int64(time.Millisecond) {
+		return 0, errTimeoutNotInteger
+	}
+	if ms < 0 {
+		return 0, errTimeoutNegative
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// xreadArgs holds the parsed arguments of XREAD and XREADGROUP.
+type xreadArgs struct {
+	command string
+
+	// count limits the entries returned per stream. It is negative when
+	// there is no limit.
+	count   int64
+	block   bool
+	timeout time.Duration
+
+	// group and consumer are only set by XREADGROUP.
+	group    string
+	consumer string
+	noAck    bool
+
+	keys   []string
+	rawIDs [][]byte
+}
+
+func parseXReadArgs(command string, args [][]byte) (*xreadArgs, resp.Message) {
+	xread := &xreadArgs{command: command, count: -1}
+	withGroup := command == XREADGROUP
+
+	i := 0
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+
+			count, ok := parseInt(args[i+1])
+			if !ok {
+				return nil, errNotInteger
+			}
+			if count > 0 {
+				xread.count = count
+			}
+			i++
+		case "BLOCK":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+
+			timeout, errMsg := parseBlockTimeout(args[i+1])
+			if errMsg != nil {
+				return nil, errMsg
+			}
+			xread.block = true
+			xread.timeout = timeout
+			i++
+		case "GROUP":
+			if !withGroup {
+				return nil, errXReadGroupOption
+			}
+			if i+2 >= len(args) {
+				return nil, errSyntax
+			}
+
+			xread.group = string(args[i+1])
+			xread.consumer = string(args[i+2])
+			i += 2
+		case "NOACK":
+			if !withGroup {
+				return nil, errSyntax
+			}
+			xread.noAck = true
+		case "STREAMS":
+			break options
+		default:
+			return nil, errSyntax
+		}
+	}
+
+	if i == len(args) {
+		return nil, errSyntax
+	}
+
+	if withGroup && xread.group == "" {
+		return nil, errXReadGroupMissing
+	}
+
+	streams := args[i+1:]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		special := "$"
+		if withGroup {
+			special = ">"
+		}
+		return nil, &resp.Error{Value: fmt.Sprintf("ERR Unbalanced '%s' list of streams: for each stream key an ID or '%s' must be specified.", strings.ToLower(command), special)}
+	}
+
+	for _, arg := range streams[:len(streams)/2] {
+		xread.keys = append(xread.keys, string(arg))
+	}
+	xread.rawIDs = streams[len(streams)/2:]
+
+	return xread, nil
+}
+
+// streamReadReply pairs the entries read from a stream with its key.
+func streamReadReply(key string, entries resp.Message) resp.Message {
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(key)},
+		entries,
+	}}
+}
+
+// streamRead reads the entries of the stream at key added after id.
+func streamRead(db storage.Storage, key string, id stream.ID, count int64) (resp.Message, bool) {
+	s, errMsg := getStream(db, key)
+	if errMsg != nil || s == nil {
+		return nil, false
+	}
+
+	start, ok := id.Next()
+	if !ok {
+		return nil, false
+	}
+
+	entries := streamRange(s, start, stream.MaxID, false, count)
+	if len(entries.Value) == 0 {
+		return nil, false
+	}
+
+	return streamReadReply(key, entries), true
+}
+
+func executeXRead(args [][]byte, db storage.Storage) (resp.Message, *blockSpec) {
+	xread, errMsg := parseXReadArgs(XREAD, args)
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	// "$" is resolved now, so that only entries added after the command
+	// was issued are returned
+	ids := make(map[string]stream.ID, len(xread.keys))
+	for i, key := range xread.keys {
+		s, errMsg := getStream(db, key)
+		if errMsg != nil {
+			return errMsg, nil
+		}
+
+		switch raw := xread.rawIDs[i]; string(raw) {
+		case "$":
+			if s != nil {
+				ids[key] = s.LastID()
+			} else {
+				ids[key] = stream.MinID
+			}
+		case ">":
+			return errXReadGreaterID, nil
+		default:
+			id, errMsg := parseStreamID(raw, 0)
+			if errMsg != nil {
+				return errMsg, nil
+			}
+			ids[key] = id
+		}
+	}
+
+	var replies []resp.Message
+	for _, key := range xread.keys {
+		if msg, ok := streamRead(db, key, ids[key], xread.count); ok {
+			replies = append(replies, msg)
+		}
+	}
+
+	if len(replies) > 0 {
+		return &resp.Array{Value: replies}, nil
+	}
+
+	if !xread.block {
+		return &resp.Array{}, nil
+	}
+
+	return nil, &blockSpec{
+		keys:         xread.keys,
+		timeout:      xread.timeout,
+		timeoutReply: &resp.Array{},
+		serve: func(db storage.Storage, key string) (resp.Message, bool) {
+			msg, ok := streamRead(db, key, ids[key], xread.count)
+			if !ok {
+				return nil, false
+			}
+
+			return &resp.Array{Value: []resp.Message{msg}}, true
+		},
+	}
+}
+
+// noGroupError reports a missing stream or consumer group.
+func noGroupError(key, group, suffix string) *resp.Error {
+	return &resp.Error{Value: fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s'%s", key, group, suffix)}
+}
+
+// getStreamGroup looks up the stream stored at key and its consumer group.
+// Both are nil if either doesn't exist.
+func getStreamGroup(db storage.Storage, key, group string) (*stream.Stream, *stream.Group, resp.Message) {
+	s, errMsg := getStream(db, key)
+	if errMsg != nil || s == nil {
+		return nil, nil, errMsg
+	}
+
+	g := s.Group(group)
+	if g == nil {
+		return nil, nil, nil
+	}
+
+	return s, g, nil
+}
+
+// streamReadGroup delivers up to count entries added after the group's last
+// ID to c, adding them to the group's pending entries unless noAck is set.
+func streamReadGroup(s *stream.Stream, g *stream.Group, c *stream.Consumer, count int64, noAck bool) *resp.Array {
+	now := nowMillis()
+
+	entries := []resp.Message{}
+	start, ok := g.LastID.Next()
+	if !ok || count == 0 {
+		return &resp.Array{Value: entries}
+	}
+
+	// the number of entries read can be counted on as long as none were
+	// deleted past the group's last ID, and has to be worked out again
+	// otherwise
+	counted := g.EntriesRead >= 0 && !g.LastID.Less(s.MaxDeletedID())
+	s.Range(start, stream.MaxID, false, func(e stream.Entry) bool {
+		g.LastID = e.ID
+		if !noAck {
+			g.Deliver(e.ID, c, now)
+		}
+
+		entries = append(entries, streamEntryReply(e))
+		return int64(len(entries)) != count
+	})
+
+	if counted {
+		g.EntriesRead += int64(len(entries))
+	} else if n, ok := s.EntriesUpTo(g.LastID); ok {
+		g.EntriesRead = n
+	}
+
+	if len(entries) > 0 {
+		c.ActiveTime = now
+	}
+
+	return &resp.Array{Value: entries}
+}
+
+// streamReadHistory delivers again up to count of the entries pending for c
+// with an ID greater than id. Entries deleted from the stream since they
+// were delivered are replied with no fields.
+func streamReadHistory(s *stream.Stream, c *stream.Consumer, id stream.ID, count int64) *resp.Array {
+	now := nowMillis()
+
+	entries := []resp.Message{}
+	start, ok := id.Next()
+	if !ok || count == 0 {
+		return &resp.Array{Value: entries}
+	}
+
+	c.RangePending(start, stream.MaxID, func(p *stream.PendingEntry) bool {
+		p.DeliveryTime = now
+		p.DeliveryCount++
+
+		if e, ok := s.Get(p.ID); ok {
+			entries = append(entries, streamEntryReply(e))
+		} else {
+			entries = append(entries, &resp.Array{Value: []resp.Message{
+				&resp.BulkString{Value: []byte(p.ID.String())},
+				&resp.Array{},
+			}})
+		}
+		return int64(len(entries)) != count
+	})
+
+	return &resp.Array{Value: entries}
+}
+
+func executeXReadGroup(args [][]byte, db storage.Storage) (resp.Message, *blockSpec) {
+	xread, errMsg := parseXReadArgs(XREADGROUP, args)
+	if errMsg != nil {
+		return errMsg, nil
+	}
+
+	// IDs are checked for every stream before anything is delivered. A nil
+	// ID reads new entries.
+	ids := make([]*stream.ID, len(xread.keys))
+	for i, key := range xread.keys {
+		_, g, errMsg := getStreamGroup(db, key, xread.group)
+		if errMsg != nil {
+			return errMsg, nil
+		}
+		if g == nil {
+			return noGroupError(key, xread.group, " in XREADGROUP with GROUP option"), nil
+		}
+
+		switch raw := xread.rawIDs[i]; string(raw) {
+		case ">":
+		case "$":
+			return errXReadGroupDollar, nil
+		default:
+			id, errMsg := parseStreamID(raw, 0)
+			if errMsg != nil {
+				return errMsg, nil
+			}
+			ids[i] = &id
+		}
+	}
+
+	now := nowMillis()
+	consumer := func(g *stream.Group) *stream.Consumer {
+		c, _ := g.CreateConsumer(xread.consumer, now)
+		c.SeenTime = now
+		return c
+	}
+
+	var (
+		replies []resp.Message
+		history bool
+	)
+	for i, key := range xread.keys {
+		s, g, _ := getStreamGroup(db, key, xread.group)
+		c := consumer(g)
+
+		if ids[i] != nil {
+			history = true
+			replies = append(replies, streamReadReply(key, streamReadHistory(s, c, *ids[i], xread.count)))
+			continue
+		}
+
+		if entries := streamReadGroup(s, g, c, xread.count, xread.noAck); len(entries.Value) > 0 {
+			replies = append(replies, streamReadReply(key, entries))
+		}
+	}
+
+	// reading the history never blocks, even if it's empty
+	if len(replies) > 0 || history {
+		return &resp.Array{Value: replies}, nil
+	}
+
+	if !xread.block {
+		return &resp.Array{}, nil
+	}
+
+	return nil, &blockSpec{
+		keys:         xread.keys,
+		timeout:      xread.timeout,
+		timeoutReply: &resp.Array{},
+		serve: func(db storage.Storage, key string) (resp.Message, bool) {
+			s, errMsg := getStream(db, key)
+			if errMsg != nil || s == nil {
+				return nil, false
+			}
+
+			g := s.Group(xread.group)
+			if g == nil {
+				return errGroupDestroyed, true
+			}
+
+			entries := streamReadGroup(s, g, consumer(g), xread.count, xread.noAck)
+			if len(entries.Value) == 0 {
+				return nil, false
+			}
+
+			return &resp.Array{Value: []resp.Message{streamReadReply(key, entries)}}, true
+		},
+	}
+}
+
+// parseGroupID parses the ID a consumer group is created with or set to,
+// where "$" is the last ID of the stream.
+func parseGroupID(s *stream.Stream, raw []byte) (stream.ID, resp.Message) {
+	if string(raw) == "$" {
+		if s == nil {
+			return stream.MinID, nil
+		}
+		return s.LastID(), nil
+	}
+
+	return parseStreamID(raw, 0)
+}
+
+// parseEntriesRead parses the ENTRIESREAD option starting at args[i], which
+// must be the only remaining option.
+func parseEntriesRead(args [][]byte, i int) (int64, resp.Message) {
+	if i == len(args) {
+		return -1, nil
+	}
+
+	if len(args)-i != 2 || strings.ToUpper(string(args[i])) != "ENTRIESREAD" {
+		return 0, errSyntax
+	}
+
+	n, ok := parseInt(args[i+1])
+	if !ok {
+		return 0, errNotInteger
+	}
+	if n < -1 {
+		return 0, errEntriesRead
+	}
+
+	return n, nil
+}
+
+func executeXGroup(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(XGROUP)
+	}
+
+	subcommand := strings.ToUpper(string(args[0]))
+	arity := map[string]int{
+		"CREATE":         4,
+		"SETID":          4,
+		"DESTROY":        3,
+		"CREATECONSUMER": 4,
+		"DELCONSUMER":    4,
+	}
+	n, ok := arity[subcommand]
+	if !ok {
+		return unknownSubcommand(XGROUP, args[0])
+	}
+	if len(args) < n || (subcommand != "CREATE" && subcommand != "SETID" && len(args) != n) {
+		return wrongNumberOfArgs(XGROUP + "|" + subcommand)
+	}
+
+	key, name := string(args[1]), string(args[2])
+	s, errMsg := getStream(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if subcommand == "CREATE" {
+		return xgroupCreate(db, s, key, name, args)
+	}
+
+	if s == nil {
+		return errXGroupNoKey
+	}
+
+	g := s.Group(name)
+	if g == nil && subcommand != "DESTROY" {
+		return &resp.Error{Value: fmt.Sprintf("NOGROUP No such consumer group '%s' for key name '%s'", name, key)}
+	}
+
+	switch subcommand {
+	case "SETID":
+		id, errMsg := parseGroupID(s, args[3])
+		if errMsg != nil {
+			return errMsg
+		}
+
+		entriesRead, errMsg := parseEntriesRead(args, 4)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		g.LastID = id
+		g.EntriesRead = entriesRead
+
+		return &resp.SimpleString{Value: "OK"}
+	case "DESTROY":
+		if !s.DeleteGroup(name) {
+			return &resp.Int{Value: 0}
+		}
+		return &resp.Int{Value: 1}
+	case "CREATECONSUMER":
+		if _, created := g.CreateConsumer(string(args[3]), nowMillis()); !created {
+			return &resp.Int{Value: 0}
+		}
+		return &resp.Int{Value: 1}
+	default:
+		pending, _ := g.DeleteConsumer(string(args[3]))
+		return &resp.Int{Value: int64(pending)}
+	}
+}
+
+func xgroupCreate(db storage.Storage, s *stream.Stream, key, name string, args [][]byte) resp.Message {
+	i := 4
+	mkStream := i < len(args) && strings.ToUpper(string(args[i])) == "MKSTREAM"
+	if mkStream {
+		i++
+	}
+
+	entriesRead, errMsg := parseEntriesRead(args, i)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	id, errMsg := parseGroupID(s, args[3])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		if !mkStream {
+			return errXGroupNoKey
+		}
+
+		s = stream.New()
+		db.Set(key, storage.NewStreamNode(s))
+	}
+
+	if _, created := s.CreateGroup(name, id, entriesRead); !created {
+		return errBusyGroup
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func executeXAck(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 3 {
+		return wrongNumberOfArgs(XACK)
+	}
+
+	ids := make([]stream.ID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, errMsg := parseStreamID(arg, 0)
+		if errMsg != nil {
+			return errMsg
+		}
+		ids = append(ids, id)
+	}
+
+	_, g, errMsg := getStreamGroup(db, string(args[0]), string(args[1]))
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if g == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	var acked int64
+	for _, id := range ids {
+		if g.Ack(id) {
+			acked++
+		}
+	}
+
+	return &resp.Int{Value: acked}
+}
+
+func executeXPending(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(XPENDING)
+	}
+
+	key, group := string(args[0]), string(args[1])
+	if len(args) == 2 {
+		_, g, errMsg := getStreamGroup(db, key, group)
+		if errMsg != nil {
+			return errMsg
+		}
+		if g == nil {
+			return noGroupError(key, group, "")
+		}
+
+		return xpendingSummary(g)
+	}
+
+	rest := args[2:]
+	var minIdle int64
+	if strings.ToUpper(string(rest[0])) == "IDLE" {
+		if len(rest) < 2 {
+			return errSyntax
+		}
+
+		var ok bool
+		minIdle, ok = parseInt(rest[1])
+		if !ok {
+			return errNotInteger
+		}
+		rest = rest[2:]
+	}
+
+	if len(rest) != 3 && len(rest) != 4 {
+		return errSyntax
+	}
+
+	start, end, errMsg := parseStreamRange(rest[0], rest[1])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	count, ok := parseInt(rest[2])
+	if !ok {
+		return errNotInteger
+	}
+
+	_, g, errMsg := getStreamGroup(db, key, group)
+	if errMsg != nil {
+		return errMsg
+	}
+	if g == nil {
+		return noGroupError(key, group, "")
+	}
+
+	rangePending := g.RangePending
+	if len(rest) == 4 {
+		c := g.Consumer(string(rest[3]))
+		if c == nil {
+			return &resp.Array{Value: []resp.Message{}}
+		}
+		rangePending = c.RangePending
+	}
+
+	now := nowMillis()
+	entries := []resp.Message{}
+	if count <= 0 {
+		return &resp.Array{Value: entries}
+	}
+
+	rangePending(start, end, func(p *stream.PendingEntry) bool {
+		idle := now - p.DeliveryTime
+		if idle < minIdle {
+			return true
+		}
+
+		entries = append(entries, &resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte(p.ID.String())},
+			&resp.BulkString{Value: []byte(p.Consumer.Name)},
+			&resp.Int{Value: idle},
+			&resp.Int{Value: int64(p.DeliveryCount)},
+		}})
+		return int64(len(entries)) != count
+	})
+
+	return &resp.Array{Value: entries}
+}
+
+// xpendingSummary replies with the number of pending entries of g, the
+// smallest and largest pending IDs, and how many entries are pending for
+// each consumer.
+func xpendingSummary(g *stream.Group) resp.Message {
+	if g.PendingLen() == 0 {
+		return &resp.Array{Value: []resp.Message{
+			&resp.Int{Value: 0},
+			&resp.BulkString{},
+			&resp.BulkString{},
+			&resp.Array{},
+		}}
+	}
+
+	consumers := []resp.Message{}
+	g.RangeConsumers(func(c *stream.Consumer) bool {
+		if c.PendingLen() > 0 {
+			consumers = append(consumers, &resp.Array{Value: []resp.Message{
+				&resp.BulkString{Value: []byte(c.Name)},
+				&resp.BulkString{Value: []byte(strconv.Itoa(c.PendingLen()))},
+			}})
+		}
+		return true
+	})
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: int64(g.PendingLen())},
+		&resp.BulkString{Value: []byte(g.FirstPending().ID.String())},
+		&resp.BulkString{Value: []byte(g.LastPending().ID.String())},
+		&resp.Array{Value: consumers},
+	}}
+}
+
+// parseMinIdle parses the min-idle-time argument of XCLAIM and XAUTOCLAIM.
+// Negative values are treated as 0.
+func parseMinIdle(command string, b []byte) (int64, resp.Message) {
+	minIdle, ok := parseInt(b)
+	if !ok {
+		return 0, &resp.Error{Value: "ERR Invalid min-idle-time argument for " + command}
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+
+	return minIdle, nil
+}
+
+func executeXClaim(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 5 {
+		return wrongNumberOfArgs(XCLAIM)
+	}
+
+	key, group, consumer := string(args[0]), string(args[1]), string(args[2])
+	minIdle, errMsg := parseMinIdle(XCLAIM, args[3])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	// IDs are followed by the options
+	var (
+		ids []stream.ID
+		i   = 4
+	)
+	for ; i < len(args); i++ {
+		id, _, ok := stream.ParseID(string(args[i]), 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return errStreamID
+	}
+
+	var (
+		now          = nowMillis()
+		deliveryTime = now
+		retryCount   = int64(-1)
+		force        bool
+		justID       bool
+		lastID       *stream.ID
+	)
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch opt {
+		case "FORCE":
+			force = true
+			continue
+		case "JUSTID":
+			justID = true
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		i++
+
+		switch opt {
+		case "IDLE", "TIME", "RETRYCOUNT":
+			n, ok := parseInt(args[i])
+			if !ok {
+				return &resp.Error{Value: "ERR Invalid " + opt + " option argument for XCLAIM"}
+			}
+
+			switch opt {
+			case "IDLE":
+				deliveryTime = now - n
+			case "TIME":
+				deliveryTime = n
+			default:
+				retryCount = n
+			}
+		case "LASTID":
+			id, errMsg := parseStreamID(args[i], 0)
+			if errMsg != nil {
+				return errMsg
+			}
+			lastID = &id
+		default:
+			return &resp.Error{Value: fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", args[i-1])}
+		}
+	}
+
+	s, g, errMsg := getStreamGroup(db, key, group)
+	if errMsg != nil {
+		return errMsg
+	}
+	if g == nil {
+		return noGroupError(key, group, "")
+	}
+
+	if lastID != nil && g.LastID.Less(*lastID) {
+		g.LastID = *lastID
+	}
+
+	c, _ := g.CreateConsumer(consumer, now)
+	c.SeenTime = now
+
+	claimed := []resp.Message{}
+	for _, id := range ids {
+		e, exists := s.Get(id)
+
+		p := g.Pending(id)
+		if p == nil {
+			// FORCE creates the pending entry if the entry exists, as if it
+			// had just been delivered
+			if !force || !exists {
+				continue
+			}
+
+			p = g.Deliver(id, c, now)
+			p.DeliveryCount = 0
+		}
+
+		if !exists {
+			// the entry was deleted since it was delivered
+			g.Ack(id)
+			continue
+		}
+
+		if minIdle > 0 && now-p.DeliveryTime < minIdle {
+			continue
+		}
+
+		g.Claim(p, c)
+		p.DeliveryTime = deliveryTime
+		if retryCount >= 0 {
+			p.DeliveryCount = uint64(retryCount)
+		} else if !justID {
+			p.DeliveryCount++
+		}
+		c.ActiveTime = now
+
+		if justID {
+			claimed = append(claimed, &resp.BulkString{Value: []byte(id.String())})
+		} else {
+			claimed = append(claimed, streamEntryReply(e))
+		}
+	}
+
+	return &resp.Array{Value: claimed}
+}
+
+func executeXAutoClaim(args [][]byte, db storage.Storage) resp.Message {
+	if len(args) < 5 {
+		return wrongNumberOfArgs(XAUTOCLAIM)
+	}
+
+	key, group, consumer := string(args[0]), string(args[1]), string(args[2])
+	minIdle, errMsg := parseMinIdle(XAUTOCLAIM, args[3])
+	if errMsg != nil {
+		return errMsg
+	}
+
+	start, ok, errMsg := parseStreamBound(args[4], false)
+	if errMsg != nil {
+		return errMsg
+	}
+	if !ok {
+		return errStreamStartID
+	}
+
+	var (
+		count  = int64(100)
+		justID bool
+	)
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+
+			count, ok = parseInt(args[i+1])
+			if !ok {
+				return errNotInteger
+			}
+			if count < 1 || count > math.MaxInt64/10 {
+				return errCountPositive
+			}
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return errSyntax
+		}
+	}
+
+	s, g, errMsg := getStreamGroup(db, key, group)
+	if errMsg != nil {
+		return errMsg
+	}
+	if g == nil {
+		return noGroupError(key, group, "")
+	}
+
+	now := nowMillis()
+	c, _ := g.CreateConsumer(consumer, now)
+	c.SeenTime = now
+
+	// like Redis, at most ten times count pending entries are looked at,
+	// and the next one is returned as the cursor to continue from
+	var (
+		attempts = count * 10
+		next     stream.ID
+		claimed  = []resp.Message{}
+		deleted  = []resp.Message{}
+	)
+	g.RangePending(start, stream.MaxID, func(p *stream.PendingEntry) bool {
+		if attempts == 0 || count == 0 {
+			next = p.ID
+			return false
+		}
+		attempts--
+
+		if now-p.DeliveryTime < minIdle {
+			return true
+		}
+
+		e, ok := s.Get(p.ID)
+		if !ok {
+			deleted = append(deleted, &resp.BulkString{Value: []byte(p.ID.String())})
+			g.Ack(p.ID)
+			return true
+		}
+
+		g.Claim(p, c)
+		p.DeliveryTime = now
+		if !justID {
+			p.DeliveryCount++
+		}
+		c.ActiveTime = now
+		count--
+
+		if justID {
+			claimed = append(claimed, &resp.BulkString{Value: []byte(p.ID.String())})
+		} else {
+			claimed = append(claimed, streamEntryReply(e))
+		}
+		return true
+	})
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(next.String())},
+		&resp.Array{Value: claimed},
+		&resp.Array{Value: deleted},
+	}}
+}
+
+// groupEntriesRead returns the number of entries delivered to g, which is
+// worked out from the stream if it isn't known. ok is false if it can't be
+// worked out either.
+func groupEntriesRead(s *stream.Stream, g *stream.Group) (int64, bool) {
+	if g.EntriesRead >= 0 {
+		return g.EntriesRead, true
+	}
+
+	return s.EntriesUpTo(g.LastID)
+}
+
+func xinfoGroups(key string, db storage.Storage) resp.Message {
+	s, errMsg := getStream(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if s == nil {
+		return errStreamNoSuchKey
+	}
+
+	groups := []resp.Message{}
+	s.RangeGroups(func(g *stream.Group) bool {
+		// like Redis, the lag is derived from the number of entries read
+		// and is nil when that isn't known
+		var (
+			entriesRead resp.Message = &resp.BulkString{}
+			lag         resp.Message = &resp.BulkString{}
+		)
+		if n, ok := groupEntriesRead(s, g); ok {
+			entriesRead = &resp.Int{Value: n}
+			lag = &resp.Int{Value: int64(s.EntriesAdded()) - n}
+		}
+
+		groups = append(groups, &resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("name")},
+			&resp.BulkString{Value: []byte(g.Name)},
+			&resp.BulkString{Value: []byte("consumers")},
+			&resp.Int{Value: int64(g.Consumers())},
+			&resp.BulkString{Value: []byte("pending")},
+			&resp.Int{Value: int64(g.PendingLen())},
+			&resp.BulkString{Value: []byte("last-delivered-id")},
+			&resp.BulkString{Value: []byte(g.LastID.String())},
+			&resp.BulkString{Value: []byte("entries-read")},
+			entriesRead,
+			&resp.BulkString{Value: []byte("lag")},
+			lag,
+		}})
+		return true
+	})
+
+	return &resp.Array{Value: groups}
+}
+
+func xinfoConsumers(key, group string, db storage.Storage) resp.Message {
+	_, g, errMsg := getStreamGroup(db, key, group)
+	if errMsg != nil {
+		return errMsg
+	}
+	if g == nil {
+		return noGroupError(key, group, "")
+	}
+
+	now := nowMillis()
+	consumers := []resp.Message{}
+	g.RangeConsumers(func(c *stream.Consumer) bool {
+		inactive := int64(-1)
+		if c.ActiveTime >= 0 {
+			inactive = now - c.ActiveTime
+		}
+
+		consumers = append(consumers, &resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("name")},
+			&resp.BulkString{Value: []byte(c.Name)},
+			&resp.BulkString{Value: []byte("pending")},
+			&resp.Int{Value: int64(c.PendingLen())},
+			&resp.BulkString{Value: []byte("idle")},
+			&resp.Int{Value: now - c.SeenTime},
+			&resp.BulkString{Value: []byte("inactive")},
+			&resp.Int{Value: inactive},
+		}})
+		return true
+	})
+
+	return &resp.Array{Value: consumers}
+}
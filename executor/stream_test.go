@@ -0,0 +1,417 @@
+package executor
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamEntry(id string, fields ...string) resp.Message {
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte(id)},
+		bulks(fields...),
+	}}
+}
+
+func streamEntries(entries ...resp.Message) resp.Message {
+	return &resp.Array{Value: append([]resp.Message{}, entries...)}
+}
+
+func TestXAddIDs(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("1-1")}, execute(e, "XADD s 1-1 f v"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1-2")}, execute(e, "XADD s 1-* f v"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("5-0")}, execute(e, "XADD s 5 f v"))
+	assert.Equal(t, errStreamIDTooSmall, execute(e, "XADD s 5-0 f v"))
+	assert.Equal(t, errStreamIDTooSmall, execute(e, "XADD s 4-* f v"))
+	assert.Equal(t, errStreamIDZero, execute(e, "XADD t 0-0 f v"))
+	assert.Equal(t, errStreamID, execute(e, "XADD s abc f v"))
+	assert.Equal(t, wrongNumberOfArgs(XADD), execute(e, "XADD s * f"))
+
+	id := execute(e, "XADD s * f v").(*resp.BulkString)
+	assert.NotEqual(t, "5-1", string(id.Value))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "XLEN s"))
+
+	assert.Equal(t, &resp.BulkString{}, execute(e, "XADD nokey NOMKSTREAM * f v"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XLEN nokey"))
+	assert.Equal(t, &resp.SimpleString{Value: "stream"}, execute(e, "TYPE s"))
+
+	execute(e, "SET str x")
+	assert.Equal(t, errWrongType, execute(e, "XADD str * f v"))
+}
+
+func TestXAddTrims(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 1; i <= 5; i++ {
+		execute(e, "XADD s MAXLEN 3 "+strconv.Itoa(i)+" f v")
+	}
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "XLEN s"))
+
+	execute(e, "XADD s MINID = 5 6 f v")
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "XLEN s"))
+
+	assert.Equal(t, errStreamLimitExact, execute(e, "XADD s MAXLEN 1 LIMIT 10 * f v"))
+	assert.Equal(t, errStreamMaxLen, execute(e, "XADD s MAXLEN -1 * f v"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "XLEN s"))
+
+	// approximate trimming keeps entries that share a node with kept ones
+	execute(e, "XADD s MAXLEN ~ 1 7 f v")
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "XLEN s"))
+}
+
+func TestXRange(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1-0 a 1")
+	execute(e, "XADD s 2-0 b 2")
+	execute(e, "XADD s 2-1 c 3")
+	execute(e, "XADD s 3-0 d 4")
+
+	assert.Equal(t, streamEntries(
+		streamEntry("1-0", "a", "1"),
+		streamEntry("2-0", "b", "2"),
+		streamEntry("2-1", "c", "3"),
+		streamEntry("3-0", "d", "4"),
+	), execute(e, "XRANGE s - +"))
+	assert.Equal(t, streamEntries(
+		streamEntry("2-0", "b", "2"),
+		streamEntry("2-1", "c", "3"),
+	), execute(e, "XRANGE s 2 2"))
+	assert.Equal(t, streamEntries(
+		streamEntry("2-1", "c", "3"),
+	), execute(e, "XRANGE s (2-0 (3-0"))
+	assert.Equal(t, streamEntries(
+		streamEntry("3-0", "d", "4"),
+		streamEntry("2-1", "c", "3"),
+	), execute(e, "XREVRANGE s + - COUNT 2"))
+	assert.Equal(t, streamEntries(), execute(e, "XRANGE s 3 1"))
+	assert.Equal(t, streamEntries(), execute(e, "XRANGE nokey - +"))
+	assert.Equal(t, errStreamID, execute(e, "XRANGE s x +"))
+	assert.Equal(t, errSyntax, execute(e, "XRANGE s - + LIMIT 1"))
+	assert.Equal(t, errStreamStartID, execute(e, "XRANGE s (18446744073709551615-18446744073709551615 +"))
+}
+
+func TestXDelAndXTrim(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for _, id := range []string{"1", "2", "3", "4"} {
+		execute(e, "XADD s "+id+" f v")
+	}
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "XDEL s 1 3 9"))
+	assert.Equal(t, errStreamID, execute(e, "XDEL s 2 x"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "XLEN s"))
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XTRIM s MAXLEN 1"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XTRIM nokey MINID 5"))
+	assert.Equal(t, errSyntax, execute(e, "XTRIM s FOO 1"))
+
+	// empty streams are kept, along with their last ID
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XDEL s 4"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XLEN s"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "EXISTS s"))
+	assert.Equal(t, errStreamIDTooSmall, execute(e, "XADD s 4 f v"))
+}
+
+func TestXInfoStream(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1-0 a 1")
+	execute(e, "XADD s 2-0 b 2")
+	execute(e, "XDEL s 2-0")
+
+	info := execute(e, "XINFO STREAM s").(*resp.Array)
+	fields := make(map[string]resp.Message)
+	for i := 0; i < len(info.Value); i += 2 {
+		fields[string(info.Value[i].(*resp.BulkString).Value)] = info.Value[i+1]
+	}
+
+	assert.Equal(t, &resp.Int{Value: 1}, fields["length"])
+	assert.Equal(t, &resp.Int{Value: 1}, fields["radix-tree-keys"])
+	assert.Equal(t, &resp.BulkString{Value: []byte("2-0")}, fields["last-generated-id"])
+	assert.Equal(t, &resp.BulkString{Value: []byte("2-0")}, fields["max-deleted-entry-id"])
+	assert.Equal(t, &resp.Int{Value: 2}, fields["entries-added"])
+	assert.Equal(t, streamEntry("1-0", "a", "1"), fields["first-entry"])
+	assert.Equal(t, streamEntry("1-0", "a", "1"), fields["last-entry"])
+
+	assert.Equal(t, errStreamNoSuchKey, execute(e, "XINFO STREAM nokey"))
+	assert.IsType(t, &resp.Error{}, execute(e, "XINFO FOO s"))
+}
+
+func TestXRead(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD a 1 f 1")
+	execute(e, "XADD a 2 f 2")
+	execute(e, "XADD b 1 g 1")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("a")},
+			streamEntries(streamEntry("2-0", "f", "2")),
+		}},
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("b")},
+			streamEntries(streamEntry("1-0", "g", "1")),
+		}},
+	}}, execute(e, "XREAD COUNT 5 STREAMS a b 1 0"))
+
+	assert.Equal(t, &resp.Array{}, execute(e, "XREAD STREAMS a $"))
+	assert.Equal(t, &resp.Error{Value: "ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."}, execute(e, "XREAD STREAMS a b 0"))
+	assert.Equal(t, errSyntax, execute(e, "XREAD COUNT 1 a 0"))
+}
+
+func TestXReadBlocks(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1 f 1")
+
+	replies := executeAsync(t, e, context.Background(), "XREAD BLOCK 0 STREAMS s $")
+	// a different stream doesn't serve the client
+	execute(e, "XADD other 1 f 1")
+	assert.Equal(t, 1, blockedCount(e))
+
+	execute(e, "XADD s 2 f 2")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("s")},
+			streamEntries(streamEntry("2-0", "f", "2")),
+		}},
+	}}, receive(t, replies))
+
+	assert.Equal(t, &resp.Array{}, execute(e, "XREAD BLOCK 10 STREAMS s $"))
+}
+
+func TestXReadBlockErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, errTimeoutNegative, execute(e, "XREAD BLOCK -1 STREAMS s $"))
+	assert.Equal(t, errTimeoutNotInteger, execute(e, "XREAD BLOCK soon STREAMS s $"))
+	// the largest timeout in milliseconds that fits a time.Duration, plus one
+	tooLong := strconv.FormatInt(math.MaxInt64/int64(time.Millisecond)+1, 10)
+	assert.Equal(t, errTimeoutNotInteger, execute(e, "XREAD BLOCK "+tooLong+" STREAMS s $"))
+	assert.Equal(t, errTimeoutNotInteger, execute(e, "XREADGROUP GROUP g c BLOCK "+tooLong+" STREAMS s >"))
+}
+
+func TestXReadServesEveryReadyClient(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 5 f v")
+
+	// the first client waits for entries past 10-0 and can't be served by
+	// 6-0, which mustn't stop the second one being served
+	first := executeAsync(t, e, context.Background(), "XREAD BLOCK 0 STREAMS s 10")
+	second := executeAsync(t, e, context.Background(), "XREAD BLOCK 0 STREAMS s $")
+
+	execute(e, "XADD s 6 f v")
+	msg := receive(t, second).(*resp.Array)
+	require.Len(t, msg.Value, 1)
+	assert.Equal(t, 1, blockedCount(e))
+
+	execute(e, "XADD s 11 f v")
+	msg = receive(t, first).(*resp.Array)
+	require.Len(t, msg.Value, 1)
+}
+
+func TestXGroup(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, errXGroupNoKey, execute(e, "XGROUP CREATE s g $"))
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "XGROUP CREATE s g $ MKSTREAM"))
+	assert.Equal(t, errBusyGroup, execute(e, "XGROUP CREATE s g 0"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XGROUP CREATECONSUMER s g alice"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XGROUP CREATECONSUMER s g alice"))
+	assert.Equal(t, &resp.SimpleString{Value: "OK"}, execute(e, "XGROUP SETID s g 0 ENTRIESREAD 0"))
+	assert.IsType(t, &resp.Error{}, execute(e, "XGROUP SETID s nogroup 0"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XGROUP DELCONSUMER s g alice"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XGROUP DESTROY s g"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XGROUP DESTROY s g"))
+	assert.Equal(t, unknownSubcommand(XGROUP, []byte("foo")), execute(e, "XGROUP foo s g"))
+}
+
+func TestXReadGroup(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1 f 1")
+	execute(e, "XADD s 2 f 2")
+	execute(e, "XADD s 3 f 3")
+	execute(e, "XGROUP CREATE s g 0")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		streamReadReply("s", streamEntries(
+			streamEntry("1-0", "f", "1"),
+			streamEntry("2-0", "f", "2"),
+		)),
+	}}, execute(e, "XREADGROUP GROUP g alice COUNT 2 STREAMS s >"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		streamReadReply("s", streamEntries(streamEntry("3-0", "f", "3"))),
+	}}, execute(e, "XREADGROUP GROUP g bob STREAMS s >"))
+	assert.Equal(t, &resp.Array{}, execute(e, "XREADGROUP GROUP g bob STREAMS s >"))
+
+	// the history holds the entries delivered to the consumer and not
+	// acknowledged, and reading it counts as another delivery
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XACK s g 1 9"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		streamReadReply("s", streamEntries(streamEntry("2-0", "f", "2"))),
+	}}, execute(e, "XREADGROUP GROUP g alice STREAMS s 0"))
+
+	pending := execute(e, "XPENDING s g - + 10").(*resp.Array)
+	require.Len(t, pending.Value, 2)
+	assert.Equal(t, &resp.BulkString{Value: []byte("alice")}, pending.Value[0].(*resp.Array).Value[1])
+	assert.Equal(t, &resp.Int{Value: 2}, pending.Value[0].(*resp.Array).Value[3])
+
+	// deleted entries stay pending and are replied without fields
+	execute(e, "XDEL s 2")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		streamReadReply("s", streamEntries(&resp.Array{Value: []resp.Message{
+			&resp.BulkString{Value: []byte("2-0")},
+			&resp.Array{},
+		}})),
+	}}, execute(e, "XREADGROUP GROUP g alice STREAMS s 0"))
+
+	assert.Equal(t, errXReadGroupDollar, execute(e, "XREADGROUP GROUP g alice STREAMS s $"))
+	assert.Equal(t, errXReadGroupMissing, execute(e, "XREADGROUP STREAMS s >"))
+	assert.Equal(t, errXReadGreaterID, execute(e, "XREAD STREAMS s >"))
+	assert.IsType(t, &resp.Error{}, execute(e, "XREADGROUP GROUP nogroup alice STREAMS s >"))
+}
+
+func TestXReadGroupNoAck(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1 f 1")
+	execute(e, "XGROUP CREATE s g 0")
+
+	execute(e, "XREADGROUP GROUP g alice NOACK STREAMS s >")
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "XPENDING s g").(*resp.Array).Value[0])
+}
+
+func TestXReadGroupBlocks(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XGROUP CREATE s g $ MKSTREAM")
+
+	alice := executeAsync(t, e, context.Background(), "XREADGROUP GROUP g alice BLOCK 0 STREAMS s >")
+	bob := executeAsync(t, e, context.Background(), "XREADGROUP GROUP g bob BLOCK 0 STREAMS s >")
+
+	// each entry is delivered to a single consumer of the group
+	execute(e, "XADD s 1 f v")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		streamReadReply("s", streamEntries(streamEntry("1-0", "f", "v"))),
+	}}, receive(t, alice))
+	assert.Equal(t, 1, blockedCount(e))
+
+	execute(e, "XGROUP DESTROY s g")
+	execute(e, "XADD s 2 f v")
+	assert.Equal(t, errGroupDestroyed, receive(t, bob))
+}
+
+func TestXPendingSummary(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1 f v")
+	execute(e, "XADD s 2 f v")
+	execute(e, "XADD s 3 f v")
+	execute(e, "XGROUP CREATE s g 0")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 0}, &resp.BulkString{}, &resp.BulkString{}, &resp.Array{},
+	}}, execute(e, "XPENDING s g"))
+
+	execute(e, "XREADGROUP GROUP g alice COUNT 2 STREAMS s >")
+	execute(e, "XREADGROUP GROUP g bob STREAMS s >")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 3},
+		&resp.BulkString{Value: []byte("1-0")},
+		&resp.BulkString{Value: []byte("3-0")},
+		&resp.Array{Value: []resp.Message{bulks("alice", "2"), bulks("bob", "1")}},
+	}}, execute(e, "XPENDING s g"))
+
+	assert.Len(t, execute(e, "XPENDING s g - + 10 bob").(*resp.Array).Value, 1)
+	assert.Len(t, execute(e, "XPENDING s g IDLE 100000 - + 10").(*resp.Array).Value, 0)
+	assert.Equal(t, noGroupError("s", "nogroup", ""), execute(e, "XPENDING s nogroup"))
+}
+
+func TestXClaim(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1 f v")
+	execute(e, "XADD s 2 f v")
+	execute(e, "XGROUP CREATE s g 0")
+	execute(e, "XREADGROUP GROUP g alice STREAMS s >")
+
+	// entries delivered too recently aren't claimed
+	assert.Equal(t, streamEntries(), execute(e, "XCLAIM s g bob 100000 1"))
+
+	assert.Equal(t, streamEntries(streamEntry("1-0", "f", "v")), execute(e, "XCLAIM s g bob 0 1"))
+	assert.Equal(t, bulks("2-0"), execute(e, "XCLAIM s g bob 0 2 JUSTID RETRYCOUNT 7"))
+
+	pending := execute(e, "XPENDING s g - + 10").(*resp.Array).Value
+	assert.Equal(t, &resp.BulkString{Value: []byte("bob")}, pending[0].(*resp.Array).Value[1])
+	assert.Equal(t, &resp.Int{Value: 2}, pending[0].(*resp.Array).Value[3])
+	assert.Equal(t, &resp.Int{Value: 7}, pending[1].(*resp.Array).Value[3])
+
+	// deleted entries are dropped from the pending entries instead
+	execute(e, "XDEL s 1")
+	assert.Equal(t, streamEntries(), execute(e, "XCLAIM s g alice 0 1"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "XPENDING s g").(*resp.Array).Value[0])
+
+	execute(e, "XADD s 3 f v")
+	assert.Equal(t, bulks("3-0"), execute(e, "XCLAIM s g alice 0 3 FORCE JUSTID LASTID 3"))
+	assert.Equal(t, &resp.Array{}, execute(e, "XREADGROUP GROUP g alice STREAMS s >"))
+}
+
+func TestXAutoClaim(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for _, id := range []string{"1", "2", "3", "4"} {
+		execute(e, "XADD s "+id+" f v")
+	}
+	execute(e, "XGROUP CREATE s g 0")
+	execute(e, "XREADGROUP GROUP g alice STREAMS s >")
+	execute(e, "XDEL s 2")
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("4-0")},
+		bulks("1-0", "3-0"),
+		bulks("2-0"),
+	}}, execute(e, "XAUTOCLAIM s g bob 0 - COUNT 2 JUSTID"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("0-0")},
+		streamEntries(streamEntry("4-0", "f", "v")),
+		bulks(),
+	}}, execute(e, "XAUTOCLAIM s g bob 0 4-0"))
+
+	assert.Equal(t, errCountPositive, execute(e, "XAUTOCLAIM s g bob 0 - COUNT 0"))
+}
+
+func TestXInfoGroupsAndConsumers(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "XADD s 1 f v")
+	execute(e, "XADD s 2 f v")
+	execute(e, "XGROUP CREATE s g 0")
+	execute(e, "XREADGROUP GROUP g alice COUNT 1 STREAMS s >")
+	execute(e, "XGROUP CREATECONSUMER s g bob")
+
+	groups := execute(e, "XINFO GROUPS s").(*resp.Array).Value
+	require.Len(t, groups, 1)
+	assert.Equal(t, []resp.Message{
+		&resp.BulkString{Value: []byte("name")},
+		&resp.BulkString{Value: []byte("g")},
+		&resp.BulkString{Value: []byte("consumers")},
+		&resp.Int{Value: 2},
+		&resp.BulkString{Value: []byte("pending")},
+		&resp.Int{Value: 1},
+		&resp.BulkString{Value: []byte("last-delivered-id")},
+		&resp.BulkString{Value: []byte("1-0")},
+		&resp.BulkString{Value: []byte("entries-read")},
+		&resp.Int{Value: 1},
+		&resp.BulkString{Value: []byte("lag")},
+		&resp.Int{Value: 1},
+	}, groups[0].(*resp.Array).Value)
+
+	consumers := execute(e, "XINFO CONSUMERS s g").(*resp.Array).Value
+	require.Len(t, consumers, 2)
+	alice := consumers[0].(*resp.Array).Value
+	assert.Equal(t, &resp.BulkString{Value: []byte("alice")}, alice[1])
+	assert.Equal(t, &resp.Int{Value: 1}, alice[3])
+	bob := consumers[1].(*resp.Array).Value
+	assert.Equal(t, &resp.Int{Value: -1}, bob[7])
+
+	assert.IsType(t, &resp.Error{}, execute(e, "XINFO CONSUMERS s nogroup"))
+}
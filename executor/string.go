@@ -0,0 +1,593 @@
+package executor
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+)
+
+const (
+	INCR        = "INCR"
+	DECR        = "DECR"
+	INCRBY      = "INCRBY"
+	DECRBY      = "DECRBY"
+	INCRBYFLOAT = "INCRBYFLOAT"
+	APPEND      = "APPEND"
+	STRLEN      = "STRLEN"
+	GETRANGE    = "GETRANGE"
+	SETRANGE    = "SETRANGE"
+	LCS         = "LCS"
+)
+
+// maxStringLength is the largest string value that can be built up by
+// commands that grow a value in place, matching Redis's default
+// proto-max-bulk-len.
+const maxStringLength = 512 * 1024 * 1024
+
+var (
+	errNotInteger = &resp.Error{Value: "ERR value is not an integer or out of range"}
+	errNotFloat   = &resp.Error{Value: "ERR value is not a valid float"}
+	errOverflow   = &resp.Error{Value: "ERR increment or decrement would overflow"}
+	errNaNOrInf   = &resp.Error{Value: "ERR increment would produce NaN or Infinity"}
+	errTooLong    = &resp.Error{Value: "ERR string exceeds maximum allowed size (proto-max-bulk-len)"}
+	errSyntax     = &resp.Error{Value: "ERR syntax error"}
+)
+
+// newStringNode stores val as an int64 when it is the canonical
+// representation of an integer and as raw bytes otherwise.
+func newStringNode(val []byte) storage.Node {
+	if n, ok := parseInt(val); ok {
+		return storage.NewIntNode(n)
+	}
+
+	return storage.NewNode(val)
+}
+
+// stringValue returns the bytes of a string value regardless of how it is
+// encoded. ok is false if the node does not hold a string.
+func stringValue(node storage.Node) (val []byte, ok bool) {
+	switch v := node.Value().(type) {
+	case []byte:
+		return v, true
+	case int64:
+		return strconv.AppendInt(nil, v, 10), true
+	default:
+		return nil, false
+	}
+}
+
+// bulkCopy returns a reply holding a copy of val. Stored strings can be
+// modified in place by later commands, so replies must not alias them once
+// the storage lock has been released.
+func bulkCopy(val []byte) *resp.BulkString {
+	return &resp.BulkString{Value: append(make([]byte, 0, len(val)), val...)}
+}
+
+// getString looks up the string value stored at key. exists is false if the
+// key does not exist.
+func getString(db storage.Storage, key string) (val []byte, exists bool, errMsg resp.Message) {
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, false, nil
+		}
+
+		return nil, false, genericErrorMessage
+	}
+
+	val, ok := stringValue(node)
+	if !ok {
+		return nil, false, errWrongType
+	}
+
+	return val, true, nil
+}
+
+// parseInt parses b as a 64-bit signed integer. Only the canonical
+// representation is accepted: no sign prefix, whitespace or leading zeros.
+func parseInt(b []byte) (int64, bool) {
+	if len(b) == 0 || len(b) > 20 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if strconv.FormatInt(n, 10) != string(b) {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// parseFloat parses b as a float64, rejecting NaN. Only decimal floats are
+// accepted, without the hexadecimal form and underscores of Go literals.
+func parseFloat(b []byte) (float64, bool) {
+	if len(b) == 0 || bytes.ContainsAny(b, "_xX") {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+
+	return f, true
+}
+
+// formatFloat formats f like "%.17g" does, switching to an exponent when it
+// is below -4 or at least 17, but with the fewest digits that parse back to
+// f.
+func formatFloat(f float64) []byte {
+	e := strconv.AppendFloat(nil, f, 'e', -1, 64)
+	if i := bytes.IndexByte(e, 'e'); i >= 0 {
+		if exp, _ := strconv.Atoi(string(e[i+1:])); exp < -4 || exp >= 17 {
+			return e
+		}
+	}
+
+	return strconv.AppendFloat(nil, f, 'f', -1, 64)
+}
+
+func executeIncr(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	return incrBy(db, key, 1)
+}
+
+func executeDecr(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	return incrBy(db, key, -1)
+}
+
+func executeIncrBy(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawIncr := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	incr, ok := parseInt(rawIncr)
+	if !ok {
+		return errNotInteger
+	}
+
+	return incrBy(db, key, incr)
+}
+
+func executeDecrBy(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawDecr := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	decr, ok := parseInt(rawDecr)
+	if !ok {
+		return errNotInteger
+	}
+
+	// -math.MinInt64 is not representable
+	if decr == math.MinInt64 {
+		return &resp.Error{Value: "ERR decrement would overflow"}
+	}
+
+	return incrBy(db, key, -decr)
+}
+
+func incrBy(db storage.Storage, key string, incr int64) resp.Message {
+	var current int64
+
+	node, err := db.Get(key)
+	if err == nil {
+		switch v := node.Value().(type) {
+		case int64:
+			current = v
+		case []byte:
+			n, ok := parseInt(v)
+			if !ok {
+				return errNotInteger
+			}
+			current = n
+		default:
+			return errWrongType
+		}
+	}
+
+	if (incr > 0 && current > math.MaxInt64-incr) ||
+		(incr < 0 && current < math.MinInt64-incr) {
+		return errOverflow
+	}
+
+	current += incr
+	db.Set(key, storage.NewIntNode(current))
+
+	return &resp.Int{Value: current}
+}
+
+func executeIncrByFloat(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawIncr := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	incr, ok := parseFloat(rawIncr)
+	if !ok {
+		return errNotFloat
+	}
+
+	var current float64
+
+	node, err := db.Get(key)
+	if err == nil {
+		val, ok := stringValue(node)
+		if !ok {
+			return errWrongType
+		}
+
+		current, ok = parseFloat(val)
+		if !ok {
+			return errNotFloat
+		}
+	}
+
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return errNaNOrInf
+	}
+
+	val := formatFloat(current)
+	db.Set(key, storage.NewNode(val))
+
+	return bulkCopy(val)
+}
+
+func executeAppend(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	suffix := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	val, exists, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if !exists {
+		db.Set(key, storage.NewNode(append([]byte(nil), suffix...)))
+		return &resp.Int{Value: int64(len(suffix))}
+	}
+
+	if len(val)+len(suffix) > maxStringLength {
+		return errTooLong
+	}
+
+	// append grows the stored slice geometrically, so repeated appends to
+	// the same key don't copy the whole value each time
+	val = append(val, suffix...)
+	db.Set(key, storage.NewNode(val))
+
+	return &resp.Int{Value: int64(len(val))}
+}
+
+func executeStrlen(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	val, _, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	return &resp.Int{Value: int64(len(val))}
+}
+
+func executeGetRange(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawStart := ae.ExtractAt(1)
+	rawEnd := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	start, ok := parseInt(rawStart)
+	if !ok {
+		return errNotInteger
+	}
+
+	end, ok := parseInt(rawEnd)
+	if !ok {
+		return errNotInteger
+	}
+
+	val, _, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	start, end, ok = normalizeRange(start, end, int64(len(val)))
+	if !ok {
+		return &resp.BulkString{Value: []byte{}}
+	}
+
+	return bulkCopy(val[start : end+1])
+}
+
+// normalizeRange converts the inclusive range [start, end], where negative
+// indexes count back from the end of a sequence of the given length, into
+// absolute indexes. ok is false if the range is empty.
+func normalizeRange(start, end, length int64) (int64, int64, bool) {
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+
+	if start > end || length == 0 {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+func executeSetRange(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawOffset := ae.ExtractAt(1)
+	patch := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	offset, ok := parseInt(rawOffset)
+	if !ok {
+		return errNotInteger
+	}
+
+	if offset < 0 {
+		return &resp.Error{Value: "ERR offset is out of range"}
+	}
+
+	val, exists, errMsg := getString(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	// an empty patch never creates or grows the value
+	if len(patch) == 0 {
+		return &resp.Int{Value: int64(len(val))}
+	}
+
+	// compared without adding to offset, which may overflow
+	if offset > maxStringLength-int64(len(patch)) {
+		return errTooLong
+	}
+
+	end := int(offset) + len(patch)
+	if !exists || end > len(val) {
+		val = growZeroed(val, end)
+	}
+
+	copy(val[offset:], patch)
+	db.Set(key, storage.NewNode(val))
+
+	return &resp.Int{Value: int64(len(val))}
+}
+
+// growZeroed extends val to size bytes, zero-filling the new bytes. The
+// existing bytes are only copied when val doesn't have enough capacity.
+func growZeroed(val []byte, size int) []byte {
+	if size <= len(val) {
+		return val
+	}
+
+	if size <= cap(val) {
+		tail := val[len(val):size]
+		for i := range tail {
+			tail[i] = 0
+		}
+		return val[:size]
+	}
+
+	grown := make([]byte, size, size+size/4)
+	copy(grown, val)
+	return grown
+}
+
+func executeLCS(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key1 := ae.ExtractStringAt(0)
+	key2 := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	var (
+		getLen, getIdx, withMatchLen bool
+		minMatchLen                  int64
+	)
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			getIdx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return errNotInteger
+			}
+			if n > 0 {
+				minMatchLen = n
+			}
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	if getLen && getIdx {
+		return &resp.Error{Value: "ERR If you want both the length and indexes, please just use IDX."}
+	}
+
+	a, _, errMsg := getString(db, key1)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	b, _, errMsg := getString(db, key2)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if (uint64(len(a))+1)*(uint64(len(b))+1) >= math.MaxUint32/4 {
+		return &resp.Error{Value: "ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len"}
+	}
+
+	return lcs(a, b, getLen, getIdx, withMatchLen, minMatchLen)
+}
+
+// lcs computes the longest common subsequence of a and b using the classic
+// dynamic programming table, then walks the table backwards to recover the
+// subsequence and the ranges of contiguous matches.
+func lcs(a, b []byte, getLen, getIdx, withMatchLen bool, minMatchLen int64) resp.Message {
+	alen, blen := len(a), len(b)
+	table := make([]uint32, (alen+1)*(blen+1))
+	at := func(i, j int) uint32 { return table[j*(alen+1)+i] }
+
+	for j := 1; j <= blen; j++ {
+		for i := 1; i <= alen; i++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				table[j*(alen+1)+i] = at(i-1, j-1) + 1
+			case at(i-1, j) > at(i, j-1):
+				table[j*(alen+1)+i] = at(i-1, j)
+			default:
+				table[j*(alen+1)+i] = at(i, j-1)
+			}
+		}
+	}
+
+	length := at(alen, blen)
+	if getLen {
+		return &resp.Int{Value: int64(length)}
+	}
+
+	result := make([]byte, length)
+	idx := length
+
+	var matches []resp.Message
+	// astart == alen means no range is being tracked
+	astart, aend, bstart, bend := alen, 0, 0, 0
+
+	i, j := alen, blen
+	for i > 0 && j > 0 {
+		emit := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+
+			if astart == alen {
+				astart, aend = i-1, i-1
+				bstart, bend = j-1, j-1
+			} else {
+				// matches found while walking backwards extend the current
+				// range as long as they are contiguous in both strings
+				astart--
+				bstart--
+			}
+
+			if astart == 0 || bstart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			if astart != alen {
+				emit = true
+			}
+		}
+
+		if emit {
+			matchLen := int64(aend - astart + 1)
+			if getIdx && matchLen >= minMatchLen {
+				match := []resp.Message{
+					&resp.Array{Value: []resp.Message{
+						&resp.Int{Value: int64(astart)},
+						&resp.Int{Value: int64(aend)},
+					}},
+					&resp.Array{Value: []resp.Message{
+						&resp.Int{Value: int64(bstart)},
+						&resp.Int{Value: int64(bend)},
+					}},
+				}
+				if withMatchLen {
+					match = append(match, &resp.Int{Value: matchLen})
+				}
+				matches = append(matches, &resp.Array{Value: match})
+			}
+			astart = alen
+		}
+	}
+
+	if !getIdx {
+		return &resp.BulkString{Value: result}
+	}
+
+	if matches == nil {
+		matches = []resp.Message{}
+	}
+
+	return &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("matches")},
+		&resp.Array{Value: matches},
+		&resp.BulkString{Value: []byte("len")},
+		&resp.Int{Value: int64(length)},
+	}}
+}
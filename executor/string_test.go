@@ -0,0 +1,247 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncr(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "INCR counter"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "INCR counter"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "DECR counter"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1")}, execute(e, "GET counter"))
+}
+
+func TestIncrStoresInteger(t *testing.T) {
+	db := inmem.NewStorage()
+	e := NewExecutor(db)
+
+	execute(e, "SET counter 10")
+	execute(e, "INCR counter")
+
+	n, err := db.Get("counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), n.Value())
+}
+
+func TestSetKeepsNonCanonicalIntegers(t *testing.T) {
+	db := inmem.NewStorage()
+	e := NewExecutor(db)
+
+	execute(e, "SET a 010")
+
+	n, err := db.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("010"), n.Value())
+	assert.Equal(t, errNotInteger, execute(e, "INCR a"))
+}
+
+func TestIncrBy(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 5}, execute(e, "INCRBY counter 5"))
+	assert.Equal(t, &resp.Int{Value: -5}, execute(e, "DECRBY counter 10"))
+	assert.Equal(t, errNotInteger, execute(e, "INCRBY counter 1.5"))
+	assert.Equal(t, errNotInteger, execute(e, "INCRBY counter +1"))
+}
+
+func TestIncrNotInteger(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a foo")
+
+	assert.Equal(t, errNotInteger, execute(e, "INCR a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("foo")}, execute(e, "GET a"))
+}
+
+func TestIncrOverflow(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	execute(e, "SET a 9223372036854775807")
+	assert.Equal(t, errOverflow, execute(e, "INCR a"))
+
+	execute(e, "SET b -9223372036854775808")
+	assert.Equal(t, errOverflow, execute(e, "DECR b"))
+
+	assert.Equal(t, &resp.Error{Value: "ERR decrement would overflow"}, execute(e, "DECRBY c -9223372036854775808"))
+	assert.Equal(t, errNotInteger, execute(e, "INCRBY c 9223372036854775808"))
+}
+
+func TestIncrByFloat(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("10.5")}, execute(e, "INCRBYFLOAT f 10.5"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("10.6")}, execute(e, "INCRBYFLOAT f 0.1"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("5.6")}, execute(e, "INCRBYFLOAT f -5"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("5.6")}, execute(e, "GET f"))
+
+	execute(e, "SET i 3")
+	assert.Equal(t, &resp.BulkString{Value: []byte("3.5")}, execute(e, "INCRBYFLOAT i 0.5"))
+}
+
+func TestIncrByFloatErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a foo")
+
+	assert.Equal(t, errNotFloat, execute(e, "INCRBYFLOAT a 1"))
+	assert.Equal(t, errNotFloat, execute(e, "INCRBYFLOAT b nan"))
+	assert.Equal(t, errNaNOrInf, execute(e, "INCRBYFLOAT b inf"))
+	assert.Equal(t, errNotFloat, execute(e, "INCRBYFLOAT b 0x1p3"))
+	assert.Equal(t, errNotFloat, execute(e, "INCRBYFLOAT b 1_000"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS b"))
+}
+
+func TestIncrByFloatExponent(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("1e+308")}, execute(e, "INCRBYFLOAT f 1e308"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1.5e-07")}, execute(e, "INCRBYFLOAT g 0.00000015"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("123456789")}, execute(e, "INCRBYFLOAT h 123456789"))
+}
+
+func TestGetIntegerNode(t *testing.T) {
+	db := &storage.MockStorage{
+		GetFn: func(key string) (storage.Node, error) {
+			return storage.NewIntNode(-42), nil
+		},
+	}
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("-42")}, executeGet(asArgs("a"), db))
+}
+
+func TestAppend(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 5}, execute(e, "APPEND log hello"))
+	assert.Equal(t, &resp.Int{Value: 11}, execute(e, "APPEND log _world"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("hello_world")}, execute(e, "GET log"))
+
+	execute(e, "SET n 12")
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "APPEND n 3"))
+	assert.Equal(t, &resp.Int{Value: 124}, execute(e, "INCR n"))
+}
+
+func TestAppendDoesNotAliasReplies(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "APPEND k ab")
+	execute(e, "APPEND k cd")
+
+	got := execute(e, "GET k")
+	execute(e, "SETRANGE k 0 zz")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("abcd")}, got)
+}
+
+func TestStrlen(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a hello")
+	execute(e, "SET n -100")
+
+	assert.Equal(t, &resp.Int{Value: 5}, execute(e, "STRLEN a"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "STRLEN n"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "STRLEN missing"))
+}
+
+func TestGetRange(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a This_is_a_string")
+
+	var tests = []struct {
+		start, end string
+		expected   string
+	}{
+		{"0", "3", "This"},
+		{"-3", "-1", "ing"},
+		{"0", "-1", "This_is_a_string"},
+		{"10", "100", "string"},
+		{"5", "3", ""},
+		{"-1", "-5", ""},
+		{"-100", "3", "This"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, &resp.BulkString{Value: []byte(tt.expected)},
+			execute(e, "GETRANGE a "+tt.start+" "+tt.end), tt.start+" "+tt.end)
+	}
+
+	assert.Equal(t, &resp.BulkString{Value: []byte{}}, execute(e, "GETRANGE missing 0 -1"))
+	assert.Equal(t, errNotInteger, execute(e, "GETRANGE a x 1"))
+}
+
+func TestSetRange(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET a Hello_World")
+
+	assert.Equal(t, &resp.Int{Value: 11}, execute(e, "SETRANGE a 6 Redis"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("Hello_Redis")}, execute(e, "GET a"))
+
+	assert.Equal(t, &resp.Int{Value: 11}, execute(e, "SETRANGE b 6 Redis"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("\x00\x00\x00\x00\x00\x00Redis")}, execute(e, "GET b"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "SETRANGE c 5 "))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS c"))
+}
+
+func TestSetRangeLimits(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Error{Value: "ERR offset is out of range"}, execute(e, "SETRANGE a -1 x"))
+	assert.Equal(t, errTooLong, execute(e, "SETRANGE a 536870912 x"))
+	// an offset this large would overflow once the patch is added
+	assert.Equal(t, errTooLong, execute(e, fmt.Sprintf("SETRANGE a %d ab", int64(math.MaxInt64))))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS a"))
+}
+
+func TestLCS(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET key1 ohmytext key2 mynewtext")
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("mytext")}, execute(e, "LCS key1 key2"))
+	assert.Equal(t, &resp.Int{Value: 6}, execute(e, "LCS key1 key2 LEN"))
+	assert.Equal(t, &resp.BulkString{Value: []byte{}}, execute(e, "LCS key1 missing"))
+}
+
+func TestLCSIdx(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "MSET key1 ohmytext key2 mynewtext")
+
+	rng := func(start, end int64) resp.Message {
+		return &resp.Array{Value: []resp.Message{&resp.Int{Value: start}, &resp.Int{Value: end}}}
+	}
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("matches")},
+		&resp.Array{Value: []resp.Message{
+			&resp.Array{Value: []resp.Message{rng(4, 7), rng(5, 8)}},
+			&resp.Array{Value: []resp.Message{rng(2, 3), rng(0, 1)}},
+		}},
+		&resp.BulkString{Value: []byte("len")},
+		&resp.Int{Value: 6},
+	}}, execute(e, "LCS key1 key2 IDX"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("matches")},
+		&resp.Array{Value: []resp.Message{
+			&resp.Array{Value: []resp.Message{rng(4, 7), rng(5, 8), &resp.Int{Value: 4}}},
+		}},
+		&resp.BulkString{Value: []byte("len")},
+		&resp.Int{Value: 6},
+	}}, execute(e, "LCS key1 key2 IDX MINMATCHLEN 4 WITHMATCHLEN"))
+}
+
+func TestLCSOptionErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, errSyntax, execute(e, "LCS a b FOO"))
+	assert.Equal(t, errSyntax, execute(e, "LCS a b MINMATCHLEN"))
+	assert.Equal(t, &resp.Error{Value: "ERR If you want both the length and indexes, please just use IDX."},
+		execute(e, "LCS a b LEN IDX"))
+}
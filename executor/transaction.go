@@ -0,0 +1,347 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+)
+
+const (
+	MULTI   = "MULTI"
+	EXEC    = "EXEC"
+	DISCARD = "DISCARD"
+	WATCH   = "WATCH"
+	UNWATCH = "UNWATCH"
+)
+
+var (
+	errMultiNested      = &resp.Error{Value: "ERR MULTI calls can not be nested"}
+	errExecWithoutMulti = &resp.Error{Value: "ERR EXEC without MULTI"}
+	errDiscardNoMulti   = &resp.Error{Value: "ERR DISCARD without MULTI"}
+	errWatchInMulti     = &resp.Error{Value: "ERR WATCH inside MULTI is not allowed"}
+	errExecAbort        = &resp.Error{Value: "EXECABORT Transaction discarded because of previous errors."}
+	errNotInMulti       = &resp.Error{Value: "ERR Command not allowed inside a transaction"}
+)
+
+var queuedReply = &resp.SimpleString{Value: "QUEUED"}
+
+// transactionCommands are run straight away rather than queued while a
+// transaction is open.
+var transactionCommands = map[string]bool{
+	MULTI:   true,
+	EXEC:    true,
+	DISCARD: true,
+	WATCH:   true,
+	QUIT:    true,
+	RESET:   true,
+}
+
+// noMultiCommands can't be queued in a transaction.
+var noMultiCommands = map[string]bool{
+	SUBSCRIBE:    true,
+	UNSUBSCRIBE:  true,
+	PSUBSCRIBE:   true,
+	PUNSUBSCRIBE: true,
+	SSUBSCRIBE:   true,
+	SUNSUBSCRIBE: true,
+	MONITOR:      true,
+}
+
+// watchedKey is a key of the database at index db.
+type watchedKey struct {
+	db  int
+	key string
+}
+
+// watchRegistry tracks the keys clients watch and which clients saw one of
+// their watched keys modified since they started watching it.
+type watchRegistry struct {
+	mu sync.Mutex
+
+	watchers map[watchedKey]map[*Client]struct{}
+	watched  map[*Client][]watchedKey
+	dirty    map[*Client]bool
+}
+
+func newWatchRegistry() *watchRegistry {
+	return &watchRegistry{
+		watchers: make(map[watchedKey]map[*Client]struct{}),
+		watched:  make(map[*Client][]watchedKey),
+		dirty:    make(map[*Client]bool),
+	}
+}
+
+// empty reports whether any key is watched.
+func (r *watchRegistry) empty() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.watchers) == 0
+}
+
+func (r *watchRegistry) watch(c *Client, db int, keys []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range keys {
+		key := watchedKey{db: db, key: k}
+		clients, ok := r.watchers[key]
+		if !ok {
+			clients = make(map[*Client]struct{})
+			r.watchers[key] = clients
+		}
+
+		if _, ok := clients[c]; ok {
+			continue
+		}
+		clients[c] = struct{}{}
+		r.watched[c] = append(r.watched[c], key)
+	}
+}
+
+// unwatch forgets the keys watched by c and whether any was modified.
+func (r *watchRegistry) unwatch(c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.watched[c] {
+		clients := r.watchers[key]
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(r.watchers, key)
+		}
+	}
+	delete(r.watched, c)
+	delete(r.dirty, c)
+}
+
+// touch marks the clients watching any of keys of database db as dirty.
+func (r *watchRegistry) touch(db int, keys []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		for c := range r.watchers[watchedKey{db: db, key: key}] {
+			r.dirty[c] = true
+		}
+	}
+}
+
+// touchAll marks every client watching a key of database db as dirty.
+func (r *watchRegistry) touchAll(db int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, clients := range r.watchers {
+		if key.db != db {
+			continue
+		}
+		for c := range clients {
+			r.dirty[c] = true
+		}
+	}
+}
+
+func (r *watchRegistry) isDirty(c *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.dirty[c]
+}
+
+// touchWritten marks the keys of a write command run against the database
+// at index as modified for the clients watching them. Commands that fail are
+// assumed to leave their keys untouched. Commands spanning databases mark
+// the keys they modify themselves.
+func (ce *compositeExecutor) touchWritten(index int, name string, args [][]byte, reply resp.Message) {
+	spec, ok := commandTable[name]
+	if !ok || !spec.write || ce.watches.empty() {
+		return
+	}
+
+	if _, failed := reply.(*resp.Error); failed {
+		return
+	}
+
+	if spec.flush {
+		ce.watches.touchAll(index)
+		return
+	}
+
+	ce.watches.touch(index, spec.keys(args))
+}
+
+// queue adds a command to the client's open transaction. Commands that are
+// unknown or have the wrong number of arguments fail the transaction.
+func (ce *compositeExecutor) queue(c *Client, name string, command Command) resp.Message {
+	spec, ok := commandTable[name]
+	switch {
+	case !ok:
+		c.multiFailed = true
+		return &resp.Error{Value: "unknown command"}
+	case noMultiCommands[name]:
+		c.multiFailed = true
+		return errNotInMulti
+	case !spec.checkArity(command.Args):
+		c.multiFailed = true
+		return wrongNumberOfArgs(command.Name)
+	case ce.outOfMemory(name):
+		c.multiFailed = true
+		return errOOM
+	}
+
+	command.Name = name
+	c.queued = append(c.queued, command)
+
+	return queuedReply
+}
+
+func (c *Client) resetMulti() {
+	c.multi = false
+	c.multiFailed = false
+	c.queued = nil
+}
+
+func (ce *compositeExecutor) executeMulti(c *Client, args [][]byte) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(MULTI)
+	}
+	if c == nil {
+		return errNoClient
+	}
+	if c.multi {
+		return errMultiNested
+	}
+
+	c.multi = true
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func (ce *compositeExecutor) executeDiscard(c *Client, args [][]byte) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(DISCARD)
+	}
+	if c == nil || !c.multi {
+		return errDiscardNoMulti
+	}
+
+	c.resetMulti()
+	ce.watches.unwatch(c)
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func (ce *compositeExecutor) executeWatch(c *Client, args [][]byte) resp.Message {
+	if len(args) == 0 {
+		return wrongNumberOfArgs(WATCH)
+	}
+	if c == nil {
+		return errNoClient
+	}
+	if c.multi {
+		return errWatchInMulti
+	}
+
+	c.mu.Lock()
+	c.onClose("watch", func() { ce.watches.unwatch(c) })
+	c.mu.Unlock()
+
+	keys := make([]string, 0, len(args))
+	for _, arg := range args {
+		keys = append(keys, string(arg))
+	}
+	ce.watches.watch(c, c.db, keys)
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+func (ce *compositeExecutor) executeUnwatch(c *Client, args [][]byte) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(UNWATCH)
+	}
+
+	if c != nil {
+		ce.watches.unwatch(c)
+	}
+
+	return &resp.SimpleString{Value: "OK"}
+}
+
+// executeExec runs the queued commands atomically, unless the transaction
+// failed to queue a command or a watched key was modified since it was
+// watched. Either way the transaction is over and every key is unwatched.
+func (ce *compositeExecutor) executeExec(c *Client, args [][]byte) resp.Message {
+	if len(args) != 0 {
+		return wrongNumberOfArgs(EXEC)
+	}
+	if c == nil || !c.multi {
+		return errExecWithoutMulti
+	}
+
+	queued, failed := c.queued, c.multiFailed
+	c.resetMulti()
+	defer ce.watches.unwatch(c)
+
+	if failed {
+		return errExecAbort
+	}
+
+	var reply resp.Message
+	ce.exclusively(func() {
+		// watched keys are only modified by commands holding dbsMu, so
+		// none can be modified between this check and the commands running
+		if ce.watches.isDirty(c) {
+			reply = &resp.Array{}
+			return
+		}
+
+		replies := make([]resp.Message, 0, len(queued))
+		for _, command := range queued {
+			ce.feedMonitors(c, c.selected(), command.Name, command.Args)
+
+			start := time.Now()
+			msg := ce.executeQueued(c, command)
+			ce.stats.record(command.Name, time.Since(start), msg)
+
+			replies = append(replies, msg)
+		}
+		reply = &resp.Array{Value: replies}
+	})
+
+	return reply
+}
+
+// executeQueued runs a command of a transaction with every other command
+// excluded. Blocking commands don't wait inside transactions: they reply as
+// if they timed out straight away. Commands run against the database
+// selected when they run, which SELECT may change along the way.
+func (ce *compositeExecutor) executeQueued(c *Client, command Command) resp.Message {
+	name := command.Name
+
+	if fn, ok := ce.clientLookup[name]; ok {
+		return fn(c, command.Args)
+	}
+	if fn, ok := ce.exclusiveLookup[name]; ok {
+		return fn(c, command.Args)
+	}
+
+	index := c.selected()
+	var reply resp.Message
+	ce.atomically(index, func(tx storage.Storage) {
+		if fn, ok := ce.executorLookup[name]; ok {
+			reply = fn(command.Args, tx)
+		} else {
+			var spec *blockSpec
+			if reply, spec = ce.blockingLookup[name](command.Args, tx); spec != nil {
+				reply = spec.timeoutReply
+			}
+		}
+
+		ce.touchWritten(index, name, command.Args, reply)
+	})
+
+	return reply
+}
@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ok     = &resp.SimpleString{Value: "OK"}
+	queued = &resp.SimpleString{Value: "QUEUED"}
+)
+
+func TestMultiExec(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	assert.Equal(t, ok, executeAs(e, c, "MULTI"))
+	assert.Equal(t, queued, executeAs(e, c, "SET a 1"))
+	assert.Equal(t, queued, executeAs(e, c, "incr a"))
+	assert.Equal(t, queued, executeAs(e, c, "LPUSH a x"))
+	assert.Equal(t, queued, executeAs(e, c, "GET a"))
+
+	// nothing runs before EXEC
+	assert.Equal(t, &resp.BulkString{}, execute(e, "GET a"))
+
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		ok,
+		&resp.Int{Value: 2},
+		errWrongType,
+		&resp.BulkString{Value: []byte("2")},
+	}}, executeAs(e, c, "EXEC"))
+
+	assert.Equal(t, errExecWithoutMulti, executeAs(e, c, "EXEC"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("2")}, executeAs(e, c, "GET a"))
+}
+
+func TestMultiExecEmpty(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, errMultiNested, executeAs(e, c, "MULTI"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{}}, executeAs(e, c, "EXEC"))
+}
+
+func TestExecAbort(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, queued, executeAs(e, c, "SET a 1"))
+	assert.Equal(t, wrongNumberOfArgs("get"), executeAs(e, c, "get"))
+	assert.Equal(t, &resp.Error{Value: "unknown command"}, executeAs(e, c, "NOSUCHCOMMAND"))
+	assert.Equal(t, errNotInMulti, executeAs(e, c, "SUBSCRIBE a"))
+	assert.Equal(t, errExecAbort, executeAs(e, c, "EXEC"))
+
+	assert.Equal(t, &resp.BulkString{}, execute(e, "GET a"))
+	assert.Equal(t, ok, executeAs(e, c, "SET a 1"))
+}
+
+func TestDiscard(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	assert.Equal(t, errDiscardNoMulti, executeAs(e, c, "DISCARD"))
+
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "SET a 1")
+	assert.Equal(t, ok, executeAs(e, c, "DISCARD"))
+	assert.Equal(t, &resp.BulkString{}, executeAs(e, c, "GET a"))
+}
+
+func TestWatch(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "SET a 1")
+
+	// untouched watched keys don't abort the transaction
+	assert.Equal(t, ok, executeAs(e, c, "WATCH a b"))
+	execute(e, "GET a")
+	execute(e, "SET c 1")
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "INCR a")
+	assert.Equal(t, ints(2), executeAs(e, c, "EXEC"))
+
+	// a modified key does
+	executeAs(e, c, "WATCH a")
+	execute(e, "APPEND a 0")
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "INCR a")
+	assert.Equal(t, &resp.Array{}, executeAs(e, c, "EXEC"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("20")}, execute(e, "GET a"))
+
+	// EXEC unwatches every key
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "INCR a")
+	execute(e, "SET a 1")
+	assert.Equal(t, ints(2), executeAs(e, c, "EXEC"))
+}
+
+func TestWatchModifiedByTransaction(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	a, _ := newTestClient(1)
+	b, _ := newTestClient(2)
+
+	executeAs(e, a, "WATCH k")
+	executeAs(e, b, "MULTI")
+	executeAs(e, b, "SADD k x")
+	executeAs(e, b, "EXEC")
+
+	executeAs(e, a, "MULTI")
+	executeAs(e, a, "SADD k y")
+	assert.Equal(t, &resp.Array{}, executeAs(e, a, "EXEC"))
+}
+
+func TestWatchModifiedByBlockedClient(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reply := executeAsync(t, e, ctx, "BLMOVE src dst LEFT RIGHT 0")
+
+	executeAs(e, c, "WATCH dst")
+	execute(e, "RPUSH src x")
+	assert.Equal(t, &resp.BulkString{Value: []byte("x")}, receive(t, reply))
+
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "LLEN dst")
+	assert.Equal(t, &resp.Array{}, executeAs(e, c, "EXEC"))
+}
+
+func TestWatchFieldExpiry(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage(), inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	// fields deleted by the expire cycle touch the key, in whichever
+	// database it was swapped to
+	execute(e, "HSET h a 1 b 2")
+	execute(e, "HPEXPIRE h 1 FIELDS 1 a")
+	execute(e, "SWAPDB 0 1")
+	executeAs(e, c, "SELECT 1")
+	executeAs(e, c, "WATCH h")
+	time.Sleep(5 * time.Millisecond)
+	e.deleteExpired(time.Now())
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "HLEN h")
+	assert.Equal(t, &resp.Array{}, executeAs(e, c, "EXEC"))
+
+	// and so do fields deleted by another client reading them
+	executeAs(e, c, "SELECT 0")
+	execute(e, "HSET g a 1")
+	execute(e, "HPEXPIRE g 1 FIELDS 1 a")
+	executeAs(e, c, "WATCH g")
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS g"))
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "EXISTS g")
+	assert.Equal(t, &resp.Array{}, executeAs(e, c, "EXEC"))
+}
+
+func TestWatchIgnoresFailedWrites(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "SET a x")
+	executeAs(e, c, "WATCH a")
+	execute(e, "INCR a")
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "GET a")
+	assert.Equal(t, bulks("x"), executeAs(e, c, "EXEC"))
+}
+
+func TestWatchErrors(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	assert.Equal(t, wrongNumberOfArgs(WATCH), executeAs(e, c, "WATCH"))
+	assert.Equal(t, errNoClient, execute(e, "WATCH a"))
+	assert.Equal(t, errNoClient, execute(e, "MULTI"))
+	assert.Equal(t, ok, execute(e, "UNWATCH"))
+
+	executeAs(e, c, "MULTI")
+	assert.Equal(t, errWatchInMulti, executeAs(e, c, "WATCH a"))
+	assert.Equal(t, ok, executeAs(e, c, "DISCARD"))
+}
+
+func TestBlockingCommandsInMulti(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	execute(e, "RPUSH a x")
+	executeAs(e, c, "MULTI")
+	executeAs(e, c, "BLPOP a 0")
+	executeAs(e, c, "BLPOP a 0")
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		bulks("a", "x"),
+		&resp.Array{},
+	}}, executeAs(e, c, "EXEC"))
+}
+
+func TestClientCloseUnwatches(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	c, _ := newTestClient(1)
+
+	executeAs(e, c, "WATCH a b")
+	c.Close()
+	assert.True(t, e.watches.empty())
+}
@@ -0,0 +1,1070 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/set"
+	"github.com/scnewma/godb/storage/zset"
+)
+
+const (
+	ZADD        = "ZADD"
+	ZREM        = "ZREM"
+	ZSCORE      = "ZSCORE"
+	ZMSCORE     = "ZMSCORE"
+	ZINCRBY     = "ZINCRBY"
+	ZCARD       = "ZCARD"
+	ZCOUNT      = "ZCOUNT"
+	ZRANK       = "ZRANK"
+	ZREVRANK    = "ZREVRANK"
+	ZRANGE      = "ZRANGE"
+	ZRANGESTORE = "ZRANGESTORE"
+	ZPOPMIN     = "ZPOPMIN"
+	ZPOPMAX     = "ZPOPMAX"
+	ZUNIONSTORE = "ZUNIONSTORE"
+	ZINTERSTORE = "ZINTERSTORE"
+	ZDIFFSTORE  = "ZDIFFSTORE"
+	ZSCAN       = "ZSCAN"
+)
+
+var (
+	errMinMaxNotFloat  = &resp.Error{Value: "ERR min or max is not a float"}
+	errMinMaxNotString = &resp.Error{Value: "ERR min or max not valid string range item"}
+	errZAddXXAndNX     = &resp.Error{Value: "ERR XX and NX options at the same time are not compatible"}
+	errZAddGTLTAndNX   = &resp.Error{Value: "ERR GT, LT, and/or NX options at the same time are not compatible"}
+	errZAddIncrPair    = &resp.Error{Value: "ERR INCR option supports a single increment-element pair"}
+	errScoreNaN        = &resp.Error{Value: "ERR resulting score is not a number (NaN)"}
+	errWeightNotFloat  = &resp.Error{Value: "ERR weight value is not a float"}
+	errLimitByRank     = &resp.Error{Value: "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"}
+	errWithScoresByLex = &resp.Error{Value: "ERR syntax error, WITHSCORES not supported in combination with BYLEX"}
+)
+
+// getZSet looks up the sorted set stored at key. The sorted set is nil if
+// the key does not exist.
+func getZSet(db storage.Storage, key string) (*zset.ZSet, resp.Message) {
+	node, err := db.Get(key)
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, nil
+		}
+
+		return nil, genericErrorMessage
+	}
+
+	z, ok := node.Value().(*zset.ZSet)
+	if !ok {
+		return nil, errWrongType
+	}
+
+	return z, nil
+}
+
+// formatScore formats a score the way Redis replies with it: the shortest
+// representation that parses back to the same value, switching to
+// scientific notation for very large and very small magnitudes.
+func formatScore(score float64) []byte {
+	switch {
+	case math.IsInf(score, 1):
+		return []byte("inf")
+	case math.IsInf(score, -1):
+		return []byte("-inf")
+	}
+
+	return formatFloat(score)
+}
+
+// parseScoreRange parses the bounds of a score range. A bound prefixed with
+// "(" is exclusive.
+func parseScoreRange(rawMin, rawMax []byte) (zset.ScoreRange, bool) {
+	var (
+		r       zset.ScoreRange
+		okMin   bool
+		okMax   bool
+		exclMin bool
+		exclMax bool
+	)
+
+	r.Min, exclMin, okMin = parseScoreBound(rawMin)
+	r.Max, exclMax, okMax = parseScoreBound(rawMax)
+	r.MinExclusive, r.MaxExclusive = exclMin, exclMax
+
+	return r, okMin && okMax
+}
+
+func parseScoreBound(b []byte) (score float64, exclusive bool, ok bool) {
+	if len(b) > 0 && b[0] == '(' {
+		exclusive = true
+		b = b[1:]
+	}
+
+	score, ok = parseFloat(b)
+	return score, exclusive, ok
+}
+
+// parseLexRange parses the bounds of a lexicographical range. A bound is
+// "-" or "+" for an infinite bound, or a value prefixed with "[" when it is
+// inclusive or "(" when it is exclusive.
+func parseLexRange(rawMin, rawMax []byte) (zset.LexRange, bool) {
+	min, okMin := parseLexBound(rawMin)
+	max, okMax := parseLexBound(rawMax)
+
+	return zset.LexRange{Min: min, Max: max}, okMin && okMax
+}
+
+func parseLexBound(b []byte) (zset.LexBound, bool) {
+	if len(b) == 0 {
+		return zset.LexBound{}, false
+	}
+
+	switch b[0] {
+	case '-':
+		if len(b) != 1 {
+			return zset.LexBound{}, false
+		}
+		return zset.LexBound{Infinite: -1}, true
+	case '+':
+		if len(b) != 1 {
+			return zset.LexBound{}, false
+		}
+		return zset.LexBound{Infinite: 1}, true
+	case '[':
+		return zset.LexBound{Value: string(b[1:])}, true
+	case '(':
+		return zset.LexBound{Value: string(b[1:]), Exclusive: true}, true
+	default:
+		return zset.LexBound{}, false
+	}
+}
+
+// zaddFlags are the options of ZADD that restrict or change how a score is
+// applied.
+type zaddFlags struct {
+	nx, xx, gt, lt, incr bool
+}
+
+type zaddResult int
+
+const (
+	// zaddSkipped means the flags prevented the update.
+	zaddSkipped zaddResult = iota
+	zaddAdded
+	zaddUpdated
+	zaddUnchanged
+)
+
+// zaddMember applies score to member according to flags and returns the
+// member's resulting score.
+func zaddMember(z *zset.ZSet, member string, score float64, flags zaddFlags) (zaddResult, float64, resp.Message) {
+	current, exists := z.Score(member)
+	if !exists {
+		if flags.xx {
+			return zaddSkipped, 0, nil
+		}
+
+		z.Add(member, score)
+		return zaddAdded, score, nil
+	}
+
+	if flags.nx {
+		return zaddSkipped, current, nil
+	}
+
+	if flags.incr {
+		score += current
+		if math.IsNaN(score) {
+			return zaddSkipped, 0, errScoreNaN
+		}
+	}
+
+	if (flags.gt && score <= current) || (flags.lt && score >= current) {
+		return zaddSkipped, current, nil
+	}
+
+	if score == current {
+		return zaddUnchanged, current, nil
+	}
+
+	z.Add(member, score)
+	return zaddUpdated, score, nil
+}
+
+func executeZAdd(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	var (
+		flags zaddFlags
+		ch    bool
+		i     = 1
+	)
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			flags.nx = true
+		case "XX":
+			flags.xx = true
+		case "GT":
+			flags.gt = true
+		case "LT":
+			flags.lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			flags.incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errSyntax
+	}
+
+	if flags.nx && flags.xx {
+		return errZAddXXAndNX
+	}
+
+	if (flags.gt && flags.nx) || (flags.lt && flags.nx) || (flags.gt && flags.lt) {
+		return errZAddGTLTAndNX
+	}
+
+	if flags.incr && len(pairs) > 2 {
+		return errZAddIncrPair
+	}
+
+	// every score is parsed before anything is added so that a bad score
+	// leaves the sorted set untouched
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseFloat(pairs[j])
+		if !ok {
+			return errNotFloat
+		}
+		scores = append(scores, score)
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		if flags.xx {
+			if flags.incr {
+				return &resp.BulkString{}
+			}
+			return &resp.Int{Value: 0}
+		}
+
+		z = zset.New()
+		db.Set(key, storage.NewZSetNode(z))
+	}
+
+	var added, updated int64
+	for j, score := range scores {
+		result, newScore, errMsg := zaddMember(z, string(pairs[j*2+1]), score, flags)
+		if errMsg != nil {
+			return errMsg
+		}
+
+		if flags.incr {
+			if result == zaddSkipped {
+				return &resp.BulkString{}
+			}
+			return &resp.BulkString{Value: formatScore(newScore)}
+		}
+
+		switch result {
+		case zaddAdded:
+			added++
+		case zaddUpdated:
+			updated++
+		}
+	}
+
+	if ch {
+		return &resp.Int{Value: added + updated}
+	}
+
+	return &resp.Int{Value: added}
+}
+
+func executeZIncrBy(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawIncr := ae.ExtractAt(1)
+	member := ae.ExtractStringAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	incr, ok := parseFloat(rawIncr)
+	if !ok {
+		return errNotFloat
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		z = zset.New()
+		db.Set(key, storage.NewZSetNode(z))
+	}
+
+	_, score, errMsg := zaddMember(z, member, incr, zaddFlags{incr: true})
+	if errMsg != nil {
+		return errMsg
+	}
+
+	return &resp.BulkString{Value: formatScore(score)}
+}
+
+func executeZRem(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	members := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	var removed int64
+	for _, member := range members {
+		if z.Remove(member) {
+			removed++
+		}
+	}
+
+	if z.Len() == 0 {
+		db.Del(key)
+	}
+
+	return &resp.Int{Value: removed}
+}
+
+func executeZScore(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	member := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return &resp.BulkString{}
+	}
+
+	score, ok := z.Score(member)
+	if !ok {
+		return &resp.BulkString{}
+	}
+
+	return &resp.BulkString{Value: formatScore(score)}
+}
+
+func executeZMScore(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	members := ae.ExtractStringsFrom(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := make([]resp.Message, 0, len(members))
+	for _, member := range members {
+		if z == nil {
+			vals = append(vals, &resp.BulkString{})
+			continue
+		}
+
+		score, ok := z.Score(member)
+		if !ok {
+			vals = append(vals, &resp.BulkString{})
+			continue
+		}
+
+		vals = append(vals, &resp.BulkString{Value: formatScore(score)})
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+func executeZCard(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(z.Len())}
+}
+
+func executeZCount(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawMin := ae.ExtractAt(1)
+	rawMax := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	r, ok := parseScoreRange(rawMin, rawMax)
+	if !ok {
+		return errMinMaxNotFloat
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return &resp.Int{Value: 0}
+	}
+
+	return &resp.Int{Value: int64(z.CountByScore(r))}
+}
+
+func executeZRank(args [][]byte, db storage.Storage) resp.Message {
+	return zrank(args, db, false)
+}
+
+func executeZRevRank(args [][]byte, db storage.Storage) resp.Message {
+	return zrank(args, db, true)
+}
+
+// zrank replies with the rank of a member, along with its score if
+// WITHSCORE is given.
+func zrank(args [][]byte, db storage.Storage, reverse bool) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	member := ae.ExtractStringAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 3 || (len(args) == 3 && strings.ToUpper(string(args[2])) != "WITHSCORE") {
+		return errSyntax
+	}
+	withScore := len(args) == 3
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	var (
+		rank int
+		ok   bool
+	)
+	if z != nil {
+		rank, ok = z.Rank(member, reverse)
+	}
+
+	if !ok {
+		if withScore {
+			return &resp.Array{}
+		}
+		return &resp.BulkString{}
+	}
+
+	if !withScore {
+		return &resp.Int{Value: int64(rank)}
+	}
+
+	score, _ := z.Score(member)
+	return &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: int64(rank)},
+		&resp.BulkString{Value: formatScore(score)},
+	}}
+}
+
+// zrangeBy is what the bounds of a ZRANGE select by.
+type zrangeBy int
+
+const (
+	zrangeByRank zrangeBy = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeSpec is a parsed ZRANGE or ZRANGESTORE request.
+type zrangeSpec struct {
+	by         zrangeBy
+	reverse    bool
+	withScores bool
+
+	// offset and count are given by LIMIT. A negative count returns every
+	// remaining member.
+	offset, count int64
+
+	start, stop int64
+	scores      zset.ScoreRange
+	lex         zset.LexRange
+}
+
+// parseZRange parses the bounds and options of ZRANGE. WITHSCORES is only
+// accepted if allowWithScores is set.
+func parseZRange(rawStart, rawStop []byte, opts [][]byte, allowWithScores bool) (*zrangeSpec, resp.Message) {
+	spec := &zrangeSpec{count: -1}
+
+	var hasLimit bool
+	for i := 0; i < len(opts); i++ {
+		switch opt := strings.ToUpper(string(opts[i])); {
+		case opt == "BYSCORE":
+			spec.by = zrangeByScore
+		case opt == "BYLEX":
+			spec.by = zrangeByLex
+		case opt == "REV":
+			spec.reverse = true
+		case opt == "WITHSCORES" && allowWithScores:
+			spec.withScores = true
+		case opt == "LIMIT" && i+2 < len(opts):
+			var ok bool
+			spec.offset, ok = parseInt(opts[i+1])
+			if !ok {
+				return nil, errNotInteger
+			}
+			spec.count, ok = parseInt(opts[i+2])
+			if !ok {
+				return nil, errNotInteger
+			}
+			hasLimit = true
+			i += 2
+		default:
+			return nil, errSyntax
+		}
+	}
+
+	if hasLimit && spec.by == zrangeByRank {
+		return nil, errLimitByRank
+	}
+
+	if spec.withScores && spec.by == zrangeByLex {
+		return nil, errWithScoresByLex
+	}
+
+	// reversed score and lex ranges are given from max to min
+	if spec.reverse && spec.by != zrangeByRank {
+		rawStart, rawStop = rawStop, rawStart
+	}
+
+	switch spec.by {
+	case zrangeByScore:
+		var ok bool
+		spec.scores, ok = parseScoreRange(rawStart, rawStop)
+		if !ok {
+			return nil, errMinMaxNotFloat
+		}
+	case zrangeByLex:
+		var ok bool
+		spec.lex, ok = parseLexRange(rawStart, rawStop)
+		if !ok {
+			return nil, errMinMaxNotString
+		}
+	default:
+		var ok bool
+		spec.start, ok = parseInt(rawStart)
+		if !ok {
+			return nil, errNotInteger
+		}
+		spec.stop, ok = parseInt(rawStop)
+		if !ok {
+			return nil, errNotInteger
+		}
+	}
+
+	return spec, nil
+}
+
+// run calls fn for each member of z selected by the spec, in order.
+func (spec *zrangeSpec) run(z *zset.ZSet, fn func(member string, score float64)) {
+	if spec.by == zrangeByRank {
+		start, stop, ok := listRange(spec.start, spec.stop, z.Len())
+		if !ok {
+			return
+		}
+
+		z.RangeByRank(start, stop, spec.reverse, func(member string, score float64) bool {
+			fn(member, score)
+			return true
+		})
+		return
+	}
+
+	offset, count := spec.offset, spec.count
+	if offset < 0 || count == 0 {
+		return
+	}
+
+	limited := func(member string, score float64) bool {
+		if offset > 0 {
+			offset--
+			return true
+		}
+
+		fn(member, score)
+		if count > 0 {
+			count--
+		}
+		return count != 0
+	}
+
+	if spec.by == zrangeByScore {
+		z.RangeByScore(spec.scores, spec.reverse, limited)
+	} else {
+		z.RangeByLex(spec.lex, spec.reverse, limited)
+	}
+}
+
+func executeZRange(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	rawStart := ae.ExtractAt(1)
+	rawStop := ae.ExtractAt(2)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	spec, errMsg := parseZRange(rawStart, rawStop, args[3:], true)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := []resp.Message{}
+	if z == nil {
+		return &resp.Array{Value: vals}
+	}
+
+	spec.run(z, func(member string, score float64) {
+		vals = append(vals, &resp.BulkString{Value: []byte(member)})
+		if spec.withScores {
+			vals = append(vals, &resp.BulkString{Value: formatScore(score)})
+		}
+	})
+
+	return &resp.Array{Value: vals}
+}
+
+// executeZRangeStore stores the members selected like ZRANGE at the
+// destination key, replacing whatever it held.
+func executeZRangeStore(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	dest := ae.ExtractStringAt(0)
+	src := ae.ExtractStringAt(1)
+	rawStart := ae.ExtractAt(2)
+	rawStop := ae.ExtractAt(3)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	spec, errMsg := parseZRange(rawStart, rawStop, args[4:], false)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	z, errMsg := getZSet(db, src)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	result := zset.New()
+	if z != nil {
+		spec.run(z, func(member string, score float64) {
+			result.Add(member, score)
+		})
+	}
+
+	return storeZSet(db, dest, result)
+}
+
+// storeZSet stores z at key, deleting the key instead if z is empty, and
+// replies with the size of z.
+func storeZSet(db storage.Storage, key string, z *zset.ZSet) resp.Message {
+	if z.Len() == 0 {
+		db.Del(key)
+	} else {
+		db.Set(key, storage.NewZSetNode(z))
+	}
+
+	return &resp.Int{Value: int64(z.Len())}
+}
+
+func executeZPopMin(args [][]byte, db storage.Storage) resp.Message {
+	return zpop(args, db, false)
+}
+
+func executeZPopMax(args [][]byte, db storage.Storage) resp.Message {
+	return zpop(args, db, true)
+}
+
+// zpop removes the members with the lowest scores, or the highest if max is
+// set, and replies with them and their scores.
+func zpop(args [][]byte, db storage.Storage, max bool) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	if len(args) > 2 {
+		return errSyntax
+	}
+
+	count := int64(1)
+	if len(args) == 2 {
+		var ok bool
+		count, ok = parseInt(args[1])
+		if !ok || count < 0 {
+			return errNotPositive
+		}
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	vals := []resp.Message{}
+	if z == nil {
+		return &resp.Array{Value: vals}
+	}
+
+	for ; count > 0; count-- {
+		member, score, ok := z.Pop(max)
+		if !ok {
+			break
+		}
+		vals = append(vals,
+			&resp.BulkString{Value: []byte(member)},
+			&resp.BulkString{Value: formatScore(score)},
+		)
+	}
+
+	if z.Len() == 0 {
+		db.Del(key)
+	}
+
+	return &resp.Array{Value: vals}
+}
+
+// zsetInput is a source of ZUNIONSTORE, ZINTERSTORE or ZDIFFSTORE. Sets can
+// be used as inputs, with every member scoring 1.
+type zsetInput struct {
+	z      *zset.ZSet
+	s      *set.Set
+	weight float64
+}
+
+func (in *zsetInput) len() int {
+	switch {
+	case in.z != nil:
+		return in.z.Len()
+	case in.s != nil:
+		return in.s.Len()
+	default:
+		return 0
+	}
+}
+
+func (in *zsetInput) score(member string) (float64, bool) {
+	switch {
+	case in.z != nil:
+		return in.z.Score(member)
+	case in.s != nil:
+		return 1, in.s.Contains(member)
+	default:
+		return 0, false
+	}
+}
+
+func (in *zsetInput) each(fn func(member string, score float64)) {
+	switch {
+	case in.z != nil:
+		in.z.Range(func(member string, score float64) bool {
+			fn(member, score)
+			return true
+		})
+	case in.s != nil:
+		in.s.Range(func(member string) bool {
+			fn(member, 1)
+			return true
+		})
+	}
+}
+
+// weighted returns score multiplied by the input's weight. Multiplying an
+// infinite score by a zero weight gives 0 rather than NaN.
+func (in *zsetInput) weighted(score float64) float64 {
+	score *= in.weight
+	if math.IsNaN(score) {
+		return 0
+	}
+
+	return score
+}
+
+// zsetAggregate combines the scores a member has in several inputs.
+type zsetAggregate func(a, b float64) float64
+
+func aggregateSum(a, b float64) float64 {
+	// inf + -inf is NaN, which Redis turns into 0
+	if sum := a + b; !math.IsNaN(sum) {
+		return sum
+	}
+
+	return 0
+}
+
+func aggregateMin(a, b float64) float64 {
+	return math.Min(a, b)
+}
+
+func aggregateMax(a, b float64) float64 {
+	return math.Max(a, b)
+}
+
+// zsetOperation computes a sorted set from inputs.
+type zsetOperation func(inputs []*zsetInput, aggregate zsetAggregate) *zset.ZSet
+
+func zsetUnion(inputs []*zsetInput, aggregate zsetAggregate) *zset.ZSet {
+	scores := make(map[string]float64)
+	for _, in := range inputs {
+		in.each(func(member string, score float64) {
+			score = in.weighted(score)
+			if current, ok := scores[member]; ok {
+				score = aggregate(current, score)
+			}
+			scores[member] = score
+		})
+	}
+
+	result := zset.New()
+	for member, score := range scores {
+		result.Add(member, score)
+	}
+
+	return result
+}
+
+func zsetInter(inputs []*zsetInput, aggregate zsetAggregate) *zset.ZSet {
+	result := zset.New()
+
+	// check the members of the smallest input against the others
+	smallest := 0
+	for i, in := range inputs {
+		if in.len() < inputs[smallest].len() {
+			smallest = i
+		}
+	}
+
+	inputs[smallest].each(func(member string, score float64) {
+		score = inputs[smallest].weighted(score)
+		for i, in := range inputs {
+			if i == smallest {
+				continue
+			}
+
+			other, ok := in.score(member)
+			if !ok {
+				return
+			}
+			score = aggregate(score, in.weighted(other))
+		}
+		result.Add(member, score)
+	})
+
+	return result
+}
+
+func zsetDiff(inputs []*zsetInput, _ zsetAggregate) *zset.ZSet {
+	result := zset.New()
+
+	inputs[0].each(func(member string, score float64) {
+		for _, in := range inputs[1:] {
+			if _, ok := in.score(member); ok {
+				return
+			}
+		}
+		result.Add(member, score)
+	})
+
+	return result
+}
+
+func executeZUnionStore(args [][]byte, db storage.Storage) resp.Message {
+	return zsetStore(ZUNIONSTORE, args, db, zsetUnion, true)
+}
+
+func executeZInterStore(args [][]byte, db storage.Storage) resp.Message {
+	return zsetStore(ZINTERSTORE, args, db, zsetInter, true)
+}
+
+func executeZDiffStore(args [][]byte, db storage.Storage) resp.Message {
+	return zsetStore(ZDIFFSTORE, args, db, zsetDiff, false)
+}
+
+// zsetStore parses "destination numkeys key [key ...]", followed by the
+// WEIGHTS and AGGREGATE options if allowed, and stores the result of op at
+// destination.
+func zsetStore(command string, args [][]byte, db storage.Storage, op zsetOperation, weighted bool) resp.Message {
+	ae := newArgExtractor(args)
+	dest := ae.ExtractStringAt(0)
+	rawNumKeys := ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	numKeys, ok := parseInt(rawNumKeys)
+	if !ok {
+		return errNotInteger
+	}
+
+	if numKeys < 1 {
+		return &resp.Error{Value: fmt.Sprintf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(command))}
+	}
+
+	if numKeys > int64(len(args)-2) {
+		return errSyntax
+	}
+
+	inputs := make([]*zsetInput, 0, numKeys)
+	for range args[2 : 2+numKeys] {
+		inputs = append(inputs, &zsetInput{weight: 1})
+	}
+
+	aggregate := zsetAggregate(aggregateSum)
+	opts := args[2+numKeys:]
+	for i := 0; i < len(opts); i++ {
+		switch opt := strings.ToUpper(string(opts[i])); {
+		case weighted && opt == "WEIGHTS" && int64(len(opts)-i-1) >= numKeys:
+			for _, in := range inputs {
+				i++
+				in.weight, ok = parseFloat(opts[i])
+				if !ok {
+					return errWeightNotFloat
+				}
+			}
+		case weighted && opt == "AGGREGATE" && i+1 < len(opts):
+			i++
+			switch strings.ToUpper(string(opts[i])) {
+			case "SUM":
+				aggregate = aggregateSum
+			case "MIN":
+				aggregate = aggregateMin
+			case "MAX":
+				aggregate = aggregateMax
+			default:
+				return errSyntax
+			}
+		default:
+			return errSyntax
+		}
+	}
+
+	for i, key := range args[2 : 2+numKeys] {
+		node, err := db.Get(string(key))
+		if err != nil {
+			if err == storage.ErrKeyNotFound {
+				continue
+			}
+			return genericErrorMessage
+		}
+
+		switch v := node.Value().(type) {
+		case *zset.ZSet:
+			inputs[i].z = v
+		case *set.Set:
+			inputs[i].s = v
+		default:
+			return errWrongType
+		}
+	}
+
+	return storeZSet(db, dest, op(inputs, aggregate))
+}
+
+// executeZScan incrementally iterates over the members of a sorted set and
+// their scores.
+func executeZScan(args [][]byte, db storage.Storage) resp.Message {
+	ae := newArgExtractor(args)
+	key := ae.ExtractStringAt(0)
+	ae.ExtractAt(1)
+	if ae.Err() != nil {
+		return &resp.Error{Value: ae.Error()}
+	}
+
+	opts, errMsg := parseScanOptions(args[1:], nil)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	z, errMsg := getZSet(db, key)
+	if errMsg != nil {
+		return errMsg
+	}
+
+	if z == nil {
+		return scanReply(0, nil)
+	}
+
+	var vals []resp.Message
+	cursor := opts.scan(func(cursor uint64) (uint64, int) {
+		n := 0
+		cursor = z.Scan(cursor, func(member string, score float64) {
+			n++
+			if opts.matches(member) {
+				vals = append(vals,
+					&resp.BulkString{Value: []byte(member)},
+					&resp.BulkString{Value: formatScore(score)},
+				)
+			}
+		})
+		return cursor, n
+	})
+
+	return scanReply(cursor, vals)
+}
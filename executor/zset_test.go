@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZAddAndScore(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "ZADD z 1 a 2 b 3 c"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZADD z 10 a 4 d"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("10")}, execute(e, "ZSCORE z a"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZSCORE z missing"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZSCORE nokey a"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.BulkString{Value: []byte("2")},
+		&resp.BulkString{},
+	}}, execute(e, "ZMSCORE z b missing"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "ZCARD z"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZCARD nokey"))
+}
+
+func TestZAddFlags(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD z 5 a")
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZADD z NX 1 a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("5")}, execute(e, "ZSCORE z a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZADD z XX 1 b"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZSCORE z b"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZADD z XX 1 a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("1")}, execute(e, "ZSCORE z a"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZADD z GT CH 0 a"))
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZADD z GT CH 7 a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZADD z LT CH 9 a"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "ZADD z LT CH 3 a 1 b"))
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("5.5")}, execute(e, "ZADD z INCR 2.5 a"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZADD z NX INCR 1 a"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZADD z GT INCR -1 a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("5.5")}, execute(e, "ZADD z XX INCR 0 a"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZADD nokey XX INCR 1 a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS nokey"))
+
+	assert.Equal(t, errZAddXXAndNX, execute(e, "ZADD z NX XX 1 a"))
+	assert.Equal(t, errZAddGTLTAndNX, execute(e, "ZADD z GT LT 1 a"))
+	assert.Equal(t, errZAddGTLTAndNX, execute(e, "ZADD z NX GT 1 a"))
+	assert.Equal(t, errZAddIncrPair, execute(e, "ZADD z INCR 1 a 2 b"))
+	assert.Equal(t, errSyntax, execute(e, "ZADD z 1 a 2"))
+	assert.Equal(t, errNotFloat, execute(e, "ZADD z 1 x y b"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZSCORE z x"))
+}
+
+func TestZIncrBy(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+
+	assert.Equal(t, &resp.BulkString{Value: []byte("2")}, execute(e, "ZINCRBY z 2 a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("-0.5")}, execute(e, "ZINCRBY z -2.5 a"))
+	assert.Equal(t, &resp.BulkString{Value: []byte("inf")}, execute(e, "ZINCRBY z +inf a"))
+	assert.Equal(t, errScoreNaN, execute(e, "ZINCRBY z -inf a"))
+	assert.Equal(t, errNotFloat, execute(e, "ZINCRBY z x a"))
+}
+
+func TestZRemAndPop(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD z 1 a 2 b 3 c 4 d")
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZREM z b missing"))
+	assert.Equal(t, bulks("a", "1"), execute(e, "ZPOPMIN z"))
+	assert.Equal(t, bulks("d", "4", "c", "3"), execute(e, "ZPOPMAX z 5"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS z"))
+	assert.Equal(t, bulks(), execute(e, "ZPOPMIN z"))
+	assert.Equal(t, errNotPositive, execute(e, "ZPOPMIN z -1"))
+
+	execute(e, "ZADD z 1 a")
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZREM z a"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS z"))
+}
+
+func TestZCountAndRank(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD z 1 a 2 b 3 c 4 d")
+
+	assert.Equal(t, &resp.Int{Value: 3}, execute(e, "ZCOUNT z 2 +inf"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "ZCOUNT z (1 (4"))
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "ZCOUNT z -inf inf"))
+	assert.Equal(t, errMinMaxNotFloat, execute(e, "ZCOUNT z x 1"))
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZRANK z b"))
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "ZREVRANK z b"))
+	assert.Equal(t, &resp.BulkString{}, execute(e, "ZRANK z missing"))
+	assert.Equal(t, &resp.Array{Value: []resp.Message{
+		&resp.Int{Value: 3},
+		&resp.BulkString{Value: []byte("4")},
+	}}, execute(e, "ZRANK z d WITHSCORE"))
+	assert.Equal(t, &resp.Array{}, execute(e, "ZRANK z missing WITHSCORE"))
+}
+
+func TestZRange(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD z 1 a 2 b 3 c 4 d 5 e")
+
+	assert.Equal(t, bulks("a", "b", "c", "d", "e"), execute(e, "ZRANGE z 0 -1"))
+	assert.Equal(t, bulks("d", "4", "e", "5"), execute(e, "ZRANGE z -2 -1 WITHSCORES"))
+	assert.Equal(t, bulks("e", "d"), execute(e, "ZRANGE z 0 1 REV"))
+	assert.Equal(t, bulks(), execute(e, "ZRANGE z 10 20"))
+
+	assert.Equal(t, bulks("b", "c", "d"), execute(e, "ZRANGE z 2 4 BYSCORE"))
+	assert.Equal(t, bulks("c", "d"), execute(e, "ZRANGE z (2 4 BYSCORE"))
+	assert.Equal(t, bulks("d", "c", "b"), execute(e, "ZRANGE z 4 2 BYSCORE REV"))
+	assert.Equal(t, bulks("c", "d"), execute(e, "ZRANGE z -inf +inf BYSCORE LIMIT 2 2"))
+	assert.Equal(t, bulks("c", "d", "e"), execute(e, "ZRANGE z -inf +inf BYSCORE LIMIT 2 -1"))
+	assert.Equal(t, bulks(), execute(e, "ZRANGE z -inf +inf BYSCORE LIMIT 2 0"))
+	assert.Equal(t, bulks("b", "2"), execute(e, "ZRANGE z 2 2 BYSCORE WITHSCORES"))
+
+	assert.Equal(t, errLimitByRank, execute(e, "ZRANGE z 0 -1 LIMIT 0 1"))
+	assert.Equal(t, errWithScoresByLex, execute(e, "ZRANGE z - + BYLEX WITHSCORES"))
+	assert.Equal(t, errMinMaxNotFloat, execute(e, "ZRANGE z a b BYSCORE"))
+	assert.Equal(t, errNotInteger, execute(e, "ZRANGE z a b"))
+	assert.Equal(t, errSyntax, execute(e, "ZRANGE z 0 1 BOGUS"))
+}
+
+func TestZRangeByLex(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD z 0 a 0 b 0 c 0 d 0 e")
+
+	assert.Equal(t, bulks("a", "b", "c"), execute(e, "ZRANGE z - [c BYLEX"))
+	assert.Equal(t, bulks("b", "c"), execute(e, "ZRANGE z (a (d BYLEX"))
+	assert.Equal(t, bulks("e", "d"), execute(e, "ZRANGE z + [d BYLEX REV"))
+	assert.Equal(t, bulks("c"), execute(e, "ZRANGE z - + BYLEX LIMIT 2 1"))
+	assert.Equal(t, bulks(), execute(e, "ZRANGE z + - BYLEX"))
+	assert.Equal(t, errMinMaxNotString, execute(e, "ZRANGE z a [c BYLEX"))
+}
+
+func TestZRangeStore(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD z 1 a 2 b 3 c")
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "ZRANGESTORE dest z 2 +inf BYSCORE"))
+	assert.Equal(t, bulks("b", "2", "c", "3"), execute(e, "ZRANGE dest 0 -1 WITHSCORES"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZRANGESTORE dest z 10 20"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS dest"))
+	assert.Equal(t, errSyntax, execute(e, "ZRANGESTORE dest z 0 -1 WITHSCORES"))
+}
+
+func TestZUnionInterDiffStore(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "ZADD a 1 x 2 y 3 z")
+	execute(e, "ZADD b 10 y 20 z 30 w")
+	execute(e, "SADD s z w")
+
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "ZUNIONSTORE out 2 a b"))
+	assert.Equal(t, bulks("x", "1", "y", "12", "z", "23", "w", "30"), execute(e, "ZRANGE out 0 -1 WITHSCORES"))
+
+	assert.Equal(t, &resp.Int{Value: 4}, execute(e, "ZUNIONSTORE out 2 a b WEIGHTS 2 1 AGGREGATE MAX"))
+	assert.Equal(t, bulks("x", "2", "y", "10", "z", "20", "w", "30"), execute(e, "ZRANGE out 0 -1 WITHSCORES"))
+
+	assert.Equal(t, &resp.Int{Value: 2}, execute(e, "ZINTERSTORE out 2 a b AGGREGATE MIN"))
+	assert.Equal(t, bulks("y", "2", "z", "3"), execute(e, "ZRANGE out 0 -1 WITHSCORES"))
+
+	// sets count as sorted sets with every score being 1
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZINTERSTORE out 3 a b s"))
+	assert.Equal(t, bulks("z", "24"), execute(e, "ZRANGE out 0 -1 WITHSCORES"))
+
+	assert.Equal(t, &resp.Int{Value: 1}, execute(e, "ZDIFFSTORE out 2 a b"))
+	assert.Equal(t, bulks("x", "1"), execute(e, "ZRANGE out 0 -1 WITHSCORES"))
+
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "ZINTERSTORE out 2 a missing"))
+	assert.Equal(t, &resp.Int{Value: 0}, execute(e, "EXISTS out"))
+
+	assert.Equal(t, &resp.Error{Value: "ERR at least 1 input key is needed for 'zunionstore' command"}, execute(e, "ZUNIONSTORE out 0 a"))
+	assert.Equal(t, errSyntax, execute(e, "ZUNIONSTORE out 3 a b"))
+	assert.Equal(t, errSyntax, execute(e, "ZUNIONSTORE out 2 a b WEIGHTS 1"))
+	assert.Equal(t, errWeightNotFloat, execute(e, "ZUNIONSTORE out 2 a b WEIGHTS 1 x"))
+	assert.Equal(t, errSyntax, execute(e, "ZUNIONSTORE out 2 a b AGGREGATE AVG"))
+	assert.Equal(t, errSyntax, execute(e, "ZDIFFSTORE out 2 a b WEIGHTS 1 1"))
+
+	execute(e, "SET str v")
+	assert.Equal(t, errWrongType, execute(e, "ZUNIONSTORE out 2 a str"))
+}
+
+func TestZScan(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	for i := 0; i < 300; i++ {
+		execute(e, fmt.Sprintf("ZADD z %d m%d", i, i))
+	}
+
+	seen := make(map[string]string)
+	cursor := "0"
+	for {
+		reply := execute(e, "ZSCAN z "+cursor).(*resp.Array).Value
+		cursor = string(reply[0].(*resp.BulkString).Value)
+		elements := reply[1].(*resp.Array).Value
+		for i := 0; i < len(elements); i += 2 {
+			seen[string(elements[i].(*resp.BulkString).Value)] = string(elements[i+1].(*resp.BulkString).Value)
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	require.Len(t, seen, 300)
+	assert.Equal(t, "42", seen["m42"])
+}
+
+func TestFormatScore(t *testing.T) {
+	for score, want := range map[float64]string{
+		1:       "1",
+		-2.5:    "-2.5",
+		0.1:     "0.1",
+		1000000: "1000000",
+		1e17:    "1e+17",
+		1e21:    "1e+21",
+		1.5e-7:  "1.5e-07",
+	} {
+		assert.Equal(t, want, string(formatScore(score)))
+	}
+}
+
+func TestZSetWrongType(t *testing.T) {
+	e := NewExecutor(inmem.NewStorage())
+	execute(e, "SET str v")
+	execute(e, "ZADD z 1 a")
+
+	for _, cmd := range []string{"ZADD str 1 a", "ZSCORE str a", "ZRANGE str 0 -1", "ZRANK str a", "ZPOPMIN str", "ZSCAN str 0", "ZRANGESTORE out str 0 -1"} {
+		assert.Equal(t, errWrongType, execute(e, cmd), cmd)
+	}
+	assert.Equal(t, errWrongType, execute(e, "SADD z a"))
+	assert.Equal(t, &resp.SimpleString{Value: "zset"}, execute(e, "TYPE z"))
+}
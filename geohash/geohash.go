@@ -0,0 +1,250 @@
+// Package geohash implements the geohash encoding used by the GEO commands,
+// which store locations as sorted set scores.
+//
+// A location is encoded by interleaving the bits of its longitude and
+// latitude, each scaled to a 26-bit integer, into a 52-bit integer that fits
+// a float64 exactly. Locations close to each other tend to share a prefix,
+// so the locations within an area can be found with a few score ranges. Like
+// Redis, latitudes are limited to the range EPSG:900913 covers.
+package geohash
+
+import (
+	"math"
+)
+
+const (
+	// MaxStep is the number of bits each coordinate is encoded with.
+	MaxStep = 26
+
+	MinLongitude = -180.0
+	MaxLongitude = 180.0
+	MinLatitude  = -85.05112878
+	MaxLatitude  = 85.05112878
+
+	// EarthRadius is the radius in meters used to compute distances, the
+	// same as Redis's.
+	EarthRadius = 6372797.560856
+
+	// mercatorMax is half the circumference of the earth in meters.
+	mercatorMax = 20037726.37
+)
+
+// Hash is a geohash of Step bits per coordinate.
+type Hash struct {
+	Bits uint64
+	Step uint
+}
+
+// Range is an interval of coordinates.
+type Range struct {
+	Min, Max float64
+}
+
+// Area is the rectangle of locations sharing a geohash.
+type Area struct {
+	Hash      Hash
+	Longitude Range
+	Latitude  Range
+}
+
+// Valid reports whether a location can be encoded.
+func Valid(lon, lat float64) bool {
+	return lon >= MinLongitude && lon <= MaxLongitude && lat >= MinLatitude && lat <= MaxLatitude
+}
+
+// Encode returns the geohash of a location with step bits per coordinate,
+// using the given ranges of longitudes and latitudes.
+func Encode(lonRange, latRange Range, lon, lat float64, step uint) (Hash, bool) {
+	if step == 0 || step > 32 ||
+		lon < lonRange.Min || lon > lonRange.Max || lat < latRange.Min || lat > latRange.Max {
+		return Hash{}, false
+	}
+
+	latOffset := (lat - latRange.Min) / (latRange.Max - latRange.Min)
+	lonOffset := (lon - lonRange.Min) / (lonRange.Max - lonRange.Min)
+	latOffset *= float64(uint64(1) << step)
+	lonOffset *= float64(uint64(1) << step)
+
+	return Hash{Bits: interleave(uint32(latOffset), uint32(lonOffset)), Step: step}, true
+}
+
+// EncodeWGS84 returns the geohash of a location with step bits per
+// coordinate.
+func EncodeWGS84(lon, lat float64, step uint) (Hash, bool) {
+	return Encode(Range{MinLongitude, MaxLongitude}, Range{MinLatitude, MaxLatitude}, lon, lat, step)
+}
+
+// Decode returns the area a geohash covers, given the ranges of longitudes
+// and latitudes it was encoded with.
+func Decode(lonRange, latRange Range, hash Hash) Area {
+	ilat, ilon := deinterleave(hash.Bits)
+
+	latScale := latRange.Max - latRange.Min
+	lonScale := lonRange.Max - lonRange.Min
+	cells := float64(uint64(1) << hash.Step)
+
+	return Area{
+		Hash: hash,
+		Latitude: Range{
+			Min: latRange.Min + float64(ilat)/cells*latScale,
+			Max: latRange.Min + float64(ilat+1)/cells*latScale,
+		},
+		Longitude: Range{
+			Min: lonRange.Min + float64(ilon)/cells*lonScale,
+			Max: lonRange.Min + float64(ilon+1)/cells*lonScale,
+		},
+	}
+}
+
+// DecodeWGS84 returns the area a geohash covers.
+func DecodeWGS84(hash Hash) Area {
+	return Decode(Range{MinLongitude, MaxLongitude}, Range{MinLatitude, MaxLatitude}, hash)
+}
+
+// Center returns the location at the center of the area, clamped to the
+// limits of the encoding.
+func (a Area) Center() (lon, lat float64) {
+	lon = (a.Longitude.Min + a.Longitude.Max) / 2
+	lat = (a.Latitude.Min + a.Latitude.Max) / 2
+
+	lon = math.Max(MinLongitude, math.Min(MaxLongitude, lon))
+	lat = math.Max(MinLatitude, math.Min(MaxLatitude, lat))
+
+	return lon, lat
+}
+
+// Score returns the 52-bit geohash of a location as a sorted set score.
+func Score(lon, lat float64) (float64, bool) {
+	hash, ok := EncodeWGS84(lon, lat, MaxStep)
+	if !ok {
+		return 0, false
+	}
+
+	return float64(hash.Bits), true
+}
+
+// Location returns the location encoded in a sorted set score.
+func Location(score float64) (lon, lat float64) {
+	return DecodeWGS84(Hash{Bits: uint64(score), Step: MaxStep}).Center()
+}
+
+// ScoreRange returns the scores of the locations within the area of h, from
+// min included to max excluded.
+func (h Hash) ScoreRange() (min, max float64) {
+	shift := 52 - h.Step*2
+	return float64(h.Bits << shift), float64((h.Bits + 1) << shift)
+}
+
+// Neighbors holds the geohashes of the areas surrounding another one.
+type Neighbors struct {
+	North, South, East, West                   Hash
+	NorthEast, SouthEast, NorthWest, SouthWest Hash
+}
+
+// Neighbors returns the geohashes of the eight areas surrounding the area
+// of h, at the same step.
+func (h Hash) Neighbors() Neighbors {
+	return Neighbors{
+		East:      h.move(1, 0),
+		West:      h.move(-1, 0),
+		South:     h.move(0, -1),
+		North:     h.move(0, 1),
+		SouthWest: h.move(-1, -1),
+		SouthEast: h.move(1, -1),
+		NorthWest: h.move(-1, 1),
+		NorthEast: h.move(1, 1),
+	}
+}
+
+// move returns the geohash of the area dx cells east and dy cells north of
+// h, each being -1, 0 or 1, wrapping around at the edges.
+func (h Hash) move(dx, dy int) Hash {
+	const (
+		oddBits  = 0xaaaaaaaaaaaaaaaa
+		evenBits = 0x5555555555555555
+	)
+
+	// longitudes are in the odd bits and latitudes in the even ones
+	shift := 64 - h.Step*2
+	if dx != 0 {
+		x, y := h.Bits&oddBits, h.Bits&evenBits
+		zz := uint64(evenBits) >> shift
+		if dx > 0 {
+			x += zz + 1
+		} else {
+			x |= zz
+			x -= zz + 1
+		}
+		x &= uint64(oddBits) >> shift
+		h.Bits = x | y
+	}
+
+	if dy != 0 {
+		x, y := h.Bits&oddBits, h.Bits&evenBits
+		zz := uint64(oddBits) >> shift
+		if dy > 0 {
+			y += zz + 1
+		} else {
+			y |= zz
+			y -= zz + 1
+		}
+		y &= uint64(evenBits) >> shift
+		h.Bits = x | y
+	}
+
+	return h
+}
+
+// interleave interleaves the bits of x and y, with those of x in the even
+// positions and those of y in the odd ones.
+func interleave(x, y uint32) uint64 {
+	return spread(x) | spread(y)<<1
+}
+
+// deinterleave reverses interleave.
+func deinterleave(bits uint64) (x, y uint32) {
+	return squash(bits), squash(bits >> 1)
+}
+
+// spread moves the bits of v to the even positions of the result.
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// squash reverses spread, ignoring the odd bits.
+func squash(x uint64) uint32 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return uint32(x)
+}
+
+// String returns the standard base32 geohash of a location, 11 characters
+// long. Unlike scores it uses the full range of latitudes.
+func String(lon, lat float64) string {
+	const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	hash, _ := Encode(Range{-180, 180}, Range{-90, 90}, lon, lat, MaxStep)
+
+	b := make([]byte, 11)
+	for i := range b {
+		// the 52 bits fill ten characters and two bits of the last one,
+		// which Redis leaves as zero
+		var idx uint64
+		if i < 10 {
+			idx = (hash.Bits >> (52 - uint(i+1)*5)) & 0x1f
+		}
+		b[i] = alphabet[idx]
+	}
+
+	return string(b)
+}
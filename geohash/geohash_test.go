@@ -0,0 +1,123 @@
+package geohash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the examples from the Redis documentation
+const (
+	palermoLon, palermoLat = 13.361389, 38.115556
+	cataniaLon, cataniaLat = 15.087269, 37.502669
+)
+
+func TestScore(t *testing.T) {
+	score, ok := Score(palermoLon, palermoLat)
+	assert.True(t, ok)
+	assert.Equal(t, 3479099956230698.0, score)
+
+	score, _ = Score(cataniaLon, cataniaLat)
+	assert.Equal(t, 3479447370796909.0, score)
+
+	_, ok = Score(0, 86)
+	assert.False(t, ok)
+	_, ok = Score(181, 0)
+	assert.False(t, ok)
+}
+
+func TestLocation(t *testing.T) {
+	lon, lat := Location(3479099956230698)
+	assert.InDelta(t, 13.36138933897018433, lon, 1e-12)
+	assert.InDelta(t, 38.11555639549629859, lat, 1e-12)
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "sqc8b49rny0", String(Location(3479099956230698)))
+	assert.Equal(t, "sqdtr74hyu0", String(Location(3479447370796909)))
+}
+
+func TestDistance(t *testing.T) {
+	plon, plat := Location(3479099956230698)
+	clon, clat := Location(3479447370796909)
+	assert.InDelta(t, 166274.1516, Distance(plon, plat, clon, clat), 1e-4)
+	assert.Equal(t, 0.0, Distance(plon, plat, plon, plat))
+}
+
+func TestInterleave(t *testing.T) {
+	x, y := deinterleave(interleave(0x12345678, 0x9abcdef0))
+	assert.Equal(t, uint32(0x12345678), x)
+	assert.Equal(t, uint32(0x9abcdef0), y)
+	assert.Equal(t, uint64(0x1), interleave(1, 0))
+	assert.Equal(t, uint64(0x2), interleave(0, 1))
+}
+
+func TestNeighbors(t *testing.T) {
+	hash, _ := EncodeWGS84(palermoLon, palermoLat, 10)
+	area := DecodeWGS84(hash)
+	n := hash.Neighbors()
+
+	north := DecodeWGS84(n.North)
+	assert.InDelta(t, area.Latitude.Max, north.Latitude.Min, 1e-9)
+	assert.Equal(t, area.Longitude, north.Longitude)
+
+	east := DecodeWGS84(n.East)
+	assert.InDelta(t, area.Longitude.Max, east.Longitude.Min, 1e-9)
+	assert.Equal(t, area.Latitude, east.Latitude)
+
+	southWest := DecodeWGS84(n.SouthWest)
+	assert.InDelta(t, area.Latitude.Min, southWest.Latitude.Max, 1e-9)
+	assert.InDelta(t, area.Longitude.Min, southWest.Longitude.Max, 1e-9)
+}
+
+func TestShapeContains(t *testing.T) {
+	plon, plat := Location(3479099956230698)
+	clon, clat := Location(3479447370796909)
+
+	circle := Shape{Longitude: 15, Latitude: 37, Radius: 200000}
+	d, ok := circle.Contains(clon, clat)
+	assert.True(t, ok)
+	assert.InDelta(t, 56441.3, d, 0.05)
+	d, ok = circle.Contains(plon, plat)
+	assert.True(t, ok)
+	assert.InDelta(t, 190442.4, d, 0.05)
+
+	circle.Radius = 100000
+	_, ok = circle.Contains(plon, plat)
+	assert.False(t, ok)
+
+	box := Shape{Longitude: 15, Latitude: 37, Width: 400000, Height: 400000, Box: true}
+	_, ok = box.Contains(plon, plat)
+	assert.True(t, ok)
+	box.Width = 200000
+	_, ok = box.Contains(plon, plat)
+	assert.False(t, ok)
+}
+
+func TestAreasCoverTheShape(t *testing.T) {
+	shape := Shape{Longitude: 15, Latitude: 37, Radius: 200000}
+	areas := shape.Areas()
+	assert.NotEmpty(t, areas)
+
+	covered := func(lon, lat float64) bool {
+		score, _ := Score(lon, lat)
+		for _, h := range areas {
+			if min, max := h.ScoreRange(); score >= min && score < max {
+				return true
+			}
+		}
+		return false
+	}
+
+	assert.True(t, covered(palermoLon, palermoLat))
+	assert.True(t, covered(cataniaLon, cataniaLat))
+	assert.True(t, covered(15, 38.7))
+	assert.True(t, covered(12.8, 37))
+}
+
+func TestEstimateStep(t *testing.T) {
+	assert.Equal(t, uint(MaxStep), estimateStep(0, 0))
+	assert.True(t, estimateStep(1000, 0) > estimateStep(100000, 0))
+	assert.True(t, estimateStep(1000, 85) < estimateStep(1000, 0))
+	assert.Equal(t, uint(1), estimateStep(1e9, 0))
+}
@@ -0,0 +1,181 @@
+package geohash
+
+import (
+	"math"
+)
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance returns the distance in meters between two locations, using the
+// haversine formula.
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lon1r := degToRad(lat1), degToRad(lon1)
+	lat2r, lon2r := degToRad(lat2), degToRad(lon2)
+
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+
+	return 2 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+// latDistance returns the distance in meters between two latitudes along a
+// meridian.
+func latDistance(lat1, lat2 float64) float64 {
+	return EarthRadius * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+// Shape is an area to search centered on a location, either a circle of
+// the given radius or a box of the given width and height, in meters.
+type Shape struct {
+	Longitude, Latitude float64
+
+	Radius        float64
+	Width, Height float64
+	// Box is set for boxes.
+	Box bool
+}
+
+// Contains reports whether the location is within the shape, and returns
+// its distance in meters to the center.
+func (s Shape) Contains(lon, lat float64) (distance float64, ok bool) {
+	if !s.Box {
+		distance = Distance(s.Longitude, s.Latitude, lon, lat)
+		return distance, distance <= s.Radius
+	}
+
+	// the latitude distance is cheaper to compute so it is checked first
+	if latDistance(lat, s.Latitude) > s.Height/2 {
+		return 0, false
+	}
+	if Distance(lon, lat, s.Longitude, lat) > s.Width/2 {
+		return 0, false
+	}
+
+	return Distance(s.Longitude, s.Latitude, lon, lat), true
+}
+
+// boundingBox returns the longitudes and latitudes bounding the shape.
+func (s Shape) boundingBox() (lon, lat Range) {
+	width, height := s.Radius, s.Radius
+	if s.Box {
+		width, height = s.Width/2, s.Height/2
+	}
+
+	latDelta := radToDeg(height / EarthRadius)
+	lonDeltaTop := radToDeg(width / EarthRadius / math.Cos(degToRad(s.Latitude+latDelta)))
+	lonDeltaBottom := radToDeg(width / EarthRadius / math.Cos(degToRad(s.Latitude-latDelta)))
+
+	// the box is widest on the side closest to the equator
+	lonDelta := lonDeltaTop
+	if s.Latitude < 0 {
+		lonDelta = lonDeltaBottom
+	}
+
+	return Range{s.Longitude - lonDelta, s.Longitude + lonDelta}, Range{s.Latitude - latDelta, s.Latitude + latDelta}
+}
+
+// estimateStep returns the largest step whose cells are still larger than
+// the given radius in meters at the given latitude.
+func estimateStep(radius, lat float64) uint {
+	if radius == 0 {
+		return MaxStep
+	}
+
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+
+	// cells get narrower towards the poles
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+
+	if step < 1 {
+		step = 1
+	}
+	if step > MaxStep {
+		step = MaxStep
+	}
+
+	return uint(step)
+}
+
+// Areas returns the geohashes of the areas to search for locations within
+// the shape: the area of the center and its neighbors, at a step where they
+// cover the whole shape, leaving out the neighbors the shape doesn't reach.
+// The same area can be returned more than once when the step is small.
+func (s Shape) Areas() []Hash {
+	radius := s.Radius
+	if s.Box {
+		radius = math.Sqrt(s.Width/2*s.Width/2 + s.Height/2*s.Height/2)
+	}
+
+	lonBounds, latBounds := s.boundingBox()
+	step := estimateStep(radius, s.Latitude)
+
+	hash, _ := EncodeWGS84(s.Longitude, s.Latitude, step)
+	neighbors := hash.Neighbors()
+	area := DecodeWGS84(hash)
+
+	// the step may still be too large at the edges of the shape
+	north := DecodeWGS84(neighbors.North)
+	south := DecodeWGS84(neighbors.South)
+	east := DecodeWGS84(neighbors.East)
+	west := DecodeWGS84(neighbors.West)
+	if step > 1 && (north.Latitude.Max < latBounds.Max || south.Latitude.Min > latBounds.Min ||
+		east.Longitude.Max < lonBounds.Max || west.Longitude.Min > lonBounds.Min) {
+		step--
+		hash, _ = EncodeWGS84(s.Longitude, s.Latitude, step)
+		neighbors = hash.Neighbors()
+		area = DecodeWGS84(hash)
+	}
+
+	// a zero geohash marks an area that doesn't need searching
+	var none Hash
+	if step >= 2 {
+		if area.Latitude.Min < latBounds.Min {
+			neighbors.South, neighbors.SouthWest, neighbors.SouthEast = none, none, none
+		}
+		if area.Latitude.Max > latBounds.Max {
+			neighbors.North, neighbors.NorthWest, neighbors.NorthEast = none, none, none
+		}
+		if area.Longitude.Min < lonBounds.Min {
+			neighbors.West, neighbors.SouthWest, neighbors.NorthWest = none, none, none
+		}
+		if area.Longitude.Max > lonBounds.Max {
+			neighbors.East, neighbors.SouthEast, neighbors.NorthEast = none, none, none
+		}
+	}
+
+	var areas []Hash
+	for _, h := range []Hash{
+		hash,
+		neighbors.North, neighbors.South, neighbors.East, neighbors.West,
+		neighbors.NorthEast, neighbors.NorthWest, neighbors.SouthEast, neighbors.SouthWest,
+	} {
+		if h == none {
+			continue
+		}
+
+		// when the step is very small neighbors can be the same area
+		if len(areas) > 0 && areas[len(areas)-1] == h {
+			continue
+		}
+		areas = append(areas, h)
+	}
+
+	return areas
+}
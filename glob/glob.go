@@ -0,0 +1,154 @@
+// Package glob implements the glob-style pattern matching used by Redis
+// commands such as KEYS, SCAN MATCH and PSUBSCRIBE.
+//
+// In a pattern, '*' matches any sequence of bytes including none, '?'
+// matches any single byte, "[abc]" matches one of the bytes in the brackets,
+// "[^abc]" matches any byte not in the brackets, "[a-z]" matches any byte in
+// the range, and a backslash matches the byte following it literally.
+package glob
+
+// Match reports whether s matches pattern.
+func Match(pattern, s []byte) bool {
+	var skipLonger bool
+	return match(pattern, s, false, &skipLonger, 0)
+}
+
+// MatchString is like Match but accepts strings.
+func MatchString(pattern, s string) bool {
+	return Match([]byte(pattern), []byte(s))
+}
+
+// MatchFold is like Match but compares ASCII letters case-insensitively.
+func MatchFold(pattern, s []byte) bool {
+	var skipLonger bool
+	return match(pattern, s, true, &skipLonger, 0)
+}
+
+// maxNesting bounds the recursion used for '*' so that pathological patterns
+// can't exhaust the stack.
+const maxNesting = 1000
+
+// match reports whether s matches pattern. skipLonger is set when a '*' has
+// tried every suffix of s without success: an enclosing '*' handing over a
+// shorter suffix can't do any better, which keeps patterns such as
+// "a*a*a*a*b" from taking exponential time.
+func match(pattern, s []byte, fold bool, skipLonger *bool, nesting int) bool {
+	if nesting > maxNesting {
+		return false
+	}
+
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for ; len(s) > 0; s = s[1:] {
+				if match(pattern[1:], s, fold, skipLonger, nesting+1) {
+					return true
+				}
+				if *skipLonger {
+					return false
+				}
+			}
+			*skipLonger = true
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+
+			matched := false
+			for {
+				if len(pattern) == 0 {
+					// unterminated class, treat the end as the closing bracket
+					break
+				}
+				if pattern[0] == ']' {
+					break
+				}
+
+				switch {
+				case pattern[0] == '\\' && len(pattern) >= 2:
+					pattern = pattern[1:]
+					if equal(pattern[0], s[0], fold) {
+						matched = true
+					}
+				case len(pattern) >= 3 && pattern[1] == '-':
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					c := s[0]
+					if fold {
+						start, end, c = lower(start), lower(end), lower(c)
+					}
+					if c >= start && c <= end {
+						matched = true
+					}
+					pattern = pattern[2:]
+				default:
+					if equal(pattern[0], s[0], fold) {
+						matched = true
+					}
+				}
+				pattern = pattern[1:]
+			}
+
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			s = s[1:]
+
+			if len(pattern) == 0 {
+				// the unterminated class consumed the rest of the pattern
+				return len(s) == 0
+			}
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || !equal(pattern[0], s[0], fold) {
+				return false
+			}
+			s = s[1:]
+		}
+
+		pattern = pattern[1:]
+	}
+
+	return len(s) == 0
+}
+
+func equal(a, b byte, fold bool) bool {
+	if fold {
+		return lower(a) == lower(b)
+	}
+	return a == b
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
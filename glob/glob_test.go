@@ -0,0 +1,54 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hllo", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"user:*:name", "user:1000:name", true},
+		{"user:*:name", "user:1000:email", false},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXbY", false},
+		{"[\\]]", "]", true},
+		{"abc", "ab", false},
+		{"ab", "abc", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, MatchString(tt.pattern, tt.s), "%q ~ %q", tt.pattern, tt.s)
+	}
+}
+
+func TestMatchFold(t *testing.T) {
+	assert.True(t, MatchFold([]byte("HE*"), []byte("hello")))
+	assert.True(t, MatchFold([]byte("[A-C]x"), []byte("bX")))
+	assert.False(t, Match([]byte("HE*"), []byte("hello")))
+}
+
+func TestMatchPathological(t *testing.T) {
+	pattern := "a*a*a*a*a*a*a*a*a*a*a*a*a*b"
+	s := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	assert.False(t, MatchString(pattern, s))
+}
@@ -1,6 +1,15 @@
 module github.com/scnewma/godb
 
+go 1.27.1
+
 require (
 	github.com/hashicorp/go-multierror v1.0.0
 	github.com/stretchr/testify v1.3.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.0 // indirect
+)
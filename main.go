@@ -3,20 +3,103 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/scnewma/godb/config"
 	"github.com/scnewma/godb/executor"
 	"github.com/scnewma/godb/resp"
+	"github.com/scnewma/godb/storage"
 	"github.com/scnewma/godb/storage/inmem"
 )
 
+// logLevels maps the loglevel parameter to the levels of the logger.
+var logLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"verbose": slog.LevelDebug + 2,
+	"notice":  slog.LevelInfo,
+	"warning": slog.LevelWarn,
+}
+
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [godb.conf]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	addr := flag.String("addr", ":1123", "tcp listen addr")
+	databases := flag.Int("databases", 16, "number of logical databases")
+	aclFile := flag.String("aclfile", "", "file to load the ACL users from")
 	flag.Parse()
 
-	db := inmem.NewStorage()
-	exctr := executor.NewExecutor(db)
-	handler := executor.NewHandler(exctr)
+	cfg := config.New(config.Params...)
+	if path := flag.Arg(0); path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// flags given on the command line take precedence over the config file
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		// the first invalid flag is reported rather than overwritten
+		if err != nil {
+			return
+		}
+
+		switch f.Name {
+		case "addr":
+			var host, port string
+			if host, port, err = net.SplitHostPort(*addr); err == nil {
+				err = cfg.Override(config.Bind, host)
+			}
+			if err == nil {
+				err = cfg.Override(config.Port, port)
+			}
+		case "databases":
+			err = cfg.Override(config.Databases, strconv.Itoa(*databases))
+		case "aclfile":
+			err = cfg.Override(config.ACLFile, *aclFile)
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	level := new(slog.LevelVar)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	cfg.Watch(config.LogLevel, func() {
+		level.Set(logLevels[cfg.Value(config.LogLevel)])
+	})
+
+	dbs := make([]storage.Storage, cfg.Int(config.Databases))
+	for i := range dbs {
+		dbs[i] = inmem.NewStorage()
+	}
+
+	exctr := executor.NewExecutor(dbs...)
+	exctr.SetConfig(cfg)
+	stopExpiry := exctr.ExpireEvery(100 * time.Millisecond)
+	defer stopExpiry()
+	if path := cfg.Value(config.ACLFile); path != "" {
+		if err := exctr.ACL().LoadFile(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	listenAddr := net.JoinHostPort(cfg.Value(config.Bind), cfg.Value(config.Port))
+	srv := resp.NewServer(listenAddr, executor.NewHandler(exctr))
+	exctr.SetServer(srv)
+	cfg.Watch(config.Timeout, func() {
+		srv.SetIdleTimeout(time.Duration(cfg.Int(config.Timeout)) * time.Second)
+	})
+	cfg.Watch(config.MaxClients, func() {
+		srv.SetMaxClients(int(cfg.Int(config.MaxClients)))
+	})
 
-	fmt.Printf("Serving on %s\n", *addr)
-	resp.ListenAndServe(*addr, handler)
+	slog.Info("serving", "addr", listenAddr, "config", cfg.File())
+	log.Fatal(srv.ListenAndServe())
 }
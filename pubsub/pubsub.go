@@ -0,0 +1,174 @@
+// Package pubsub routes published messages to the subscribers of channels
+// and of glob-style patterns matching channels.
+package pubsub
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/scnewma/godb/glob"
+)
+
+// Subscriber receives the messages published to the channels and patterns
+// it subscribes to.
+type Subscriber interface {
+	// Deliver is called for every message published to the subscriber.
+	// It is called with the hub locked and must not block, so that slow
+	// subscribers never hold up publishers.
+	Deliver(msg Message)
+}
+
+// Message is a message published to a channel.
+type Message struct {
+	Channel string
+	// Pattern is the pattern the channel matched for pattern
+	// subscriptions, and empty for channel subscriptions.
+	Pattern string
+	Payload []byte
+}
+
+// Hub keeps track of subscriptions and delivers published messages. It is
+// safe for concurrent use.
+type Hub struct {
+	mu       sync.RWMutex
+	channels map[string]map[Subscriber]struct{}
+	patterns map[string]map[Subscriber]struct{}
+}
+
+func New() *Hub {
+	return &Hub{
+		channels: make(map[string]map[Subscriber]struct{}),
+		patterns: make(map[string]map[Subscriber]struct{}),
+	}
+}
+
+// Subscribe subscribes s to channel, reporting false if it already was.
+func (h *Hub) Subscribe(s Subscriber, channel string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return add(h.channels, channel, s)
+}
+
+// Unsubscribe unsubscribes s from channel, reporting false if it wasn't
+// subscribed.
+func (h *Hub) Unsubscribe(s Subscriber, channel string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return remove(h.channels, channel, s)
+}
+
+// PSubscribe subscribes s to the channels matching pattern, reporting false
+// if it already was.
+func (h *Hub) PSubscribe(s Subscriber, pattern string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return add(h.patterns, pattern, s)
+}
+
+// PUnsubscribe unsubscribes s from pattern, reporting false if it wasn't
+// subscribed.
+func (h *Hub) PUnsubscribe(s Subscriber, pattern string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return remove(h.patterns, pattern, s)
+}
+
+// Publish delivers payload to the subscribers of channel and of the
+// patterns matching it, and returns the number of deliveries. A subscriber
+// of several matching patterns receives the message once per pattern.
+func (h *Hub) Publish(channel string, payload []byte) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n := 0
+	for s := range h.channels[channel] {
+		s.Deliver(Message{Channel: channel, Payload: payload})
+		n++
+	}
+
+	for pattern, subs := range h.patterns {
+		if !glob.MatchString(pattern, channel) {
+			continue
+		}
+
+		for s := range subs {
+			s.Deliver(Message{Channel: channel, Pattern: pattern, Payload: payload})
+			n++
+		}
+	}
+
+	return n
+}
+
+// Channels returns the channels with at least one subscriber that match
+// pattern, or every such channel if pattern is empty, in sorted order.
+func (h *Hub) Channels(pattern string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	channels := make([]string, 0, len(h.channels))
+	for channel := range h.channels {
+		if pattern == "" || glob.MatchString(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+
+	return channels
+}
+
+// NumSub returns the number of subscribers of channel, not counting pattern
+// subscriptions.
+func (h *Hub) NumSub(channel string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.channels[channel])
+}
+
+// NumPat returns the number of patterns with at least one subscriber.
+func (h *Hub) NumPat() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.patterns)
+}
+
+func add(subs map[string]map[Subscriber]struct{}, name string, s Subscriber) bool {
+	set, ok := subs[name]
+	if !ok {
+		set = make(map[Subscriber]struct{})
+		subs[name] = set
+	}
+
+	if _, ok := set[s]; ok {
+		return false
+	}
+	set[s] = struct{}{}
+
+	return true
+}
+
+func remove(subs map[string]map[Subscriber]struct{}, name string, s Subscriber) bool {
+	set, ok := subs[name]
+	if !ok {
+		return false
+	}
+
+	if _, ok := set[s]; !ok {
+		return false
+	}
+	delete(set, s)
+
+	// channels without subscribers are forgotten so that they stop being
+	// listed
+	if len(set) == 0 {
+		delete(subs, name)
+	}
+
+	return true
+}
@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	messages []Message
+}
+
+func (r *recorder) Deliver(msg Message) {
+	r.messages = append(r.messages, msg)
+}
+
+func TestPublishToChannel(t *testing.T) {
+	h := New()
+	a, b := &recorder{}, &recorder{}
+
+	assert.True(t, h.Subscribe(a, "news"))
+	assert.False(t, h.Subscribe(a, "news"))
+	assert.True(t, h.Subscribe(b, "news"))
+	assert.True(t, h.Subscribe(b, "sports"))
+
+	assert.Equal(t, 2, h.Publish("news", []byte("hello")))
+	assert.Equal(t, 0, h.Publish("weather", []byte("sunny")))
+
+	assert.Equal(t, []Message{{Channel: "news", Payload: []byte("hello")}}, a.messages)
+	assert.Equal(t, []Message{{Channel: "news", Payload: []byte("hello")}}, b.messages)
+
+	assert.True(t, h.Unsubscribe(a, "news"))
+	assert.False(t, h.Unsubscribe(a, "news"))
+	assert.Equal(t, 1, h.Publish("news", []byte("again")))
+	assert.Len(t, a.messages, 1)
+	assert.Len(t, b.messages, 2)
+}
+
+func TestPublishToPattern(t *testing.T) {
+	h := New()
+	a := &recorder{}
+
+	assert.True(t, h.PSubscribe(a, "news.*"))
+	assert.True(t, h.PSubscribe(a, "*"))
+	assert.True(t, h.Subscribe(a, "news.tech"))
+
+	// one delivery per matching subscription
+	assert.Equal(t, 3, h.Publish("news.tech", []byte("go")))
+	assert.Equal(t, 1, h.Publish("sports", []byte("ball")))
+
+	sort.Slice(a.messages, func(i, j int) bool { return a.messages[i].Pattern < a.messages[j].Pattern })
+	assert.Equal(t, []Message{
+		{Channel: "news.tech", Payload: []byte("go")},
+		{Channel: "news.tech", Pattern: "*", Payload: []byte("go")},
+		{Channel: "sports", Pattern: "*", Payload: []byte("ball")},
+		{Channel: "news.tech", Pattern: "news.*", Payload: []byte("go")},
+	}, a.messages)
+
+	assert.True(t, h.PUnsubscribe(a, "*"))
+	assert.False(t, h.PUnsubscribe(a, "*"))
+	assert.Equal(t, 0, h.Publish("sports", []byte("ball")))
+}
+
+func TestIntrospection(t *testing.T) {
+	h := New()
+	a, b := &recorder{}, &recorder{}
+	h.Subscribe(a, "news.tech")
+	h.Subscribe(b, "news.tech")
+	h.Subscribe(a, "sports")
+	h.PSubscribe(a, "news.*")
+	h.PSubscribe(b, "news.*")
+	h.PSubscribe(b, "x")
+
+	assert.Equal(t, []string{"news.tech", "sports"}, h.Channels(""))
+	assert.Equal(t, []string{"news.tech"}, h.Channels("news.*"))
+	assert.Equal(t, 2, h.NumSub("news.tech"))
+	assert.Equal(t, 0, h.NumSub("weather"))
+	assert.Equal(t, 2, h.NumPat())
+
+	h.Unsubscribe(a, "sports")
+	assert.Equal(t, []string{"news.tech"}, h.Channels(""))
+}
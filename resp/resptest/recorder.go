@@ -1,6 +1,11 @@
 package resptest
 
-import "github.com/scnewma/godb/resp"
+import (
+	"net"
+	"sync"
+
+	"github.com/scnewma/godb/resp"
+)
 
 type ResponseRecorder struct {
 	Messages []resp.Message
@@ -32,3 +37,60 @@ func (rr *ResponseRecorder) MessageAt(idx int) resp.Message {
 func (rr *ResponseRecorder) MessageCount() int {
 	return len(rr.Messages)
 }
+
+// ConnRecorder is a resp.Conn that records the messages pushed to it.
+type ConnRecorder struct {
+	ConnID    uint64
+	Addr      net.Addr
+	Pushed    []resp.Message
+	KeptAlive bool
+	// Closing is set once the connection was asked to close after its
+	// reply.
+	Closing bool
+
+	mu sync.Mutex
+}
+
+func NewConnRecorder(id uint64) *ConnRecorder {
+	return &ConnRecorder{ConnID: id}
+}
+
+func (cr *ConnRecorder) ID() uint64 {
+	return cr.ConnID
+}
+
+func (cr *ConnRecorder) RemoteAddr() net.Addr {
+	return cr.Addr
+}
+
+func (cr *ConnRecorder) Push(msg resp.Message) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.Pushed = append(cr.Pushed, msg)
+	return true
+}
+
+func (cr *ConnRecorder) KeepAlive(keep bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.KeptAlive = keep
+}
+
+func (cr *ConnRecorder) CloseAfterReply() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.Closing = true
+}
+
+// Flush returns the messages pushed since the last call.
+func (cr *ConnRecorder) Flush() []resp.Message {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	pushed := cr.Pushed
+	cr.Pushed = nil
+	return pushed
+}
@@ -23,8 +23,8 @@ func asServerCommand(s string) *resp.Array {
 
 	var msgs []resp.Message
 	for _, p := range parts {
-		msgs = append(msgs, &resp.BulkString{[]byte(p)})
+		msgs = append(msgs, &resp.BulkString{Value: []byte(p)})
 	}
 
-	return &resp.Array{msgs}
+	return &resp.Array{Value: msgs}
 }
@@ -2,63 +2,274 @@ package resp
 
 import (
 	"bufio"
+	"context"
 	"errors"
+	"log/slog"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	idleTimeout = 60 * time.Second
+	// defaultIdleTimeout is how long a server keeps idle connections open by
+	// default.
+	defaultIdleTimeout = 60 * time.Second
+
+	// defaultMaxClients is the number of connections a server accepts at
+	// once by default, like Redis.
+	defaultMaxClients = 10000
+
+	// pushQueueSize is the number of pushed messages a connection holds
+	// before it is considered too slow and closed.
+	pushQueueSize = 1024
 )
 
+// Conn is the client connection a request was received on.
+type Conn interface {
+	// ID identifies the connection among the server's connections.
+	ID() uint64
+
+	// RemoteAddr returns the address of the client.
+	RemoteAddr() net.Addr
+
+	// Push queues msg to be written to the client outside of a reply,
+	// without waiting for the write. Pushed messages are written in order
+	// with replies. If the client doesn't read fast enough for the queue to
+	// have room the connection is closed and Push returns false.
+	Push(msg Message) bool
+
+	// KeepAlive exempts the connection from the idle timeout while it is
+	// set, e.g. while the client waits for pushed messages.
+	KeepAlive(keep bool)
+
+	// CloseAfterReply closes the connection once the reply to the current
+	// request is written, without reading further requests.
+	CloseAfterReply()
+}
+
 type conn struct {
 	server *server
 
+	id  uint64
 	rwc net.Conn
+
+	pushes    chan Message
+	keepAlive atomic.Bool
+	quit      atomic.Bool
+}
+
+func (c *conn) ID() uint64 {
+	return c.id
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.rwc.RemoteAddr()
+}
+
+func (c *conn) KeepAlive(keep bool) {
+	c.keepAlive.Store(keep)
+}
+
+func (c *conn) CloseAfterReply() {
+	c.quit.Store(true)
+}
+
+// resetIdleTimeout sets the deadline of the next request.
+func (c *conn) resetIdleTimeout() {
+	timeout := c.server.IdleTimeout()
+	if c.keepAlive.Load() || timeout == 0 {
+		c.rwc.SetReadDeadline(time.Time{})
+		return
+	}
+
+	c.rwc.SetReadDeadline(time.Now().Add(timeout))
+}
+
+func (c *conn) Push(msg Message) bool {
+	select {
+	case c.pushes <- msg:
+		return true
+	default:
+		// closing the connection ends serve, which stops reading pushes
+		c.rwc.Close()
+		return false
+	}
+}
+
+// writePushes writes the messages pushed so far.
+func (c *conn) writePushes(w *Writer) {
+	for {
+		select {
+		case msg := <-c.pushes:
+			w.WriteMessage(msg)
+		default:
+			return
+		}
+	}
+}
+
+// connWriter writes the messages pushed to a connection before each reply,
+// so that a reply never overtakes a message pushed before it.
+type connWriter struct {
+	c *conn
+	w *Writer
+}
+
+func (cw connWriter) WriteMessage(msg Message) error {
+	cw.c.writePushes(cw.w)
+	return cw.w.WriteMessage(msg)
+}
+
+// readResult is a message read from the connection, or the error that ended
+// reading.
+type readResult struct {
+	msg Message
+	err error
 }
 
 func (c *conn) serve() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	defer c.rwc.Close()
-	c.rwc.SetReadDeadline(time.Now().Add(idleTimeout))
+	defer c.server.stats.ConnectedClients.Add(-1)
+	defer slog.Debug("closed connection", "id", c.id, "addr", c.rwc.RemoteAddr())
+	c.resetIdleTimeout()
 
-	bufr := bufio.NewReader(c.rwc)
-	bufw := bufio.NewWriter(c.rwc)
+	bufw := bufio.NewWriter(countingWriter{w: c.rwc, count: &c.server.stats.NetOutputBytes})
 
 	respw := NewWriter(bufw)
 
+	// messages are read in the background so that the request context can
+	// be cancelled as soon as the client goes away, even while a handler is
+	// blocked waiting for something to happen.
+	done := make(chan struct{})
+	defer close(done)
+
+	msgs := make(chan readResult, 16)
+	go c.readMessages(cancel, msgs, done)
+
 	for {
-		msg, err := ReadMessage(bufr)
-		if err != nil {
-			respw.WriteMessage(&Error{err.Error()})
+		var res readResult
+		select {
+		case msg := <-c.pushes:
+			respw.WriteMessage(msg)
+			c.writePushes(respw)
+			bufw.Flush()
+			continue
+		case r, ok := <-msgs:
+			if !ok {
+				return
+			}
+			res = r
+		}
+
+		if res.err != nil {
+			respw.WriteMessage(&Error{Value: res.err.Error()})
+			bufw.Flush()
 
 			// this will close the connection if the read deadline
 			// is exceeded or the client passes in an unparseable
 			// message.
-			break
+			return
 		}
 
-		arr, ok := msg.(*Array)
+		arr, ok := res.msg.(*Array)
 		if !ok {
-			respw.WriteMessage(&Error{"invalid command"})
+			respw.WriteMessage(&Error{Value: "invalid command"})
+			bufw.Flush()
+			continue
 		}
 
-		c.server.Handler.Serve(respw, &Request{
+		// the idle timeout only applies while waiting for a request, not
+		// while one is being handled
+		c.rwc.SetReadDeadline(time.Time{})
+
+		c.server.Handler.Serve(connWriter{c: c, w: respw}, &Request{
 			RawMessage: arr,
+			ctx:        ctx,
+			conn:       c,
 		})
 
+		c.writePushes(respw)
 		bufw.Flush()
 
-		c.rwc.SetReadDeadline(time.Now().Add(idleTimeout))
+		if c.quit.Load() {
+			return
+		}
+
+		c.resetIdleTimeout()
+	}
+}
+
+// readMessages reads messages from the connection until an error occurs,
+// which is delivered as the last result. The request context is cancelled as
+// soon as the error is seen so handlers stop waiting on a dead client.
+func (c *conn) readMessages(cancel context.CancelFunc, msgs chan<- readResult, done <-chan struct{}) {
+	defer close(msgs)
+
+	bufr := bufio.NewReader(countingReader{r: c.rwc, count: &c.server.stats.NetInputBytes})
+	for {
+		msg, err := ReadMessage(bufr)
+		if err != nil {
+			cancel()
+		}
+
+		select {
+		case msgs <- readResult{msg: msg, err: err}:
+		case <-done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
 	}
 }
 
 type Request struct {
 	RawMessage *Array
 
+	ctx  context.Context
+	conn Conn
+
 	command string
 	args    [][]byte
 }
 
+// Context returns the request's context. For server requests the context is
+// cancelled when the client connection closes.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+
+	return context.Background()
+}
+
+// Conn returns the connection the request was received on, or nil if it
+// wasn't received by a server.
+func (r *Request) Conn() Conn {
+	return r.conn
+}
+
+// WithConn returns a shallow copy of r with its connection changed to c.
+func (r *Request) WithConn(c Conn) *Request {
+	r2 := new(Request)
+	*r2 = *r
+	r2.conn = c
+
+	return r2
+}
+
+// WithContext returns a shallow copy of r with its context changed to ctx.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+
+	return r2
+}
+
 func (r *Request) ParseCommand() error {
 	for i, a := range r.RawMessage.Value {
 		bs, ok := a.(*BulkString)
@@ -110,6 +321,62 @@ type Handler interface {
 type server struct {
 	Addr    string
 	Handler Handler
+
+	lastConnID atomic.Uint64
+
+	// port is the port the server listens on, once it does.
+	port atomic.Int64
+	// maxClients is the number of connections accepted at once, or zero
+	// for no limit.
+	maxClients atomic.Int64
+	// idleTimeout is how long idle connections are kept open, in
+	// nanoseconds, or zero to keep them open forever.
+	idleTimeout atomic.Int64
+	stats       Stats
+}
+
+// NewServer returns a server listening on addr that serves requests with
+// handler.
+func NewServer(addr string, handler Handler) *server {
+	srv := &server{Addr: addr, Handler: handler}
+	srv.maxClients.Store(defaultMaxClients)
+	srv.idleTimeout.Store(int64(defaultIdleTimeout))
+
+	return srv
+}
+
+// Stats returns the server's counters.
+func (srv *server) Stats() *Stats {
+	return &srv.stats
+}
+
+// Port returns the TCP port the server listens on, or 0 if it doesn't yet.
+func (srv *server) Port() int {
+	return int(srv.port.Load())
+}
+
+// MaxClients returns the number of connections the server accepts at once,
+// or zero if there is no limit.
+func (srv *server) MaxClients() int {
+	return int(srv.maxClients.Load())
+}
+
+// SetMaxClients changes the number of connections the server accepts at
+// once. Connections already open are kept even if there are too many.
+func (srv *server) SetMaxClients(n int) {
+	srv.maxClients.Store(int64(n))
+}
+
+// IdleTimeout returns how long idle connections are kept open, or zero if
+// they are kept open forever.
+func (srv *server) IdleTimeout() time.Duration {
+	return time.Duration(srv.idleTimeout.Load())
+}
+
+// SetIdleTimeout changes how long idle connections are kept open. Open
+// connections use the new timeout from their next request on.
+func (srv *server) SetIdleTimeout(d time.Duration) {
+	srv.idleTimeout.Store(int64(d))
 }
 
 func (srv *server) ListenAndServe() error {
@@ -121,25 +388,53 @@ func (srv *server) ListenAndServe() error {
 }
 
 func (srv *server) Serve(ln net.Listener) error {
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok {
+		srv.port.Store(int64(addr.Port))
+	}
+
 	for {
 		rw, err := ln.Accept()
 		if err != nil {
 			return err
 		}
+		srv.stats.TotalConnections.Add(1)
+
+		// connections are counted before they are served so that a burst
+		// of them can't get past the limit
+		max := srv.maxClients.Load()
+		if max > 0 && srv.stats.ConnectedClients.Load() >= max {
+			srv.stats.RejectedConnections.Add(1)
+			slog.Warn("rejected connection: max number of clients reached", "addr", rw.RemoteAddr())
+			go reject(rw)
+			continue
+		}
+		srv.stats.ConnectedClients.Add(1)
 
 		c := srv.newConn(rw)
+		slog.Debug("accepted connection", "id", c.id, "addr", rw.RemoteAddr())
 		go c.serve()
 	}
 }
 
+// reject tells a client the server has too many clients and closes its
+// connection.
+func reject(rw net.Conn) {
+	defer rw.Close()
+
+	rw.SetWriteDeadline(time.Now().Add(time.Second))
+	w := NewWriter(rw)
+	w.WriteMessage(&Error{Value: "ERR max number of clients reached"})
+}
+
 func (srv *server) newConn(rwc net.Conn) *conn {
 	return &conn{
 		server: srv,
+		id:     srv.lastConnID.Add(1),
 		rwc:    rwc,
+		pushes: make(chan Message, pushQueueSize),
 	}
 }
 
 func ListenAndServe(addr string, handler Handler) error {
-	server := &server{Addr: addr, Handler: handler}
-	return server.ListenAndServe()
+	return NewServer(addr, handler).ListenAndServe()
 }
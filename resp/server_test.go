@@ -0,0 +1,207 @@
+package resp
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContextCancelledOnDisconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	srv := &server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})}
+	go srv.Serve(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+
+	_, err = client.Write([]byte("*1\r\n$5\r\nBLOCK\r\n"))
+	require.NoError(t, err)
+
+	<-started
+	client.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not cancelled")
+	}
+}
+
+func TestServePipelinedRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	srv := &server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteMessage(&BulkString{Value: r.Args()[0]})
+	})}
+	go srv.Serve(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("*2\r\n$4\r\nECHO\r\n$1\r\na\r\n*2\r\n$4\r\nECHO\r\n$1\r\nb\r\n"))
+	require.NoError(t, err)
+
+	expected := "$1\r\na\r\n$1\r\nb\r\n"
+	buf := make([]byte, len(expected))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(buf))
+}
+
+func TestServePushes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	conns := make(chan Conn, 1)
+	srv := &server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		conns <- r.Conn()
+		r.Conn().Push(&SimpleString{Value: "pushed"})
+		w.WriteMessage(&SimpleString{Value: "reply"})
+	})}
+	go srv.Serve(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("*1\r\n$4\r\nPING\r\n"))
+	require.NoError(t, err)
+
+	// messages pushed during a request are written before its reply
+	expected := "+pushed\r\n+reply\r\n"
+	buf := make([]byte, len(expected))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(buf))
+
+	// and messages pushed between requests are written straight away
+	c := <-conns
+	assert.True(t, c.Push(&SimpleString{Value: "later"}))
+
+	expected = "+later\r\n"
+	buf = make([]byte, len(expected))
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(buf))
+}
+
+func TestPushClosesSlowConnections(t *testing.T) {
+	rwc, client := net.Pipe()
+	defer client.Close()
+
+	c := (&server{}).newConn(rwc)
+	for i := 0; i < pushQueueSize; i++ {
+		require.True(t, c.Push(&SimpleString{Value: "x"}))
+	}
+
+	assert.False(t, c.Push(&SimpleString{Value: "x"}))
+	_, err := rwc.Write([]byte("x"))
+	assert.Error(t, err)
+}
+
+func TestCloseAfterReply(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	srv := &server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		r.Conn().CloseAfterReply()
+		w.WriteMessage(&SimpleString{Value: "OK"})
+	})}
+	go srv.Serve(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// the pipelined request is never served
+	_, err = client.Write([]byte("*1\r\n$4\r\nQUIT\r\n*1\r\n$4\r\nPING\r\n"))
+	require.NoError(t, err)
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	reply, err := io.ReadAll(client)
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", string(reply))
+}
+
+func TestServerStats(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	srv := NewServer("", HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteMessage(&SimpleString{Value: "OK"})
+	}))
+	srv.SetMaxClients(1)
+	go srv.Serve(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	request := "*1\r\n$4\r\nPING\r\n"
+	_, err = client.Write([]byte(request))
+	require.NoError(t, err)
+
+	buf := make([]byte, len("+OK\r\n"))
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(client, buf)
+	require.NoError(t, err)
+
+	// a second client is one too many
+	rejected, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer rejected.Close()
+
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	reply, err := io.ReadAll(rejected)
+	require.NoError(t, err)
+	assert.Equal(t, "-ERR max number of clients reached\r\n", string(reply))
+
+	stats := srv.Stats()
+	assert.Equal(t, int64(1), stats.ConnectedClients.Load())
+	assert.Equal(t, int64(2), stats.TotalConnections.Load())
+	assert.Equal(t, int64(1), stats.RejectedConnections.Load())
+	assert.Equal(t, int64(len(request)), stats.NetInputBytes.Load())
+	assert.Equal(t, int64(len(buf)), stats.NetOutputBytes.Load())
+	assert.Equal(t, ln.Addr().(*net.TCPAddr).Port, srv.Port())
+}
+
+func TestIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	srv := NewServer("", HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteMessage(&SimpleString{Value: "OK"})
+	}))
+	srv.SetIdleTimeout(50 * time.Millisecond)
+	go srv.Serve(ln)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadAll(client)
+	require.NoError(t, err, "the idle connection should be closed by the server")
+}
@@ -0,0 +1,54 @@
+package resp
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Stats counts the connections and traffic of a server. It is safe for
+// concurrent use.
+type Stats struct {
+	// ConnectedClients is the number of open connections.
+	ConnectedClients atomic.Int64
+	// TotalConnections is the number of connections accepted, including
+	// the rejected ones.
+	TotalConnections atomic.Int64
+	// RejectedConnections is the number of connections closed straight away
+	// because the server had too many clients.
+	RejectedConnections atomic.Int64
+
+	NetInputBytes  atomic.Int64
+	NetOutputBytes atomic.Int64
+}
+
+// Reset zeroes the counters, except for the number of open connections.
+func (s *Stats) Reset() {
+	s.TotalConnections.Store(0)
+	s.RejectedConnections.Store(0)
+	s.NetInputBytes.Store(0)
+	s.NetOutputBytes.Store(0)
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r     io.Reader
+	count *atomic.Int64
+}
+
+func (cr countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.count.Add(int64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Int64
+}
+
+func (cw countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.count.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,376 @@
+// Package dict implements a hash table keyed by strings that supports
+// cursor based iteration with the same guarantees as Redis's SCAN: every
+// element present for the whole duration of a scan is returned at least
+// once, even if the table is resized between calls.
+//
+// The table has a power of two number of buckets and grows or shrinks by
+// rehashing incrementally into a second table, a few buckets per operation,
+// so that resizing a large table never stalls a single caller.
+package dict
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+)
+
+const (
+	initialSize = 4
+
+	// rehashEmptyVisits bounds how many empty buckets a single rehash step
+	// may skip over.
+	rehashEmptyVisits = 10
+)
+
+type entry struct {
+	key string
+	val interface{}
+
+	next *entry
+}
+
+type table struct {
+	buckets []*entry
+	used    int
+}
+
+func (t *table) mask() uint64 {
+	return uint64(len(t.buckets) - 1)
+}
+
+// Dict is a hash table mapping strings to arbitrary values. The zero value is
+// not usable; create dicts with New. A Dict is not safe for concurrent use.
+type Dict struct {
+	seed maphash.Seed
+
+	// tables[1] is only in use while rehashing from tables[0] into it.
+	tables [2]table
+
+	// rehashIdx is the next bucket of tables[0] to rehash, or -1 if no
+	// rehash is in progress.
+	rehashIdx int
+}
+
+func New() *Dict {
+	return &Dict{seed: maphash.MakeSeed(), rehashIdx: -1}
+}
+
+// Len returns the number of entries in the dict.
+func (d *Dict) Len() int {
+	return d.tables[0].used + d.tables[1].used
+}
+
+func (d *Dict) hash(key string) uint64 {
+	return maphash.String(d.seed, key)
+}
+
+func (d *Dict) rehashing() bool {
+	return d.rehashIdx >= 0
+}
+
+// Get returns the value stored for key.
+func (d *Dict) Get(key string) (interface{}, bool) {
+	e := d.find(key)
+	if e == nil {
+		return nil, false
+	}
+
+	return e.val, true
+}
+
+// Set stores val for key, reporting whether key was newly added.
+func (d *Dict) Set(key string, val interface{}) bool {
+	if e := d.find(key); e != nil {
+		e.val = val
+		return false
+	}
+
+	d.expandIfNeeded()
+
+	// new entries go straight into the new table while rehashing
+	t := &d.tables[0]
+	if d.rehashing() {
+		t = &d.tables[1]
+	}
+
+	idx := d.hash(key) & t.mask()
+	t.buckets[idx] = &entry{key: key, val: val, next: t.buckets[idx]}
+	t.used++
+
+	return true
+}
+
+// Delete removes key, returning its value.
+func (d *Dict) Delete(key string) (interface{}, bool) {
+	if d.Len() == 0 {
+		return nil, false
+	}
+
+	d.rehashStep()
+
+	h := d.hash(key)
+	for i := range d.tables {
+		t := &d.tables[i]
+		if len(t.buckets) == 0 {
+			continue
+		}
+
+		idx := h & t.mask()
+		for prev, e := (*entry)(nil), t.buckets[idx]; e != nil; prev, e = e, e.next {
+			if e.key != key {
+				continue
+			}
+
+			if prev == nil {
+				t.buckets[idx] = e.next
+			} else {
+				prev.next = e.next
+			}
+			t.used--
+
+			d.shrinkIfNeeded()
+			return e.val, true
+		}
+
+		if !d.rehashing() {
+			break
+		}
+	}
+
+	return nil, false
+}
+
+// Clear removes every entry.
+func (d *Dict) Clear() {
+	d.tables = [2]table{}
+	d.rehashIdx = -1
+}
+
+// Range calls fn for every entry until fn returns false. The dict must not be
+// modified during the iteration.
+func (d *Dict) Range(fn func(key string, val interface{}) bool) {
+	for i := range d.tables {
+		for _, e := range d.tables[i].buckets {
+			for ; e != nil; e = e.next {
+				if !fn(e.key, e.val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Random returns a random entry. ok is false if the dict is empty.
+func (d *Dict) Random() (key string, val interface{}, ok bool) {
+	if d.Len() == 0 {
+		return "", nil, false
+	}
+
+	d.rehashStep()
+
+	var e *entry
+	for e == nil {
+		if d.rehashing() {
+			// buckets of tables[0] below rehashIdx are known to be empty
+			size0 := len(d.tables[0].buckets)
+			n := d.rehashIdx + rand.Intn(size0+len(d.tables[1].buckets)-d.rehashIdx)
+			if n >= size0 {
+				e = d.tables[1].buckets[n-size0]
+			} else {
+				e = d.tables[0].buckets[n]
+			}
+		} else {
+			e = d.tables[0].buckets[rand.Intn(len(d.tables[0].buckets))]
+		}
+	}
+
+	// pick a random element of the chain
+	n := 0
+	for c := e; c != nil; c = c.next {
+		n++
+	}
+	for n = rand.Intn(n); n > 0; n-- {
+		e = e.next
+	}
+
+	return e.key, e.val, true
+}
+
+// Scan calls fn for the entries in the bucket addressed by cursor and returns
+// the cursor to continue from. A scan starts with cursor 0 and is complete
+// when the returned cursor is 0 again. fn must not modify the dict, but the
+// dict may be modified freely between calls.
+//
+// The cursor is incremented with its bits reversed, so that buckets are
+// visited in an order that stays valid when the table size changes: growing
+// or shrinking the table between calls only causes entries to be returned
+// more than once, never to be skipped.
+func (d *Dict) Scan(cursor uint64, fn func(key string, val interface{})) uint64 {
+	if d.Len() == 0 {
+		return 0
+	}
+
+	emit := func(t *table, idx uint64) {
+		for e := t.buckets[idx]; e != nil; e = e.next {
+			fn(e.key, e.val)
+		}
+	}
+
+	v := cursor
+	if !d.rehashing() {
+		t0 := &d.tables[0]
+		m0 := t0.mask()
+		emit(t0, v&m0)
+
+		// set the unmasked bits so that incrementing the reversed cursor
+		// only operates on the masked bits
+		v |= ^m0
+		v = bits.Reverse64(v)
+		v++
+		v = bits.Reverse64(v)
+
+		return v
+	}
+
+	t0, t1 := &d.tables[0], &d.tables[1]
+	if len(t0.buckets) > len(t1.buckets) {
+		t0, t1 = t1, t0
+	}
+	m0, m1 := t0.mask(), t1.mask()
+
+	emit(t0, v&m0)
+
+	// visit every bucket of the larger table that the smaller table's
+	// bucket expands to
+	for {
+		emit(t1, v&m1)
+
+		v |= ^m1
+		v = bits.Reverse64(v)
+		v++
+		v = bits.Reverse64(v)
+
+		if v&(m0^m1) == 0 {
+			break
+		}
+	}
+
+	return v
+}
+
+func (d *Dict) find(key string) *entry {
+	if d.Len() == 0 {
+		return nil
+	}
+
+	d.rehashStep()
+
+	h := d.hash(key)
+	for i := range d.tables {
+		t := &d.tables[i]
+		if len(t.buckets) == 0 {
+			continue
+		}
+
+		for e := t.buckets[h&t.mask()]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+
+		if !d.rehashing() {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (d *Dict) expandIfNeeded() {
+	if d.rehashing() {
+		return
+	}
+
+	t := &d.tables[0]
+	if len(t.buckets) == 0 {
+		t.buckets = make([]*entry, initialSize)
+		return
+	}
+
+	if t.used >= len(t.buckets) {
+		d.resize(t.used + 1)
+	}
+}
+
+func (d *Dict) shrinkIfNeeded() {
+	if d.rehashing() {
+		return
+	}
+
+	t := &d.tables[0]
+	if len(t.buckets) > initialSize && t.used*8 < len(t.buckets) {
+		d.resize(t.used)
+	}
+}
+
+// resize starts rehashing into a table big enough for size entries.
+func (d *Dict) resize(size int) {
+	n := initialSize
+	for n < size {
+		n *= 2
+	}
+
+	if n == len(d.tables[0].buckets) {
+		return
+	}
+
+	d.tables[1] = table{buckets: make([]*entry, n)}
+	d.rehashIdx = 0
+}
+
+// rehashStep moves the entries of one bucket of the old table into the new
+// one, finishing the rehash once the old table is empty.
+func (d *Dict) rehashStep() {
+	if !d.rehashing() {
+		return
+	}
+
+	t0, t1 := &d.tables[0], &d.tables[1]
+
+	emptyVisits := rehashEmptyVisits
+	for t0.used > 0 && d.rehashIdx < len(t0.buckets) {
+		e := t0.buckets[d.rehashIdx]
+		if e == nil {
+			d.rehashIdx++
+			emptyVisits--
+			if emptyVisits == 0 {
+				return
+			}
+			continue
+		}
+
+		for e != nil {
+			next := e.next
+
+			idx := d.hash(e.key) & t1.mask()
+			e.next = t1.buckets[idx]
+			t1.buckets[idx] = e
+			t0.used--
+			t1.used++
+
+			e = next
+		}
+		t0.buckets[d.rehashIdx] = nil
+		d.rehashIdx++
+		break
+	}
+
+	if t0.used == 0 {
+		d.tables[0] = d.tables[1]
+		d.tables[1] = table{}
+		d.rehashIdx = -1
+
+		// entries may have been deleted faster than the table shrank
+		d.shrinkIfNeeded()
+	}
+}
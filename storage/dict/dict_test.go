@@ -0,0 +1,174 @@
+package dict
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetGetDelete(t *testing.T) {
+	d := New()
+
+	assert.True(t, d.Set("a", 1))
+	assert.False(t, d.Set("a", 2))
+	assert.Equal(t, 1, d.Len())
+
+	val, ok := d.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	_, ok = d.Get("b")
+	assert.False(t, ok)
+
+	val, ok = d.Delete("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	_, ok = d.Delete("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, d.Len())
+}
+
+func TestGrowAndShrink(t *testing.T) {
+	d := New()
+
+	for i := 0; i < 10000; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+	require.Equal(t, 10000, d.Len())
+
+	for i := 0; i < 10000; i++ {
+		val, ok := d.Get(strconv.Itoa(i))
+		require.True(t, ok)
+		require.Equal(t, i, val)
+	}
+
+	for i := 0; i < 9990; i++ {
+		_, ok := d.Delete(strconv.Itoa(i))
+		require.True(t, ok)
+	}
+	require.Equal(t, 10, d.Len())
+
+	// finish any pending rehash
+	for i := 0; i < 1000; i++ {
+		d.Get("x")
+	}
+	assert.True(t, len(d.tables[0].buckets) <= 64)
+
+	for i := 9990; i < 10000; i++ {
+		_, ok := d.Get(strconv.Itoa(i))
+		assert.True(t, ok)
+	}
+}
+
+func TestRange(t *testing.T) {
+	d := New()
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	seen := make(map[string]bool)
+	d.Range(func(key string, val interface{}) bool {
+		seen[key] = true
+		return true
+	})
+	assert.Equal(t, 100, len(seen))
+
+	n := 0
+	d.Range(func(key string, val interface{}) bool {
+		n++
+		return n < 10
+	})
+	assert.Equal(t, 10, n)
+}
+
+func TestRandom(t *testing.T) {
+	d := New()
+	_, _, ok := d.Random()
+	assert.False(t, ok)
+
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 2000; i++ {
+		key, val, ok := d.Random()
+		require.True(t, ok)
+		require.Equal(t, key, strconv.Itoa(val.(int)))
+		seen[key] = true
+	}
+	assert.True(t, len(seen) > 50)
+}
+
+func scanAll(d *Dict, between func(step int)) map[string]int {
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for step := 0; ; step++ {
+		cursor = d.Scan(cursor, func(key string, val interface{}) {
+			seen[key]++
+		})
+		if cursor == 0 {
+			return seen
+		}
+		between(step)
+	}
+}
+
+func TestScan(t *testing.T) {
+	d := New()
+	assert.Equal(t, uint64(0), d.Scan(0, func(string, interface{}) {}))
+
+	for i := 0; i < 1000; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	seen := scanAll(d, func(int) {})
+	assert.Equal(t, 1000, len(seen))
+	for _, n := range seen {
+		assert.Equal(t, 1, n)
+	}
+}
+
+func TestScanWhileGrowing(t *testing.T) {
+	d := New()
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	next := 100
+	seen := scanAll(d, func(step int) {
+		if step > 40 {
+			return
+		}
+		for j := 0; j < 50; j++ {
+			d.Set(strconv.Itoa(next), next)
+			next++
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		assert.Contains(t, seen, strconv.Itoa(i))
+	}
+}
+
+func TestScanWhileShrinking(t *testing.T) {
+	d := New()
+	for i := 0; i < 5000; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	next := 100
+	seen := scanAll(d, func(int) {
+		for j := 0; j < 100 && next < 5000; j++ {
+			d.Delete(strconv.Itoa(next))
+			next++
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		assert.Contains(t, seen, strconv.Itoa(i))
+	}
+}
@@ -0,0 +1,326 @@
+// Package hash implements the hash value type: a map of fields to values.
+//
+// Small hashes are stored compactly as a slice of field/value pairs that is
+// searched linearly, like Redis's listpack encoding. Once a hash grows past
+// MaxCompactEntries fields or stores a value longer than MaxCompactValue bytes
+// it is converted to a hash table, and stays one.
+package hash
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/scnewma/godb/storage/dict"
+)
+
+const (
+	MaxCompactEntries = 128
+	MaxCompactValue   = 64
+)
+
+const (
+	EncodingCompact   = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+type entry struct {
+	field string
+	value []byte
+}
+
+// Hash is a map of fields to values. Fields can be given a deadline after
+// which they are deleted by DeleteExpired. A Hash is not safe for concurrent
+// use.
+type Hash struct {
+	// compact holds the fields until the hash is converted to table.
+	compact []entry
+	table   *dict.Dict
+
+	// expires holds the deadlines of fields, as unix milliseconds, and is
+	// nil until a field is given one. nextExpiry is the earliest of them.
+	expires    map[string]int64
+	nextExpiry int64
+}
+
+func New() *Hash {
+	return &Hash{}
+}
+
+// Encoding returns the name of the representation used for the hash.
+func (h *Hash) Encoding() string {
+	if h.table != nil {
+		return EncodingHashtable
+	}
+
+	return EncodingCompact
+}
+
+// Len returns the number of fields in the hash, not counting the ones whose
+// deadline has passed but that DeleteExpired hasn't deleted yet.
+func (h *Hash) Len() int {
+	n := len(h.compact)
+	if h.table != nil {
+		n = h.table.Len()
+	}
+
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	if h.expires == nil || ms < h.nextExpiry {
+		return n
+	}
+
+	for _, at := range h.expires {
+		if at <= ms {
+			n--
+		}
+	}
+
+	return n
+}
+
+// Get returns the value of field.
+func (h *Hash) Get(field string) ([]byte, bool) {
+	if h.table != nil {
+		val, ok := h.table.Get(field)
+		if !ok {
+			return nil, false
+		}
+		return val.([]byte), true
+	}
+
+	if i := h.index(field); i >= 0 {
+		return h.compact[i].value, true
+	}
+
+	return nil, false
+}
+
+// Set sets field to value, reporting whether the field is new. Any deadline
+// of the field is removed.
+func (h *Hash) Set(field string, value []byte) bool {
+	h.Persist(field)
+	return h.SetKeepExpiry(field, value)
+}
+
+// SetKeepExpiry is like Set but keeps the deadline of an existing field.
+func (h *Hash) SetKeepExpiry(field string, value []byte) bool {
+	if h.table == nil {
+		if i := h.index(field); i >= 0 {
+			h.compact[i].value = value
+			h.convertIfNeeded(value)
+			return false
+		}
+
+		h.compact = append(h.compact, entry{field: field, value: value})
+		h.convertIfNeeded(value)
+		return true
+	}
+
+	return h.table.Set(field, value)
+}
+
+// Delete removes field, reporting whether it existed.
+func (h *Hash) Delete(field string) bool {
+	h.Persist(field)
+
+	if h.table != nil {
+		_, ok := h.table.Delete(field)
+		return ok
+	}
+
+	i := h.index(field)
+	if i < 0 {
+		return false
+	}
+
+	last := len(h.compact) - 1
+	copy(h.compact[i:], h.compact[i+1:])
+	h.compact[last] = entry{}
+	h.compact = h.compact[:last]
+
+	return true
+}
+
+// Range calls fn for each field until fn returns false. The hash must not be
+// modified during the iteration.
+func (h *Hash) Range(fn func(field string, value []byte) bool) {
+	if h.table != nil {
+		h.table.Range(func(key string, val interface{}) bool {
+			return fn(key, val.([]byte))
+		})
+		return
+	}
+
+	for _, e := range h.compact {
+		if !fn(e.field, e.value) {
+			return
+		}
+	}
+}
+
+// Scan calls fn for a batch of fields starting at cursor and returns the
+// cursor to continue from, which is 0 once every field has been visited.
+// Compact hashes are always returned in a single batch.
+func (h *Hash) Scan(cursor uint64, fn func(field string, value []byte)) uint64 {
+	if h.table != nil {
+		return h.table.Scan(cursor, func(key string, val interface{}) {
+			fn(key, val.([]byte))
+		})
+	}
+
+	for _, e := range h.compact {
+		fn(e.field, e.value)
+	}
+
+	return 0
+}
+
+// Random returns a random field and its value. ok is false if the hash is
+// empty.
+func (h *Hash) Random() (field string, value []byte, ok bool) {
+	if h.table != nil {
+		key, val, ok := h.table.Random()
+		if !ok {
+			return "", nil, false
+		}
+		return key, val.([]byte), true
+	}
+
+	if len(h.compact) == 0 {
+		return "", nil, false
+	}
+
+	e := h.compact[rand.Intn(len(h.compact))]
+	return e.field, e.value, true
+}
+
+// Clone returns a copy of the hash, including the deadlines of its fields.
+// Values are shared with the original, which is safe since they are never
+// modified in place.
+func (h *Hash) Clone() *Hash {
+	clone := New()
+	h.cloneExpires(clone)
+	if h.table != nil {
+		clone.table = dict.New()
+		h.table.Range(func(key string, val interface{}) bool {
+			clone.table.Set(key, val)
+			return true
+		})
+		return clone
+	}
+
+	clone.compact = append([]entry(nil), h.compact...)
+	return clone
+}
+
+func (h *Hash) cloneExpires(clone *Hash) {
+	if h.expires == nil {
+		return
+	}
+
+	clone.expires = make(map[string]int64, len(h.expires))
+	for field, at := range h.expires {
+		clone.expires[field] = at
+	}
+	clone.nextExpiry = h.nextExpiry
+}
+
+// SetExpiry sets the deadline of field to at, in unix milliseconds. It
+// reports false if the field does not exist.
+func (h *Hash) SetExpiry(field string, at int64) bool {
+	if _, ok := h.Get(field); !ok {
+		return false
+	}
+
+	if h.expires == nil {
+		h.expires = make(map[string]int64)
+	}
+	h.expires[field] = at
+
+	if h.nextExpiry == 0 || at < h.nextExpiry {
+		h.nextExpiry = at
+	}
+
+	return true
+}
+
+// Expiry returns the deadline of field in unix milliseconds. ok is false if
+// the field has no deadline.
+func (h *Hash) Expiry(field string) (at int64, ok bool) {
+	at, ok = h.expires[field]
+	return at, ok
+}
+
+// Persist removes the deadline of field, reporting whether it had one.
+func (h *Hash) Persist(field string) bool {
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+
+	delete(h.expires, field)
+	if len(h.expires) == 0 {
+		h.expires = nil
+		h.nextExpiry = 0
+	}
+
+	// nextExpiry is left alone otherwise: it may now be earlier than any
+	// deadline, which only costs DeleteExpired a wasted pass
+
+	return true
+}
+
+// Expiring reports whether any field has a deadline.
+func (h *Hash) Expiring() bool {
+	return len(h.expires) > 0
+}
+
+// DeleteExpired deletes the fields whose deadline is at or before now and
+// returns how many were deleted.
+func (h *Hash) DeleteExpired(now time.Time) int {
+	ms := now.UnixNano() / int64(time.Millisecond)
+	if h.expires == nil || ms < h.nextExpiry {
+		return 0
+	}
+
+	var (
+		deleted int
+		next    int64
+	)
+	for field, at := range h.expires {
+		if at <= ms {
+			h.Delete(field)
+			deleted++
+			continue
+		}
+
+		if next == 0 || at < next {
+			next = at
+		}
+	}
+	h.nextExpiry = next
+
+	return deleted
+}
+
+func (h *Hash) index(field string) int {
+	for i := range h.compact {
+		if h.compact[i].field == field {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// convertIfNeeded switches to the hash table encoding once the hash has
+// outgrown the compact one.
+func (h *Hash) convertIfNeeded(value []byte) {
+	if len(h.compact) <= MaxCompactEntries && len(value) <= MaxCompactValue {
+		return
+	}
+
+	h.table = dict.New()
+	for _, e := range h.compact {
+		h.table.Set(e.field, e.value)
+	}
+	h.compact = nil
+}
@@ -0,0 +1,181 @@
+package hash
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetGetDelete(t *testing.T) {
+	h := New()
+
+	assert.True(t, h.Set("name", []byte("ada")))
+	assert.False(t, h.Set("name", []byte("grace")))
+	assert.True(t, h.Set("age", []byte("36")))
+	assert.Equal(t, 2, h.Len())
+
+	val, ok := h.Get("name")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("grace"), val)
+
+	assert.True(t, h.Delete("name"))
+	assert.False(t, h.Delete("name"))
+	_, ok = h.Get("name")
+	assert.False(t, ok)
+	assert.Equal(t, 1, h.Len())
+}
+
+func TestConvertsWhenTooManyFields(t *testing.T) {
+	h := New()
+	for i := 0; i < MaxCompactEntries; i++ {
+		h.Set(strconv.Itoa(i), []byte("v"))
+	}
+	assert.Equal(t, EncodingCompact, h.Encoding())
+
+	h.Set("one-more", []byte("v"))
+	assert.Equal(t, EncodingHashtable, h.Encoding())
+	assert.Equal(t, MaxCompactEntries+1, h.Len())
+
+	for i := 0; i < MaxCompactEntries; i++ {
+		_, ok := h.Get(strconv.Itoa(i))
+		require.True(t, ok)
+	}
+}
+
+func TestConvertsWhenValueTooLong(t *testing.T) {
+	h := New()
+	h.Set("a", []byte("short"))
+	h.Set("b", []byte(strings.Repeat("x", MaxCompactValue+1)))
+
+	assert.Equal(t, EncodingHashtable, h.Encoding())
+	val, _ := h.Get("a")
+	assert.Equal(t, []byte("short"), val)
+}
+
+func TestScanAndRange(t *testing.T) {
+	for _, n := range []int{10, 1000} {
+		h := New()
+		for i := 0; i < n; i++ {
+			h.Set(strconv.Itoa(i), []byte(strconv.Itoa(i)))
+		}
+
+		seen := make(map[string]bool)
+		cursor := uint64(0)
+		for {
+			cursor = h.Scan(cursor, func(field string, value []byte) {
+				assert.Equal(t, field, string(value))
+				seen[field] = true
+			})
+			if cursor == 0 {
+				break
+			}
+		}
+		assert.Equal(t, n, len(seen))
+
+		count := 0
+		h.Range(func(string, []byte) bool {
+			count++
+			return true
+		})
+		assert.Equal(t, n, count)
+	}
+}
+
+func TestRandomAndClone(t *testing.T) {
+	h := New()
+	_, _, ok := h.Random()
+	assert.False(t, ok)
+
+	h.Set("a", []byte("1"))
+	field, val, ok := h.Random()
+	assert.True(t, ok)
+	assert.Equal(t, "a", field)
+	assert.Equal(t, []byte("1"), val)
+
+	clone := h.Clone()
+	clone.Set("b", []byte("2"))
+	assert.Equal(t, 1, h.Len())
+	assert.Equal(t, 2, clone.Len())
+}
+
+func TestExpiry(t *testing.T) {
+	h := New()
+	h.Set("a", []byte("1"))
+	h.Set("b", []byte("2"))
+	h.Set("c", []byte("3"))
+	assert.False(t, h.Expiring())
+	assert.False(t, h.SetExpiry("missing", 1000))
+
+	// b expires after a, an hour from now
+	later := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+	assert.True(t, h.SetExpiry("a", 1000))
+	assert.True(t, h.SetExpiry("b", later))
+	assert.True(t, h.Expiring())
+
+	at, ok := h.Expiry("a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1000), at)
+	_, ok = h.Expiry("c")
+	assert.False(t, ok)
+
+	assert.Equal(t, 0, h.DeleteExpired(time.Unix(0, 999*int64(time.Millisecond))))
+	assert.Equal(t, 1, h.DeleteExpired(time.Unix(1, 0)))
+	_, ok = h.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 2, h.Len())
+
+	assert.True(t, h.Persist("b"))
+	assert.False(t, h.Persist("b"))
+	assert.False(t, h.Expiring())
+	assert.Equal(t, 0, h.DeleteExpired(time.Unix(10, 0)))
+	assert.Equal(t, 2, h.Len())
+}
+
+func TestLenSkipsExpiredFields(t *testing.T) {
+	h := New()
+	h.Set("a", []byte("1"))
+	h.Set("b", []byte("2"))
+	h.SetExpiry("a", 1000)
+	assert.Equal(t, 1, h.Len())
+
+	h.SetExpiry("b", 1000)
+	assert.Equal(t, 0, h.Len())
+	assert.Equal(t, 2, h.DeleteExpired(time.Now()))
+	assert.Equal(t, 0, h.Len())
+}
+
+func TestSetRemovesExpiry(t *testing.T) {
+	h := New()
+	h.Set("a", []byte("1"))
+	h.SetExpiry("a", 1000)
+
+	h.SetKeepExpiry("a", []byte("2"))
+	_, ok := h.Expiry("a")
+	assert.True(t, ok)
+
+	h.Set("a", []byte("3"))
+	_, ok = h.Expiry("a")
+	assert.False(t, ok)
+
+	h.SetExpiry("a", 1000)
+	h.Delete("a")
+	h.Set("a", []byte("4"))
+	_, ok = h.Expiry("a")
+	assert.False(t, ok)
+}
+
+func TestCloneCopiesExpiry(t *testing.T) {
+	h := New()
+	h.Set("a", []byte("1"))
+	h.SetExpiry("a", 1000)
+
+	clone := h.Clone()
+	clone.Persist("a")
+
+	_, ok := h.Expiry("a")
+	assert.True(t, ok)
+}
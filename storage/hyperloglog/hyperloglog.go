@@ -0,0 +1,419 @@
+// Package hyperloglog implements the HyperLogLog cardinality estimator used
+// by the PF* commands, in the same format as Redis so that it can be stored
+// as a plain string value.
+//
+// A HyperLogLog has 16384 6-bit registers and is laid out as a 16 byte
+// header followed by the registers in one of two encodings. The dense
+// encoding packs every register into 12288 bytes. The sparse encoding
+// run-length encodes the registers with three opcodes and is used until the
+// HyperLogLog grows past SparseMaxBytes or a register holds a value too
+// large for it:
+//
+//	ZERO  00xxxxxx           xxxxxx+1 registers set to 0, up to 64
+//	XZERO 01xxxxxx yyyyyyyy  xxxxxxyyyyyyyy+1 registers set to 0, up to 16384
+//	VAL   1vvvvvxx           xx+1 registers set to vvvvv+1, up to 4 and 32
+//
+// The header holds the magic "HYLL", the encoding, three unused bytes and the
+// last computed cardinality as a little-endian uint64 whose most significant
+// bit is set when it is stale.
+package hyperloglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	precision = 14
+	// Registers is the number of registers of a HyperLogLog.
+	Registers = 1 << precision
+	bits      = 6
+	// q is the number of hash bits used to count the run of zeros.
+	q = 64 - precision
+
+	headerSize = 16
+	denseSize  = headerSize + (Registers*bits+7)/8
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	sparseValMax      = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+
+	alphaInf = 0.721347520444481703680
+)
+
+// SparseMaxBytes is the size past which a sparse HyperLogLog is converted to
+// the dense encoding, matching Redis's default hll-sparse-max-bytes.
+const SparseMaxBytes = 3000
+
+var (
+	// ErrInvalid is returned when parsing a value that isn't a
+	// HyperLogLog.
+	ErrInvalid = errors.New("not a valid HyperLogLog string value")
+	// ErrCorrupted is returned when parsing a HyperLogLog whose registers
+	// can't be decoded.
+	ErrCorrupted = errors.New("corrupted HyperLogLog")
+)
+
+// HLL is a HyperLogLog. Its methods modify the bytes it was parsed from in
+// place where they can; Bytes returns the current representation.
+type HLL struct {
+	buf []byte
+}
+
+// New returns an empty HyperLogLog in the sparse encoding.
+func New() *HLL {
+	buf := make([]byte, headerSize, headerSize+2)
+	copy(buf, "HYLL")
+	buf[4] = encodingSparse
+
+	h := &HLL{buf: buf}
+	h.buf = append(h.buf, encodeSparse([]run{{value: 0, length: Registers}})...)
+
+	return h
+}
+
+// Parse checks that b holds a HyperLogLog and returns it.
+func Parse(b []byte) (*HLL, error) {
+	if len(b) < headerSize || string(b[:4]) != "HYLL" {
+		return nil, ErrInvalid
+	}
+
+	switch b[4] {
+	case encodingDense:
+		if len(b) != denseSize {
+			return nil, ErrInvalid
+		}
+	case encodingSparse:
+		if _, ok := decodeSparse(b[headerSize:]); !ok {
+			return nil, ErrCorrupted
+		}
+	default:
+		return nil, ErrInvalid
+	}
+
+	return &HLL{buf: b}, nil
+}
+
+// Bytes returns the HyperLogLog's representation.
+func (h *HLL) Bytes() []byte {
+	return h.buf
+}
+
+// Dense reports whether the HyperLogLog uses the dense encoding.
+func (h *HLL) Dense() bool {
+	return h.buf[4] == encodingDense
+}
+
+// Add adds elements to the HyperLogLog, reporting whether any register was
+// updated, in which case the estimated cardinality may have changed.
+func (h *HLL) Add(elements ...[]byte) bool {
+	var (
+		updated bool
+		runs    []run
+	)
+	if !h.Dense() {
+		runs, _ = decodeSparse(h.buf[headerSize:])
+	}
+
+	for _, element := range elements {
+		index, count := hashElement(element)
+		if runs != nil {
+			if count > sparseValMax {
+				h.promote(runs)
+				runs = nil
+			} else {
+				var ok bool
+				runs, ok = setSparse(runs, index, count)
+				updated = updated || ok
+
+				// every run takes at least a byte to encode
+				if headerSize+len(runs) > SparseMaxBytes {
+					h.promote(runs)
+					runs = nil
+				}
+				continue
+			}
+		}
+
+		if count > denseGet(h.registers(), index) {
+			denseSet(h.registers(), index, count)
+			updated = true
+		}
+	}
+
+	if runs != nil && updated {
+		h.storeSparse(runs)
+	}
+
+	if updated {
+		h.invalidateCache()
+	}
+
+	return updated
+}
+
+// Count returns the estimated cardinality, computing it again only if the
+// registers changed since it was last computed.
+func (h *HLL) Count() uint64 {
+	if card := binary.LittleEndian.Uint64(h.buf[8:]); card&(1<<63) == 0 {
+		return card
+	}
+
+	card := estimate(h.histogram())
+	binary.LittleEndian.PutUint64(h.buf[8:], card)
+
+	return card
+}
+
+// Merge sets each register to the largest of its value and the value of the
+// same register of other.
+func (h *HLL) Merge(other *HLL) {
+	regs := h.Registers()
+	for i, v := range other.Registers() {
+		if v > regs[i] {
+			regs[i] = v
+		}
+	}
+
+	h.SetRegisters(regs, h.Dense() || other.Dense())
+}
+
+// Registers returns the value of every register.
+func (h *HLL) Registers() []uint8 {
+	regs := make([]uint8, Registers)
+	if h.Dense() {
+		dense := h.registers()
+		for i := range regs {
+			regs[i] = denseGet(dense, i)
+		}
+		return regs
+	}
+
+	runs, _ := decodeSparse(h.buf[headerSize:])
+	i := 0
+	for _, r := range runs {
+		for end := i + r.length; i < end; i++ {
+			regs[i] = r.value
+		}
+	}
+
+	return regs
+}
+
+// SetRegisters sets the value of every register, using the sparse encoding
+// unless dense is set or the registers don't fit it.
+func (h *HLL) SetRegisters(regs []uint8, dense bool) {
+	h.invalidateCache()
+
+	if !dense {
+		var runs []run
+		for _, v := range regs {
+			if v > sparseValMax {
+				dense = true
+				break
+			}
+			runs = appendRun(runs, run{value: v, length: 1})
+		}
+
+		if !dense && h.storeSparse(runs) {
+			return
+		}
+	}
+
+	h.buf = append(h.buf[:headerSize], make([]byte, denseSize-headerSize)...)
+	h.buf[4] = encodingDense
+	for i, v := range regs {
+		denseSet(h.registers(), i, v)
+	}
+}
+
+func (h *HLL) invalidateCache() {
+	h.buf[15] |= 0x80
+}
+
+// registers returns the dense registers.
+func (h *HLL) registers() []byte {
+	return h.buf[headerSize:]
+}
+
+// storeSparse stores runs in the sparse encoding, or in the dense one if
+// the sparse encoding would be larger than SparseMaxBytes. It reports whether
+// the sparse encoding was used.
+func (h *HLL) storeSparse(runs []run) bool {
+	sparse := encodeSparse(runs)
+	if headerSize+len(sparse) > SparseMaxBytes {
+		h.promote(runs)
+		return false
+	}
+
+	h.buf = append(h.buf[:headerSize], sparse...)
+	return true
+}
+
+// promote converts the HyperLogLog to the dense encoding, given its
+// registers as runs.
+func (h *HLL) promote(runs []run) {
+	h.buf = append(h.buf[:headerSize], make([]byte, denseSize-headerSize)...)
+	h.buf[4] = encodingDense
+
+	dense := h.registers()
+	i := 0
+	for _, r := range runs {
+		for end := i + r.length; i < end; i++ {
+			denseSet(dense, i, r.value)
+		}
+	}
+}
+
+// histogram counts the registers holding each value.
+func (h *HLL) histogram() *[64]int {
+	var histo [64]int
+	if h.Dense() {
+		dense := h.registers()
+		for i := 0; i < Registers; i++ {
+			histo[denseGet(dense, i)]++
+		}
+		return &histo
+	}
+
+	runs, _ := decodeSparse(h.buf[headerSize:])
+	for _, r := range runs {
+		histo[r.value] += r.length
+	}
+
+	return &histo
+}
+
+// hashElement hashes element and returns the register it maps to, along
+// with the length of the run of zeros in the rest of the hash plus one,
+// which is the value the register is raised to.
+func hashElement(element []byte) (index int, count uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index = int(hash & (Registers - 1))
+
+	// the bit past the used ones ensures the loop terminates
+	hash >>= precision
+	hash |= 1 << q
+	count = 1
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+
+	return index, count
+}
+
+// murmurHash64A is MurmurHash2's 64-bit variant, as used by Redis.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+
+	h := seed ^ (uint64(len(key)) * m)
+	for ; len(key) >= 8; key = key[8:] {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+	}
+
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+
+	return h
+}
+
+func denseGet(regs []byte, index int) uint8 {
+	bit := index * bits
+	b, shift := bit/8, uint(bit&7)
+
+	v := uint16(regs[b])
+	if b+1 < len(regs) {
+		v |= uint16(regs[b+1]) << 8
+	}
+
+	return uint8(v>>shift) & (1<<bits - 1)
+}
+
+func denseSet(regs []byte, index int, value uint8) {
+	bit := index * bits
+	b, shift := bit/8, uint(bit&7)
+
+	v := uint16(regs[b])
+	if b+1 < len(regs) {
+		v |= uint16(regs[b+1]) << 8
+	}
+
+	v &^= (1<<bits - 1) << shift
+	v |= uint16(value) << shift
+
+	regs[b] = byte(v)
+	if b+1 < len(regs) {
+		regs[b+1] = byte(v >> 8)
+	}
+}
+
+// estimate returns the cardinality estimated from the register histogram,
+// using the improved estimator by Otmar Ertl that Redis uses.
+func estimate(histo *[64]int) uint64 {
+	const m = float64(Registers)
+
+	z := m * tau((m-float64(histo[q+1]))/m)
+	for j := q; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histo[0])/m)
+
+	return uint64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
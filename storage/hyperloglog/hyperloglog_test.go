@@ -0,0 +1,181 @@
+package hyperloglog
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func elements(prefix string, n int) [][]byte {
+	elems := make([][]byte, n)
+	for i := range elems {
+		elems[i] = []byte(prefix + strconv.Itoa(i))
+	}
+	return elems
+}
+
+func TestNewIsEmptyAndSparse(t *testing.T) {
+	h := New()
+	assert.False(t, h.Dense())
+	assert.Equal(t, uint64(0), h.Count())
+	assert.Len(t, h.Bytes(), headerSize+2)
+
+	parsed, err := Parse(h.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), parsed.Count())
+}
+
+func TestAddAndCountSmallSets(t *testing.T) {
+	h := New()
+	assert.True(t, h.Add([]byte("a"), []byte("b"), []byte("c")))
+	assert.False(t, h.Add([]byte("a")))
+	assert.Equal(t, uint64(3), h.Count())
+
+	for i := 0; i < 100; i++ {
+		h.Add([]byte(strconv.Itoa(i)))
+	}
+	assert.InDelta(t, 103, float64(h.Count()), 2)
+	assert.False(t, h.Dense())
+}
+
+func TestCountIsAccurate(t *testing.T) {
+	for _, n := range []int{1000, 10000, 100000} {
+		h := New()
+		h.Add(elements("e", n)...)
+
+		// the standard error with 16384 registers is 0.81%
+		assert.InEpsilon(t, n, float64(h.Count()), 0.03, "n=%d", n)
+	}
+}
+
+func TestPromotesToDense(t *testing.T) {
+	h := New()
+	h.Add(elements("e", 5000)...)
+
+	assert.True(t, h.Dense())
+	assert.Len(t, h.Bytes(), denseSize)
+}
+
+func TestSparseAndDenseAgree(t *testing.T) {
+	sparse := New()
+	sparse.Add(elements("e", 500)...)
+	require.False(t, sparse.Dense())
+
+	dense := New()
+	dense.SetRegisters(sparse.Registers(), true)
+	require.True(t, dense.Dense())
+
+	assert.Equal(t, sparse.Registers(), dense.Registers())
+	assert.Equal(t, sparse.Count(), dense.Count())
+
+	// adding the same elements to either encoding changes nothing
+	assert.False(t, sparse.Add(elements("e", 500)...))
+	assert.False(t, dense.Add(elements("e", 500)...))
+}
+
+func TestCountIsCached(t *testing.T) {
+	h := New()
+	h.Add([]byte("a"))
+	assert.Equal(t, uint64(1), h.Count())
+	assert.Equal(t, byte(0), h.Bytes()[15]&0x80)
+
+	h.Add([]byte("b"))
+	assert.Equal(t, byte(0x80), h.Bytes()[15]&0x80)
+	assert.Equal(t, uint64(2), h.Count())
+}
+
+func TestMerge(t *testing.T) {
+	a := New()
+	a.Add(elements("a", 3000)...)
+	b := New()
+	b.Add(elements("b", 50)...)
+	b.Add(elements("a", 1000)...)
+
+	b.Merge(a)
+	assert.True(t, b.Dense())
+	assert.InEpsilon(t, 3050, float64(b.Count()), 0.03)
+
+	c := New()
+	c.Add(elements("c", 10)...)
+	d := New()
+	d.Add(elements("d", 10)...)
+	c.Merge(d)
+	assert.False(t, c.Dense())
+	assert.Equal(t, uint64(20), c.Count())
+}
+
+func TestParseRejectsInvalidValues(t *testing.T) {
+	_, err := Parse([]byte("hello"))
+	assert.Equal(t, ErrInvalid, err)
+
+	b := append([]byte(nil), New().Bytes()...)
+	b[4] = 7
+	_, err = Parse(b)
+	assert.Equal(t, ErrInvalid, err)
+
+	// the opcodes must describe every register exactly once
+	b = append([]byte(nil), New().Bytes()...)
+	b = append(b, 0x00)
+	_, err = Parse(b)
+	assert.Equal(t, ErrCorrupted, err)
+}
+
+func TestDenseRegisters(t *testing.T) {
+	regs := make([]byte, denseSize-headerSize)
+	for i := 0; i < Registers; i++ {
+		denseSet(regs, i, uint8(i%64))
+	}
+	for i := 0; i < Registers; i++ {
+		require.Equal(t, uint8(i%64), denseGet(regs, i))
+	}
+}
+
+func TestSparseEncodingRoundTrips(t *testing.T) {
+	runs := []run{{0, 100}, {3, 9}, {0, 16000}, {32, 1}, {0, Registers - 16110}}
+	decoded, ok := decodeSparse(encodeSparse(runs))
+	assert.True(t, ok)
+	assert.Equal(t, runs, decoded)
+}
+
+func TestEstimateOfEmptyAndFullRegisters(t *testing.T) {
+	var histo [64]int
+	histo[0] = Registers
+	assert.Equal(t, uint64(0), estimate(&histo))
+
+	histo[0], histo[q+1] = 0, Registers
+	assert.False(t, math.IsNaN(float64(estimate(&histo))))
+}
+
+func TestSetSparseMatchesModel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	runs := []run{{0, Registers}}
+	model := make([]uint8, Registers)
+
+	for i := 0; i < 2000; i++ {
+		index, value := r.Intn(Registers), uint8(r.Intn(sparseValMax)+1)
+		var ok bool
+		runs, ok = setSparse(runs, index, value)
+		assert.Equal(t, value > model[index], ok)
+		if ok {
+			model[index] = value
+		}
+	}
+
+	decoded, ok := decodeSparse(encodeSparse(runs))
+	require.True(t, ok)
+
+	var regs []uint8
+	for i, r := range decoded {
+		if i > 0 {
+			require.NotEqual(t, decoded[i-1].value, r.value, "adjacent runs should be merged")
+		}
+		for j := 0; j < r.length; j++ {
+			regs = append(regs, r.value)
+		}
+	}
+	assert.Equal(t, model, regs)
+}
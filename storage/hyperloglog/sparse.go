@@ -0,0 +1,123 @@
+package hyperloglog
+
+import "slices"
+
+// run is a sequence of consecutive registers holding the same value.
+type run struct {
+	value  uint8
+	length int
+}
+
+// appendRun appends r to runs, extending the last run if it holds the same
+// value.
+func appendRun(runs []run, r run) []run {
+	if r.length == 0 {
+		return runs
+	}
+
+	if n := len(runs); n > 0 && runs[n-1].value == r.value {
+		runs[n-1].length += r.length
+		return runs
+	}
+
+	return append(runs, r)
+}
+
+// decodeSparse decodes the opcodes of the sparse encoding into runs. ok is
+// false unless they describe exactly Registers registers.
+func decodeSparse(b []byte) (runs []run, ok bool) {
+	total := 0
+	for i := 0; i < len(b); i++ {
+		var r run
+		switch op := b[i]; {
+		case op&0xc0 == 0x00: // ZERO
+			r.length = int(op&0x3f) + 1
+		case op&0xc0 == 0x40: // XZERO
+			if i+1 == len(b) {
+				return nil, false
+			}
+			i++
+			r.length = (int(op&0x3f)<<8 | int(b[i])) + 1
+		default: // VAL
+			r.value = (op>>2)&0x1f + 1
+			r.length = int(op&0x03) + 1
+		}
+
+		total += r.length
+		if total > Registers {
+			return nil, false
+		}
+		runs = appendRun(runs, r)
+	}
+
+	return runs, total == Registers
+}
+
+// encodeSparse encodes runs with the opcodes of the sparse encoding. Every
+// value must be at most sparseValMax.
+func encodeSparse(runs []run) []byte {
+	var b []byte
+	for _, r := range runs {
+		for n := r.length; n > 0; {
+			switch {
+			case r.value > 0:
+				l := minInt(n, sparseValMaxLen)
+				b = append(b, 0x80|(r.value-1)<<2|byte(l-1))
+				n -= l
+			case n > sparseZeroMaxLen:
+				l := minInt(n, sparseXZeroMaxLen) - 1
+				b = append(b, 0x40|byte(l>>8), byte(l))
+				n -= l + 1
+			default:
+				b = append(b, byte(n-1))
+				n = 0
+			}
+		}
+	}
+
+	return b
+}
+
+// setSparse raises the register at index to value, splitting the run
+// holding it. ok is false if the register already held value or more.
+func setSparse(runs []run, index int, value uint8) (updated []run, ok bool) {
+	i, start := 0, 0
+	for ; start+runs[i].length <= index; i++ {
+		start += runs[i].length
+	}
+
+	r := runs[i]
+	if r.value >= value {
+		return runs, false
+	}
+
+	// the run is split around the register, and the pieces are merged with
+	// the neighbouring runs where they hold the same value
+	lo, hi := i, i+1
+	if lo > 0 {
+		lo--
+	}
+	if hi < len(runs) {
+		hi++
+	}
+
+	var pieces []run
+	if lo < i {
+		pieces = appendRun(pieces, runs[lo])
+	}
+	pieces = appendRun(pieces, run{value: r.value, length: index - start})
+	pieces = appendRun(pieces, run{value: value, length: 1})
+	pieces = appendRun(pieces, run{value: r.value, length: start + r.length - index - 1})
+	if hi > i+1 {
+		pieces = appendRun(pieces, runs[i+1])
+	}
+
+	return slices.Replace(runs, lo, hi, pieces...), true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -2,36 +2,63 @@ package inmem
 
 import (
 	"sync"
+	"time"
 
 	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/dict"
+)
+
+const (
+	// expireCycleKeys is how many volatile values are checked by each round
+	// of an expire cycle.
+	expireCycleKeys = 20
+
+	// expireCycleBudget bounds how long an expire cycle keeps going while it
+	// finds plenty of expired elements.
+	expireCycleBudget = 25 * time.Millisecond
 )
 
 func NewStorage() *database {
-	return &database{data: make(map[string]*node, 1024)}
+	return &database{
+		data:     dict.New(),
+		volatile: make(map[string]struct{}),
+	}
 }
 
 type database struct {
 	sync.RWMutex
 
-	data map[string]*node
+	// data maps keys to *node. It is a dict rather than a map so that SCAN
+	// can resume iterating after the keyspace was modified.
+	data *dict.Dict
+
+	// volatile holds the keys whose values have elements with deadlines,
+	// which the expire cycle visits.
+	volatile map[string]struct{}
+	// onExpire is called with the keys whose values lost elements to
+	// expiry, if set.
+	onExpire func(key string)
 }
 
+// Get takes the write lock: lookups move entries along while the dict is
+// rehashing.
 func (db *database) Get(key string) (storage.Node, error) {
-	db.RLock()
+	db.Lock()
+	defer db.Unlock()
 
-	n, ok := db.data[key]
-	db.RUnlock()
+	return db.get(key)
+}
 
-	if !ok {
-		return nil, storage.ErrKeyNotFound
-	}
+func (db *database) Peek(key string) (storage.Node, error) {
+	db.Lock()
+	defer db.Unlock()
 
-	return n, nil
+	return db.peek(key)
 }
 
 func (db *database) Set(key string, n storage.Node) {
 	db.Lock()
-	db.data[key] = newNode(n)
+	db.set(key, n)
 	db.Unlock()
 }
 
@@ -39,21 +66,295 @@ func (db *database) Del(key string) int {
 	db.Lock()
 	defer db.Unlock()
 
-	_, ok := db.data[key]
-	if ok {
-		delete(db.data, key)
+	return db.del(key)
+}
+
+func (db *database) Scan(cursor uint64, fn func(key string, n storage.Node)) uint64 {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.scan(cursor, fn)
+}
+
+func (db *database) Len() int {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.len()
+}
+
+func (db *database) RandomKey() (string, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.randomKey()
+}
+
+func (db *database) Flush() {
+	db.Lock()
+	db.flush()
+	db.Unlock()
+}
+
+func (db *database) Idle(key string) (time.Duration, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.idle(key)
+}
+
+func (db *database) NotifyExpired(fn func(key string)) {
+	db.Lock()
+	db.onExpire = fn
+	db.Unlock()
+}
+
+func (db *database) Atomic(fn func(storage.Storage)) {
+	db.Lock()
+	defer db.Unlock()
+
+	fn(&tx{db: db})
+}
+
+func (db *database) get(key string) (storage.Node, error) {
+	n, ok := db.lookup(key)
+	if !ok {
+		return nil, storage.ErrKeyNotFound
+	}
+	n.accessed = time.Now()
+
+	return n, nil
+}
+
+func (db *database) peek(key string) (storage.Node, error) {
+	n, ok := db.lookup(key)
+	if !ok {
+		return nil, storage.ErrKeyNotFound
+	}
+
+	return n, nil
+}
+
+// lookup returns the node stored at key without counting as an access. The
+// expired elements of a volatile value are deleted first, along with the key
+// if none remain, as Redis does on reads.
+func (db *database) lookup(key string) (*node, bool) {
+	val, ok := db.data.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	n := val.(*node)
+	if _, ok := db.volatile[key]; ok {
+		if _, deleted := db.expire(key, n, time.Now()); deleted {
+			return nil, false
+		}
+	}
+
+	return n, true
+}
+
+// expire deletes the expired elements of the volatile value of n, stored at
+// key, and the key if none remain. It returns how many elements were deleted
+// and reports whether the key was.
+func (db *database) expire(key string, n *node, now time.Time) (int, bool) {
+	v, ok := n.Value().(storage.Volatile)
+	if !ok {
+		delete(db.volatile, key)
+		return 0, false
+	}
+
+	deleted, gone := v.DeleteExpired(now), v.Len() == 0
+	if gone {
+		db.del(key)
+	} else if !v.Expiring() {
+		delete(db.volatile, key)
+	}
+
+	if (deleted > 0 || gone) && db.onExpire != nil {
+		db.onExpire(key)
+	}
+
+	return deleted, gone
+}
+
+// hollow reports whether n holds a volatile value whose elements have all
+// expired, which is deleted once read.
+func hollow(n *node) bool {
+	v, ok := n.Value().(storage.Volatile)
+	return ok && v.Expiring() && v.Len() == 0
+}
+
+func (db *database) set(key string, n storage.Node) {
+	// nodes read from the storage are stored again as is, e.g. by RENAME
+	if wrapped, ok := n.(*node); ok {
+		n = wrapped.Node
+	}
+	db.data.Set(key, newNode(n))
+
+	if v, ok := n.Value().(storage.Volatile); ok && v.Expiring() {
+		db.volatile[key] = struct{}{}
+	} else {
+		delete(db.volatile, key)
+	}
+}
+
+func (db *database) del(key string) int {
+	if _, ok := db.data.Delete(key); ok {
+		delete(db.volatile, key)
 		return 1
 	}
 
 	return 0
 }
 
+// randomKey picks keys at random until it finds one that isn't hollow,
+// deleting the hollow ones along the way.
+func (db *database) randomKey() (string, error) {
+	for {
+		key, _, ok := db.data.Random()
+		if !ok {
+			return "", storage.ErrKeyNotFound
+		}
+
+		if _, ok := db.lookup(key); ok {
+			return key, nil
+		}
+	}
+}
+
+// len counts the keys, leaving out the hollow ones.
+func (db *database) len() int {
+	n := db.data.Len()
+	for key := range db.volatile {
+		if val, ok := db.data.Get(key); ok && hollow(val.(*node)) {
+			n--
+		}
+	}
+
+	return n
+}
+
+func (db *database) flush() {
+	db.data.Clear()
+	db.volatile = make(map[string]struct{})
+}
+
+func (db *database) idle(key string) (time.Duration, error) {
+	n, ok := db.lookup(key)
+	if !ok {
+		return 0, storage.ErrKeyNotFound
+	}
+
+	return time.Since(n.accessed), nil
+}
+
+// scan skips hollow keys, which it can't delete while iterating.
+func (db *database) scan(cursor uint64, fn func(key string, n storage.Node)) uint64 {
+	return db.data.Scan(cursor, func(key string, val interface{}) {
+		if n := val.(*node); !hollow(n) {
+			fn(key, n)
+		}
+	})
+}
+
+// DeleteExpired runs an expire cycle, deleting the expired elements of a
+// sample of the volatile values and returning how many were deleted. Like
+// Redis's active expiry, it keeps sampling while more than a quarter of the
+// sampled values had expired elements, for at most expireCycleBudget.
+func (db *database) DeleteExpired(now time.Time) int {
+	start := time.Now()
+
+	var deleted int
+	for {
+		n, sampled, expired := db.expireRound(now)
+		deleted += n
+
+		if sampled == 0 || expired*4 <= sampled || time.Since(start) > expireCycleBudget {
+			return deleted
+		}
+	}
+}
+
+// expireRound deletes the expired elements of up to expireCycleKeys volatile
+// values. Map iteration order is random, which makes the sample fair.
+func (db *database) expireRound(now time.Time) (deleted, sampled, expired int) {
+	db.Lock()
+	defer db.Unlock()
+
+	for key := range db.volatile {
+		if sampled == expireCycleKeys {
+			break
+		}
+		sampled++
+
+		val, _ := db.data.Get(key)
+		if n, _ := db.expire(key, val.(*node), now); n > 0 {
+			deleted += n
+			expired++
+		}
+	}
+
+	return deleted, sampled, expired
+}
+
+// tx is the view of a database handed to Atomic callbacks. The database lock
+// is already held, so its methods access the data directly.
+type tx struct {
+	db *database
+}
+
+func (t *tx) Get(key string) (storage.Node, error) {
+	return t.db.get(key)
+}
+
+func (t *tx) Peek(key string) (storage.Node, error) {
+	return t.db.peek(key)
+}
+
+func (t *tx) Set(key string, n storage.Node) {
+	t.db.set(key, n)
+}
+
+func (t *tx) Del(key string) int {
+	return t.db.del(key)
+}
+
+func (t *tx) Scan(cursor uint64, fn func(key string, n storage.Node)) uint64 {
+	return t.db.scan(cursor, fn)
+}
+
+func (t *tx) Len() int {
+	return t.db.len()
+}
+
+func (t *tx) RandomKey() (string, error) {
+	return t.db.randomKey()
+}
+
+func (t *tx) Flush() {
+	t.db.flush()
+}
+
+func (t *tx) Idle(key string) (time.Duration, error) {
+	return t.db.idle(key)
+}
+
+// Atomic runs fn directly since the transaction already holds the lock.
+func (t *tx) Atomic(fn func(storage.Storage)) {
+	fn(t)
+}
+
 type node struct {
 	sync.RWMutex
 
 	storage.Node
+
+	// accessed is when the node was last read or written, for OBJECT
+	// IDLETIME.
+	accessed time.Time
 }
 
 func newNode(n storage.Node) *node {
-	return &node{Node: n}
+	return &node{Node: n, accessed: time.Now()}
 }
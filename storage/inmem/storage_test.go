@@ -1,9 +1,13 @@
 package inmem
 
 import (
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/scnewma/godb/storage"
+	"github.com/scnewma/godb/storage/hash"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -46,3 +50,226 @@ func TestDelDoesNotExist(t *testing.T) {
 
 	assert.Equal(t, 0, db.Del("test"))
 }
+
+func TestAtomic(t *testing.T) {
+	db := NewStorage()
+	db.Set("a", storage.NewStringNode("1"))
+
+	db.Atomic(func(tx storage.Storage) {
+		_, err := tx.Get("a")
+		require.NoError(t, err)
+
+		tx.Set("b", storage.NewStringNode("2"))
+		assert.Equal(t, 1, tx.Del("a"))
+
+		// nested calls must not deadlock
+		tx.Atomic(func(tx storage.Storage) {
+			tx.Set("c", storage.NewStringNode("3"))
+		})
+	})
+
+	_, err := db.Get("a")
+	assert.Equal(t, storage.ErrKeyNotFound, err)
+
+	n, err := db.Get("c")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("3"), n.Value())
+}
+
+func TestAtomicExcludesOtherWriters(t *testing.T) {
+	db := NewStorage()
+	db.Set("n", storage.NewNode([]byte{0}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Atomic(func(tx storage.Storage) {
+				n, _ := tx.Get("n")
+				v := n.Value().([]byte)[0]
+				tx.Set("n", storage.NewNode([]byte{v + 1}))
+			})
+		}()
+	}
+	wg.Wait()
+
+	n, err := db.Get("n")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{50}, n.Value())
+}
+
+func TestDeleteExpired(t *testing.T) {
+	db := NewStorage()
+
+	now := time.Now()
+	past := now.Add(-time.Second).UnixNano() / int64(time.Millisecond)
+	future := now.Add(time.Hour).UnixNano() / int64(time.Millisecond)
+
+	expiring := hash.New()
+	expiring.Set("a", []byte("1"))
+	expiring.Set("b", []byte("2"))
+	expiring.SetExpiry("a", past)
+	db.Set("expiring", storage.NewHashNode(expiring))
+
+	emptied := hash.New()
+	emptied.Set("a", []byte("1"))
+	emptied.SetExpiry("a", past)
+	db.Set("emptied", storage.NewHashNode(emptied))
+
+	later := hash.New()
+	later.Set("a", []byte("1"))
+	later.SetExpiry("a", future)
+	db.Set("later", storage.NewHashNode(later))
+
+	assert.Equal(t, 2, db.DeleteExpired(now))
+	assert.Equal(t, 0, db.DeleteExpired(now))
+
+	_, err := db.Get("emptied")
+	assert.Equal(t, storage.ErrKeyNotFound, err)
+	assert.Equal(t, 1, expiring.Len())
+	assert.Equal(t, 1, later.Len())
+
+	// values without expiring elements are no longer visited
+	assert.NotContains(t, db.volatile, "expiring")
+	assert.Contains(t, db.volatile, "later")
+}
+
+func TestReadsDeleteExpiredElements(t *testing.T) {
+	db := NewStorage()
+
+	h := hash.New()
+	h.Set("a", []byte("1"))
+	h.Set("b", []byte("2"))
+	h.SetExpiry("a", 1000)
+	db.Set("partly", storage.NewHashNode(h))
+
+	hollow := hash.New()
+	hollow.Set("a", []byte("1"))
+	hollow.SetExpiry("a", 1000)
+	db.Set("hollow", storage.NewHashNode(hollow))
+	db.Set("string", storage.NewStringNode("x"))
+
+	// keys whose elements all expired are hidden before they are deleted
+	assert.Equal(t, 2, db.Len())
+	var keys []string
+	collect := func(key string, _ storage.Node) { keys = append(keys, key) }
+	for cursor := db.Scan(0, collect); cursor != 0; cursor = db.Scan(cursor, collect) {
+	}
+	assert.ElementsMatch(t, []string{"partly", "string"}, keys)
+
+	_, err := db.Idle("hollow")
+	assert.Equal(t, storage.ErrKeyNotFound, err)
+	assert.Equal(t, 2, db.data.Len())
+
+	_, err = db.Get("partly")
+	require.NoError(t, err)
+	_, ok := h.Get("a")
+	assert.False(t, ok)
+	assert.NotContains(t, db.volatile, "partly")
+}
+
+func TestDeleteExpiredVisitsEveryValue(t *testing.T) {
+	db := NewStorage()
+
+	for i := 0; i < 10*expireCycleKeys; i++ {
+		h := hash.New()
+		h.Set("a", []byte("1"))
+		h.SetExpiry("a", 1000)
+		db.Set(strconv.Itoa(i), storage.NewHashNode(h))
+	}
+
+	// every sampled value has expired elements, so the cycle keeps going
+	assert.Equal(t, 10*expireCycleKeys, db.DeleteExpired(time.Unix(2, 0)))
+	assert.Zero(t, db.data.Len())
+	assert.Empty(t, db.volatile)
+}
+
+func TestOverwritingForgetsVolatileValue(t *testing.T) {
+	db := NewStorage()
+
+	h := hash.New()
+	h.Set("a", []byte("1"))
+	h.SetExpiry("a", 1000)
+	db.Set("key", storage.NewHashNode(h))
+	require.Contains(t, db.volatile, "key")
+
+	db.Set("key", storage.NewStringNode("value"))
+	assert.NotContains(t, db.volatile, "key")
+
+	db.Set("key", storage.NewHashNode(h))
+	db.Del("key")
+	assert.NotContains(t, db.volatile, "key")
+}
+
+func TestScan(t *testing.T) {
+	db := NewStorage()
+	for i := 0; i < 100; i++ {
+		db.Set(strconv.Itoa(i), storage.NewStringNode("x"))
+	}
+
+	seen := make(map[string]bool)
+	cursor := uint64(0)
+	for {
+		cursor = db.Scan(cursor, func(key string, n storage.Node) {
+			assert.Equal(t, []byte("x"), n.Value())
+			seen[key] = true
+		})
+		if cursor == 0 {
+			break
+		}
+
+		// growing the keyspace between calls doesn't make the scan skip keys
+		db.Set("new"+strconv.FormatUint(cursor, 10), storage.NewStringNode("x"))
+	}
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, seen[strconv.Itoa(i)], i)
+	}
+}
+
+func TestLenRandomKeyAndFlush(t *testing.T) {
+	db := NewStorage()
+	_, err := db.RandomKey()
+	assert.Equal(t, storage.ErrKeyNotFound, err)
+
+	db.Set("a", storage.NewStringNode("1"))
+	db.Set("b", storage.NewStringNode("2"))
+	assert.Equal(t, 2, db.Len())
+
+	key, err := db.RandomKey()
+	require.NoError(t, err)
+	assert.Contains(t, []string{"a", "b"}, key)
+
+	db.Flush()
+	assert.Equal(t, 0, db.Len())
+	_, err = db.Get("a")
+	assert.Equal(t, storage.ErrKeyNotFound, err)
+}
+
+func TestIdle(t *testing.T) {
+	db := NewStorage()
+	_, err := db.Idle("a")
+	assert.Equal(t, storage.ErrKeyNotFound, err)
+
+	db.Set("a", storage.NewStringNode("1"))
+	n, err := db.Get("a")
+	require.NoError(t, err)
+	n.(*node).accessed = time.Now().Add(-time.Minute)
+
+	idle, err := db.Idle("a")
+	require.NoError(t, err)
+	assert.True(t, idle >= time.Minute)
+
+	// reading the key makes it recently accessed again
+	db.Get("a")
+	idle, _ = db.Idle("a")
+	assert.True(t, idle < time.Minute)
+
+	// nodes read from the storage can be stored again under another key
+	db.Set("b", n)
+	m, err := db.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), m.Value())
+	assert.False(t, m.(*node).Node == n)
+}
@@ -0,0 +1,194 @@
+// Package intset implements a sorted set of integers packed into a byte
+// slice, like Redis's intset. Every element is stored with the width needed
+// by the largest magnitude in the set, so sets of small integers use two
+// bytes per element.
+package intset
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+)
+
+// Set is a set of int64 values kept in ascending order. The zero value is an
+// empty set ready to use. A Set is not safe for concurrent use.
+type Set struct {
+	// width is the size in bytes of each element: 2, 4 or 8.
+	width    int
+	contents []byte
+}
+
+func New() *Set {
+	return &Set{width: 2}
+}
+
+// widthFor returns the number of bytes needed to store v.
+func widthFor(v int64) int {
+	switch {
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// Len returns the number of elements in the set.
+func (s *Set) Len() int {
+	if s.width == 0 {
+		return 0
+	}
+
+	return len(s.contents) / s.width
+}
+
+// Bytes returns the size of the packed elements in bytes.
+func (s *Set) Bytes() int {
+	return len(s.contents)
+}
+
+// At returns the i-th smallest element.
+func (s *Set) At(i int) int64 {
+	return s.get(s.contents, s.width, i)
+}
+
+func (s *Set) get(contents []byte, width, i int) int64 {
+	b := contents[i*width:]
+	switch width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+}
+
+func (s *Set) put(i int, v int64) {
+	b := s.contents[i*s.width:]
+	switch s.width {
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(v))
+	}
+}
+
+// search returns the index of v, or the index it would be inserted at along
+// with false if it is not in the set.
+func (s *Set) search(v int64) (int, bool) {
+	lo, hi := 0, s.Len()
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		switch at := s.At(mid); {
+		case at == v:
+			return mid, true
+		case at < v:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return lo, false
+}
+
+// Contains reports whether v is in the set.
+func (s *Set) Contains(v int64) bool {
+	if widthFor(v) > s.width {
+		return false
+	}
+
+	_, ok := s.search(v)
+	return ok
+}
+
+// Add adds v to the set, reporting whether it was not already present.
+func (s *Set) Add(v int64) bool {
+	if s.width == 0 {
+		s.width = 2
+	}
+
+	if widthFor(v) > s.width {
+		s.upgrade(v)
+		return true
+	}
+
+	i, ok := s.search(v)
+	if ok {
+		return false
+	}
+
+	n := s.Len()
+	s.contents = append(s.contents, make([]byte, s.width)...)
+	copy(s.contents[(i+1)*s.width:], s.contents[i*s.width:n*s.width])
+	s.put(i, v)
+
+	return true
+}
+
+// upgrade widens every element to fit v and adds v, which being out of the
+// range of the current width is either smaller or larger than every element.
+func (s *Set) upgrade(v int64) {
+	oldWidth, old := s.width, s.contents
+	n := s.Len()
+
+	s.width = widthFor(v)
+	s.contents = make([]byte, (n+1)*s.width)
+
+	offset := 0
+	if v < 0 {
+		offset = 1
+		s.put(0, v)
+	} else {
+		s.put(n, v)
+	}
+
+	for i := 0; i < n; i++ {
+		s.put(i+offset, s.get(old, oldWidth, i))
+	}
+}
+
+// Remove removes v from the set, reporting whether it was present.
+func (s *Set) Remove(v int64) bool {
+	if widthFor(v) > s.width {
+		return false
+	}
+
+	i, ok := s.search(v)
+	if !ok {
+		return false
+	}
+
+	copy(s.contents[i*s.width:], s.contents[(i+1)*s.width:])
+	s.contents = s.contents[:len(s.contents)-s.width]
+
+	return true
+}
+
+// Range calls fn for each element in ascending order until fn returns false.
+func (s *Set) Range(fn func(v int64) bool) {
+	for i, n := 0, s.Len(); i < n; i++ {
+		if !fn(s.At(i)) {
+			return
+		}
+	}
+}
+
+// Random returns a random element. ok is false if the set is empty.
+func (s *Set) Random() (v int64, ok bool) {
+	n := s.Len()
+	if n == 0 {
+		return 0, false
+	}
+
+	return s.At(rand.Intn(n)), true
+}
+
+// Clone returns a copy of the set.
+func (s *Set) Clone() *Set {
+	return &Set{width: s.width, contents: append([]byte(nil), s.contents...)}
+}
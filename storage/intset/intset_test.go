@@ -0,0 +1,110 @@
+package intset
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func elements(s *Set) []int64 {
+	vals := []int64{}
+	s.Range(func(v int64) bool {
+		vals = append(vals, v)
+		return true
+	})
+	return vals
+}
+
+func TestAddKeepsOrder(t *testing.T) {
+	s := New()
+
+	assert.True(t, s.Add(5))
+	assert.True(t, s.Add(-3))
+	assert.True(t, s.Add(10))
+	assert.False(t, s.Add(5))
+
+	assert.Equal(t, []int64{-3, 5, 10}, elements(s))
+	assert.Equal(t, 6, s.Bytes())
+}
+
+func TestUpgrade(t *testing.T) {
+	s := New()
+	s.Add(1)
+	s.Add(2)
+
+	s.Add(math.MaxInt32)
+	assert.Equal(t, 12, s.Bytes())
+	assert.Equal(t, []int64{1, 2, math.MaxInt32}, elements(s))
+
+	s.Add(math.MinInt64)
+	assert.Equal(t, 32, s.Bytes())
+	assert.Equal(t, []int64{math.MinInt64, 1, 2, math.MaxInt32}, elements(s))
+
+	assert.True(t, s.Contains(math.MinInt64))
+	assert.False(t, s.Contains(math.MaxInt64))
+}
+
+func TestRemove(t *testing.T) {
+	s := New()
+	s.Add(1)
+	s.Add(2)
+	s.Add(3)
+
+	assert.True(t, s.Remove(2))
+	assert.False(t, s.Remove(2))
+	assert.False(t, s.Remove(math.MaxInt64))
+	assert.Equal(t, []int64{1, 3}, elements(s))
+}
+
+func TestZeroValue(t *testing.T) {
+	var s Set
+	assert.Equal(t, 0, s.Len())
+	_, ok := s.Random()
+	assert.False(t, ok)
+
+	s.Add(7)
+	assert.Equal(t, []int64{7}, elements(&s))
+}
+
+func TestMatchesModel(t *testing.T) {
+	s := New()
+	model := make(map[int64]bool)
+	widths := []int64{100, math.MaxInt16 * 4, math.MaxInt32 * 4}
+
+	for i := 0; i < 5000; i++ {
+		v := rand.Int63n(widths[i%3]) - widths[i%3]/2
+		if rand.Intn(3) == 0 {
+			assert.Equal(t, model[v], s.Remove(v))
+			delete(model, v)
+		} else {
+			assert.Equal(t, !model[v], s.Add(v))
+			model[v] = true
+		}
+	}
+
+	want := make([]int64, 0, len(model))
+	for v := range model {
+		want = append(want, v)
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+
+	require.Equal(t, want, elements(s))
+	for _, v := range want {
+		assert.True(t, s.Contains(v))
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	s := New()
+	s.Add(1)
+
+	clone := s.Clone()
+	clone.Add(2)
+
+	assert.Equal(t, []int64{1}, elements(s))
+	assert.Equal(t, []int64{1, 2}, elements(clone))
+}
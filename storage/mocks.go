@@ -1,15 +1,28 @@
 package storage
 
+import "time"
+
 type MockStorage struct {
-	GetFn func(string) (Node, error)
-	SetFn func(string, Node)
-	DelFn func(string) int
+	GetFn       func(string) (Node, error)
+	PeekFn      func(string) (Node, error)
+	SetFn       func(string, Node)
+	DelFn       func(string) int
+	ScanFn      func(uint64, func(string, Node)) uint64
+	LenFn       func() int
+	RandomKeyFn func() (string, error)
+	FlushFn     func()
+	IdleFn      func(string) (time.Duration, error)
+	AtomicFn    func(func(Storage))
 }
 
 func (m *MockStorage) Get(key string) (Node, error) {
 	return m.GetFn(key)
 }
 
+func (m *MockStorage) Peek(key string) (Node, error) {
+	return m.PeekFn(key)
+}
+
 func (m *MockStorage) Set(key string, node Node) {
 	m.SetFn(key, node)
 }
@@ -17,3 +30,34 @@ func (m *MockStorage) Set(key string, node Node) {
 func (m *MockStorage) Del(key string) int {
 	return m.DelFn(key)
 }
+
+func (m *MockStorage) Scan(cursor uint64, fn func(string, Node)) uint64 {
+	return m.ScanFn(cursor, fn)
+}
+
+func (m *MockStorage) Len() int {
+	return m.LenFn()
+}
+
+func (m *MockStorage) RandomKey() (string, error) {
+	return m.RandomKeyFn()
+}
+
+func (m *MockStorage) Flush() {
+	m.FlushFn()
+}
+
+func (m *MockStorage) Idle(key string) (time.Duration, error) {
+	return m.IdleFn(key)
+}
+
+// Atomic calls AtomicFn if it is set, otherwise it calls fn with the mock
+// itself so tests only need to stub the operations they care about.
+func (m *MockStorage) Atomic(fn func(Storage)) {
+	if m.AtomicFn != nil {
+		m.AtomicFn(fn)
+		return
+	}
+
+	fn(m)
+}
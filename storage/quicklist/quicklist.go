@@ -0,0 +1,356 @@
+// Package quicklist implements the list value type as a doubly linked list of
+// small contiguous chunks, in the spirit of Redis's quicklist. Chunks keep
+// pushes and pops at either end cheap while avoiding the per-element
+// overhead of a plain linked list.
+package quicklist
+
+// chunkSize is the maximum number of elements held by a single chunk.
+const chunkSize = 128
+
+type chunk struct {
+	prev, next *chunk
+
+	entries [][]byte
+}
+
+// List is a sequence of byte string elements. The zero value is an empty
+// list ready to use. A List is not safe for concurrent use.
+type List struct {
+	head, tail *chunk
+
+	length int
+}
+
+func New() *List {
+	return &List{}
+}
+
+// Len returns the number of elements in the list.
+func (l *List) Len() int {
+	return l.length
+}
+
+// PushFront inserts val at the head of the list.
+func (l *List) PushFront(val []byte) {
+	if l.head == nil || len(l.head.entries) >= chunkSize {
+		l.linkBefore(l.head, &chunk{entries: make([][]byte, 0, 8)})
+	}
+
+	c := l.head
+	c.entries = append(c.entries, nil)
+	copy(c.entries[1:], c.entries)
+	c.entries[0] = val
+	l.length++
+}
+
+// PushBack inserts val at the tail of the list.
+func (l *List) PushBack(val []byte) {
+	if l.tail == nil || len(l.tail.entries) >= chunkSize {
+		l.linkAfter(l.tail, &chunk{entries: make([][]byte, 0, 8)})
+	}
+
+	l.tail.entries = append(l.tail.entries, val)
+	l.length++
+}
+
+// PopFront removes and returns the head of the list. ok is false if the list
+// is empty.
+func (l *List) PopFront() (val []byte, ok bool) {
+	if l.length == 0 {
+		return nil, false
+	}
+
+	c := l.head
+	val = c.entries[0]
+	c.entries[0] = nil
+	c.entries = c.entries[1:]
+	l.length--
+	if len(c.entries) == 0 {
+		l.unlink(c)
+	}
+
+	return val, true
+}
+
+// PopBack removes and returns the tail of the list. ok is false if the list
+// is empty.
+func (l *List) PopBack() (val []byte, ok bool) {
+	if l.length == 0 {
+		return nil, false
+	}
+
+	c := l.tail
+	last := len(c.entries) - 1
+	val = c.entries[last]
+	c.entries[last] = nil
+	c.entries = c.entries[:last]
+	l.length--
+	if len(c.entries) == 0 {
+		l.unlink(c)
+	}
+
+	return val, true
+}
+
+// Index returns the element at index i, where 0 is the head of the list.
+// ok is false if i is out of range.
+func (l *List) Index(i int) (val []byte, ok bool) {
+	c, off := l.find(i)
+	if c == nil {
+		return nil, false
+	}
+
+	return c.entries[off], true
+}
+
+// Set replaces the element at index i. It returns false if i is out of range.
+func (l *List) Set(i int, val []byte) bool {
+	c, off := l.find(i)
+	if c == nil {
+		return false
+	}
+
+	c.entries[off] = val
+	return true
+}
+
+// Insert inserts val so that it ends up at index i. Inserting at Len()
+// appends to the list. It returns false if i is out of range.
+func (l *List) Insert(i int, val []byte) bool {
+	switch {
+	case i < 0 || i > l.length:
+		return false
+	case i == 0:
+		l.PushFront(val)
+		return true
+	case i == l.length:
+		l.PushBack(val)
+		return true
+	}
+
+	c, off := l.find(i)
+	if len(c.entries) >= chunkSize {
+		// split the full chunk in half so inserts stay O(chunkSize)
+		half := len(c.entries) / 2
+		rest := &chunk{entries: make([][]byte, len(c.entries)-half, chunkSize)}
+		copy(rest.entries, c.entries[half:])
+		for j := half; j < len(c.entries); j++ {
+			c.entries[j] = nil
+		}
+		c.entries = c.entries[:half]
+		l.linkAfter(c, rest)
+
+		if off >= half {
+			c, off = rest, off-half
+		}
+	}
+
+	c.entries = append(c.entries, nil)
+	copy(c.entries[off+1:], c.entries[off:])
+	c.entries[off] = val
+	l.length++
+
+	return true
+}
+
+// Range calls fn for each element from index start to stop inclusive, in
+// order, until fn returns false. The indexes must already be within range.
+func (l *List) Range(start, stop int, fn func(i int, val []byte) bool) {
+	c, off := l.find(start)
+	for i := start; c != nil && i <= stop; c, off = c.next, 0 {
+		for ; off < len(c.entries) && i <= stop; off++ {
+			if !fn(i, c.entries[off]) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// ReverseRange calls fn for each element from index start down to stop
+// inclusive, until fn returns false. The indexes must already be within
+// range and start must not be less than stop.
+func (l *List) ReverseRange(start, stop int, fn func(i int, val []byte) bool) {
+	c, off := l.find(start)
+	for i := start; c != nil && i >= stop; c = c.prev {
+		for ; off >= 0 && i >= stop; off-- {
+			if !fn(i, c.entries[off]) {
+				return
+			}
+			i--
+		}
+		if c.prev != nil {
+			off = len(c.prev.entries) - 1
+		}
+	}
+}
+
+// RemoveFunc removes up to limit elements for which match returns true,
+// scanning from the tail when reverse is set. A limit of zero removes every
+// matching element. It returns the number of removed elements.
+func (l *List) RemoveFunc(limit int, reverse bool, match func(val []byte) bool) int {
+	removed := 0
+
+	c := l.head
+	if reverse {
+		c = l.tail
+	}
+
+	for c != nil && (limit == 0 || removed < limit) {
+		next := c.next
+		if reverse {
+			next = c.prev
+		}
+
+		kept := c.entries[:0]
+		if reverse {
+			// compact from the end so that matching stops at the right element
+			// when the limit is reached part way through a chunk
+			end := len(c.entries)
+			for j := len(c.entries) - 1; j >= 0; j-- {
+				if (limit == 0 || removed < limit) && match(c.entries[j]) {
+					copy(c.entries[j:end-1], c.entries[j+1:end])
+					end--
+					removed++
+				}
+			}
+			for j := end; j < len(c.entries); j++ {
+				c.entries[j] = nil
+			}
+			c.entries = c.entries[:end]
+		} else {
+			for _, val := range c.entries {
+				if (limit == 0 || removed < limit) && match(val) {
+					removed++
+					continue
+				}
+				kept = append(kept, val)
+			}
+			for j := len(kept); j < len(c.entries); j++ {
+				c.entries[j] = nil
+			}
+			c.entries = kept
+		}
+
+		if len(c.entries) == 0 {
+			l.unlink(c)
+		}
+		c = next
+	}
+
+	l.length -= removed
+	return removed
+}
+
+// Trim removes n elements from the head and m elements from the tail.
+func (l *List) Trim(n, m int) {
+	for ; n > 0 && l.head != nil; n-- {
+		l.PopFront()
+	}
+	for ; m > 0 && l.tail != nil; m-- {
+		l.PopBack()
+	}
+}
+
+// Clone returns a copy of the list. Elements are shared with the original,
+// which is safe since they are never modified in place.
+func (l *List) Clone() *List {
+	clone := New()
+	for c := l.head; c != nil; c = c.next {
+		entries := make([][]byte, len(c.entries), chunkSize)
+		copy(entries, c.entries)
+		clone.linkAfter(clone.tail, &chunk{entries: entries})
+	}
+	clone.length = l.length
+
+	return clone
+}
+
+// find returns the chunk holding index i and the offset of the element within
+// it, walking from whichever end of the list is closer.
+func (l *List) find(i int) (*chunk, int) {
+	if i < 0 || i >= l.length {
+		return nil, 0
+	}
+
+	if i < l.length/2 {
+		for c := l.head; c != nil; c = c.next {
+			if i < len(c.entries) {
+				return c, i
+			}
+			i -= len(c.entries)
+		}
+		return nil, 0
+	}
+
+	i = l.length - 1 - i
+	for c := l.tail; c != nil; c = c.prev {
+		if i < len(c.entries) {
+			return c, len(c.entries) - 1 - i
+		}
+		i -= len(c.entries)
+	}
+	return nil, 0
+}
+
+// linkBefore inserts c before mark, or at the head of the list if mark is nil.
+func (l *List) linkBefore(mark, c *chunk) {
+	if mark == nil {
+		c.next = l.head
+		if l.head != nil {
+			l.head.prev = c
+		}
+		l.head = c
+		if l.tail == nil {
+			l.tail = c
+		}
+		return
+	}
+
+	c.prev, c.next = mark.prev, mark
+	if mark.prev != nil {
+		mark.prev.next = c
+	} else {
+		l.head = c
+	}
+	mark.prev = c
+}
+
+// linkAfter inserts c after mark, or at the tail of the list if mark is nil.
+func (l *List) linkAfter(mark, c *chunk) {
+	if mark == nil {
+		c.prev = l.tail
+		if l.tail != nil {
+			l.tail.next = c
+		}
+		l.tail = c
+		if l.head == nil {
+			l.head = c
+		}
+		return
+	}
+
+	c.prev, c.next = mark, mark.next
+	if mark.next != nil {
+		mark.next.prev = c
+	} else {
+		l.tail = c
+	}
+	mark.next = c
+}
+
+func (l *List) unlink(c *chunk) {
+	if c.prev != nil {
+		c.prev.next = c.next
+	} else {
+		l.head = c.next
+	}
+
+	if c.next != nil {
+		c.next.prev = c.prev
+	} else {
+		l.tail = c.prev
+	}
+
+	c.prev, c.next = nil, nil
+}
@@ -0,0 +1,207 @@
+package quicklist
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushPop(t *testing.T) {
+	l := New()
+	l.PushBack([]byte("b"))
+	l.PushFront([]byte("a"))
+	l.PushBack([]byte("c"))
+
+	assert.Equal(t, 3, l.Len())
+	assert.Equal(t, []string{"a", "b", "c"}, elements(l))
+
+	val, ok := l.PopFront()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), val)
+
+	val, ok = l.PopBack()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("c"), val)
+
+	l.PopBack()
+	_, ok = l.PopBack()
+	assert.False(t, ok)
+	assert.Equal(t, 0, l.Len())
+}
+
+func TestIndexAndSet(t *testing.T) {
+	l := fromInts(1000)
+
+	val, ok := l.Index(0)
+	require.True(t, ok)
+	assert.Equal(t, []byte("0"), val)
+
+	val, ok = l.Index(999)
+	require.True(t, ok)
+	assert.Equal(t, []byte("999"), val)
+
+	_, ok = l.Index(1000)
+	assert.False(t, ok)
+
+	assert.True(t, l.Set(500, []byte("x")))
+	val, _ = l.Index(500)
+	assert.Equal(t, []byte("x"), val)
+	assert.False(t, l.Set(-1, []byte("x")))
+}
+
+func TestInsertSplitsFullChunks(t *testing.T) {
+	l := fromInts(chunkSize)
+
+	assert.True(t, l.Insert(10, []byte("x")))
+	assert.True(t, l.Insert(chunkSize+1, []byte("end")))
+	assert.False(t, l.Insert(chunkSize+3, []byte("oob")))
+
+	els := elements(l)
+	assert.Equal(t, chunkSize+2, len(els))
+	assert.Equal(t, "9", els[9])
+	assert.Equal(t, "x", els[10])
+	assert.Equal(t, "10", els[11])
+	assert.Equal(t, "end", els[chunkSize+1])
+}
+
+func TestRange(t *testing.T) {
+	l := fromInts(300)
+
+	var got []string
+	l.Range(120, 140, func(i int, val []byte) bool {
+		assert.Equal(t, strconv.Itoa(i), string(val))
+		got = append(got, string(val))
+		return len(got) < 5
+	})
+	assert.Equal(t, []string{"120", "121", "122", "123", "124"}, got)
+
+	got = nil
+	l.ReverseRange(130, 125, func(i int, val []byte) bool {
+		assert.Equal(t, strconv.Itoa(i), string(val))
+		got = append(got, string(val))
+		return true
+	})
+	assert.Equal(t, []string{"130", "129", "128", "127", "126", "125"}, got)
+}
+
+func TestRemoveFunc(t *testing.T) {
+	l := New()
+	for _, v := range []string{"a", "b", "a", "c", "a"} {
+		l.PushBack([]byte(v))
+	}
+	isA := func(val []byte) bool { return string(val) == "a" }
+
+	assert.Equal(t, 1, l.RemoveFunc(1, true, isA))
+	assert.Equal(t, []string{"a", "b", "a", "c"}, elements(l))
+
+	assert.Equal(t, 1, l.RemoveFunc(1, false, isA))
+	assert.Equal(t, []string{"b", "a", "c"}, elements(l))
+
+	assert.Equal(t, 1, l.RemoveFunc(0, false, isA))
+	assert.Equal(t, []string{"b", "c"}, elements(l))
+	assert.Equal(t, 2, l.Len())
+}
+
+func TestTrim(t *testing.T) {
+	l := fromInts(500)
+	l.Trim(200, 250)
+
+	els := elements(l)
+	assert.Equal(t, 50, l.Len())
+	assert.Equal(t, "200", els[0])
+	assert.Equal(t, "249", els[49])
+
+	l.Trim(100, 0)
+	assert.Equal(t, 0, l.Len())
+}
+
+func TestClone(t *testing.T) {
+	l := fromInts(200)
+	clone := l.Clone()
+	clone.PushBack([]byte("x"))
+	clone.Set(0, []byte("y"))
+
+	assert.Equal(t, 200, l.Len())
+	val, _ := l.Index(0)
+	assert.Equal(t, []byte("0"), val)
+	assert.Equal(t, 201, clone.Len())
+}
+
+func TestMatchesSliceModel(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	l := New()
+	var model []string
+
+	for i := 0; i < 20000; i++ {
+		v := strconv.Itoa(rnd.Intn(50))
+		switch rnd.Intn(7) {
+		case 0:
+			l.PushFront([]byte(v))
+			model = append([]string{v}, model...)
+		case 1:
+			l.PushBack([]byte(v))
+			model = append(model, v)
+		case 2:
+			if val, ok := l.PopFront(); ok {
+				require.Equal(t, model[0], string(val))
+				model = model[1:]
+			}
+		case 3:
+			if val, ok := l.PopBack(); ok {
+				require.Equal(t, model[len(model)-1], string(val))
+				model = model[:len(model)-1]
+			}
+		case 4:
+			idx := rnd.Intn(len(model) + 1)
+			require.True(t, l.Insert(idx, []byte(v)))
+			model = append(model[:idx], append([]string{v}, model[idx:]...)...)
+		case 5:
+			reverse := rnd.Intn(2) == 0
+			removed := l.RemoveFunc(1, reverse, func(val []byte) bool { return string(val) == v })
+			for k := range model {
+				j := k
+				if reverse {
+					j = len(model) - 1 - k
+				}
+				if model[j] == v {
+					require.Equal(t, 1, removed)
+					model = append(model[:j], model[j+1:]...)
+					break
+				}
+			}
+		case 6:
+			if len(model) > 0 {
+				idx := rnd.Intn(len(model))
+				val, ok := l.Index(idx)
+				require.True(t, ok)
+				require.Equal(t, model[idx], string(val))
+			}
+		}
+		require.Equal(t, len(model), l.Len())
+	}
+
+	assert.Equal(t, model, elements(l))
+}
+
+func fromInts(n int) *List {
+	l := New()
+	for i := 0; i < n; i++ {
+		l.PushBack([]byte(strconv.Itoa(i)))
+	}
+	return l
+}
+
+func elements(l *List) []string {
+	var els []string
+	if l.Len() == 0 {
+		return els
+	}
+	l.Range(0, l.Len()-1, func(_ int, val []byte) bool {
+		els = append(els, string(val))
+		return true
+	})
+	return els
+}
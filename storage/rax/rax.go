@@ -0,0 +1,348 @@
+// Package rax implements a radix tree: a sorted map from byte string keys to
+// values where keys sharing a prefix share the nodes storing it, in the
+// spirit of Redis's rax. Besides lookups it supports the ordered seeks
+// streams use to find the node holding an entry ID.
+package rax
+
+import "bytes"
+
+type node struct {
+	// prefix is the part of the key consumed by this node.
+	prefix []byte
+
+	// children are sorted by the first byte of their prefix, which is
+	// unique among siblings.
+	children []*node
+
+	leaf  bool
+	value interface{}
+}
+
+// Tree is a radix tree. The zero value is an empty tree ready to use. A Tree
+// is not safe for concurrent use.
+type Tree struct {
+	root node
+	size int
+	// nodes counts the nodes of the tree, including the root.
+	nodes int
+}
+
+func New() *Tree {
+	return &Tree{}
+}
+
+// Len returns the number of keys in the tree.
+func (t *Tree) Len() int {
+	return t.size
+}
+
+// Nodes returns the number of nodes in the tree.
+func (t *Tree) Nodes() int {
+	return t.nodes + 1
+}
+
+func commonPrefix(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// child returns the index of the child of n whose prefix starts with c, or
+// the index it would be inserted at along with false.
+func (n *node) child(c byte) (int, bool) {
+	lo, hi := 0, len(n.children)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if n.children[mid].prefix[0] < c {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo, lo < len(n.children) && n.children[lo].prefix[0] == c
+}
+
+// Get returns the value stored at key.
+func (t *Tree) Get(key []byte) (interface{}, bool) {
+	n := &t.root
+	for len(key) > 0 {
+		i, ok := n.child(key[0])
+		if !ok {
+			return nil, false
+		}
+
+		c := n.children[i]
+		if !bytes.HasPrefix(key, c.prefix) {
+			return nil, false
+		}
+		key = key[len(c.prefix):]
+		n = c
+	}
+
+	if !n.leaf {
+		return nil, false
+	}
+
+	return n.value, true
+}
+
+// Insert stores value at key, reporting whether the key is new.
+func (t *Tree) Insert(key []byte, value interface{}) bool {
+	n := &t.root
+	for len(key) > 0 {
+		i, ok := n.child(key[0])
+		if !ok {
+			leaf := &node{prefix: append([]byte(nil), key...), leaf: true, value: value}
+			n.children = append(n.children, nil)
+			copy(n.children[i+1:], n.children[i:])
+			n.children[i] = leaf
+			t.nodes++
+			t.size++
+			return true
+		}
+
+		c := n.children[i]
+		common := commonPrefix(key, c.prefix)
+		if common < len(c.prefix) {
+			// split c so that the common part becomes its own node
+			split := &node{prefix: c.prefix[:common:common], children: []*node{c}}
+			c.prefix = c.prefix[common:]
+			n.children[i] = split
+			t.nodes++
+			c = split
+		}
+
+		key = key[common:]
+		n = c
+	}
+
+	added := !n.leaf
+	n.leaf = true
+	n.value = value
+	if added {
+		t.size++
+	}
+
+	return added
+}
+
+// Delete removes key, reporting whether it was present.
+func (t *Tree) Delete(key []byte) bool {
+	var (
+		parents []*node
+		n       = &t.root
+	)
+	for len(key) > 0 {
+		i, ok := n.child(key[0])
+		if !ok {
+			return false
+		}
+
+		c := n.children[i]
+		if !bytes.HasPrefix(key, c.prefix) {
+			return false
+		}
+		key = key[len(c.prefix):]
+		parents = append(parents, n)
+		n = c
+	}
+
+	if !n.leaf {
+		return false
+	}
+
+	n.leaf = false
+	n.value = nil
+	t.size--
+
+	// remove nodes that no longer lead to a key and merge nodes left with a
+	// single child into it, keeping the tree compressed
+	for len(parents) > 0 {
+		parent := parents[len(parents)-1]
+		parents = parents[:len(parents)-1]
+
+		switch {
+		case !n.leaf && len(n.children) == 0:
+			i, _ := parent.child(n.prefix[0])
+			copy(parent.children[i:], parent.children[i+1:])
+			parent.children[len(parent.children)-1] = nil
+			parent.children = parent.children[:len(parent.children)-1]
+			t.nodes--
+		case !n.leaf && len(n.children) == 1:
+			only := n.children[0]
+			n.prefix = append(append([]byte(nil), n.prefix...), only.prefix...)
+			n.children = only.children
+			n.leaf = only.leaf
+			n.value = only.value
+			t.nodes--
+			return true
+		default:
+			return true
+		}
+
+		n = parent
+	}
+
+	return true
+}
+
+// Min returns the smallest key in the tree.
+func (t *Tree) Min() ([]byte, interface{}, bool) {
+	return t.Ceil(nil)
+}
+
+// Max returns the largest key in the tree.
+func (t *Tree) Max() ([]byte, interface{}, bool) {
+	if t.size == 0 {
+		return nil, nil, false
+	}
+
+	key, n := last(&t.root, nil)
+	return key, n.value, true
+}
+
+// Ceil returns the smallest key greater than or equal to key.
+func (t *Tree) Ceil(key []byte) ([]byte, interface{}, bool) {
+	return t.seekUp(key, false)
+}
+
+// Next returns the smallest key strictly greater than key.
+func (t *Tree) Next(key []byte) ([]byte, interface{}, bool) {
+	return t.seekUp(key, true)
+}
+
+// Floor returns the largest key less than or equal to key.
+func (t *Tree) Floor(key []byte) ([]byte, interface{}, bool) {
+	return t.seekDown(key, false)
+}
+
+// Prev returns the largest key strictly less than key.
+func (t *Tree) Prev(key []byte) ([]byte, interface{}, bool) {
+	return t.seekDown(key, true)
+}
+
+func (t *Tree) seekUp(key []byte, strict bool) ([]byte, interface{}, bool) {
+	found, n := ceil(&t.root, key, nil, strict)
+	if n == nil {
+		return nil, nil, false
+	}
+
+	return found, n.value, true
+}
+
+func (t *Tree) seekDown(key []byte, strict bool) ([]byte, interface{}, bool) {
+	found, n := floor(&t.root, key, nil, strict)
+	if n == nil {
+		return nil, nil, false
+	}
+
+	return found, n.value, true
+}
+
+// first returns the smallest key in the subtree rooted at n, whose prefix
+// has not been appended to path yet.
+func first(n *node, path []byte) ([]byte, *node) {
+	for {
+		path = append(path, n.prefix...)
+		if n.leaf {
+			return path, n
+		}
+		n = n.children[0]
+	}
+}
+
+// last returns the largest key in the subtree rooted at n, whose prefix has
+// not been appended to path yet.
+func last(n *node, path []byte) ([]byte, *node) {
+	for {
+		path = append(path, n.prefix...)
+		if len(n.children) == 0 {
+			return path, n
+		}
+		n = n.children[len(n.children)-1]
+	}
+}
+
+// ceil returns the smallest key in the subtree rooted at n that is greater
+// than, or if strict is not set equal to, path+key.
+func ceil(n *node, key, path []byte, strict bool) ([]byte, *node) {
+	common := commonPrefix(n.prefix, key)
+	if common < len(n.prefix) {
+		if common == len(key) || n.prefix[common] > key[common] {
+			// every key in the subtree is greater than the one sought
+			return first(n, path)
+		}
+		return nil, nil
+	}
+
+	path = append(path, n.prefix...)
+	key = key[common:]
+
+	if len(key) == 0 {
+		if n.leaf && !strict {
+			return path, n
+		}
+		if len(n.children) == 0 {
+			return nil, nil
+		}
+		return first(n.children[0], path)
+	}
+
+	i, ok := n.child(key[0])
+	if ok {
+		if found, m := ceil(n.children[i], key, path, strict); m != nil {
+			return found, m
+		}
+		i++
+	}
+
+	if i < len(n.children) {
+		return first(n.children[i], path)
+	}
+
+	return nil, nil
+}
+
+// floor returns the largest key in the subtree rooted at n that is less
+// than, or if strict is not set equal to, path+key.
+func floor(n *node, key, path []byte, strict bool) ([]byte, *node) {
+	common := commonPrefix(n.prefix, key)
+	if common < len(n.prefix) {
+		if common < len(key) && n.prefix[common] < key[common] {
+			// every key in the subtree is less than the one sought
+			return last(n, path)
+		}
+		return nil, nil
+	}
+
+	path = append(path, n.prefix...)
+	key = key[common:]
+
+	if len(key) == 0 {
+		// the keys of the children extend the one sought, so are greater
+		if n.leaf && !strict {
+			return path, n
+		}
+		return nil, nil
+	}
+
+	i, ok := n.child(key[0])
+	if ok {
+		if found, m := floor(n.children[i], key, path, strict); m != nil {
+			return found, m
+		}
+	}
+
+	if i > 0 {
+		return last(n.children[i-1], path)
+	}
+
+	if n.leaf {
+		return path, n
+	}
+
+	return nil, nil
+}
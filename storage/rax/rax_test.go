@@ -0,0 +1,171 @@
+package rax
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertGetDelete(t *testing.T) {
+	tree := New()
+
+	assert.True(t, tree.Insert([]byte("romane"), 1))
+	assert.True(t, tree.Insert([]byte("romanus"), 2))
+	assert.True(t, tree.Insert([]byte("rom"), 3))
+	assert.False(t, tree.Insert([]byte("rom"), 4))
+	assert.Equal(t, 3, tree.Len())
+
+	v, ok := tree.Get([]byte("rom"))
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	_, ok = tree.Get([]byte("roman"))
+	assert.False(t, ok)
+	_, ok = tree.Get([]byte("romanes"))
+	assert.False(t, ok)
+
+	assert.False(t, tree.Delete([]byte("roman")))
+	assert.True(t, tree.Delete([]byte("romane")))
+	assert.False(t, tree.Delete([]byte("romane")))
+
+	v, ok = tree.Get([]byte("romanus"))
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, 2, tree.Len())
+}
+
+func TestDeleteCompressesNodes(t *testing.T) {
+	tree := New()
+	tree.Insert([]byte("abc"), 1)
+	tree.Insert([]byte("abd"), 2)
+	assert.Equal(t, 4, tree.Nodes())
+
+	tree.Delete([]byte("abd"))
+	assert.Equal(t, 2, tree.Nodes())
+
+	tree.Delete([]byte("abc"))
+	assert.Equal(t, 1, tree.Nodes())
+	assert.Equal(t, 0, tree.Len())
+}
+
+func TestSeekMatchesModel(t *testing.T) {
+	tree := New()
+	model := make(map[string]bool)
+
+	randomKey := func() []byte {
+		key := make([]byte, 1+rand.Intn(4))
+		for i := range key {
+			key[i] = byte('a' + rand.Intn(3))
+		}
+		return key
+	}
+
+	for i := 0; i < 3000; i++ {
+		key := randomKey()
+		if rand.Intn(3) == 0 {
+			require.Equal(t, model[string(key)], tree.Delete(key))
+			delete(model, string(key))
+		} else {
+			require.Equal(t, !model[string(key)], tree.Insert(key, string(key)))
+			model[string(key)] = true
+		}
+	}
+	require.Equal(t, len(model), tree.Len())
+
+	keys := make([]string, 0, len(model))
+	for key := range model {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	check := func(name string, want string, wantOK bool, got []byte, v interface{}, ok bool, probe []byte) {
+		require.Equal(t, wantOK, ok, "%s(%q)", name, probe)
+		if ok {
+			require.Equal(t, want, string(got), "%s(%q)", name, probe)
+			require.Equal(t, want, v)
+		}
+	}
+
+	for i := 0; i < 2000; i++ {
+		probe := randomKey()
+		p := string(probe)
+
+		j := sort.SearchStrings(keys, p)
+		ceil, ceilOK := "", j < len(keys)
+		if ceilOK {
+			ceil = keys[j]
+		}
+		k, v, ok := tree.Ceil(probe)
+		check("Ceil", ceil, ceilOK, k, v, ok, probe)
+
+		next, nextOK := ceil, ceilOK
+		if ceilOK && ceil == p {
+			next, nextOK = "", j+1 < len(keys)
+			if nextOK {
+				next = keys[j+1]
+			}
+		}
+		k, v, ok = tree.Next(probe)
+		check("Next", next, nextOK, k, v, ok, probe)
+
+		prev, prevOK := "", j > 0
+		if prevOK {
+			prev = keys[j-1]
+		}
+		k, v, ok = tree.Prev(probe)
+		check("Prev", prev, prevOK, k, v, ok, probe)
+
+		floor, floorOK := prev, prevOK
+		if ceilOK && ceil == p {
+			floor, floorOK = p, true
+		}
+		k, v, ok = tree.Floor(probe)
+		check("Floor", floor, floorOK, k, v, ok, probe)
+	}
+
+	if len(keys) > 0 {
+		k, _, ok := tree.Min()
+		assert.True(t, ok)
+		assert.Equal(t, keys[0], string(k))
+
+		k, _, ok = tree.Max()
+		assert.True(t, ok)
+		assert.Equal(t, keys[len(keys)-1], string(k))
+	}
+}
+
+func TestIterateFixedLengthKeys(t *testing.T) {
+	tree := New()
+	for i := 0; i < 1000; i++ {
+		key := []byte{0, 0, byte(i >> 8), byte(i)}
+		tree.Insert(key, i)
+	}
+
+	var got []int
+	for k, v, ok := tree.Min(); ok; k, v, ok = tree.Next(k) {
+		got = append(got, v.(int))
+	}
+	require.Len(t, got, 1000)
+	assert.True(t, sort.IntsAreSorted(got))
+
+	k, _, ok := tree.Floor([]byte{0, 0, 255, 255})
+	assert.True(t, ok)
+	assert.True(t, bytes.Equal([]byte{0, 0, 3, 231}, k))
+}
+
+func TestEmpty(t *testing.T) {
+	tree := New()
+
+	_, _, ok := tree.Min()
+	assert.False(t, ok)
+	_, _, ok = tree.Max()
+	assert.False(t, ok)
+	_, _, ok = tree.Floor([]byte("a"))
+	assert.False(t, ok)
+	_, ok = tree.Get(nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,188 @@
+// Package set implements the set value type: an unordered collection of
+// distinct members.
+//
+// Sets whose members are all integers are stored as an intset until they
+// grow past MaxIntsetEntries members. Any other set is stored in a hash
+// table, and a set never converts back to an intset.
+package set
+
+import (
+	"strconv"
+
+	"github.com/scnewma/godb/storage/dict"
+	"github.com/scnewma/godb/storage/intset"
+)
+
+// MaxIntsetEntries is the largest set stored as an intset, matching Redis's
+// default set-max-intset-entries.
+const MaxIntsetEntries = 512
+
+const (
+	EncodingIntset    = "intset"
+	EncodingHashtable = "hashtable"
+)
+
+// Set is an unordered collection of distinct members. A Set is not safe for
+// concurrent use.
+type Set struct {
+	// ints holds the members until the set is converted to table.
+	ints  *intset.Set
+	table *dict.Dict
+}
+
+func New() *Set {
+	return &Set{ints: intset.New()}
+}
+
+// parseInt parses member as an integer that can be stored in an intset.
+// Only the canonical representation is accepted so that members read back
+// from the intset are identical to the ones added.
+func parseInt(member string) (int64, bool) {
+	if len(member) == 0 || len(member) > 20 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != member {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// Encoding returns the name of the representation used for the set.
+func (s *Set) Encoding() string {
+	if s.table != nil {
+		return EncodingHashtable
+	}
+
+	return EncodingIntset
+}
+
+// Len returns the number of members in the set.
+func (s *Set) Len() int {
+	if s.table != nil {
+		return s.table.Len()
+	}
+
+	return s.ints.Len()
+}
+
+// Contains reports whether member is in the set.
+func (s *Set) Contains(member string) bool {
+	if s.table != nil {
+		_, ok := s.table.Get(member)
+		return ok
+	}
+
+	n, ok := parseInt(member)
+	return ok && s.ints.Contains(n)
+}
+
+// Add adds member to the set, reporting whether it was not already present.
+func (s *Set) Add(member string) bool {
+	if s.table == nil {
+		n, ok := parseInt(member)
+		if ok && (s.ints.Len() < MaxIntsetEntries || s.ints.Contains(n)) {
+			return s.ints.Add(n)
+		}
+
+		s.convert()
+	}
+
+	return s.table.Set(member, nil)
+}
+
+// Remove removes member from the set, reporting whether it was present.
+func (s *Set) Remove(member string) bool {
+	if s.table != nil {
+		_, ok := s.table.Delete(member)
+		return ok
+	}
+
+	n, ok := parseInt(member)
+	return ok && s.ints.Remove(n)
+}
+
+// Range calls fn for each member until fn returns false. The set must not
+// be modified during the iteration.
+func (s *Set) Range(fn func(member string) bool) {
+	if s.table != nil {
+		s.table.Range(func(key string, _ interface{}) bool {
+			return fn(key)
+		})
+		return
+	}
+
+	s.ints.Range(func(v int64) bool {
+		return fn(strconv.FormatInt(v, 10))
+	})
+}
+
+// Scan calls fn for a batch of members starting at cursor and returns the
+// cursor to continue from, which is 0 once every member has been visited.
+// Intsets are always returned in a single batch.
+func (s *Set) Scan(cursor uint64, fn func(member string)) uint64 {
+	if s.table != nil {
+		return s.table.Scan(cursor, func(key string, _ interface{}) {
+			fn(key)
+		})
+	}
+
+	s.ints.Range(func(v int64) bool {
+		fn(strconv.FormatInt(v, 10))
+		return true
+	})
+
+	return 0
+}
+
+// Random returns a random member. ok is false if the set is empty.
+func (s *Set) Random() (member string, ok bool) {
+	if s.table != nil {
+		member, _, ok = s.table.Random()
+		return member, ok
+	}
+
+	v, ok := s.ints.Random()
+	if !ok {
+		return "", false
+	}
+
+	return strconv.FormatInt(v, 10), true
+}
+
+// Pop removes and returns a random member. ok is false if the set is empty.
+func (s *Set) Pop() (member string, ok bool) {
+	member, ok = s.Random()
+	if ok {
+		s.Remove(member)
+	}
+
+	return member, ok
+}
+
+// Clone returns a copy of the set.
+func (s *Set) Clone() *Set {
+	if s.table == nil {
+		return &Set{ints: s.ints.Clone()}
+	}
+
+	clone := &Set{table: dict.New()}
+	s.table.Range(func(key string, _ interface{}) bool {
+		clone.table.Set(key, nil)
+		return true
+	})
+
+	return clone
+}
+
+// convert switches to the hash table encoding.
+func (s *Set) convert() {
+	s.table = dict.New()
+	s.ints.Range(func(v int64) bool {
+		s.table.Set(strconv.FormatInt(v, 10), nil)
+		return true
+	})
+	s.ints = nil
+}
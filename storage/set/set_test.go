@@ -0,0 +1,115 @@
+package set
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func members(s *Set) []string {
+	vals := []string{}
+	s.Range(func(member string) bool {
+		vals = append(vals, member)
+		return true
+	})
+	sort.Strings(vals)
+	return vals
+}
+
+func TestIntegerMembersUseIntset(t *testing.T) {
+	s := New()
+
+	assert.True(t, s.Add("3"))
+	assert.True(t, s.Add("-1"))
+	assert.False(t, s.Add("3"))
+	assert.Equal(t, EncodingIntset, s.Encoding())
+	assert.True(t, s.Contains("-1"))
+	assert.False(t, s.Contains("abc"))
+	assert.Equal(t, []string{"-1", "3"}, members(s))
+}
+
+func TestNonCanonicalIntegersConvert(t *testing.T) {
+	for _, member := range []string{"abc", "007", "+1", " 1", "99999999999999999999"} {
+		s := New()
+		s.Add("1")
+		s.Add(member)
+
+		assert.Equal(t, EncodingHashtable, s.Encoding(), member)
+		assert.Equal(t, 2, s.Len())
+		assert.True(t, s.Contains(member))
+		assert.True(t, s.Contains("1"))
+	}
+}
+
+func TestConvertsWhenTooLarge(t *testing.T) {
+	s := New()
+	for i := 0; i < MaxIntsetEntries; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	assert.Equal(t, EncodingIntset, s.Encoding())
+
+	// re-adding an existing member doesn't count as growing
+	s.Add("0")
+	assert.Equal(t, EncodingIntset, s.Encoding())
+
+	s.Add(strconv.Itoa(MaxIntsetEntries))
+	assert.Equal(t, EncodingHashtable, s.Encoding())
+	assert.Equal(t, MaxIntsetEntries+1, s.Len())
+}
+
+func TestRemoveAndPop(t *testing.T) {
+	for _, first := range []string{"1", "a"} {
+		s := New()
+		s.Add(first)
+		s.Add("2")
+
+		assert.True(t, s.Remove("2"))
+		assert.False(t, s.Remove("2"))
+		assert.False(t, s.Remove("x"))
+
+		member, ok := s.Pop()
+		assert.True(t, ok)
+		assert.Equal(t, first, member)
+		assert.Equal(t, 0, s.Len())
+
+		_, ok = s.Pop()
+		assert.False(t, ok)
+	}
+}
+
+func TestScan(t *testing.T) {
+	for _, prefix := range []string{"", "m"} {
+		s := New()
+		for i := 0; i < 1000; i++ {
+			s.Add(prefix + strconv.Itoa(i))
+		}
+
+		seen := make(map[string]bool)
+		cursor := uint64(0)
+		for {
+			cursor = s.Scan(cursor, func(member string) {
+				seen[member] = true
+			})
+			if cursor == 0 {
+				break
+			}
+		}
+		require.Len(t, seen, 1000)
+	}
+}
+
+func TestClone(t *testing.T) {
+	for _, member := range []string{"1", "a"} {
+		s := New()
+		s.Add(member)
+
+		clone := s.Clone()
+		clone.Add("2")
+
+		assert.Equal(t, 1, s.Len())
+		assert.Equal(t, 2, clone.Len())
+	}
+}
@@ -1,13 +1,78 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"github.com/scnewma/godb/storage/hash"
+	"github.com/scnewma/godb/storage/quicklist"
+	"github.com/scnewma/godb/storage/set"
+	"github.com/scnewma/godb/storage/stream"
+	"github.com/scnewma/godb/storage/zset"
+)
 
 var ErrKeyNotFound = errors.New("key not found")
 
 type Storage interface {
 	Get(key string) (Node, error)
+	// Peek returns the node stored at key like Get, without counting as an
+	// access.
+	Peek(key string) (Node, error)
 	Set(key string, node Node)
 	Del(key string) int
+
+	// Scan calls fn for a batch of keys starting at cursor and returns the
+	// cursor to continue from. A scan starts with cursor 0 and is complete
+	// once the returned cursor is 0 again. Every key present for the whole
+	// scan is passed to fn at least once, even if keys are added or deleted
+	// between calls. fn must not modify the storage.
+	Scan(cursor uint64, fn func(key string, node Node)) uint64
+
+	// Len returns the number of keys.
+	Len() int
+	// RandomKey returns a random key, or ErrKeyNotFound if there are none.
+	RandomKey() (string, error)
+	// Flush deletes every key.
+	Flush()
+	// Idle returns how long ago key was last read or written, without
+	// counting as an access itself.
+	Idle(key string) (time.Duration, error)
+
+	// Atomic calls fn with a Storage that has exclusive access to the
+	// underlying data for the duration of the call. Operations made through
+	// the given Storage are not visible to other clients until fn returns,
+	// which lets callers perform multi-key and read-modify-write operations
+	// without interleaving with other writers.
+	Atomic(fn func(tx Storage))
+}
+
+// Volatile is implemented by values holding elements that expire on their
+// own, such as hashes with field deadlines. Storages delete the expired
+// elements of the values they hold in the background and when the values are
+// read, and delete values that are left empty. A value that is modified in
+// place to give an element a deadline must be Set again for the storage to
+// notice.
+type Volatile interface {
+	// Expiring reports whether any element has a deadline.
+	Expiring() bool
+	// DeleteExpired deletes the elements whose deadline is at or before now
+	// and returns how many were deleted.
+	DeleteExpired(now time.Time) int
+	// Len returns the number of elements, not counting the expired ones.
+	Len() int
+}
+
+// Expirer is implemented by storages that delete the expired elements of
+// Volatile values on their own.
+type Expirer interface {
+	// DeleteExpired runs an expire cycle over a sample of the volatile
+	// values and returns how many elements were deleted.
+	DeleteExpired(now time.Time) int
+	// NotifyExpired makes the storage call fn with the key of every value
+	// that loses elements to expiry, whether on a read or by DeleteExpired,
+	// so that the key can be treated as modified. fn is called with the
+	// storage locked.
+	NotifyExpired(fn func(key string))
 }
 
 type Node interface {
@@ -29,3 +94,34 @@ func NewNode(val []byte) Node {
 func NewStringNode(val string) Node {
 	return NewNode([]byte(val))
 }
+
+// NewIntNode returns a node holding a string value that is stored as an
+// int64, so that counters don't need to be reparsed on every update.
+func NewIntNode(val int64) Node {
+	return &basicNode{val}
+}
+
+// NewListNode returns a node holding a list value.
+func NewListNode(l *quicklist.List) Node {
+	return &basicNode{l}
+}
+
+// NewHashNode returns a node holding a hash value.
+func NewHashNode(h *hash.Hash) Node {
+	return &basicNode{h}
+}
+
+// NewSetNode returns a node holding a set value.
+func NewSetNode(s *set.Set) Node {
+	return &basicNode{s}
+}
+
+// NewZSetNode returns a node holding a sorted set value.
+func NewZSetNode(z *zset.ZSet) Node {
+	return &basicNode{z}
+}
+
+// NewStreamNode returns a node holding a stream value.
+func NewStreamNode(s *stream.Stream) Node {
+	return &basicNode{s}
+}
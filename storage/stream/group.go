@@ -0,0 +1,262 @@
+package stream
+
+import (
+	"github.com/scnewma/godb/storage/rax"
+)
+
+// PendingEntry records an entry delivered to a consumer of a group that
+// hasn't been acknowledged yet.
+type PendingEntry struct {
+	ID       ID
+	Consumer *Consumer
+
+	// DeliveryTime is the unix time in milliseconds the entry was last
+	// delivered at, and DeliveryCount the number of times it was delivered.
+	DeliveryTime  int64
+	DeliveryCount uint64
+}
+
+// Consumer is a named reader of a group.
+type Consumer struct {
+	Name string
+
+	// SeenTime is the unix time in milliseconds the consumer last tried to
+	// read or claim entries at, and ActiveTime when it last succeeded, or
+	// -1 if it never has.
+	SeenTime   int64
+	ActiveTime int64
+
+	// pending holds the consumer's share of the group's pending entries.
+	pending *rax.Tree
+}
+
+// PendingLen returns the number of entries delivered to the consumer and not
+// yet acknowledged.
+func (c *Consumer) PendingLen() int {
+	return c.pending.Len()
+}
+
+// RangePending calls fn for each of the consumer's pending entries with an ID
+// between start and end, both inclusive, in ascending order until fn returns
+// false. fn may acknowledge or claim the entry it is given.
+func (c *Consumer) RangePending(start, end ID, fn func(p *PendingEntry) bool) {
+	rangePending(c.pending, start, end, fn)
+}
+
+func rangePending(pending *rax.Tree, start, end ID, fn func(p *PendingEntry) bool) {
+	endKey := end.key()
+	for key, v, ok := pending.Ceil(start.key()); ok; key, v, ok = pending.Next(key) {
+		if string(key) > string(endKey) || !fn(v.(*PendingEntry)) {
+			return
+		}
+	}
+}
+
+// Group is a consumer group: a cursor into the stream shared by its
+// consumers, along with the entries delivered to them that haven't been
+// acknowledged yet.
+type Group struct {
+	Name string
+
+	// LastID is the ID of the last entry delivered to the group.
+	LastID ID
+
+	// EntriesRead is the number of stream entries delivered to the group,
+	// or -1 if it isn't known, e.g. after the group's last ID was set to the
+	// middle of the stream.
+	EntriesRead int64
+
+	pending   *rax.Tree
+	consumers *rax.Tree
+}
+
+func newGroup(name string, lastID ID, entriesRead int64) *Group {
+	return &Group{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		pending:     rax.New(),
+		consumers:   rax.New(),
+	}
+}
+
+// clone returns a copy of the group, its consumers and their pending
+// entries.
+func (g *Group) clone() *Group {
+	clone := newGroup(g.Name, g.LastID, g.EntriesRead)
+
+	consumers := make(map[*Consumer]*Consumer, g.consumers.Len())
+	g.RangeConsumers(func(c *Consumer) bool {
+		cc := &Consumer{
+			Name:       c.Name,
+			SeenTime:   c.SeenTime,
+			ActiveTime: c.ActiveTime,
+			pending:    rax.New(),
+		}
+		clone.consumers.Insert([]byte(c.Name), cc)
+		consumers[c] = cc
+		return true
+	})
+
+	for key, v, ok := g.pending.Min(); ok; key, v, ok = g.pending.Next(key) {
+		p := *v.(*PendingEntry)
+		p.Consumer = consumers[p.Consumer]
+		clone.pending.Insert(key, &p)
+		p.Consumer.pending.Insert(key, &p)
+	}
+
+	return clone
+}
+
+// Consumer returns the consumer with the given name, or nil if there is
+// none.
+func (g *Group) Consumer(name string) *Consumer {
+	v, ok := g.consumers.Get([]byte(name))
+	if !ok {
+		return nil
+	}
+
+	return v.(*Consumer)
+}
+
+// CreateConsumer adds a consumer, reporting false if it already exists, in
+// which case the existing consumer is returned. now is the current unix time
+// in milliseconds.
+func (g *Group) CreateConsumer(name string, now int64) (*Consumer, bool) {
+	if c := g.Consumer(name); c != nil {
+		return c, false
+	}
+
+	c := &Consumer{
+		Name:       name,
+		SeenTime:   now,
+		ActiveTime: -1,
+		pending:    rax.New(),
+	}
+	g.consumers.Insert([]byte(name), c)
+
+	return c, true
+}
+
+// DeleteConsumer removes a consumer along with its pending entries, and
+// returns how many pending entries it had. ok is false if there was no such
+// consumer.
+func (g *Group) DeleteConsumer(name string) (pending int, ok bool) {
+	c := g.Consumer(name)
+	if c == nil {
+		return 0, false
+	}
+
+	pending = c.pending.Len()
+	for key, _, ok := c.pending.Min(); ok; key, _, ok = c.pending.Next(key) {
+		g.pending.Delete(key)
+	}
+	g.consumers.Delete([]byte(name))
+
+	return pending, true
+}
+
+// Consumers returns the number of consumers in the group.
+func (g *Group) Consumers() int {
+	return g.consumers.Len()
+}
+
+// RangeConsumers calls fn for each consumer in order of name until fn
+// returns false.
+func (g *Group) RangeConsumers(fn func(c *Consumer) bool) {
+	for key, v, ok := g.consumers.Min(); ok; key, v, ok = g.consumers.Next(key) {
+		if !fn(v.(*Consumer)) {
+			return
+		}
+	}
+}
+
+// PendingLen returns the number of entries delivered to the group and not
+// yet acknowledged.
+func (g *Group) PendingLen() int {
+	return g.pending.Len()
+}
+
+// Pending returns the pending entry with the given ID, or nil if there is
+// none.
+func (g *Group) Pending(id ID) *PendingEntry {
+	v, ok := g.pending.Get(id.key())
+	if !ok {
+		return nil
+	}
+
+	return v.(*PendingEntry)
+}
+
+// FirstPending and LastPending return the pending entries with the smallest
+// and largest IDs, or nil if there are none.
+func (g *Group) FirstPending() *PendingEntry {
+	_, v, ok := g.pending.Min()
+	if !ok {
+		return nil
+	}
+
+	return v.(*PendingEntry)
+}
+
+func (g *Group) LastPending() *PendingEntry {
+	_, v, ok := g.pending.Max()
+	if !ok {
+		return nil
+	}
+
+	return v.(*PendingEntry)
+}
+
+// RangePending calls fn for each pending entry with an ID between start and
+// end, both inclusive, in ascending order until fn returns false. fn may
+// acknowledge or claim the entry it is given.
+func (g *Group) RangePending(start, end ID, fn func(p *PendingEntry) bool) {
+	rangePending(g.pending, start, end, fn)
+}
+
+// Deliver records that the entry with the given ID was delivered to c at
+// now, the unix time in milliseconds, as a new delivery. If the entry was
+// already pending it is handed over to c and its delivery count restarts.
+func (g *Group) Deliver(id ID, c *Consumer, now int64) *PendingEntry {
+	p := g.Pending(id)
+	if p == nil {
+		p = &PendingEntry{ID: id}
+		g.pending.Insert(id.key(), p)
+	} else {
+		p.Consumer.pending.Delete(id.key())
+	}
+
+	p.Consumer = c
+	p.DeliveryTime = now
+	p.DeliveryCount = 1
+	c.pending.Insert(id.key(), p)
+
+	return p
+}
+
+// Claim hands the pending entry p over to c. The delivery time and count
+// are left to the caller.
+func (g *Group) Claim(p *PendingEntry, c *Consumer) {
+	if p.Consumer == c {
+		return
+	}
+
+	p.Consumer.pending.Delete(p.ID.key())
+	p.Consumer = c
+	c.pending.Insert(p.ID.key(), p)
+}
+
+// Ack removes the entry with the given ID from the pending entries,
+// reporting whether it was pending.
+func (g *Group) Ack(id ID) bool {
+	p := g.Pending(id)
+	if p == nil {
+		return false
+	}
+
+	g.pending.Delete(id.key())
+	p.Consumer.pending.Delete(id.key())
+
+	return true
+}
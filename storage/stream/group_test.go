@@ -0,0 +1,135 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pendingIDs(rangePending func(start, end ID, fn func(*PendingEntry) bool)) []ID {
+	var got []ID
+	rangePending(MinID, MaxID, func(p *PendingEntry) bool {
+		got = append(got, p.ID)
+		return true
+	})
+	return got
+}
+
+func TestCreateGroup(t *testing.T) {
+	s := New()
+
+	g, created := s.CreateGroup("g", ID{Ms: 5}, -1)
+	assert.True(t, created)
+	assert.Equal(t, ID{Ms: 5}, g.LastID)
+
+	same, created := s.CreateGroup("g", MinID, 0)
+	assert.False(t, created)
+	assert.True(t, g == same)
+	assert.Equal(t, 1, s.Groups())
+
+	assert.True(t, s.DeleteGroup("g"))
+	assert.False(t, s.DeleteGroup("g"))
+	assert.Nil(t, s.Group("g"))
+}
+
+func TestDeliverClaimAck(t *testing.T) {
+	g := newGroup("g", MinID, 0)
+	alice, _ := g.CreateConsumer("alice", 100)
+	bob, _ := g.CreateConsumer("bob", 100)
+	assert.Equal(t, int64(-1), alice.ActiveTime)
+
+	g.Deliver(ID{Ms: 1}, alice, 100)
+	g.Deliver(ID{Ms: 2}, alice, 100)
+	p := g.Deliver(ID{Ms: 3}, bob, 200)
+	assert.Equal(t, uint64(1), p.DeliveryCount)
+	assert.Equal(t, int64(200), p.DeliveryTime)
+
+	assert.Equal(t, 3, g.PendingLen())
+	assert.Equal(t, []ID{{Ms: 1}, {Ms: 2}}, pendingIDs(alice.RangePending))
+	assert.Equal(t, ID{Ms: 1}, g.FirstPending().ID)
+	assert.Equal(t, ID{Ms: 3}, g.LastPending().ID)
+
+	g.Claim(g.Pending(ID{Ms: 1}), bob)
+	assert.Equal(t, []ID{{Ms: 2}}, pendingIDs(alice.RangePending))
+	assert.Equal(t, []ID{{Ms: 1}, {Ms: 3}}, pendingIDs(bob.RangePending))
+
+	assert.True(t, g.Ack(ID{Ms: 1}))
+	assert.False(t, g.Ack(ID{Ms: 1}))
+	assert.Equal(t, []ID{{Ms: 3}}, pendingIDs(bob.RangePending))
+	assert.Equal(t, []ID{{Ms: 2}, {Ms: 3}}, pendingIDs(g.RangePending))
+}
+
+func TestDeleteConsumerDropsItsPendingEntries(t *testing.T) {
+	g := newGroup("g", MinID, 0)
+	alice, _ := g.CreateConsumer("alice", 0)
+	bob, _ := g.CreateConsumer("bob", 0)
+	g.Deliver(ID{Ms: 1}, alice, 0)
+	g.Deliver(ID{Ms: 2}, alice, 0)
+	g.Deliver(ID{Ms: 3}, bob, 0)
+
+	pending, ok := g.DeleteConsumer("alice")
+	assert.True(t, ok)
+	assert.Equal(t, 2, pending)
+	assert.Equal(t, []ID{{Ms: 3}}, pendingIDs(g.RangePending))
+	assert.Equal(t, 1, g.Consumers())
+
+	_, ok = g.DeleteConsumer("alice")
+	assert.False(t, ok)
+}
+
+func TestEntriesUpTo(t *testing.T) {
+	s := New()
+	n, ok := s.EntriesUpTo(ID{Ms: 5})
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), n)
+
+	s = filled(10)
+	for _, tc := range []struct {
+		id   ID
+		want int64
+	}{
+		{MinID, 0},
+		{ID{Ms: 4}, 4},
+		{ID{4, 1}, 4},
+		{ID{Ms: 10}, 10},
+		{MaxID, 10},
+	} {
+		n, ok := s.EntriesUpTo(tc.id)
+		assert.True(t, ok)
+		assert.Equal(t, tc.want, n, tc.id.String())
+	}
+
+	s.TrimMaxLen(5, false, 0)
+	n, _ = s.EntriesUpTo(ID{Ms: 2})
+	assert.Equal(t, int64(5), n)
+
+	s.Delete(ID{Ms: 8})
+	_, ok = s.EntriesUpTo(ID{Ms: 7})
+	assert.False(t, ok)
+	n, ok = s.EntriesUpTo(ID{Ms: 8})
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), n)
+}
+
+func TestCloneCopiesGroups(t *testing.T) {
+	s := filled(MaxNodeEntries + 1)
+	g, _ := s.CreateGroup("g", MinID, 0)
+	alice, _ := g.CreateConsumer("alice", 100)
+	g.Deliver(ID{Ms: 1}, alice, 100)
+
+	clone := s.Clone()
+	assert.Equal(t, ids(s, MinID, MaxID, false), ids(clone, MinID, MaxID, false))
+	assert.Equal(t, s.LastID(), clone.LastID())
+
+	cg := clone.Group("g")
+	cloneAlice := cg.Consumer("alice")
+	assert.Equal(t, []ID{{Ms: 1}}, pendingIDs(cloneAlice.RangePending))
+	assert.True(t, cg.Pending(ID{Ms: 1}).Consumer == cloneAlice)
+
+	// the copies are independent
+	clone.Add(ID{Ms: 1000}, nil)
+	cg.Ack(ID{Ms: 1})
+	assert.Equal(t, MaxNodeEntries+1, s.Len())
+	assert.Equal(t, []ID{{Ms: 1}}, pendingIDs(alice.RangePending))
+	assert.Empty(t, pendingIDs(cloneAlice.RangePending))
+}
@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ID identifies a stream entry: the millisecond time it was added at and a
+// sequence number distinguishing entries added within the same millisecond.
+type ID struct {
+	Ms, Seq uint64
+}
+
+var (
+	MinID = ID{}
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+// Compare returns -1, 0 or 1 depending on whether id is less than, equal to
+// or greater than other.
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Less reports whether id is less than other.
+func (id ID) Less(other ID) bool {
+	return id.Compare(other) < 0
+}
+
+// Next returns the smallest ID greater than id. ok is false if id is MaxID.
+func (id ID) Next() (next ID, ok bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return ID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev returns the largest ID less than id. ok is false if id is MinID.
+func (id ID) Prev() (prev ID, ok bool) {
+	switch {
+	case id.Seq > 0:
+		return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// key encodes the ID so that byte order matches ID order.
+func (id ID) key() []byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], id.Ms)
+	binary.BigEndian.PutUint64(b[8:], id.Seq)
+	return b[:]
+}
+
+// ParseID parses an ID of the form "<ms>-<seq>" or "<ms>". When the
+// sequence number is missing missingSeq is used and seqGiven is false.
+func ParseID(s string, missingSeq uint64) (id ID, seqGiven bool, ok bool) {
+	ms, seq := s, ""
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		ms, seq = s[:i], s[i+1:]
+		seqGiven = true
+	}
+
+	var err error
+	id.Ms, err = strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return ID{}, false, false
+	}
+
+	if !seqGiven {
+		id.Seq = missingSeq
+		return id, false, true
+	}
+
+	id.Seq, err = strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return ID{}, false, false
+	}
+
+	return id, true, true
+}
@@ -0,0 +1,390 @@
+// Package stream implements the stream value type: an append-only log of
+// entries ordered by ID, each holding a list of field/value pairs.
+//
+// Like Redis, entries are grouped into nodes of up to MaxNodeEntries entries
+// that are indexed by a radix tree keyed on the ID the node was created
+// with, so that appending is cheap and any entry can be found in a few
+// steps without keeping a per-entry index.
+package stream
+
+import (
+	"sort"
+
+	"github.com/scnewma/godb/storage/rax"
+)
+
+// MaxNodeEntries is the number of entries held by a node before a new one
+// is started, matching Redis's default stream-node-max-entries.
+const MaxNodeEntries = 100
+
+// Entry is a stream entry. Fields holds field/value pairs one after the
+// other.
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// node holds consecutive entries. Every entry's ID is greater than or equal
+// to the node's key in the index, even after the first entries have been
+// deleted.
+type node struct {
+	entries []Entry
+}
+
+// search returns the index of the first entry with an ID greater than or
+// equal to id.
+func (n *node) search(id ID) int {
+	return sort.Search(len(n.entries), func(i int) bool {
+		return !n.entries[i].ID.Less(id)
+	})
+}
+
+// Stream is an append-only log of entries. A Stream is not safe for
+// concurrent use.
+type Stream struct {
+	index  *rax.Tree
+	length int
+
+	lastID       ID
+	maxDeletedID ID
+	entriesAdded uint64
+
+	groups *rax.Tree
+}
+
+func New() *Stream {
+	return &Stream{index: rax.New(), groups: rax.New()}
+}
+
+// Len returns the number of entries in the stream.
+func (s *Stream) Len() int {
+	return s.length
+}
+
+// LastID returns the ID of the last entry ever added, which may have been
+// deleted since.
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// MaxDeletedID returns the largest ID deleted with Delete.
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+// EntriesAdded returns the number of entries ever added to the stream.
+func (s *Stream) EntriesAdded() uint64 {
+	return s.entriesAdded
+}
+
+// Nodes returns the number of nodes the entries are grouped into.
+func (s *Stream) Nodes() int {
+	return s.index.Len()
+}
+
+// IndexNodes returns the number of nodes of the radix tree indexing the
+// entry nodes.
+func (s *Stream) IndexNodes() int {
+	return s.index.Nodes()
+}
+
+// Add appends an entry. It reports false, leaving the stream unchanged, if
+// id is not greater than LastID.
+func (s *Stream) Add(id ID, fields [][]byte) bool {
+	if s.entriesAdded > 0 && !s.lastID.Less(id) {
+		return false
+	}
+
+	e := Entry{ID: id, Fields: fields}
+	if _, v, ok := s.index.Max(); ok && len(v.(*node).entries) < MaxNodeEntries {
+		n := v.(*node)
+		n.entries = append(n.entries, e)
+	} else {
+		s.index.Insert(id.key(), &node{entries: []Entry{e}})
+	}
+
+	s.lastID = id
+	s.entriesAdded++
+	s.length++
+
+	return true
+}
+
+// Get returns the entry with the given ID.
+func (s *Stream) Get(id ID) (Entry, bool) {
+	_, v, ok := s.index.Floor(id.key())
+	if !ok {
+		return Entry{}, false
+	}
+
+	n := v.(*node)
+	i := n.search(id)
+	if i == len(n.entries) || n.entries[i].ID != id {
+		return Entry{}, false
+	}
+
+	return n.entries[i], true
+}
+
+// Delete removes the entry with the given ID, reporting whether it existed.
+func (s *Stream) Delete(id ID) bool {
+	key, v, ok := s.index.Floor(id.key())
+	if !ok {
+		return false
+	}
+
+	n := v.(*node)
+	i := n.search(id)
+	if i == len(n.entries) || n.entries[i].ID != id {
+		return false
+	}
+
+	copy(n.entries[i:], n.entries[i+1:])
+	n.entries[len(n.entries)-1] = Entry{}
+	n.entries = n.entries[:len(n.entries)-1]
+	if len(n.entries) == 0 {
+		s.index.Delete(key)
+	}
+
+	s.length--
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+
+	return true
+}
+
+// First returns the entry with the smallest ID.
+func (s *Stream) First() (Entry, bool) {
+	_, v, ok := s.index.Min()
+	if !ok {
+		return Entry{}, false
+	}
+
+	return v.(*node).entries[0], true
+}
+
+// Last returns the entry with the largest ID.
+func (s *Stream) Last() (Entry, bool) {
+	_, v, ok := s.index.Max()
+	if !ok {
+		return Entry{}, false
+	}
+
+	n := v.(*node)
+	return n.entries[len(n.entries)-1], true
+}
+
+// Range calls fn for each entry with an ID between start and end, both
+// inclusive, in ascending order or descending if reverse is set, until fn
+// returns false. The stream must not be modified during the iteration.
+func (s *Stream) Range(start, end ID, reverse bool, fn func(e Entry) bool) {
+	if end.Less(start) {
+		return
+	}
+
+	if reverse {
+		s.rangeReverse(start, end, fn)
+		return
+	}
+
+	key, v, ok := s.index.Floor(start.key())
+	if !ok {
+		key, v, ok = s.index.Min()
+	}
+
+	for ; ok; key, v, ok = s.index.Next(key) {
+		n := v.(*node)
+		for _, e := range n.entries[n.search(start):] {
+			if end.Less(e.ID) || !fn(e) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Stream) rangeReverse(start, end ID, fn func(e Entry) bool) {
+	key, v, ok := s.index.Floor(end.key())
+	for ; ok; key, v, ok = s.index.Prev(key) {
+		n := v.(*node)
+
+		// the last entry with an ID less than or equal to end
+		i := sort.Search(len(n.entries), func(i int) bool {
+			return end.Less(n.entries[i].ID)
+		}) - 1
+
+		for ; i >= 0; i-- {
+			e := n.entries[i]
+			if e.ID.Less(start) || !fn(e) {
+				return
+			}
+		}
+	}
+}
+
+// TrimMaxLen removes the oldest entries until at most maxLen remain and
+// returns how many were removed. See trim for approx and limit.
+func (s *Stream) TrimMaxLen(maxLen int, approx bool, limit int) int {
+	return s.trim(approx, limit, func(_ Entry, length int) bool {
+		return length > maxLen
+	})
+}
+
+// TrimMinID removes the entries with an ID less than minID and returns how
+// many were removed. See trim for approx and limit.
+func (s *Stream) TrimMinID(minID ID, approx bool, limit int) int {
+	return s.trim(approx, limit, func(e Entry, _ int) bool {
+		return e.ID.Less(minID)
+	})
+}
+
+// trim removes entries from the head of the stream for as long as drop
+// reports true for the entry at the head, given the length of the stream
+// at that point. When approx is set only whole nodes are removed, which is
+// much cheaper but can leave some entries that should have been dropped,
+// and at most limit entries are removed unless limit is 0.
+func (s *Stream) trim(approx bool, limit int, drop func(e Entry, length int) bool) int {
+	removed := 0
+	for {
+		key, v, ok := s.index.Min()
+		if !ok {
+			return removed
+		}
+
+		n := v.(*node)
+		last := len(n.entries) - 1
+		if drop(n.entries[last], s.length-last) {
+			if approx && limit > 0 && removed+len(n.entries) > limit {
+				return removed
+			}
+
+			s.index.Delete(key)
+			s.length -= len(n.entries)
+			removed += len(n.entries)
+			continue
+		}
+
+		if approx {
+			return removed
+		}
+
+		i := 0
+		for drop(n.entries[i], s.length-i) {
+			i++
+		}
+
+		n.entries = append(n.entries[:0], n.entries[i:]...)
+		s.length -= i
+		removed += i
+
+		return removed
+	}
+}
+
+// Clone returns a copy of the stream, including its consumer groups. Entry
+// fields are shared with the original, which is safe since they are never
+// modified in place.
+func (s *Stream) Clone() *Stream {
+	clone := &Stream{
+		index:        rax.New(),
+		length:       s.length,
+		lastID:       s.lastID,
+		maxDeletedID: s.maxDeletedID,
+		entriesAdded: s.entriesAdded,
+		groups:       rax.New(),
+	}
+
+	for key, v, ok := s.index.Min(); ok; key, v, ok = s.index.Next(key) {
+		entries := append([]Entry(nil), v.(*node).entries...)
+		clone.index.Insert(key, &node{entries: entries})
+	}
+
+	for key, v, ok := s.groups.Min(); ok; key, v, ok = s.groups.Next(key) {
+		clone.groups.Insert(key, v.(*Group).clone())
+	}
+
+	return clone
+}
+
+// CreateGroup adds a consumer group that delivers entries after lastID. It
+// reports false, leaving the existing group untouched, if there already is a
+// group with that name. See Group for entriesRead.
+func (s *Stream) CreateGroup(name string, lastID ID, entriesRead int64) (*Group, bool) {
+	if g := s.Group(name); g != nil {
+		return g, false
+	}
+
+	g := newGroup(name, lastID, entriesRead)
+	s.groups.Insert([]byte(name), g)
+
+	return g, true
+}
+
+// Group returns the consumer group with the given name, or nil if there is
+// none.
+func (s *Stream) Group(name string) *Group {
+	v, ok := s.groups.Get([]byte(name))
+	if !ok {
+		return nil
+	}
+
+	return v.(*Group)
+}
+
+// DeleteGroup removes a consumer group, reporting whether it existed.
+func (s *Stream) DeleteGroup(name string) bool {
+	return s.groups.Delete([]byte(name))
+}
+
+// Groups returns the number of consumer groups.
+func (s *Stream) Groups() int {
+	return s.groups.Len()
+}
+
+// RangeGroups calls fn for each consumer group in order of name until fn
+// returns false.
+func (s *Stream) RangeGroups(fn func(g *Group) bool) {
+	for key, v, ok := s.groups.Min(); ok; key, v, ok = s.groups.Next(key) {
+		if !fn(v.(*Group)) {
+			return
+		}
+	}
+}
+
+// EntriesUpTo returns the number of entries ever added with an ID less than
+// or equal to id, which is how many entries a consumer group whose last ID
+// is id has read. ok is false if it can't be told because entries past id
+// were deleted.
+func (s *Stream) EntriesUpTo(id ID) (n int64, ok bool) {
+	added := int64(s.entriesAdded)
+	switch {
+	case added == 0:
+		return 0, true
+	case id == s.lastID:
+		return added, true
+	case s.lastID.Less(id):
+		return added, true
+	case id.Less(s.maxDeletedID):
+		return 0, false
+	}
+
+	first, ok := s.First()
+	if !ok {
+		return 0, false
+	}
+
+	// entries before the first one were trimmed, and are assumed to have
+	// been read
+	if id.Less(first.ID) {
+		return added - int64(s.length), true
+	}
+
+	start, _ := id.Next()
+	after := int64(0)
+	s.Range(start, MaxID, false, func(Entry) bool {
+		after++
+		return true
+	})
+
+	return added - after, true
+}
@@ -0,0 +1,213 @@
+package stream
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(s *Stream, start, end ID, reverse bool) []ID {
+	var got []ID
+	s.Range(start, end, reverse, func(e Entry) bool {
+		got = append(got, e.ID)
+		return true
+	})
+	return got
+}
+
+// filled returns a stream holding entries 1-0 through n-0.
+func filled(n int) *Stream {
+	s := New()
+	for i := 1; i <= n; i++ {
+		s.Add(ID{Ms: uint64(i)}, [][]byte{[]byte("f"), []byte("v")})
+	}
+	return s
+}
+
+func TestParseID(t *testing.T) {
+	id, seqGiven, ok := ParseID("5-3", 0)
+	assert.True(t, ok)
+	assert.True(t, seqGiven)
+	assert.Equal(t, ID{5, 3}, id)
+
+	id, seqGiven, ok = ParseID("5", math.MaxUint64)
+	assert.True(t, ok)
+	assert.False(t, seqGiven)
+	assert.Equal(t, ID{5, math.MaxUint64}, id)
+
+	for _, s := range []string{"", "-", "5-", "-3", "a-1", "1-2-3", "18446744073709551616"} {
+		_, _, ok = ParseID(s, 0)
+		assert.False(t, ok, s)
+	}
+
+	assert.Equal(t, "5-3", ID{5, 3}.String())
+}
+
+func TestIDNextPrev(t *testing.T) {
+	next, ok := ID{1, math.MaxUint64}.Next()
+	assert.True(t, ok)
+	assert.Equal(t, ID{2, 0}, next)
+	_, ok = MaxID.Next()
+	assert.False(t, ok)
+
+	prev, ok := ID{2, 0}.Prev()
+	assert.True(t, ok)
+	assert.Equal(t, ID{1, math.MaxUint64}, prev)
+	_, ok = MinID.Prev()
+	assert.False(t, ok)
+}
+
+func TestAddRejectsNonIncreasingIDs(t *testing.T) {
+	s := New()
+	assert.True(t, s.Add(ID{0, 1}, nil))
+	assert.False(t, s.Add(ID{0, 1}, nil))
+	assert.False(t, s.Add(ID{0, 0}, nil))
+	assert.True(t, s.Add(ID{5, 0}, nil))
+
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, ID{5, 0}, s.LastID())
+	assert.Equal(t, uint64(2), s.EntriesAdded())
+}
+
+func TestAddGroupsEntriesIntoNodes(t *testing.T) {
+	s := filled(MaxNodeEntries*2 + 1)
+	assert.Equal(t, 3, s.Nodes())
+
+	first, _ := s.First()
+	last, _ := s.Last()
+	assert.Equal(t, ID{Ms: 1}, first.ID)
+	assert.Equal(t, ID{Ms: MaxNodeEntries*2 + 1}, last.ID)
+}
+
+func TestRange(t *testing.T) {
+	s := filled(250)
+
+	got := ids(s, ID{Ms: 99}, ID{Ms: 102}, false)
+	assert.Equal(t, []ID{{Ms: 99}, {Ms: 100}, {Ms: 101}, {Ms: 102}}, got)
+
+	got = ids(s, ID{Ms: 99}, ID{Ms: 102}, true)
+	assert.Equal(t, []ID{{Ms: 102}, {Ms: 101}, {Ms: 100}, {Ms: 99}}, got)
+
+	assert.Len(t, ids(s, MinID, MaxID, false), 250)
+	assert.Len(t, ids(s, MinID, MaxID, true), 250)
+	assert.Empty(t, ids(s, ID{Ms: 5}, ID{Ms: 4}, false))
+	assert.Empty(t, ids(s, ID{Ms: 300}, MaxID, false))
+
+	// bounds falling between entries
+	got = ids(s, ID{100, 1}, ID{101, 1}, false)
+	assert.Equal(t, []ID{{Ms: 101}}, got)
+
+	var n int
+	s.Range(MinID, MaxID, false, func(Entry) bool {
+		n++
+		return n < 3
+	})
+	assert.Equal(t, 3, n)
+}
+
+func TestDelete(t *testing.T) {
+	s := filled(150)
+
+	assert.True(t, s.Delete(ID{Ms: 120}))
+	assert.False(t, s.Delete(ID{Ms: 120}))
+	assert.False(t, s.Delete(ID{Ms: 500}))
+	assert.Equal(t, 149, s.Len())
+	assert.Equal(t, ID{Ms: 120}, s.MaxDeletedID())
+
+	_, ok := s.Get(ID{Ms: 120})
+	assert.False(t, ok)
+	e, ok := s.Get(ID{Ms: 121})
+	assert.True(t, ok)
+	assert.Equal(t, ID{Ms: 121}, e.ID)
+
+	// emptying a node removes it
+	for i := 1; i <= MaxNodeEntries; i++ {
+		require.True(t, s.Delete(ID{Ms: uint64(i)}))
+	}
+	assert.Equal(t, 1, s.Nodes())
+	first, _ := s.First()
+	assert.Equal(t, ID{Ms: 101}, first.ID)
+
+	// the last ID survives the stream being emptied
+	for _, id := range ids(s, MinID, MaxID, false) {
+		s.Delete(id)
+	}
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, ID{Ms: 150}, s.LastID())
+	_, ok = s.First()
+	assert.False(t, ok)
+	assert.False(t, s.Add(ID{Ms: 150}, nil))
+}
+
+func TestTrimMaxLen(t *testing.T) {
+	s := filled(250)
+	assert.Equal(t, 240, s.TrimMaxLen(10, false, 0))
+	assert.Equal(t, 10, s.Len())
+	first, _ := s.First()
+	assert.Equal(t, ID{Ms: 241}, first.ID)
+	assert.Equal(t, 0, s.TrimMaxLen(10, false, 0))
+
+	// approximate trimming only removes whole nodes
+	s = filled(250)
+	assert.Equal(t, 200, s.TrimMaxLen(10, true, 0))
+	assert.Equal(t, 50, s.Len())
+	assert.Equal(t, 0, s.TrimMaxLen(10, true, 0))
+
+	s = filled(250)
+	assert.Equal(t, 100, s.TrimMaxLen(10, true, 150))
+	assert.Equal(t, 0, s.TrimMaxLen(10, true, 50))
+}
+
+func TestTrimMinID(t *testing.T) {
+	s := filled(250)
+	assert.Equal(t, 149, s.TrimMinID(ID{Ms: 150}, false, 0))
+	first, _ := s.First()
+	assert.Equal(t, ID{Ms: 150}, first.ID)
+
+	s = filled(250)
+	assert.Equal(t, 100, s.TrimMinID(ID{Ms: 150}, true, 0))
+	first, _ = s.First()
+	assert.Equal(t, ID{Ms: 101}, first.ID)
+}
+
+func TestRangeMatchesModel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := New()
+	var model []ID
+
+	var ms uint64
+	for i := 0; i < 2000; i++ {
+		ms += uint64(r.Intn(3))
+		id := ID{Ms: ms, Seq: uint64(i)}
+		require.True(t, s.Add(id, nil))
+		model = append(model, id)
+
+		if r.Intn(3) == 0 {
+			j := r.Intn(len(model))
+			require.True(t, s.Delete(model[j]))
+			model = append(model[:j], model[j+1:]...)
+		}
+	}
+
+	require.Equal(t, len(model), s.Len())
+	for i := 0; i < 200; i++ {
+		a, b := ID{Ms: uint64(r.Intn(int(ms) + 2))}, ID{Ms: uint64(r.Intn(int(ms) + 2)), Seq: math.MaxUint64}
+		var want []ID
+		for _, id := range model {
+			if !id.Less(a) && !b.Less(id) {
+				want = append(want, id)
+			}
+		}
+
+		assert.Equal(t, want, ids(s, a, b, false))
+
+		var rev []ID
+		for j := len(want) - 1; j >= 0; j-- {
+			rev = append(rev, want[j])
+		}
+		assert.Equal(t, rev, ids(s, a, b, true))
+	}
+}
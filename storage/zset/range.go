@@ -0,0 +1,93 @@
+package zset
+
+import "strings"
+
+// ScoreRange is an interval of scores. Either end may be infinite.
+type ScoreRange struct {
+	Min, Max float64
+
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) gteMin(score float64) bool {
+	if r.MinExclusive {
+		return score > r.Min
+	}
+
+	return score >= r.Min
+}
+
+func (r ScoreRange) lteMax(score float64) bool {
+	if r.MaxExclusive {
+		return score < r.Max
+	}
+
+	return score <= r.Max
+}
+
+func (r ScoreRange) contains(score float64) bool {
+	return r.gteMin(score) && r.lteMax(score)
+}
+
+func (r ScoreRange) empty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinExclusive || r.MaxExclusive))
+}
+
+// LexBound is one end of a LexRange.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+
+	// Infinite is -1 for a bound below every string and 1 for a bound above
+	// every string, in which case Value is ignored.
+	Infinite int
+}
+
+// compare compares s to the bound.
+func (b LexBound) compare(s string) int {
+	if b.Infinite != 0 {
+		return -b.Infinite
+	}
+
+	return strings.Compare(s, b.Value)
+}
+
+// LexRange is an interval of members compared bytewise.
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func (r LexRange) gteMin(member string) bool {
+	c := r.Min.compare(member)
+	if r.Min.Exclusive {
+		return c > 0
+	}
+
+	return c >= 0
+}
+
+func (r LexRange) lteMax(member string) bool {
+	c := r.Max.compare(member)
+	if r.Max.Exclusive {
+		return c < 0
+	}
+
+	return c <= 0
+}
+
+func (r LexRange) contains(member string) bool {
+	return r.gteMin(member) && r.lteMax(member)
+}
+
+func (r LexRange) empty() bool {
+	if r.Min.Infinite == 1 || r.Max.Infinite == -1 {
+		return true
+	}
+
+	if r.Min.Infinite != 0 || r.Max.Infinite != 0 {
+		return false
+	}
+
+	c := strings.Compare(r.Min.Value, r.Max.Value)
+	return c > 0 || (c == 0 && (r.Min.Exclusive || r.Max.Exclusive))
+}
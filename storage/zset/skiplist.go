@@ -0,0 +1,250 @@
+package zset
+
+import "math/rand"
+
+const (
+	// maxLevel is enough for 2^64 elements with p = 1/4.
+	maxLevel = 32
+
+	// levelProbability is the chance of a node being promoted to the next
+	// level, scaled to 16 bits.
+	levelProbability = 0xFFFF / 4
+)
+
+type level struct {
+	forward *node
+	// span is the number of nodes between this node and forward, used to
+	// compute ranks.
+	span int
+}
+
+type node struct {
+	member string
+	score  float64
+
+	backward *node
+	levels   []level
+}
+
+// before reports whether n sorts before the element (score, member).
+func (n *node) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// skiplist orders elements by score, then by member, and can find the
+// element with a given rank in O(log n), as in Redis's zskiplist.
+type skiplist struct {
+	header, tail *node
+
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &node{levels: make([]level, maxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && rand.Uint32()&0xFFFF < levelProbability {
+		lvl++
+	}
+
+	return lvl
+}
+
+// insert adds an element, which must not already be in the skiplist.
+func (sl *skiplist) insert(score float64, member string) *node {
+	var (
+		update [maxLevel]*node
+		rank   [maxLevel]int
+	)
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i != sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	lvl := randomLevel()
+	if lvl > sl.level {
+		for i := sl.level; i < lvl; i++ {
+			update[i] = sl.header
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = lvl
+	}
+
+	x = &node{member: member, score: score, levels: make([]level, lvl)}
+	for i := 0; i < lvl; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+
+	// the untouched levels now span one more node
+	for i := lvl; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+
+	return x
+}
+
+// unlink removes x given the nodes preceding it on every level.
+func (sl *skiplist) unlink(x *node, update *[maxLevel]*node) {
+	for i := 0; i < sl.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+
+	for sl.level > 1 && sl.header.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// delete removes the element (score, member), reporting whether it was
+// found.
+func (sl *skiplist) delete(score float64, member string) bool {
+	var update [maxLevel]*node
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	sl.unlink(x, &update)
+	return true
+}
+
+// updateScore changes the score of an element, moving it only if its
+// position changes.
+func (sl *skiplist) updateScore(score float64, member string, newScore float64) {
+	var update [maxLevel]*node
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	x = x.levels[0].forward
+
+	if (x.backward == nil || x.backward.score < newScore) &&
+		(x.levels[0].forward == nil || x.levels[0].forward.score > newScore) {
+		x.score = newScore
+		return
+	}
+
+	sl.unlink(x, &update)
+	sl.insert(newScore, member)
+}
+
+// rank returns the 1-based rank of the element (score, member), or 0 if it
+// is not in the skiplist.
+func (sl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil &&
+			(x.levels[i].forward.before(score, member) ||
+				(x.levels[i].forward.score == score && x.levels[i].forward.member == member)) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+
+		if x != sl.header && x.member == member {
+			return rank
+		}
+	}
+
+	return 0
+}
+
+// byRank returns the node with the given 1-based rank.
+func (sl *skiplist) byRank(rank int) *node {
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+
+		if traversed == rank {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// first returns the first node for which past returns false, given that
+// past is true for a prefix of the skiplist.
+func (sl *skiplist) first(past func(n *node) bool) *node {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && past(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+
+	return x.levels[0].forward
+}
+
+// last returns the last node for which within returns true, given that
+// within is true for a prefix of the skiplist.
+func (sl *skiplist) last(within func(n *node) bool) *node {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && within(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+
+	if x == sl.header {
+		return nil
+	}
+
+	return x
+}
@@ -0,0 +1,237 @@
+// Package zset implements the sorted set value type: a set of members
+// ordered by a floating point score, with ties broken by comparing members.
+//
+// Like Redis, a sorted set is stored as a skiplist, which keeps the members
+// in order and can look them up by rank, alongside a hash table mapping
+// members to scores.
+package zset
+
+import (
+	"github.com/scnewma/godb/storage/dict"
+)
+
+const EncodingSkiplist = "skiplist"
+
+// ZSet is a sorted set. A ZSet is not safe for concurrent use.
+type ZSet struct {
+	scores *dict.Dict
+	zsl    *skiplist
+}
+
+func New() *ZSet {
+	return &ZSet{scores: dict.New(), zsl: newSkiplist()}
+}
+
+// Encoding returns the name of the representation used for the sorted set.
+func (z *ZSet) Encoding() string {
+	return EncodingSkiplist
+}
+
+// Len returns the number of members in the sorted set.
+func (z *ZSet) Len() int {
+	return z.zsl.length
+}
+
+// Score returns the score of member.
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return 0, false
+	}
+
+	return score.(float64), true
+}
+
+// Add sets the score of member, reporting whether the member is new.
+func (z *ZSet) Add(member string, score float64) bool {
+	current, ok := z.Score(member)
+	if !ok {
+		z.scores.Set(member, score)
+		z.zsl.insert(score, member)
+		return true
+	}
+
+	if current != score {
+		z.scores.Set(member, score)
+		z.zsl.updateScore(current, member, score)
+	}
+
+	return false
+}
+
+// Remove removes member, reporting whether it was present.
+func (z *ZSet) Remove(member string) bool {
+	score, ok := z.scores.Delete(member)
+	if !ok {
+		return false
+	}
+
+	z.zsl.delete(score.(float64), member)
+	return true
+}
+
+// Rank returns the 0-based position of member in ascending order, or in
+// descending order if reverse is set.
+func (z *ZSet) Rank(member string, reverse bool) (int, bool) {
+	score, ok := z.Score(member)
+	if !ok {
+		return 0, false
+	}
+
+	rank := z.zsl.rank(score, member)
+	if reverse {
+		return z.zsl.length - rank, true
+	}
+
+	return rank - 1, true
+}
+
+// Range calls fn for each member in ascending order until fn returns false.
+// The sorted set must not be modified during the iteration.
+func (z *ZSet) Range(fn func(member string, score float64) bool) {
+	for x := z.zsl.header.levels[0].forward; x != nil; x = x.levels[0].forward {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+// Clone returns a copy of the sorted set.
+func (z *ZSet) Clone() *ZSet {
+	clone := New()
+	z.Range(func(member string, score float64) bool {
+		clone.Add(member, score)
+		return true
+	})
+
+	return clone
+}
+
+// RangeByRank calls fn for each member from rank start to rank stop, both
+// inclusive and 0-based, until fn returns false. Ranks count from the
+// highest score when reverse is set.
+func (z *ZSet) RangeByRank(start, stop int, reverse bool, fn func(member string, score float64) bool) {
+	if start < 0 || start > stop || start >= z.zsl.length {
+		return
+	}
+
+	rank := start + 1
+	if reverse {
+		rank = z.zsl.length - start
+	}
+
+	x := z.zsl.byRank(rank)
+	for i := start; i <= stop && x != nil; i++ {
+		if !fn(x.member, x.score) {
+			return
+		}
+		x = z.step(x, reverse)
+	}
+}
+
+// RangeByScore calls fn for each member with a score in r, in ascending
+// order or descending if reverse is set, until fn returns false.
+func (z *ZSet) RangeByScore(r ScoreRange, reverse bool, fn func(member string, score float64) bool) {
+	if r.empty() {
+		return
+	}
+
+	var x *node
+	if reverse {
+		x = z.zsl.last(func(n *node) bool { return r.lteMax(n.score) })
+	} else {
+		x = z.zsl.first(func(n *node) bool { return !r.gteMin(n.score) })
+	}
+
+	for ; x != nil && r.contains(x.score); x = z.step(x, reverse) {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+// CountByScore returns the number of members with a score in r.
+func (z *ZSet) CountByScore(r ScoreRange) int {
+	if r.empty() {
+		return 0
+	}
+
+	first := z.zsl.first(func(n *node) bool { return !r.gteMin(n.score) })
+	if first == nil || !r.lteMax(first.score) {
+		return 0
+	}
+	last := z.zsl.last(func(n *node) bool { return r.lteMax(n.score) })
+
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+// RangeByLex calls fn for each member in r, in ascending order or descending
+// if reverse is set, until fn returns false. Lexicographical ranges are only
+// meaningful when every member has the same score.
+func (z *ZSet) RangeByLex(r LexRange, reverse bool, fn func(member string, score float64) bool) {
+	if r.empty() {
+		return
+	}
+
+	var x *node
+	if reverse {
+		x = z.zsl.last(func(n *node) bool { return r.lteMax(n.member) })
+	} else {
+		x = z.zsl.first(func(n *node) bool { return !r.gteMin(n.member) })
+	}
+
+	for ; x != nil && r.contains(x.member); x = z.step(x, reverse) {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+// CountByLex returns the number of members in r.
+func (z *ZSet) CountByLex(r LexRange) int {
+	if r.empty() {
+		return 0
+	}
+
+	first := z.zsl.first(func(n *node) bool { return !r.gteMin(n.member) })
+	if first == nil || !r.lteMax(first.member) {
+		return 0
+	}
+	last := z.zsl.last(func(n *node) bool { return r.lteMax(n.member) })
+
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+// Pop removes and returns the member with the lowest score, or the highest
+// if max is set. ok is false if the sorted set is empty.
+func (z *ZSet) Pop(max bool) (member string, score float64, ok bool) {
+	x := z.zsl.header.levels[0].forward
+	if max {
+		x = z.zsl.tail
+	}
+
+	if x == nil {
+		return "", 0, false
+	}
+
+	member, score = x.member, x.score
+	z.Remove(member)
+
+	return member, score, true
+}
+
+// Scan calls fn for a batch of members starting at cursor and returns the
+// cursor to continue from, which is 0 once every member has been visited.
+func (z *ZSet) Scan(cursor uint64, fn func(member string, score float64)) uint64 {
+	return z.scores.Scan(cursor, func(key string, val interface{}) {
+		fn(key, val.(float64))
+	})
+}
+
+func (z *ZSet) step(x *node, reverse bool) *node {
+	if reverse {
+		return x.backward
+	}
+
+	return x.levels[0].forward
+}
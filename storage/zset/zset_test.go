@@ -0,0 +1,227 @@
+package zset
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type element struct {
+	member string
+	score  float64
+}
+
+func collect(z *ZSet) []element {
+	var elements []element
+	z.Range(func(member string, score float64) bool {
+		elements = append(elements, element{member, score})
+		return true
+	})
+	return elements
+}
+
+func collectFn(elements *[]string) func(string, float64) bool {
+	return func(member string, _ float64) bool {
+		*elements = append(*elements, member)
+		return true
+	}
+}
+
+func TestAddOrdersByScoreThenMember(t *testing.T) {
+	z := New()
+
+	assert.True(t, z.Add("b", 2))
+	assert.True(t, z.Add("a", 2))
+	assert.True(t, z.Add("c", 1))
+	assert.False(t, z.Add("c", 3))
+
+	assert.Equal(t, []element{{"a", 2}, {"b", 2}, {"c", 3}}, collect(z))
+
+	score, ok := z.Score("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, score)
+}
+
+func TestRank(t *testing.T) {
+	z := New()
+	z.Add("a", 1)
+	z.Add("b", 2)
+	z.Add("c", 3)
+
+	rank, ok := z.Rank("a", false)
+	assert.True(t, ok)
+	assert.Equal(t, 0, rank)
+
+	rank, _ = z.Rank("a", true)
+	assert.Equal(t, 2, rank)
+
+	_, ok = z.Rank("missing", false)
+	assert.False(t, ok)
+}
+
+func TestRemoveAndPop(t *testing.T) {
+	z := New()
+	z.Add("a", 1)
+	z.Add("b", 2)
+	z.Add("c", 3)
+
+	assert.True(t, z.Remove("b"))
+	assert.False(t, z.Remove("b"))
+
+	member, score, ok := z.Pop(true)
+	assert.True(t, ok)
+	assert.Equal(t, "c", member)
+	assert.Equal(t, 3.0, score)
+
+	member, _, _ = z.Pop(false)
+	assert.Equal(t, "a", member)
+
+	_, _, ok = z.Pop(false)
+	assert.False(t, ok)
+	assert.Equal(t, 0, z.Len())
+}
+
+func TestClone(t *testing.T) {
+	z := New()
+	z.Add("a", 1)
+	z.Add("b", 2)
+
+	clone := z.Clone()
+	clone.Add("c", 3)
+	clone.Remove("a")
+	assert.Equal(t, []element{{"a", 1}, {"b", 2}}, collect(z))
+	assert.Equal(t, []element{{"b", 2}, {"c", 3}}, collect(clone))
+}
+
+func TestRangeByScore(t *testing.T) {
+	z := New()
+	for i := 1; i <= 5; i++ {
+		z.Add(strconv.Itoa(i), float64(i))
+	}
+
+	var got []string
+	z.RangeByScore(ScoreRange{Min: 2, Max: 4}, false, collectFn(&got))
+	assert.Equal(t, []string{"2", "3", "4"}, got)
+
+	got = nil
+	z.RangeByScore(ScoreRange{Min: 2, Max: 4, MinExclusive: true}, true, collectFn(&got))
+	assert.Equal(t, []string{"4", "3"}, got)
+
+	got = nil
+	z.RangeByScore(ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, false, collectFn(&got))
+	assert.Len(t, got, 5)
+
+	assert.Equal(t, 2, z.CountByScore(ScoreRange{Min: 2, Max: 4, MaxExclusive: true}))
+	assert.Equal(t, 0, z.CountByScore(ScoreRange{Min: 6, Max: 10}))
+	assert.Equal(t, 0, z.CountByScore(ScoreRange{Min: 3, Max: 3, MinExclusive: true}))
+}
+
+func TestRangeByLex(t *testing.T) {
+	z := New()
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		z.Add(member, 0)
+	}
+
+	var got []string
+	z.RangeByLex(LexRange{Min: LexBound{Value: "b"}, Max: LexBound{Value: "d", Exclusive: true}}, false, collectFn(&got))
+	assert.Equal(t, []string{"b", "c"}, got)
+
+	got = nil
+	z.RangeByLex(LexRange{Min: LexBound{Infinite: -1}, Max: LexBound{Value: "b"}}, true, collectFn(&got))
+	assert.Equal(t, []string{"b", "a"}, got)
+
+	assert.Equal(t, 5, z.CountByLex(LexRange{Min: LexBound{Infinite: -1}, Max: LexBound{Infinite: 1}}))
+	assert.Equal(t, 0, z.CountByLex(LexRange{Min: LexBound{Infinite: 1}, Max: LexBound{Infinite: -1}}))
+	assert.Equal(t, 0, z.CountByLex(LexRange{Min: LexBound{Value: "c"}, Max: LexBound{Value: "b"}}))
+}
+
+func TestMatchesModel(t *testing.T) {
+	z := New()
+	model := make(map[string]float64)
+
+	for i := 0; i < 5000; i++ {
+		member := strconv.Itoa(rand.Intn(500))
+		switch rand.Intn(4) {
+		case 0:
+			_, ok := model[member]
+			assert.Equal(t, ok, z.Remove(member))
+			delete(model, member)
+		default:
+			score := float64(rand.Intn(100))
+			_, ok := model[member]
+			assert.Equal(t, !ok, z.Add(member, score))
+			model[member] = score
+		}
+	}
+
+	want := make([]element, 0, len(model))
+	for member, score := range model {
+		want = append(want, element{member, score})
+	}
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].score != want[j].score {
+			return want[i].score < want[j].score
+		}
+		return want[i].member < want[j].member
+	})
+
+	require.Equal(t, want, collect(z))
+	require.Equal(t, len(want), z.Len())
+
+	for i, e := range want {
+		rank, ok := z.Rank(e.member, false)
+		require.True(t, ok)
+		require.Equal(t, i, rank)
+	}
+
+	var got []element
+	z.RangeByRank(10, 20, false, func(member string, score float64) bool {
+		got = append(got, element{member, score})
+		return true
+	})
+	assert.Equal(t, want[10:21], got)
+
+	got = nil
+	z.RangeByRank(0, 4, true, func(member string, score float64) bool {
+		got = append(got, element{member, score})
+		return true
+	})
+	for i := range got {
+		assert.Equal(t, want[len(want)-1-i], got[i])
+	}
+
+	r := ScoreRange{Min: 20, Max: 60, MinExclusive: true}
+	count := 0
+	for _, e := range want {
+		if r.contains(e.score) {
+			count++
+		}
+	}
+	assert.Equal(t, count, z.CountByScore(r))
+}
+
+func TestScan(t *testing.T) {
+	z := New()
+	for i := 0; i < 1000; i++ {
+		z.Add(strconv.Itoa(i), float64(i))
+	}
+
+	seen := make(map[string]float64)
+	cursor := uint64(0)
+	for {
+		cursor = z.Scan(cursor, func(member string, score float64) {
+			seen[member] = score
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+
+	require.Len(t, seen, 1000)
+	assert.Equal(t, 42.0, seen["42"])
+}